@@ -0,0 +1,115 @@
+//
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"cloud.google.com/go/spanner"
+	adminapi "cloud.google.com/go/spanner/admin/database/apiv1"
+	"github.com/cloudspannerecosystem/spanner-truncate/truncate"
+	"google.golang.org/api/option"
+)
+
+// clientPool creates Cloud Spanner clients on demand and reuses them across runs,
+// so that processing many databases doesn't pay the startup cost of clients for each run.
+type clientPool struct {
+	projectID  string
+	instanceID string
+	config     spanner.ClientConfig
+	clientOpts []option.ClientOption
+
+	mu      sync.Mutex
+	admin   *adminapi.DatabaseAdminClient
+	clients map[string]*spanner.Client // keyed by database ID
+	closed  bool
+}
+
+func newClientPool(projectID, instanceID string, poolConfig spanner.SessionPoolConfig, clientOpts []option.ClientOption) *clientPool {
+	return &clientPool{
+		projectID:  projectID,
+		instanceID: instanceID,
+		config:     spanner.ClientConfig{SessionPoolConfig: poolConfig},
+		clientOpts: clientOpts,
+		clients:    map[string]*spanner.Client{},
+	}
+}
+
+// client returns the client for the database, creating it on the first call.
+func (p *clientPool) client(ctx context.Context, databaseID string) (*spanner.Client, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		return nil, fmt.Errorf("client pool is already closed")
+	}
+
+	if client, ok := p.clients[databaseID]; ok {
+		return client, nil
+	}
+	database := fmt.Sprintf("projects/%s/instances/%s/databases/%s", p.projectID, p.instanceID, databaseID)
+	client, err := spanner.NewClientWithConfig(ctx, database, p.config, append(p.clientOpts, truncate.ClientOptions()...)...)
+	if err != nil {
+		return nil, err
+	}
+	p.clients[databaseID] = client
+	return client, nil
+}
+
+// adminClient returns the database admin client shared by all databases, creating it on the first call.
+func (p *clientPool) adminClient(ctx context.Context) (*adminapi.DatabaseAdminClient, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		return nil, fmt.Errorf("client pool is already closed")
+	}
+
+	if p.admin == nil {
+		admin, err := adminapi.NewDatabaseAdminClient(ctx, p.clientOpts...)
+		if err != nil {
+			return nil, err
+		}
+		p.admin = admin
+	}
+	return p.admin, nil
+}
+
+// close closes all clients created by the pool. Clients can't be obtained after close.
+func (p *clientPool) close() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for _, client := range p.clients {
+		client.Close()
+	}
+	if p.admin != nil {
+		p.admin.Close()
+	}
+	p.clients = nil
+	p.admin = nil
+	p.closed = true
+}
+
+// sessionPoolConfig returns the session pool configuration of the Cloud Spanner clients created by the command.
+func sessionPoolConfig(opts options) spanner.SessionPoolConfig {
+	config := spanner.DefaultSessionPoolConfig
+	config.MinOpened = opts.MinSessions
+	config.MaxOpened = opts.MaxSessions
+	config.WriteSessions = opts.WriteSessions
+	return config
+}
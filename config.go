@@ -0,0 +1,154 @@
+//
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cloudspannerecosystem/spanner-truncate/truncate"
+)
+
+// config is the content of the config file specified with --config.
+type config struct {
+	// Per-table settings keyed by table name.
+	Tables map[string]*tableConfig `json:"tables"`
+}
+
+// tableConfig is settings for a specific table.
+type tableConfig struct {
+	// Statements executed before deleting rows from the table.
+	PreSQL []string `json:"pre_sql"`
+
+	// Statements executed after deleting rows from the table.
+	PostSQL []string `json:"post_sql"`
+
+	// Column holding tenant IDs, by which rows of the tenant given with --tenant-id are deleted.
+	TenantColumn string `json:"tenant_column"`
+
+	// TIMESTAMP column deciding the age of rows with --older-than, instead of the commit timestamp column.
+	TimestampColumn string `json:"timestamp_column"`
+
+	// Age of rows deleted from the table with --older-than, e.g. "90d", instead of the age given with the flag.
+	OlderThan string `json:"older_than"`
+
+	// SQL expressions which columns are set to with --scrub, keyed by the column name, e.g. {"Email": "NULL"}.
+	Scrub map[string]string `json:"scrub"`
+}
+
+// loadConfig reads and parses the config file.
+func loadConfig(path string) (*config, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var c config
+	if err := json.Unmarshal(b, &c); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %v", path, err)
+	}
+	return &c, nil
+}
+
+// truncateOptions converts the config to options for the truncate package.
+func (c *config) truncateOptions() []truncate.Option {
+	var opts []truncate.Option
+	for name, t := range c.Tables {
+		if len(t.PreSQL) > 0 || len(t.PostSQL) > 0 {
+			opts = append(opts, truncate.WithHooks(name, t.PreSQL, t.PostSQL))
+		}
+	}
+	return opts
+}
+
+// tenantColumns returns the tenant columns keyed by table name.
+func (c *config) tenantColumns() map[string]string {
+	columns := map[string]string{}
+	for name, t := range c.Tables {
+		if t.TenantColumn != "" {
+			columns[name] = t.TenantColumn
+		}
+	}
+	return columns
+}
+
+// scrubOptions returns the options scrubbing columns of tables with --scrub.
+func (c *config) scrubOptions() []truncate.Option {
+	var opts []truncate.Option
+	for name, t := range c.Tables {
+		if len(t.Scrub) > 0 {
+			opts = append(opts, truncate.WithScrub(name, t.Scrub))
+		}
+	}
+	return opts
+}
+
+// ageOptions returns the options overriding the timestamp columns and the ages of tables with --older-than.
+func (c *config) ageOptions() ([]truncate.Option, error) {
+	var opts []truncate.Option
+	for name, t := range c.Tables {
+		if t.TimestampColumn == "" && t.OlderThan == "" {
+			continue
+		}
+		var age time.Duration
+		if t.OlderThan != "" {
+			var err error
+			if age, err = parseAge(t.OlderThan); err != nil {
+				return nil, fmt.Errorf("invalid older_than of %s: %v", name, err)
+			}
+		}
+		opts = append(opts, truncate.WithTimestampColumn(name, t.TimestampColumn, age))
+	}
+	return opts, nil
+}
+
+// parseAge parses an age given with --older-than. In addition to durations like "36h", it accepts days like "30d".
+func parseAge(s string) (time.Duration, error) {
+	var age time.Duration
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		n, err := strconv.ParseFloat(days, 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid age %q", s)
+		}
+		age = time.Duration(n * float64(24*time.Hour))
+	} else {
+		var err error
+		if age, err = time.ParseDuration(s); err != nil {
+			return 0, fmt.Errorf("invalid age %q", s)
+		}
+	}
+	if age <= 0 {
+		return 0, fmt.Errorf("age %q must be positive", s)
+	}
+	return age, nil
+}
+
+// parseTenantID parses a tenant ID given with --tenant-id. An integer is an INT64 value, and anything else,
+// including an integer quoted with single or double quotes, is a STRING value.
+func parseTenantID(s string) interface{} {
+	if i, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return i
+	}
+	if len(s) >= 2 && (s[0] == '\'' || s[0] == '"') && s[len(s)-1] == s[0] {
+		return s[1 : len(s)-1]
+	}
+	return s
+}
@@ -0,0 +1,48 @@
+//
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package main
+
+import (
+	"os"
+
+	"github.com/mattn/go-isatty"
+)
+
+// Values of --progress.
+const (
+	progressAuto  = "auto"
+	progressBars  = "bars"
+	progressPlain = "plain"
+)
+
+// usePlainProgress returns true if the progress should be printed as plain lines instead of progress bars.
+// In auto mode, progress bars redrawn in place are used only on terminals which support ANSI escape sequences,
+// as they are garbled in CI logs and files.
+func usePlainProgress(mode string, f *os.File) bool {
+	switch mode {
+	case progressPlain:
+		return true
+	case progressBars:
+		return false
+	}
+	return !isTerminal(f) || !supportsANSI(f)
+}
+
+// isTerminal returns true if f is a terminal, including Cygwin and MSYS2 terminals such as mintty.
+func isTerminal(f *os.File) bool {
+	return isatty.IsTerminal(f.Fd()) || isatty.IsCygwinTerminal(f.Fd())
+}
@@ -0,0 +1,119 @@
+//
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+
+	"github.com/cloudspannerecosystem/spanner-truncate/truncate"
+)
+
+// runFunc truncates the database and writes its output to out.
+type runFunc func(ctx context.Context, databaseID string, out io.Writer, opts ...truncate.Option) error
+
+// runDatabases truncates the databases with up to concurrency databases at the same time, and returns errors keyed by database ID.
+// Databases are processed strictly in order if concurrency is 1. Otherwise, output of each database is buffered
+// and printed as a section when the database finishes, and its progress is printed as prefixed lines meanwhile.
+// Unless continueOnError is true, databases which haven't started yet are skipped once any database fails.
+func runDatabases(ctx context.Context, databaseIDs []string, concurrency int, continueOnError bool, out io.Writer, run runFunc) map[string]error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		mu      sync.Mutex // Guards errs and out.
+		errs    = map[string]error{}
+		started = map[string]bool{}
+		wg      sync.WaitGroup
+		sem     = make(chan struct{}, concurrency)
+	)
+	for i, databaseID := range databaseIDs {
+		sem <- struct{}{}
+		mu.Lock()
+		aborted := !continueOnError && len(errs) > 0
+		mu.Unlock()
+		if aborted {
+			<-sem
+			break
+		}
+
+		started[databaseID] = true
+		header := fmt.Sprintf("=== Database %s (%d/%d) ===\n", databaseID, i+1, len(databaseIDs))
+		databaseID := databaseID
+		wg.Add(1)
+		go func() {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+
+			var err error
+			if concurrency == 1 {
+				fmt.Fprint(out, header)
+				err = run(ctx, databaseID, out)
+				fmt.Fprint(out, "\n")
+			} else {
+				var buf bytes.Buffer
+				err = run(ctx, databaseID, &buf, truncate.WithoutProgressBars(), truncate.WithEventHandler(func(e truncate.Event) {
+					if line := formatProgress(e); line != "" {
+						mu.Lock()
+						fmt.Fprintf(out, "[%s] %s\n", databaseID, line)
+						mu.Unlock()
+					}
+				}))
+				mu.Lock()
+				fmt.Fprint(out, header)
+				buf.WriteTo(out)
+				fmt.Fprint(out, "\n")
+				mu.Unlock()
+			}
+
+			if err != nil {
+				mu.Lock()
+				errs[databaseID] = err
+				mu.Unlock()
+				if !continueOnError {
+					cancel()
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	// Record databases which have never been started.
+	for _, databaseID := range databaseIDs {
+		if !started[databaseID] {
+			errs[databaseID] = fmt.Errorf("skipped because another database failed")
+		}
+	}
+	return errs
+}
+
+// formatProgress formats the event as a line of progress. It returns a blank string for events not worth printing.
+func formatProgress(e truncate.Event) string {
+	switch e.Type {
+	case truncate.EventWaveStarted:
+		return fmt.Sprintf("wave %d started: %s", e.Wave, strings.Join(e.Tables, ", "))
+	case truncate.EventWaveCompleted:
+		return fmt.Sprintf("wave %d completed", e.Wave)
+	}
+	return ""
+}
@@ -0,0 +1,49 @@
+//
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package main
+
+import (
+	"context"
+	"os"
+	"time"
+)
+
+// triggerDump requests a state dump unless a request is already pending.
+func triggerDump(trigger chan<- struct{}) {
+	select {
+	case trigger <- struct{}{}:
+	default:
+	}
+}
+
+// watchTriggerFile requests a state dump each time the file is created, and removes the file.
+// It's an alternative to SIGUSR1 on platforms or environments where sending signals is not handy.
+func watchTriggerFile(ctx context.Context, path string, trigger chan<- struct{}) {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if _, err := os.Stat(path); err == nil {
+				os.Remove(path)
+				triggerDump(trigger)
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
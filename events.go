@@ -0,0 +1,41 @@
+//
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+
+	"github.com/cloudspannerecosystem/spanner-truncate/truncate"
+)
+
+// newNDJSONEventHandler creates an event handler writing each event as a JSON line.
+// It can be shared by runs of multiple databases, whose lines aren't interleaved.
+func newNDJSONEventHandler(w io.Writer) truncate.EventHandler {
+	var mu sync.Mutex
+	enc := json.NewEncoder(w)
+	return func(event truncate.Event) {
+		mu.Lock()
+		defer mu.Unlock()
+		if err := enc.Encode(event); err != nil {
+			fmt.Fprintf(os.Stderr, "WARNING: failed to write an event: %v\n", err)
+		}
+	}
+}
@@ -0,0 +1,45 @@
+//
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package main
+
+import (
+	"context"
+	"io"
+	"os"
+
+	"cloud.google.com/go/spanner"
+	"github.com/cloudspannerecosystem/spanner-truncate/truncate"
+)
+
+// graphCommand is the options of the graph command.
+type graphCommand struct {
+	Output string `short:"o" long:"output" description:"Path to write the graph in DOT format to. Default to stdout."`
+}
+
+// writeGraph writes the dependency graph of the target tables to the output of the command.
+func writeGraph(ctx context.Context, client *spanner.Client, cmd graphCommand, targetTables, excludeTables []string, opts ...truncate.Option) error {
+	var w io.Writer = os.Stdout
+	if cmd.Output != "" {
+		f, err := os.Create(cmd.Output)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+	return truncate.WriteDependencyGraph(ctx, client, w, targetTables, excludeTables, opts...)
+}
@@ -19,56 +19,683 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
 	"os"
 	"os/signal"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/cloudspannerecosystem/spanner-truncate/truncate"
 	"github.com/jessevdk/go-flags"
+	"google.golang.org/api/impersonate"
+	"google.golang.org/api/option"
+	sppb "google.golang.org/genproto/googleapis/spanner/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/keepalive"
 )
 
 type options struct {
-	ProjectID     string `short:"p" long:"project" env:"SPANNER_PROJECT_ID" description:"(required) GCP Project ID."`
-	InstanceID    string `short:"i" long:"instance" env:"SPANNER_INSTANCE_ID" description:"(required) Cloud Spanner Instance ID."`
-	DatabaseID    string `short:"d" long:"database" env:"SPANNER_DATABASE_ID" description:"(required) Cloud Spanner Database ID."`
-	Quiet         bool   `short:"q" long:"quiet" description:"Disable all interactive prompts."`
-	Tables        string `short:"t" long:"tables" description:"Comma separated table names to be truncated. Default to truncate all tables if not specified."`
-	ExcludeTables string `short:"e" long:"exclude-tables" description:"Comma separated table names to be exempted from truncating. 'tables' and 'exclude-tables' cannot co-exist"`
+	ProjectID           string        `short:"p" long:"project" env:"SPANNER_PROJECT_ID" description:"(required) GCP Project ID."`
+	InstanceID          string        `short:"i" long:"instance" env:"SPANNER_INSTANCE_ID" description:"(required) Cloud Spanner Instance ID."`
+	DatabaseID          string        `short:"d" long:"database" env:"SPANNER_DATABASE_ID" description:"(required) Cloud Spanner Database ID. Comma separated database IDs truncate multiple databases."`
+	Endpoint            string        `long:"endpoint" env:"SPANNER_TRUNCATE_ENDPOINT" description:"Cloud Spanner API endpoint, e.g. a regional endpoint, instead of the default one."`
+	GRPCChannels        int           `long:"grpc-channels" env:"SPANNER_TRUNCATE_GRPC_CHANNELS" description:"Number of gRPC channels of each Cloud Spanner client. The client library's default if 0."`
+	KeepaliveTime       time.Duration `long:"keepalive-time" env:"SPANNER_TRUNCATE_KEEPALIVE_TIME" description:"Interval of gRPC keepalive pings on idle connections, e.g. 30s. Disabled if 0."`
+	KeepaliveTimeout    time.Duration `long:"keepalive-timeout" env:"SPANNER_TRUNCATE_KEEPALIVE_TIMEOUT" default:"20s" description:"Time to wait for the acknowledgement of a keepalive ping before closing the connection."`
+	EmulatorHost        string        `long:"emulator-host" env:"SPANNER_EMULATOR_HOST" description:"Address of Cloud Spanner Emulator, e.g. localhost:9010. Queries unsupported by the emulator are skipped or adapted."`
+	CredentialsFile     string        `long:"credentials-file" env:"SPANNER_TRUNCATE_CREDENTIALS_FILE" description:"Path to a service account key file used instead of Application Default Credentials."`
+	Impersonate         string        `long:"impersonate-service-account" env:"SPANNER_TRUNCATE_IMPERSONATE_SERVICE_ACCOUNT" description:"Email of a service account to impersonate. You need roles/iam.serviceAccountTokenCreator on it."`
+	BillingProject      string        `long:"billing-project" env:"SPANNER_TRUNCATE_BILLING_PROJECT" description:"Project to bill quota and API usage to, which may differ from the project of the database. Required for some user credentials."`
+	MinSessions         uint64        `long:"min-sessions" env:"SPANNER_TRUNCATE_MIN_SESSIONS" default:"100" description:"Minimum number of sessions kept opened in the session pool of each Cloud Spanner client."`
+	MaxSessions         uint64        `long:"max-sessions" env:"SPANNER_TRUNCATE_MAX_SESSIONS" description:"Maximum number of sessions opened by each Cloud Spanner client. 100 per gRPC channel if 0."`
+	WriteSessions       float64       `long:"write-sessions" env:"SPANNER_TRUNCATE_WRITE_SESSIONS" default:"0.2" description:"Fraction of sessions prepared for read-write transactions, between 0 and 1."`
+	Quiet               bool          `short:"q" long:"quiet" env:"SPANNER_TRUNCATE_QUIET" description:"Disable all interactive prompts."`
+	Silent              bool          `short:"s" long:"silent" env:"SPANNER_TRUNCATE_SILENT" description:"Suppress all output except errors and the final result. Implies --quiet."`
+	Format              string        `long:"format" env:"SPANNER_TRUNCATE_FORMAT" choice:"text" choice:"json" default:"text" description:"Output format. json writes the target tables, progress and the summary as JSON lines, and requires --quiet or --dry-run."`
+	Events              string        `long:"events" env:"SPANNER_TRUNCATE_EVENTS" choice:"ndjson" description:"Write an event on each state transition of the run and its tables as JSON lines to stdout instead of the text output, and requires --quiet or --dry-run."`
+	Schema              string        `long:"schema" env:"SPANNER_TRUNCATE_SCHEMA" description:"Comma separated named schemas to truncate tables in. Tables in the default schema are not touched if specified."`
+	AllSchemas          bool          `long:"all-schemas" env:"SPANNER_TRUNCATE_ALL_SCHEMAS" description:"Truncate tables in all schemas, including the default schema and named schemas."`
+	Tables              string        `short:"t" long:"tables" env:"SPANNER_TRUNCATE_TABLES" description:"Comma separated table names or patterns, e.g. 'tmp_*' or '/^audit_/', to be truncated. Default to truncate all tables if not specified. Specify '-' to read table names from stdin."`
+	TablesFile          string        `long:"tables-file" env:"SPANNER_TRUNCATE_TABLES_FILE" description:"Path to a file with table names or patterns to be truncated, separated by newlines, commas or whitespaces. Specify '-' to read them from stdin."`
+	ExcludeTables       string        `short:"e" long:"exclude-tables" env:"SPANNER_TRUNCATE_EXCLUDE_TABLES" description:"Comma separated table names or patterns to be exempted from truncating. 'tables' and 'exclude-tables' cannot co-exist"`
+	TenantID            string        `long:"tenant-id" env:"SPANNER_TRUNCATE_TENANT_ID" description:"Delete only rows of the tenant from tables with tenant_column in the config file. Quote integer IDs of STRING columns, e.g. \"'42'\"."`
+	KeyPrefix           string        `long:"key-prefix" env:"SPANNER_TRUNCATE_KEY_PREFIX" description:"Delete only rows under the primary key prefix of a table, e.g. 'Singers(42)', and rows of its interleaved descendants under them. Can't be used with --tables or --exclude-tables."`
+	OlderThan           string        `long:"older-than" env:"SPANNER_TRUNCATE_OLDER_THAN" description:"Delete only rows older than the age, e.g. 30d or 36h, decided by the commit timestamp column of each table. Tables without a single commit timestamp column are skipped unless timestamp_column is set in the config file."`
+	Scrub               bool          `long:"scrub" env:"SPANNER_TRUNCATE_SCRUB" description:"Scrub columns of tables with scrub in the config file by setting them to the given SQL expressions, instead of deleting rows. Other tables are not touched."`
+	SeedFile            []string      `long:"seed-file" env:"SPANNER_TRUNCATE_SEED_FILE" env-delim:"," description:"Path to a file with DML statements separated by semicolons, executed in a transaction after all rows have been deleted, e.g. to insert reference data. Can be specified multiple times, executed in order."`
+	IgnoreMissingTables bool          `long:"ignore-missing-tables" env:"SPANNER_TRUNCATE_IGNORE_MISSING_TABLES" description:"Skip tables in --tables and --exclude-tables which don't exist with a warning, instead of failing the run."`
+	ExcludeEmpty        bool          `long:"exclude-empty" env:"SPANNER_TRUNCATE_EXCLUDE_EMPTY" description:"Omit already empty tables from the table listing. They are still verified."`
+	SkipEmpty           bool          `long:"skip-empty" env:"SPANNER_TRUNCATE_SKIP_EMPTY" description:"Complete already empty tables before the deletion starts, without deleting them or showing their progress."`
+	DryRun              bool          `long:"dry-run" env:"SPANNER_TRUNCATE_DRY_RUN" description:"List the target tables and their row counts without deleting any rows."`
+	ReportFile          string        `long:"report-file" env:"SPANNER_TRUNCATE_REPORT_FILE" description:"Write a report of the run in JSON to the file."`
+	NotifyURL           []string      `long:"notify-url" env:"SPANNER_TRUNCATE_NOTIFY_URL" env-delim:"," description:"Post the report of the run in JSON to the webhook URL when the run finishes, whether it succeeded or failed. Can be specified multiple times."`
+	NotifyFormat        string        `long:"notify-format" env:"SPANNER_TRUNCATE_NOTIFY_FORMAT" choice:"json" choice:"slack" default:"json" description:"Payload posted to --notify-url. slack posts a one-line summary accepted by Slack incoming webhooks."`
+	ReportDDL           bool          `long:"report-ddl" env:"SPANNER_TRUNCATE_REPORT_DDL" description:"Include the CREATE TABLE statement of each table in the report."`
+	SampleRows          int           `long:"sample-rows" env:"SPANNER_TRUNCATE_SAMPLE_ROWS" description:"Offer to show up to the number of rows of each non-empty table before confirmation. Ignored with --quiet."`
+	VerifyIndexes       bool          `long:"verify-indexes" env:"SPANNER_TRUNCATE_VERIFY_INDEXES" description:"Verify that secondary and search indexes of the deleted tables are empty after the deletion."`
+	DiffAgainst         string        `long:"diff-against" env:"SPANNER_TRUNCATE_DIFF_AGAINST" description:"Compare the target tables and their row counts with the report of a previous run."`
+	SchemaOut           string        `long:"schema-out" env:"SPANNER_TRUNCATE_SCHEMA_OUT" description:"Write a snapshot of the schema of all tables, foreign keys and indexes to the file in JSON before the run, to plan against it later with --schema-in."`
+	SchemaIn            string        `long:"schema-in" env:"SPANNER_TRUNCATE_SCHEMA_IN" description:"Plan against the schema snapshot written by --schema-out without access to the database. Only for the plan and graph commands, and rows are not counted."`
+	FailFast            bool          `long:"fail-fast" env:"SPANNER_TRUNCATE_FAIL_FAST" description:"Abort the whole run on the first table failure without asking what to do. This is the default behavior with --quiet."`
+	ContinueOnError     bool          `long:"continue-on-error" env:"SPANNER_TRUNCATE_CONTINUE_ON_ERROR" description:"Keep deleting tables which don't depend on failed tables, and report all failures at the end."`
+	Mode                string        `long:"mode" env:"SPANNER_TRUNCATE_MODE" choice:"pdml" choice:"auto" choice:"mutations" choice:"batched" default:"pdml" description:"How to delete rows. auto deletes tables with up to --mutation-threshold rows with a mutation instead of Partitioned DML, and mutations does it for all tables. batched deletes --batch-size rows at a time in read-write transactions to reduce lock contention."`
+	MutationThreshold   int64         `long:"mutation-threshold" env:"SPANNER_TRUNCATE_MUTATION_THRESHOLD" default:"1000" description:"Maximum number of rows, including rows of interleaved descendants, of a table deleted with a mutation in auto mode."`
+	BatchSize           int64         `long:"batch-size" env:"SPANNER_TRUNCATE_BATCH_SIZE" description:"Maximum number of rows deleted in a read-write transaction by deletions without Partitioned DML, i.e. batched mode, leaf rows first and the fallback from Partitioned DML. Defaults to 1000 in batched mode, and as many rows as a commit can hold otherwise."`
+	BatchInterval       time.Duration `long:"batch-interval" env:"SPANNER_TRUNCATE_BATCH_INTERVAL" description:"Pause between read-write transactions of deletions without Partitioned DML, e.g. 200ms. Defaults to 100ms in batched mode, and no pause otherwise."`
+	NoBatchedFallback   bool          `long:"no-batched-fallback" env:"SPANNER_TRUNCATE_NO_BATCHED_FALLBACK" description:"Fail tables whose Partitioned DML is unsupported or keeps exhausting resources, instead of deleting them in batches of transactions."`
+	Shards              int           `long:"shards" env:"SPANNER_TRUNCATE_SHARDS" description:"Number of Partitioned DML statements deleting key ranges of a huge table concurrently. Disabled if less than 2."`
+	ShardThreshold      int64         `long:"shard-threshold" env:"SPANNER_TRUNCATE_SHARD_THRESHOLD" default:"10000000" description:"Minimum number of rows, including rows of interleaved descendants, of a table deleted in shards with --shards."`
+	Priority            string        `long:"priority" env:"SPANNER_TRUNCATE_PRIORITY" choice:"low" choice:"medium" choice:"high" description:"RPC priority of requests to delete and count rows. Requests run at high priority if not specified."`
+	RequestTag          string        `long:"request-tag" env:"SPANNER_TRUNCATE_REQUEST_TAG" default:"spanner-truncate" description:"Request tag of queries and DML statements, which appears in query statistics."`
+	TransactionTag      string        `long:"transaction-tag" env:"SPANNER_TRUNCATE_TRANSACTION_TAG" description:"Transaction tag of read-write and Partitioned DML transactions, which appears in transaction and lock statistics. A tag unique to the run is used if not specified."`
+	Parallelism         int           `long:"parallelism" env:"SPANNER_TRUNCATE_PARALLELISM" description:"Maximum number of tables deleted at the same time, which bounds the number of Partitioned DML operations in flight. Unlimited if 0."`
+	MaxQPS              float64       `long:"max-qps" env:"SPANNER_TRUNCATE_MAX_QPS" description:"Maximum rate of statements issued while deleting rows, including queries to count rows, across all tables. Unlimited if 0."`
+	BreakCycles         bool          `long:"break-cycles" env:"SPANNER_TRUNCATE_BREAK_CYCLES" description:"Delete tables referencing each other by foreign keys in a cycle at the same time, leaf rows first, instead of failing."`
+	DeleteRetries       int           `long:"delete-retries" env:"SPANNER_TRUNCATE_DELETE_RETRIES" default:"3" description:"Maximum number of times the deletion of a table is retried when it fails with a transient error, e.g. an aborted transaction or an unavailable server. Never retried if 0."`
+	DeleteRetryBackoff  time.Duration `long:"delete-retry-backoff" env:"SPANNER_TRUNCATE_DELETE_RETRY_BACKOFF" default:"10s" description:"How long to wait before the first retry of a deletion. It doubles on each retry up to 5m."`
+	RebuildIndexes      bool          `long:"rebuild-indexes" env:"SPANNER_TRUNCATE_REBUILD_INDEXES" description:"Drop secondary indexes of the target tables before deleting rows, and recreate them afterwards."`
+	DropFKConstraints   bool          `long:"drop-fk-constraints" env:"SPANNER_TRUNCATE_DROP_FK_CONSTRAINTS" description:"Drop foreign keys among the target tables before deleting rows, and restore them afterwards, to delete the tables in parallel."`
+	BackupBefore        bool          `long:"backup-before" env:"SPANNER_TRUNCATE_BACKUP_BEFORE" description:"Create a backup of the database before deleting rows, and abort if it fails."`
+	BackupRetention     time.Duration `long:"backup-retention" env:"SPANNER_TRUNCATE_BACKUP_RETENTION" default:"168h" description:"How long the backup created by --backup-before is retained, between 6h and 8784h (366 days)."`
+	SkipUnauthorized    bool          `long:"skip-unauthorized" env:"SPANNER_TRUNCATE_SKIP_UNAUTHORIZED" description:"Skip tables which you lack permissions to count or delete rows from, instead of failing the run."`
+	DBConcurrency       int           `long:"db-concurrency" env:"SPANNER_TRUNCATE_DB_CONCURRENCY" default:"1" description:"Number of databases processed in parallel when multiple databases are specified. 1 processes them strictly in order."`
+	SkipLargerThan      string        `long:"skip-larger-than" env:"SPANNER_TRUNCATE_SKIP_LARGER_THAN" description:"Skip tables larger than the size, in rows (e.g. 1000000) or in bytes (e.g. 10GiB) taken from table size statistics. Skipped tables are reported."`
+	SkipTTLTables       bool          `long:"skip-ttl-tables" env:"SPANNER_TRUNCATE_SKIP_TTL_TABLES" description:"Skip tables with row deletion policies (TTL), whose rows age out anyway. Skipped tables are reported."`
+	Timeout             time.Duration `long:"timeout" env:"SPANNER_TRUNCATE_TIMEOUT" default:"24h" description:"Abort the run if it doesn't finish within the duration. With multiple databases, it applies to each database."`
+	TableTimeout        time.Duration `long:"table-timeout" env:"SPANNER_TRUNCATE_TABLE_TIMEOUT" description:"Fail a table if its deletion, including retries, doesn't finish within the duration. Unlimited if 0."`
+	DumpState           string        `long:"dump-state" env:"SPANNER_TRUNCATE_DUMP_STATE" description:"Path to a trigger file. Each time the file is created, the internal state is dumped to stderr and the file is removed. SIGUSR1 does the same on platforms other than Windows."`
+	ProgressSource      string        `long:"progress-source" env:"SPANNER_TRUNCATE_PROGRESS_SOURCE" choice:"count" choice:"stats" default:"count" description:"How to track the progress of deletion. stats estimates it from hourly table size statistics instead of COUNT(*) queries, which are expensive on huge tables."`
+	BatchCounts         bool          `long:"batch-counts" env:"SPANNER_TRUNCATE_BATCH_COUNTS" description:"Count rows of up to 50 tables in a single query combined with UNION ALL, instead of a COUNT(*) query for each table. Useful for databases with hundreds of tables."`
+	CountThreshold      int64         `long:"count-threshold" env:"SPANNER_TRUNCATE_COUNT_THRESHOLD" description:"Stop counting rows of tables with more than this number of rows at the first count, and show their progress coarsely. Disabled if 0."`
+	Progress            string        `long:"progress" env:"SPANNER_TRUNCATE_PROGRESS" choice:"auto" choice:"bars" choice:"plain" default:"auto" description:"How to show the progress of deletion. auto shows progress bars on terminals, and plain lines otherwise, e.g. in CI logs or when piped to a file."`
+	NoProgress          bool          `long:"no-progress" env:"SPANNER_TRUNCATE_NO_PROGRESS" description:"Don't count rows at all, and show only state transitions of tables. Useful for batch jobs where the counting load is unwelcome."`
+	LogLevel            string        `long:"log-level" env:"SPANNER_TRUNCATE_LOG_LEVEL" choice:"off" choice:"debug" choice:"info" choice:"warn" choice:"error" default:"off" description:"Log what the run does, e.g. deleting tables, failures and warnings, to stderr at the level or above."`
+	LogFormat           string        `long:"log-format" env:"SPANNER_TRUNCATE_LOG_FORMAT" choice:"text" choice:"json" default:"text" description:"Format of logs written to stderr."`
+	MetricsAddr         string        `long:"metrics-addr" env:"SPANNER_TRUNCATE_METRICS_ADDR" description:"Serve metrics of the run in the Prometheus text format at /metrics on the address, e.g. :9090."`
+	Config              string        `short:"c" long:"config" env:"SPANNER_TRUNCATE_CONFIG" description:"Path to the config file in JSON which declares per-table settings such as pre/post SQL hooks."`
 }
 
-const maxTimeout = time.Hour * 24
-
 func main() {
 	var opts options
-	if _, err := flags.Parse(&opts); err != nil {
+	parser := flags.NewParser(&opts, flags.Default)
+	parser.SubcommandsOptional = true
+	if _, err := parser.AddCommand("list-databases", "List databases in the instance", "List databases in the instance with their dialects, table counts and sizes.", &struct{}{}); err != nil {
+		exitf("Failed to set up commands: %v\n", err)
+	}
+	var planCmd planCommand
+	if _, err := parser.AddCommand("plan", "Write a plan of deletion", "List the target tables like --dry-run, and write the plan of deletion to a file to be reviewed and applied.", &planCmd); err != nil {
+		exitf("Failed to set up commands: %v\n", err)
+	}
+	var graphCmd graphCommand
+	if _, err := parser.AddCommand("graph", "Write the dependency graph of tables", "Write the graph of the target tables in DOT format of Graphviz, which shows interleaving and foreign keys deciding the order of deletion, and circular dependencies if any.", &graphCmd); err != nil {
+		exitf("Failed to set up commands: %v\n", err)
+	}
+	var applyCmd applyCommand
+	if _, err := parser.AddCommand("apply", "Apply a plan of deletion", "Delete rows exactly as planned by the plan command, failing without deleting anything if the database has drifted from the plan.", &applyCmd); err != nil {
+		exitf("Failed to set up commands: %v\n", err)
+	}
+	if _, err := parser.Parse(); err != nil {
 		exitf("Invalid options\n")
 	}
 
+	// Cloud Spanner clients connect to the emulator, and the run adapts to it, by the environment variable.
+	if opts.EmulatorHost != "" {
+		os.Setenv("SPANNER_EMULATOR_HOST", opts.EmulatorHost)
+	}
+
+	if parser.Active != nil && parser.Active.Name == "list-databases" {
+		listDatabases(opts)
+		return
+	}
+
 	if opts.ProjectID == "" || opts.InstanceID == "" || opts.DatabaseID == "" {
 		exitf("Missing options: -p, -i, -d are required.\n")
 	}
 
+	var command string
+	if parser.Active != nil {
+		command = parser.Active.Name
+	}
+	if command == "plan" {
+		// Planning deletes nothing, like a dry run.
+		opts.DryRun = true
+	}
+	var approvedPlan *truncate.Plan
+	if command == "apply" {
+		if opts.Tables != "" || opts.TablesFile != "" || opts.ExcludeTables != "" {
+			exitf("Conflict: apply targets the tables in the plan, so --tables, --tables-file and --exclude-tables cannot be set.\n")
+		}
+		plan, err := readPlan(applyCmd.Plan)
+		if err != nil {
+			exitf("Failed to read plan: %v\n", err)
+		}
+		approvedPlan = plan
+	}
+
 	var targetTables []string
 	var excludeTables []string
-	if opts.Tables != "" {
+	if opts.Tables != "" && opts.TablesFile != "" {
+		exitf("Conflict: --tables and --tables-file cannot be both set.\n")
+	}
+	if opts.Tables == "-" || opts.TablesFile == "-" {
+		// Stdin is consumed by table names, so it can't be used for the confirmation prompt.
+		if !opts.Quiet && !opts.Silent && !opts.DryRun {
+			exitf("Invalid options: reading table names from stdin requires --quiet since stdin is used for table names.\n")
+		}
+		names, err := readTableNames(os.Stdin)
+		if err != nil {
+			exitf("Failed to read table names from stdin: %v\n", err)
+		}
+		// Never fall back to truncating all tables by an empty input.
+		if len(names) == 0 {
+			exitf("No table names are given from stdin.\n")
+		}
+		targetTables = names
+	} else if opts.TablesFile != "" {
+		names, err := readTableNamesFile(opts.TablesFile)
+		if err != nil {
+			exitf("Failed to read table names from %s: %v\n", opts.TablesFile, err)
+		}
+		if len(names) == 0 {
+			exitf("No table names are given in %s.\n", opts.TablesFile)
+		}
+		targetTables = names
+	} else if opts.Tables != "" {
 		targetTables = strings.Split(opts.Tables, ",")
+	} else if approvedPlan != nil {
+		targetTables = approvedPlan.TableNames()
 	}
 
 	if opts.ExcludeTables != "" {
-		if opts.Tables != "" {
-			exitf("Conflict: --tables and --exclude-tables cannot be both set.\n")
+		if opts.Tables != "" || opts.TablesFile != "" {
+			exitf("Conflict: --tables (or --tables-file) and --exclude-tables cannot be both set.\n")
 		}
 		excludeTables = strings.Split(opts.ExcludeTables, ",")
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), maxTimeout)
-	defer cancel()
+	var truncateOpts []truncate.Option
+	if opts.TenantID != "" {
+		if len(targetTables) > 0 || len(excludeTables) > 0 || opts.KeyPrefix != "" {
+			exitf("Conflict: --tenant-id and --tables (or --tables-file, --exclude-tables, --key-prefix) cannot be both set.\n")
+		}
+		if opts.Config == "" {
+			exitf("Invalid options: --tenant-id requires --config with tenant columns.\n")
+		}
+	}
+	if opts.KeyPrefix != "" {
+		if len(targetTables) > 0 || len(excludeTables) > 0 {
+			exitf("Conflict: --key-prefix and --tables (or --tables-file, --exclude-tables) cannot be both set.\n")
+		}
+		tableName, values, err := truncate.ParseKeyPrefix(opts.KeyPrefix)
+		if err != nil {
+			exitf("Invalid options: %v\n", err)
+		}
+		truncateOpts = append(truncateOpts, truncate.WithKeyPrefix(tableName, values))
+	}
+	if opts.Scrub {
+		if len(targetTables) > 0 || len(excludeTables) > 0 || opts.KeyPrefix != "" || opts.TenantID != "" || opts.OlderThan != "" {
+			exitf("Conflict: --scrub and --tables (or --tables-file, --exclude-tables, --key-prefix, --tenant-id, --older-than) cannot be both set.\n")
+		}
+		if opts.Config == "" {
+			exitf("Invalid options: --scrub requires --config with scrubbed columns.\n")
+		}
+	}
+	if opts.OlderThan != "" {
+		if opts.KeyPrefix != "" || opts.TenantID != "" {
+			exitf("Conflict: --older-than and --key-prefix (or --tenant-id) cannot be both set.\n")
+		}
+		age, err := parseAge(opts.OlderThan)
+		if err != nil {
+			exitf("Invalid options: --older-than: %v\n", err)
+		}
+		truncateOpts = append(truncateOpts, truncate.WithOlderThan(age))
+	}
+
+	databaseIDs := strings.Split(opts.DatabaseID, ",")
+	if len(databaseIDs) > 1 && (opts.ReportFile != "" || opts.DiffAgainst != "") {
+		exitf("Invalid options: --report-file and --diff-against can't be used with multiple databases.\n")
+	}
+	if len(databaseIDs) > 1 && (opts.SchemaOut != "" || opts.SchemaIn != "") {
+		exitf("Invalid options: --schema-out and --schema-in can't be used with multiple databases.\n")
+	}
+	if opts.SchemaIn != "" {
+		if command != "plan" && command != "graph" {
+			exitf("Invalid options: --schema-in can only be used with the plan and graph commands.\n")
+		}
+		if opts.SchemaOut != "" {
+			exitf("Conflict: --schema-in and --schema-out cannot be both set.\n")
+		}
+	}
+	if len(databaseIDs) > 1 && command != "" {
+		exitf("Invalid options: %s can't be used with multiple databases.\n", command)
+	}
+	if opts.Format == "json" {
+		// Prompts can't be shown in JSON output.
+		if !opts.Quiet && !opts.DryRun {
+			exitf("Invalid options: --format=json requires --quiet or --dry-run.\n")
+		}
+		if opts.Silent {
+			exitf("Conflict: --format=json and --silent cannot be both set.\n")
+		}
+		if len(databaseIDs) > 1 {
+			exitf("Invalid options: --format=json can't be used with multiple databases.\n")
+		}
+	}
+	if opts.Events != "" {
+		if !opts.Quiet && !opts.DryRun {
+			exitf("Invalid options: --events requires --quiet or --dry-run.\n")
+		}
+		if opts.Format == "json" {
+			exitf("Conflict: --events and --format=json cannot be both set.\n")
+		}
+	}
+	if opts.DBConcurrency < 1 {
+		exitf("Invalid options: --db-concurrency must be at least 1.\n")
+	}
+	// Confirmation prompts of databases processed in parallel would be mixed up.
+	if opts.DBConcurrency > 1 && len(databaseIDs) > 1 && !opts.Quiet && !opts.Silent && !opts.DryRun {
+		exitf("Invalid options: --db-concurrency greater than 1 requires --quiet.\n")
+	}
+
+	if opts.FailFast && opts.ContinueOnError {
+		exitf("Conflict: --fail-fast and --continue-on-error cannot be both set.\n")
+	}
+
+	if opts.Config != "" {
+		cfg, err := loadConfig(opts.Config)
+		if err != nil {
+			exitf("Failed to load config: %v\n", err)
+		}
+		truncateOpts = append(truncateOpts, cfg.truncateOptions()...)
+		if opts.TenantID != "" {
+			columns := cfg.tenantColumns()
+			if len(columns) == 0 {
+				exitf("Invalid options: --tenant-id requires tenant_column of tables in %s.\n", opts.Config)
+			}
+			truncateOpts = append(truncateOpts, truncate.WithTenant(parseTenantID(opts.TenantID), columns))
+		}
+		if opts.Scrub {
+			scrubOpts := cfg.scrubOptions()
+			if len(scrubOpts) == 0 {
+				exitf("Invalid options: --scrub requires scrub of tables in %s.\n", opts.Config)
+			}
+			truncateOpts = append(truncateOpts, scrubOpts...)
+		}
+		if opts.OlderThan != "" {
+			ageOpts, err := cfg.ageOptions()
+			if err != nil {
+				exitf("Invalid options: %v\n", err)
+			}
+			truncateOpts = append(truncateOpts, ageOpts...)
+		}
+	}
+
+	for _, path := range opts.SeedFile {
+		b, err := ioutil.ReadFile(path)
+		if err != nil {
+			exitf("Failed to read seed statements from %s: %v\n", path, err)
+		}
+		statements := truncate.SplitStatements(string(b))
+		if len(statements) == 0 {
+			exitf("No statements are given in %s.\n", path)
+		}
+		truncateOpts = append(truncateOpts, truncate.WithSeed(path, statements))
+	}
+
+	if opts.Schema != "" && opts.AllSchemas {
+		exitf("Conflict: --schema and --all-schemas cannot be both set.\n")
+	}
+	if opts.AllSchemas {
+		truncateOpts = append(truncateOpts, truncate.WithAllSchemas())
+	}
+	if opts.Schema != "" {
+		truncateOpts = append(truncateOpts, truncate.WithSchemas(strings.Split(opts.Schema, ",")...))
+	}
+	if usePlainProgress(opts.Progress, os.Stdout) {
+		truncateOpts = append(truncateOpts, truncate.WithPlainProgress())
+	}
+	if opts.Format == "json" {
+		truncateOpts = append(truncateOpts, truncate.WithJSONOutput())
+	}
+	if opts.Events == "ndjson" {
+		truncateOpts = append(truncateOpts, truncate.WithEventHandler(newNDJSONEventHandler(os.Stdout)))
+	}
+	if opts.BatchCounts {
+		truncateOpts = append(truncateOpts, truncate.WithBatchedRowCounts())
+	}
+	if opts.CountThreshold < 0 {
+		exitf("Invalid options: --count-threshold must not be negative.\n")
+	}
+	if opts.CountThreshold > 0 {
+		truncateOpts = append(truncateOpts, truncate.WithCountThreshold(opts.CountThreshold))
+	}
+	if opts.ProgressSource == "stats" {
+		truncateOpts = append(truncateOpts, truncate.WithStatsProgress())
+	}
+	if handler := newLogHandler(os.Stderr, opts.LogLevel, opts.LogFormat); handler != nil {
+		truncateOpts = append(truncateOpts, truncate.WithLogHandler(handler))
+	}
+	if opts.NoProgress {
+		if opts.ProgressSource == "stats" {
+			exitf("Invalid options: --no-progress can't be used with --progress-source=stats.\n")
+		}
+		if opts.BatchCounts {
+			exitf("Invalid options: --no-progress can't be used with --batch-counts.\n")
+		}
+		truncateOpts = append(truncateOpts, truncate.WithoutRowCounts())
+	}
+	// Tables removed since the plan was generated are reported as drift by apply.
+	if opts.IgnoreMissingTables || approvedPlan != nil {
+		truncateOpts = append(truncateOpts, truncate.WithIgnoreMissingTables())
+	}
+	if opts.ExcludeEmpty {
+		truncateOpts = append(truncateOpts, truncate.WithHideEmptyTables())
+	}
+	if opts.SkipEmpty {
+		truncateOpts = append(truncateOpts, truncate.WithSkipEmptyTables())
+	}
+	for _, u := range opts.NotifyURL {
+		truncateOpts = append(truncateOpts, truncate.WithNotification(u, opts.NotifyFormat == "slack"))
+	}
+	if opts.DryRun {
+		truncateOpts = append(truncateOpts, truncate.WithDryRun())
+	}
+	if opts.FailFast {
+		truncateOpts = append(truncateOpts, truncate.WithFailFast())
+	}
+	if opts.ContinueOnError {
+		truncateOpts = append(truncateOpts, truncate.WithContinueOnError())
+	}
+	if opts.BatchSize < 0 || opts.BatchInterval < 0 {
+		exitf("Invalid options: --batch-size and --batch-interval must not be negative.\n")
+	}
+	switch opts.Mode {
+	case "auto":
+		if opts.MutationThreshold <= 0 {
+			exitf("Invalid options: --mutation-threshold must be positive.\n")
+		}
+		truncateOpts = append(truncateOpts, truncate.WithMutationDeletion(opts.MutationThreshold))
+	case "mutations":
+		truncateOpts = append(truncateOpts, truncate.WithMutationDeletion(math.MaxInt64))
+	case "batched":
+		// Small batches with pauses keep the lock contention low, which is the point of batched mode.
+		if opts.BatchSize == 0 {
+			opts.BatchSize = 1000
+		}
+		if opts.BatchInterval == 0 {
+			opts.BatchInterval = 100 * time.Millisecond
+		}
+		truncateOpts = append(truncateOpts, truncate.WithBatchedDeletion(opts.BatchSize, opts.BatchInterval))
+	}
+	if opts.Mode != "batched" && (opts.BatchSize > 0 || opts.BatchInterval > 0) {
+		truncateOpts = append(truncateOpts, truncate.WithBatchSize(opts.BatchSize, opts.BatchInterval))
+	}
+	if opts.NoBatchedFallback {
+		truncateOpts = append(truncateOpts, truncate.WithoutBatchedFallback())
+	}
+	if opts.Shards > 1 {
+		if opts.ShardThreshold < 0 {
+			exitf("Invalid options: --shard-threshold must not be negative.\n")
+		}
+		truncateOpts = append(truncateOpts, truncate.WithShardedDeletion(opts.ShardThreshold, opts.Shards))
+	}
+	if opts.BackupBefore {
+		if opts.BackupRetention < 6*time.Hour || opts.BackupRetention > 366*24*time.Hour {
+			exitf("Invalid options: --backup-retention must be between 6h and 8784h.\n")
+		}
+		truncateOpts = append(truncateOpts, truncate.WithBackup(opts.BackupRetention))
+	}
+	if opts.Parallelism < 0 || opts.MaxQPS < 0 {
+		exitf("Invalid options: --parallelism and --max-qps must not be negative.\n")
+	}
+	switch opts.Priority {
+	case "low":
+		truncateOpts = append(truncateOpts, truncate.WithPriority(sppb.RequestOptions_PRIORITY_LOW))
+	case "medium":
+		truncateOpts = append(truncateOpts, truncate.WithPriority(sppb.RequestOptions_PRIORITY_MEDIUM))
+	case "high":
+		truncateOpts = append(truncateOpts, truncate.WithPriority(sppb.RequestOptions_PRIORITY_HIGH))
+	}
+	truncateOpts = append(truncateOpts, truncate.WithRequestTag(opts.RequestTag))
+	if opts.TransactionTag != "" {
+		truncateOpts = append(truncateOpts, truncate.WithTransactionTag(opts.TransactionTag))
+	}
+	if opts.Parallelism > 0 {
+		truncateOpts = append(truncateOpts, truncate.WithMaxConcurrentDeletes(opts.Parallelism))
+	}
+	if opts.MaxQPS > 0 {
+		truncateOpts = append(truncateOpts, truncate.WithMaxQPS(opts.MaxQPS))
+	}
+	if opts.DeleteRetries < 0 || opts.DeleteRetryBackoff < 0 {
+		exitf("Invalid options: --delete-retries and --delete-retry-backoff must not be negative.\n")
+	}
+	truncateOpts = append(truncateOpts, truncate.WithDeleteRetries(opts.DeleteRetries, opts.DeleteRetryBackoff))
+	if opts.BreakCycles {
+		truncateOpts = append(truncateOpts, truncate.WithBreakCycles())
+	}
+	if opts.RebuildIndexes {
+		truncateOpts = append(truncateOpts, truncate.WithIndexRebuild())
+	}
+	if opts.DropFKConstraints {
+		truncateOpts = append(truncateOpts, truncate.WithForeignKeyDrop())
+	}
+	if opts.SkipUnauthorized {
+		truncateOpts = append(truncateOpts, truncate.WithSkipUnauthorized())
+	}
+	if opts.SkipLargerThan != "" {
+		n, isBytes, err := parseSizeLimit(opts.SkipLargerThan)
+		if err != nil {
+			exitf("Invalid options: --skip-larger-than: %v\n", err)
+		}
+		if isBytes {
+			truncateOpts = append(truncateOpts, truncate.WithSkipLargerThanBytes(n))
+		} else {
+			if opts.NoProgress {
+				exitf("Invalid options: --skip-larger-than in rows requires counting rows, which --no-progress disables.\n")
+			}
+			truncateOpts = append(truncateOpts, truncate.WithSkipLargerThanRows(n))
+		}
+	}
+	if opts.SkipTTLTables {
+		truncateOpts = append(truncateOpts, truncate.WithSkipTTLTables())
+	}
+	if opts.VerifyIndexes {
+		truncateOpts = append(truncateOpts, truncate.WithIndexVerification())
+	}
+	if opts.SampleRows < 0 {
+		exitf("Invalid options: --sample-rows must not be negative.\n")
+	}
+	if opts.SampleRows > 0 {
+		truncateOpts = append(truncateOpts, truncate.WithSampleRows(opts.SampleRows))
+	}
+	if opts.DiffAgainst != "" {
+		baseline, err := readReport(opts.DiffAgainst)
+		if err != nil {
+			exitf("Failed to read report: %v\n", err)
+		}
+		truncateOpts = append(truncateOpts, truncate.WithBaselineReport(baseline))
+	}
+	if opts.ReportDDL {
+		if opts.ReportFile == "" {
+			exitf("Invalid options: --report-ddl requires --report-file.\n")
+		}
+		truncateOpts = append(truncateOpts, truncate.WithReportDDL())
+	}
+	// Reports of all databases are kept for the result of the silent mode.
+	var reportsMu sync.Mutex
+	var reports []*truncate.Report
+	var reportErr error
+	truncateOpts = append(truncateOpts, truncate.WithReportHandler(func(report *truncate.Report) {
+		reportsMu.Lock()
+		defer reportsMu.Unlock()
+		reports = append(reports, report)
+		if opts.ReportFile != "" {
+			reportErr = writeReport(opts.ReportFile, report)
+		}
+	}))
+	var planErr error
+	if command == "plan" {
+		truncateOpts = append(truncateOpts, truncate.WithPlanHandler(func(plan *truncate.Plan) {
+			planErr = writePlan(planCmd.Output, plan)
+		}))
+	}
+	if approvedPlan != nil {
+		truncateOpts = append(truncateOpts, truncate.WithPlan(approvedPlan))
+	}
+
+	if opts.Timeout <= 0 {
+		exitf("Invalid options: --timeout must be positive.\n")
+	}
+	truncateOpts = append(truncateOpts, truncate.WithTimeout(opts.Timeout))
+	if opts.TableTimeout < 0 {
+		exitf("Invalid options: --table-timeout must not be negative.\n")
+	}
+	truncateOpts = append(truncateOpts, truncate.WithTableTimeout(opts.TableTimeout))
+
+	ctx, cancel := context.WithCancelCause(context.Background())
+	defer cancel(nil)
 	go handleInterrupt(cancel)
 
-	if err := truncate.Run(ctx, opts.ProjectID, opts.InstanceID, opts.DatabaseID, opts.Quiet, os.Stdout, targetTables, excludeTables); err != nil {
-		exitf("ERROR: %s", err.Error())
+	// Dump the internal state to stderr on demand, for debugging a run which seems to hang.
+	dumpTrigger := make(chan struct{}, 1)
+	notifyDumpSignal(dumpTrigger)
+	if opts.DumpState != "" {
+		go watchTriggerFile(ctx, opts.DumpState, dumpTrigger)
+	}
+	truncateOpts = append(truncateOpts, truncate.WithStateDump(dumpTrigger, os.Stderr))
+
+	if opts.MetricsAddr != "" {
+		metrics := truncate.NewMetrics()
+		if err := serveMetrics(opts.MetricsAddr, metrics); err != nil {
+			exitf("ERROR: failed to serve metrics: %v\n", err)
+		}
+		truncateOpts = append(truncateOpts, truncate.WithMetrics(metrics))
+	}
+
+	var out io.Writer = os.Stdout
+	// Events take over stdout from the text output.
+	if opts.Silent || opts.Events != "" {
+		out = ioutil.Discard
+	}
+
+	if opts.GRPCChannels < 0 {
+		exitf("Invalid options: --grpc-channels must not be negative.\n")
+	}
+	if opts.KeepaliveTime < 0 || opts.KeepaliveTimeout < 0 {
+		exitf("Invalid options: --keepalive-time and --keepalive-timeout must not be negative.\n")
+	}
+	if opts.WriteSessions < 0 || opts.WriteSessions > 1 {
+		exitf("Invalid options: --write-sessions must be between 0 and 1.\n")
+	}
+	if opts.MaxSessions > 0 && opts.MinSessions > opts.MaxSessions {
+		exitf("Invalid options: --min-sessions must not exceed --max-sessions.\n")
+	}
+
+	if opts.SchemaIn != "" {
+		planFromSchemaSnapshot(ctx, opts, command, planCmd, graphCmd, targetTables, excludeTables, truncateOpts)
+		return
+	}
+
+	// Clients are shared by all databases and closed at the end.
+	clientOpts, err := clientOptions(ctx, opts)
+	if err != nil {
+		exitf("ERROR: %s\n", err.Error())
+	}
+	pool := newClientPool(opts.ProjectID, opts.InstanceID, sessionPoolConfig(opts), clientOpts)
+	defer pool.close()
+
+	if opts.SchemaOut != "" {
+		client, err := pool.client(ctx, opts.DatabaseID)
+		if err != nil {
+			exitf("ERROR: failed to create Cloud Spanner client: %v\n", err)
+		}
+		snapshot, err := truncate.FetchSchemaSnapshot(ctx, client, truncateOpts...)
+		if err != nil {
+			exitf("ERROR: %s\n", err.Error())
+		}
+		if err := writeSchemaSnapshot(opts.SchemaOut, snapshot); err != nil {
+			exitf("ERROR: failed to write schema snapshot: %v\n", err)
+		}
+	}
+	if command == "graph" {
+		client, err := pool.client(ctx, opts.DatabaseID)
+		if err != nil {
+			exitf("ERROR: failed to create Cloud Spanner client: %v\n", err)
+		}
+		if err := writeGraph(ctx, client, graphCmd, targetTables, excludeTables, truncateOpts...); err != nil {
+			exitf("ERROR: %s\n", err.Error())
+		}
+		return
+	}
+	run := func(ctx context.Context, databaseID string, out io.Writer, extraOpts ...truncate.Option) error {
+		client, err := pool.client(ctx, databaseID)
+		if err != nil {
+			return fmt.Errorf("failed to create Cloud Spanner client: %v", err)
+		}
+		runOpts := append([]truncate.Option{truncate.WithOut(out), truncate.WithTables(targetTables...), truncate.WithExcludeTables(excludeTables...)}, truncateOpts...)
+		if opts.Quiet || opts.Silent {
+			runOpts = append(runOpts, truncate.WithQuiet())
+		}
+		// Fall back to the admin client created by the run itself if it can't be created here.
+		if adminClient, err := pool.adminClient(ctx); err == nil {
+			runOpts = append(runOpts, truncate.WithAdminClient(adminClient))
+		}
+		return truncate.RunWithClient(ctx, client, append(runOpts, extraOpts...)...)
+	}
+
+	if len(databaseIDs) == 1 {
+		if err := run(ctx, opts.DatabaseID, out); err != nil {
+			exitf("ERROR: %s", describeError(ctx, err))
+		}
+	} else {
+		errs := runDatabases(ctx, databaseIDs, opts.DBConcurrency, opts.ContinueOnError, out, run)
+		if len(errs) > 0 {
+			var msgs []string
+			for _, databaseID := range databaseIDs {
+				if err, ok := errs[databaseID]; ok {
+					msgs = append(msgs, fmt.Sprintf("  %s: %s", databaseID, describeError(ctx, err)))
+				}
+			}
+			exitf("ERROR: %d of %d databases failed:\n%s\n", len(errs), len(databaseIDs), strings.Join(msgs, "\n"))
+		}
+	}
+	if reportErr != nil {
+		exitf("ERROR: failed to write report: %v\n", reportErr)
+	}
+	if planErr != nil {
+		exitf("ERROR: failed to write plan: %v\n", planErr)
+	}
+	if command == "plan" {
+		fmt.Printf("Plan has been written to %s. Run apply --plan %s to execute it.\n", planCmd.Output, planCmd.Output)
+	}
+
+	if opts.Silent {
+		fmt.Println(silentResult(strings.Join(databaseIDs, ", "), reports))
+	}
+}
+
+func listDatabases(opts options) {
+	if opts.ProjectID == "" || opts.InstanceID == "" {
+		exitf("Missing options: -p, -i are required.\n")
+	}
+
+	ctx, cancelTimeout := context.WithTimeout(context.Background(), opts.Timeout)
+	defer cancelTimeout()
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+	go handleInterrupt(cancel)
+
+	clientOpts, err := clientOptions(ctx, opts)
+	if err != nil {
+		exitf("ERROR: %s\n", err.Error())
+	}
+	if err := truncate.ListDatabases(ctx, opts.ProjectID, opts.InstanceID, os.Stdout, truncate.WithClientOptions(clientOpts...)); err != nil {
+		exitf("ERROR: %s\n", err.Error())
 	}
 }
 
@@ -77,9 +704,72 @@ func exitf(format string, a ...interface{}) {
 	os.Exit(1)
 }
 
-func handleInterrupt(cancel context.CancelFunc) {
+// forceExitWindow is how long a second interrupt exits immediately, after the first one cancelled the run.
+const forceExitWindow = 5 * time.Second
+
+// errInterrupted is the cause of the cancellation by an interrupt, e.g. Ctrl-C.
+var errInterrupted = errors.New("interrupted")
+
+// handleInterrupt cancels the run gracefully on an interrupt, so that the run reports which tables have been deleted
+// and which were being deleted. Another interrupt within forceExitWindow exits immediately without waiting for it.
+func handleInterrupt(cancel context.CancelCauseFunc) {
 	c := make(chan os.Signal, 1)
 	signal.Notify(c, os.Interrupt)
-	<-c
-	cancel()
+	var deadline time.Time
+	for range c {
+		if time.Now().Before(deadline) {
+			fmt.Fprintf(os.Stderr, "\nInterrupted again, exiting without waiting for the deletions in flight to stop.\n")
+			os.Exit(130)
+		}
+		cancel(errInterrupted)
+		deadline = time.Now().Add(forceExitWindow)
+		fmt.Fprintf(os.Stderr, "\nInterrupted, cancelling the run. Press Ctrl-C again within %s to exit immediately.\n", forceExitWindow)
+	}
+}
+
+// describeError describes the error of a run, telling that the run was interrupted if it was.
+func describeError(ctx context.Context, err error) string {
+	if errors.Is(context.Cause(ctx), errInterrupted) {
+		return fmt.Sprintf("interrupted, the run was cancelled: %v", err)
+	}
+	return err.Error()
+}
+
+// cloudPlatformScope is the OAuth scope of impersonated credentials, which covers Cloud Spanner APIs.
+const cloudPlatformScope = "https://www.googleapis.com/auth/cloud-platform"
+
+// clientOptions returns options for the Cloud Spanner clients created by the command.
+func clientOptions(ctx context.Context, opts options) ([]option.ClientOption, error) {
+	var clientOpts []option.ClientOption
+	if opts.CredentialsFile != "" {
+		clientOpts = append(clientOpts, option.WithCredentialsFile(opts.CredentialsFile))
+	}
+	if opts.Impersonate != "" {
+		// The credentials file, or Application Default Credentials, are used to impersonate the service account.
+		ts, err := impersonate.CredentialsTokenSource(ctx, impersonate.CredentialsConfig{
+			TargetPrincipal: opts.Impersonate,
+			Scopes:          []string{cloudPlatformScope},
+		}, clientOpts...)
+		if err != nil {
+			return nil, fmt.Errorf("failed to impersonate %s: %v", opts.Impersonate, err)
+		}
+		clientOpts = []option.ClientOption{option.WithTokenSource(ts)}
+	}
+	if opts.Endpoint != "" {
+		clientOpts = append(clientOpts, option.WithEndpoint(opts.Endpoint))
+	}
+	if opts.BillingProject != "" {
+		clientOpts = append(clientOpts, option.WithQuotaProject(opts.BillingProject))
+	}
+	if opts.GRPCChannels > 0 {
+		clientOpts = append(clientOpts, option.WithGRPCConnectionPool(opts.GRPCChannels))
+	}
+	if opts.KeepaliveTime > 0 {
+		clientOpts = append(clientOpts, option.WithGRPCDialOption(grpc.WithKeepaliveParams(keepalive.ClientParameters{
+			Time:                opts.KeepaliveTime,
+			Timeout:             opts.KeepaliveTimeout,
+			PermitWithoutStream: true,
+		})))
+	}
+	return clientOpts, nil
 }
@@ -0,0 +1,58 @@
+//
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+
+	"github.com/cloudspannerecosystem/spanner-truncate/truncate"
+)
+
+// planCommand is the options of the plan command.
+type planCommand struct {
+	Output string `short:"o" long:"output" required:"true" description:"Path to write the plan of deletion to in JSON."`
+}
+
+// applyCommand is the options of the apply command.
+type applyCommand struct {
+	Plan string `long:"plan" required:"true" description:"Path to the plan written by the plan command."`
+}
+
+// readPlan reads a plan written by the plan command.
+func readPlan(path string) (*truncate.Plan, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var plan truncate.Plan
+	if err := json.Unmarshal(b, &plan); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %v", path, err)
+	}
+	return &plan, nil
+}
+
+// writePlan writes the plan to the file in JSON.
+func writePlan(path string, plan *truncate.Plan) error {
+	b, err := json.MarshalIndent(plan, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, append(b, '\n'), 0644)
+}
@@ -0,0 +1,88 @@
+//
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+
+	"github.com/cloudspannerecosystem/spanner-truncate/truncate"
+)
+
+// readReport reads a report written by a previous run.
+func readReport(path string) (*truncate.Report, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var report truncate.Report
+	if err := json.Unmarshal(b, &report); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %v", path, err)
+	}
+	return &report, nil
+}
+
+// writeReport writes the report to the file in JSON.
+func writeReport(path string, report *truncate.Report) error {
+	b, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, append(b, '\n'), 0644)
+}
+
+// countSkippedTables returns the number of tables skipped before or during the deletion in the reports.
+func countSkippedTables(reports []*truncate.Report) int {
+	var n int
+	for _, report := range reports {
+		n += len(report.SkippedTables)
+		for _, t := range report.Tables {
+			if t.Status == "skipped" {
+				n++
+			}
+		}
+	}
+	return n
+}
+
+// silentResult returns the only line printed in the silent mode, describing what the runs did to the databases.
+// Warnings are discarded in the silent mode, so it tells whether some tables were skipped.
+func silentResult(databases string, reports []*truncate.Report) string {
+	if len(reports) == 0 {
+		return fmt.Sprintf("Done! %s has been processed.", databases)
+	}
+	report := reports[0]
+	if report.Status == "dry_run" {
+		return fmt.Sprintf("Dry run completed for %s. No rows were deleted.", databases)
+	}
+	skipped := countSkippedTables(reports)
+	result := "successfully"
+	if skipped > 0 {
+		result = fmt.Sprintf("except for %d skipped tables", skipped)
+	}
+	switch {
+	case report.Operation == "scrub":
+		return fmt.Sprintf("Done! Columns have been scrubbed in %s %s.", databases, result)
+	case report.Operation == "delete_filtered":
+		return fmt.Sprintf("Done! Only %s have been deleted from %s %s.", report.RowFilter, databases, result)
+	case skipped > 0:
+		return fmt.Sprintf("Done! Rows have been deleted from %s %s.", databases, result)
+	}
+	return fmt.Sprintf("Done! All rows have been deleted from %s %s.", databases, result)
+}
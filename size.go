@@ -0,0 +1,65 @@
+//
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// byteUnits is multipliers of the units accepted by parseSizeLimit.
+var byteUnits = map[string]int64{
+	"B":   1,
+	"KB":  1000,
+	"MB":  1000 * 1000,
+	"GB":  1000 * 1000 * 1000,
+	"TB":  1000 * 1000 * 1000 * 1000,
+	"KIB": 1 << 10,
+	"MIB": 1 << 20,
+	"GIB": 1 << 30,
+	"TIB": 1 << 40,
+}
+
+// parseSizeLimit parses a size given to --skip-larger-than.
+// A plain number or a number suffixed with "rows" is a number of rows, and a number suffixed with a byte unit,
+// e.g. "10GiB" or "500MB", is a number of bytes.
+func parseSizeLimit(s string) (n int64, isBytes bool, err error) {
+	upper := strings.ToUpper(strings.TrimSpace(s))
+	i := strings.IndexFunc(upper, func(r rune) bool { return r < '0' || r > '9' })
+	if i == 0 {
+		return 0, false, fmt.Errorf("invalid size %q: must start with a number", s)
+	}
+	number, unit := upper, ""
+	if i > 0 {
+		number, unit = upper[:i], strings.TrimSpace(upper[i:])
+	}
+
+	n, err = strconv.ParseInt(number, 10, 64)
+	if err != nil {
+		return 0, false, fmt.Errorf("invalid size %q: %v", s, err)
+	}
+	switch unit {
+	case "", "ROWS":
+		return n, false, nil
+	}
+	m, ok := byteUnits[unit]
+	if !ok {
+		return 0, false, fmt.Errorf("invalid size %q: unknown unit %q", s, unit)
+	}
+	return n * m, true, nil
+}
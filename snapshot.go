@@ -0,0 +1,82 @@
+//
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	"github.com/cloudspannerecosystem/spanner-truncate/truncate"
+)
+
+// readSchemaSnapshot reads a schema snapshot written with --schema-out.
+func readSchemaSnapshot(path string) (*truncate.SchemaSnapshot, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var snapshot truncate.SchemaSnapshot
+	if err := json.Unmarshal(b, &snapshot); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %v", path, err)
+	}
+	return &snapshot, nil
+}
+
+// writeSchemaSnapshot writes the schema snapshot to the file in JSON.
+func writeSchemaSnapshot(path string, snapshot *truncate.SchemaSnapshot) error {
+	b, err := json.MarshalIndent(snapshot, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, append(b, '\n'), 0644)
+}
+
+// planFromSchemaSnapshot runs the plan or graph command against the schema snapshot given with --schema-in,
+// without creating any clients.
+func planFromSchemaSnapshot(ctx context.Context, opts options, command string, planCmd planCommand, graphCmd graphCommand, targetTables, excludeTables []string, truncateOpts []truncate.Option) {
+	snapshot, err := readSchemaSnapshot(opts.SchemaIn)
+	if err != nil {
+		exitf("Failed to read schema snapshot: %v\n", err)
+	}
+	if !strings.HasSuffix(snapshot.Database, "/databases/"+opts.DatabaseID) {
+		exitf("Invalid options: the schema snapshot in %s is of %s, not %s.\n", opts.SchemaIn, snapshot.Database, opts.DatabaseID)
+	}
+	truncateOpts = append(truncateOpts, truncate.WithSchemaSnapshot(snapshot))
+
+	if command == "graph" {
+		if err := writeGraph(ctx, nil, graphCmd, targetTables, excludeTables, truncateOpts...); err != nil {
+			exitf("ERROR: %s\n", err.Error())
+		}
+		return
+	}
+	plan, err := truncate.PlanDeletion(ctx, nil, targetTables, excludeTables, truncateOpts...)
+	if err != nil {
+		exitf("ERROR: %s\n", err.Error())
+	}
+	fmt.Printf("Planned against the schema snapshot of %s taken at %s, without row counts.\n", snapshot.Database, snapshot.GeneratedAt.Format("2006-01-02 15:04:05 MST"))
+	for i, wave := range plan.Waves {
+		fmt.Printf("  Wave %d: %s\n", i+1, strings.Join(wave, ", "))
+	}
+	if err := writePlan(planCmd.Output, plan); err != nil {
+		exitf("ERROR: failed to write plan: %v\n", err)
+	}
+	fmt.Printf("Plan has been written to %s. Run apply --plan %s to execute it.\n", planCmd.Output, planCmd.Output)
+}
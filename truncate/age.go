@@ -0,0 +1,121 @@
+//
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package truncate
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/spanner"
+)
+
+// timestampColumn is the column by which the age of rows of a table is decided, and the age overriding WithOlderThan.
+type timestampColumn struct {
+	column string
+	age    time.Duration
+}
+
+// WithOlderThan deletes only the rows older than age from each table, e.g. to clean up stale rows instead of all rows.
+// The age of a row is taken from the commit timestamp column of the table, i.e. the TIMESTAMP column with
+// allow_commit_timestamp, or the column given by WithTimestampColumn. Tables with none or more than one of such
+// columns are skipped with warnings, along with their ancestors deleting them in cascade.
+// It can't be used with WithKeyPrefix or WithTenant.
+func WithOlderThan(age time.Duration) Option {
+	return func(o *options) {
+		o.olderThan = age
+	}
+}
+
+// WithTimestampColumn overrides the column deciding the age of rows of the table with WithOlderThan, and the age
+// if it's positive. The column may be any TIMESTAMP column, not necessarily a commit timestamp column.
+func WithTimestampColumn(tableName, column string, age time.Duration) Option {
+	return func(o *options) {
+		o.timestampColumns[tableName] = timestampColumn{column: column, age: age}
+	}
+}
+
+// fetchCommitTimestampColumns returns the names of the commit timestamp columns keyed by the table name.
+func fetchCommitTimestampColumns(ctx context.Context, client *spanner.Client, scope schemaScope) (map[string][]string, error) {
+	iter := client.Single().Query(ctx, spanner.Statement{
+		SQL: `
+		SELECT TABLE_SCHEMA, TABLE_NAME, COLUMN_NAME FROM INFORMATION_SCHEMA.COLUMN_OPTIONS
+		WHERE TABLE_CATALOG = '' AND OPTION_NAME = 'allow_commit_timestamp' AND OPTION_VALUE = 'TRUE'
+		AND (@all OR TABLE_SCHEMA IN UNNEST(@schemas))
+		ORDER BY TABLE_SCHEMA, TABLE_NAME, COLUMN_NAME;
+	`,
+		Params: scope.params(),
+	})
+
+	columns := map[string][]string{}
+	if err := iter.Do(func(r *spanner.Row) error {
+		var schema, tableName, column string
+		if err := r.Columns(&schema, &tableName, &column); err != nil {
+			return err
+		}
+		name := qualifyName(schema, tableName)
+		columns[name] = append(columns[name], column)
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	return columns, nil
+}
+
+// ageFilters returns filters restricting the tables to the rows older than the cutoffs from now, keyed by the table
+// name, and the reasons of tables which can't be filtered as they have no single timestamp column.
+// Table names of the overrides are qualified with the schema if they aren't.
+func ageFilters(schemas []*tableSchema, commitTimestamps map[string][]string, age time.Duration, overrides map[string]timestampColumn, defaultSchema string, now time.Time) (map[string]*rowFilter, map[string]string) {
+	qualified := make(map[string]timestampColumn, len(overrides))
+	for name, override := range overrides {
+		qualified[qualifyNames(defaultSchema, []string{name})[0]] = override
+	}
+
+	filters := map[string]*rowFilter{}
+	reasons := map[string]string{}
+	for _, schema := range schemas {
+		column, tableAge := "", age
+		override, ok := qualified[schema.tableName]
+		if ok {
+			column = override.column
+			if override.age > 0 {
+				tableAge = override.age
+			}
+		}
+		if column == "" {
+			switch columns := commitTimestamps[schema.tableName]; len(columns) {
+			case 0:
+				reasons[schema.tableName] = "it has no commit timestamp column to decide the age of rows"
+				continue
+			case 1:
+				column = columns[0]
+			default:
+				reasons[schema.tableName] = fmt.Sprintf("it has multiple commit timestamp columns %s, specify one of them", strings.Join(columns, ", "))
+				continue
+			}
+		}
+		filters[schema.tableName] = ageFilter(column, now.Add(-tableAge))
+	}
+	return filters, reasons
+}
+
+// ageFilter returns a filter matching rows whose timestamp column is older than the cutoff.
+// The cutoff is embedded as a literal rather than a parameter, as cutoffs of tables may differ.
+func ageFilter(column string, cutoff time.Time) *rowFilter {
+	return &rowFilter{condition: fmt.Sprintf("%s < TIMESTAMP '%s'", quoteIdentifier(column), cutoff.UTC().Format("2006-01-02 15:04:05.999999-07:00"))}
+}
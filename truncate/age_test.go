@@ -0,0 +1,70 @@
+//
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package truncate
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestAgeFilters(t *testing.T) {
+	schemas := []*tableSchema{
+		{tableName: "Users"},
+		{tableName: "Events"},
+		{tableName: "Sessions"},
+		{tableName: "Logs"},
+		{tableName: "Audits"},
+	}
+	commitTimestamps := map[string][]string{
+		"Users":    {"UpdatedAt"},
+		"Events":   {"CreatedAt", "UpdatedAt"},
+		"Sessions": {"UpdatedAt"},
+		"Audits":   {"CreatedAt", "UpdatedAt"},
+	}
+	overrides := map[string]timestampColumn{
+		"Sessions": {age: time.Hour},
+		"Audits":   {column: "CreatedAt"},
+	}
+	now := time.Date(2021, 3, 31, 12, 0, 0, 0, time.FixedZone("JST", 9*60*60))
+
+	filters, reasons := ageFilters(schemas, commitTimestamps, 30*24*time.Hour, overrides, "", now)
+	wantFilters := map[string]*rowFilter{
+		"Users":    {condition: "`UpdatedAt` < TIMESTAMP '2021-03-01 03:00:00+00:00'"},
+		"Sessions": {condition: "`UpdatedAt` < TIMESTAMP '2021-03-31 02:00:00+00:00'"},
+		"Audits":   {condition: "`CreatedAt` < TIMESTAMP '2021-03-01 03:00:00+00:00'"},
+	}
+	if diff := cmp.Diff(filters, wantFilters, cmp.AllowUnexported(rowFilter{})); diff != "" {
+		t.Errorf("filters mismatch (-got +want):\n%s", diff)
+	}
+	wantReasons := map[string]string{
+		"Events": "it has multiple commit timestamp columns CreatedAt, UpdatedAt, specify one of them",
+		"Logs":   "it has no commit timestamp column to decide the age of rows",
+	}
+	if diff := cmp.Diff(reasons, wantReasons); diff != "" {
+		t.Errorf("reasons mismatch (-got +want):\n%s", diff)
+	}
+}
+
+func TestAgeFilter(t *testing.T) {
+	cutoff := time.Date(2021, 3, 1, 3, 4, 5, 678000, time.UTC)
+	want := "`Created At` < TIMESTAMP '2021-03-01 03:04:05.000678+00:00'"
+	if got := ageFilter("Created At", cutoff).condition; got != want {
+		t.Errorf("ageFilter() = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,82 @@
+//
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package truncate
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	adminapi "cloud.google.com/go/spanner/admin/database/apiv1"
+	"google.golang.org/api/option"
+	adminpb "google.golang.org/genproto/googleapis/spanner/admin/database/v1"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+const permissionCreateBackup = "spanner.databases.createBackup"
+
+// WithBackup creates a backup of the database, which expires after the retention, before any rows are deleted.
+// The run fails without deleting anything if the backup can't be created.
+func WithBackup(retention time.Duration) Option {
+	return func(o *options) {
+		o.backupRetention = retention
+	}
+}
+
+// backupID returns the ID of the backup of the database taken at now, e.g. "mydb-truncate-20210601-120000".
+func backupID(database string, now time.Time) string {
+	databaseID := database[strings.LastIndex(database, "/")+1:]
+	return fmt.Sprintf("%s-truncate-%s", databaseID, now.UTC().Format("20060102-150405"))
+}
+
+// createBackup creates a backup of the database expiring after the retention and waits for its completion,
+// which may take a while for a large database.
+// If adminClient is nil, a new admin client is created with clientOpts and closed on return.
+func createBackup(ctx context.Context, adminClient *adminapi.DatabaseAdminClient, out io.Writer, database string, retention time.Duration, clientOpts ...option.ClientOption) (string, error) {
+	if adminClient == nil {
+		var err error
+		adminClient, err = adminapi.NewDatabaseAdminClient(ctx, clientOpts...)
+		if err != nil {
+			return "", fmt.Errorf("failed to create Cloud Spanner admin client: %v", err)
+		}
+		defer adminClient.Close()
+	}
+
+	now := time.Now()
+	instance := database[:strings.Index(database, "/databases/")]
+	id := backupID(database, now)
+	fmt.Fprintf(out, "\nCreating backup %s, which expires at %s. This may take a while...\n", id, now.Add(retention).Format(time.RFC3339))
+	op, err := adminClient.CreateBackup(ctx, &adminpb.CreateBackupRequest{
+		Parent:   instance,
+		BackupId: id,
+		Backup: &adminpb.Backup{
+			Database:   database,
+			ExpireTime: timestamppb.New(now.Add(retention)),
+		},
+	})
+	if err != nil {
+		return "", err
+	}
+	backup, err := op.Wait(ctx)
+	if err != nil {
+		return "", err
+	}
+	fmt.Fprintf(out, "Backup %s has been created.\n", backup.GetName())
+	return backup.GetName(), nil
+}
@@ -0,0 +1,30 @@
+//
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package truncate
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBackupID(t *testing.T) {
+	now := time.Date(2021, 6, 1, 21, 0, 0, 0, time.FixedZone("JST", 9*60*60))
+	got := backupID("projects/p/instances/i/databases/mydb", now)
+	if want := "mydb-truncate-20210601-120000"; got != want {
+		t.Errorf("backupID() = %q, but want %q", got, want)
+	}
+}
@@ -0,0 +1,115 @@
+//
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package truncate
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/spanner"
+)
+
+// maxBatchedCountTables is the maximum number of tables counted by a single query in batched row count mode.
+const maxBatchedCountTables = 50
+
+// WithBatchedRowCounts counts rows of all tables with a few combined queries, each counting up to 50 tables with
+// UNION ALL, instead of a COUNT(*) query for each table, which generates hundreds of concurrent queries on databases
+// with hundreds of tables. The queries are repeated with pauses ten times as long as they took, as well as the
+// per-table counts. If a combined query fails, its tables are counted one by one for that round.
+func WithBatchedRowCounts() Option {
+	return func(o *options) {
+		o.batchedRowCounts = true
+	}
+}
+
+// runBatchedRowCounts periodically counts rows of the deleters in batches until they are all done or no longer counted,
+// or the context is done.
+func runBatchedRowCounts(ctx context.Context, client *spanner.Client, limiter *rateLimiter, reqOpts requestOptions, deleters []*deleter) {
+	for {
+		var pending []*deleter
+		for _, d := range deleters {
+			if !d.isDone() && !d.uncounted {
+				pending = append(pending, d)
+			}
+		}
+		if len(pending) == 0 {
+			return
+		}
+
+		begin := time.Now()
+		for len(pending) > 0 {
+			batch := pending[:min(len(pending), maxBatchedCountTables)]
+			pending = pending[len(batch):]
+			if err := limiter.wait(ctx); err != nil {
+				return
+			}
+			if err := countRowsBatch(ctx, client, reqOpts, batch); err != nil {
+				if ctx.Err() != nil {
+					return
+				}
+				// The tables are counted one by one, so that the failure is reported on the table causing it.
+				for _, d := range batch {
+					if err := d.updateRowCount(ctx); err != nil && ctx.Err() == nil {
+						d.handleCountError(err)
+					}
+				}
+			}
+		}
+
+		// Sleep for a while to minimize the impact on CPU usage caused by SELECT COUNT(*) queries.
+		time.Sleep(time.Since(begin) * 10)
+	}
+}
+
+// countRowsBatch counts rows of the deleters with a single query, and updates their row counts.
+func countRowsBatch(ctx context.Context, client *spanner.Client, reqOpts requestOptions, deleters []*deleter) error {
+	stmt := batchedCountStatement(deleters)
+	counts := make([]int64, len(deleters))
+
+	// Use stale read to minimize the impact on the leader replica.
+	txn := client.Single().WithTimestampBound(spanner.ExactStaleness(time.Second))
+	if err := txn.QueryWithOptions(ctx, stmt, reqOpts.query()).Do(func(r *spanner.Row) error {
+		var i, count int64
+		if err := r.Columns(&i, &count); err != nil {
+			return err
+		}
+		counts[i] = count
+		return nil
+	}); err != nil {
+		return err
+	}
+	for i, d := range deleters {
+		orDiscard(d.logger).Debug("counted rows", "rows", counts[i])
+		d.setRowCount(counts[i])
+	}
+	return nil
+}
+
+// batchedCountStatement returns a query counting rows of the deleters' tables, which returns the index of each table
+// in the deleters along with its count.
+func batchedCountStatement(deleters []*deleter) spanner.Statement {
+	selects := make([]string, len(deleters))
+	stmt := spanner.Statement{}
+	for i, d := range deleters {
+		selects[i] = fmt.Sprintf("SELECT %d AS i, COUNT(*) AS count FROM %s%s", i, quoteIdentifier(d.tableName), d.filter.where())
+		stmt = d.filter.withParams(stmt)
+	}
+	stmt.SQL = strings.Join(selects, " UNION ALL ")
+	return stmt
+}
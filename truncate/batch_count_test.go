@@ -0,0 +1,29 @@
+//
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package truncate
+
+import (
+	"testing"
+)
+
+func TestBatchedCountStatement(t *testing.T) {
+	deleters := []*deleter{{tableName: "Singers"}, {tableName: "analytics.Events"}}
+	want := "SELECT 0 AS i, COUNT(*) AS count FROM `Singers` UNION ALL SELECT 1 AS i, COUNT(*) AS count FROM `analytics`.`Events`"
+	if got := batchedCountStatement(deleters).SQL; got != want {
+		t.Errorf("batchedCountStatement() = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,254 @@
+//
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package truncate
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"cloud.google.com/go/spanner"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	sppb "google.golang.org/genproto/googleapis/spanner/v1"
+)
+
+// WithBatchedDeletion deletes tables by repeatedly deleting up to batchRows rows in a read-write transaction, pausing
+// between batches, instead of Partitioned DML. Each transaction holds locks on only a batch of rows for a short time,
+// so live services reading the tables see less lock contention, at the cost of a much slower deletion.
+// The batch is capped by the mutation limit of a commit, and halved when a transaction turns out to be too large.
+// Tables deleted with custom statements, mutations or leaf rows first are deleted as before.
+func WithBatchedDeletion(batchRows int64, pause time.Duration) Option {
+	return func(o *options) {
+		o.batched = true
+		WithBatchSize(batchRows, pause)(o)
+	}
+}
+
+// WithBatchSize tunes the deletions in read-write transactions instead of Partitioned DML, i.e. batched deletion,
+// leaf rows first deletion and the fallback from Partitioned DML, without changing which tables are deleted so.
+// Each transaction deletes up to batchRows rows, and the deletion pauses for interval between transactions.
+// If batchRows is 0, a transaction deletes as many rows as a commit can hold.
+func WithBatchSize(batchRows int64, interval time.Duration) Option {
+	return func(o *options) {
+		o.batchRows = batchRows
+		o.batchPause = interval
+	}
+}
+
+// WithoutBatchedFallback fails tables whose Partitioned DML statement is rejected as unsupported, or keeps failing
+// with exhausted resources, instead of deleting them again in batches of read-write transactions.
+func WithoutBatchedFallback() Option {
+	return func(o *options) {
+		o.noBatchedFallback = true
+	}
+}
+
+// fallsBackToBatches returns true if the table should be deleted in batches after PDML failed with err.
+// Custom statements can't be run in batches, and strategies other than PDML don't fail the same way.
+func (d *deleter) fallsBackToBatches(ctx context.Context, err error) bool {
+	if err == nil || ctx.Err() != nil || d.noFallback {
+		return false
+	}
+	if d.byMutation || d.deletesLeavesFirst() || d.batched || d.statementBuilder != nil {
+		return false
+	}
+	return isPDMLUnsuitable(err)
+}
+
+// deleteRowsBatched deletes rows from the table in batches of read-write transactions until the table is empty.
+func (d *deleter) deleteRowsBatched(ctx context.Context) error {
+	keyColumns, err := fetchKeyColumns(ctx, d.client, d.tableName)
+	if err != nil {
+		return newTableError(d.tableName, OpDelete, "", fmt.Errorf("failed to fetch the primary key: %w", err))
+	}
+	batchRows := d.batchSize()
+	stmt := d.filter.withParams(batchKeysStatement(d.tableName, keyColumns, d.filter.and("true"), batchRows))
+	ctx = withRetryCounter(ctx, &d.retries)
+	defer d.beginOperation(OpDelete)()
+	var deleted int64
+	for {
+		if err := d.limiter.wait(ctx); err != nil {
+			return newTableError(d.tableName, OpDelete, stmt.SQL, err)
+		}
+		var count int64
+		attempts := 0
+		if _, err := d.client.ReadWriteTransactionWithOptions(ctx, func(ctx context.Context, txn *spanner.ReadWriteTransaction) error {
+			// The function is called again when the transaction is aborted.
+			if attempts++; attempts > 1 {
+				atomic.AddInt64(&d.retries.transactionAborts, 1)
+			}
+			var err error
+			count, err = deleteBatch(ctx, txn, d.tableName, keyColumns, stmt, d.reqOpts.query())
+			return err
+		}, d.reqOpts.transaction()); err != nil {
+			if isTransactionTooLarge(err) && batchRows > 1 && len(keyColumns) > 0 {
+				batchRows /= 2
+				stmt = d.filter.withParams(batchKeysStatement(d.tableName, keyColumns, d.filter.and("true"), batchRows))
+				orDiscard(d.logger).Debug("transaction too large, shrinking the batch", "rows", batchRows)
+				continue
+			}
+			return newTableError(d.tableName, OpDelete, stmt.SQL, err)
+		}
+		if count == 0 {
+			break
+		}
+		deleted += count
+		orDiscard(d.logger).Debug("batch deleted", "rows", count)
+		if err := d.pauseBatch(ctx); err != nil {
+			return newTableError(d.tableName, OpDelete, stmt.SQL, err)
+		}
+	}
+	orDiscard(d.logger).Info("batches deleted until empty", "rows", deleted)
+	trace.SpanFromContext(ctx).SetAttributes(attribute.Int64("truncate.deleted_rows", deleted))
+
+	if d.uncounted {
+		if d.totalRows == 0 {
+			d.totalRows = uint64(deleted)
+		}
+		d.remainedRows = 0
+		d.status = statusCompleted
+	}
+	return nil
+}
+
+// batchSize returns the maximum number of rows deleted in a read-write transaction, capped by the mutation limit of a commit.
+func (d *deleter) batchSize() int64 {
+	if d.batchRows > 0 {
+		return min(d.batchRows, d.leafBatchRows())
+	}
+	return d.leafBatchRows()
+}
+
+// pauseBatch waits for the pause between transactions, which lets transactions of live services take the locks
+// before the next batch.
+func (d *deleter) pauseBatch(ctx context.Context) error {
+	if d.batchPause <= 0 {
+		return nil
+	}
+	select {
+	case <-time.After(d.batchPause):
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// formatBatchSettings describes the read-write transactions deleting rows instead of PDML,
+// e.g. "read-write transactions of up to 1,000 rows each, pausing 100ms between transactions".
+func formatBatchSettings(batchRows int64, interval time.Duration) string {
+	size := "as large as a commit can hold"
+	if batchRows > 0 {
+		size = fmt.Sprintf("of up to %s rows each", formatNumber(uint64(batchRows)))
+	}
+	pause := "without pauses"
+	if interval > 0 {
+		pause = fmt.Sprintf("pausing %s between transactions", interval)
+	}
+	return fmt.Sprintf("read-write transactions %s, %s", size, pause)
+}
+
+// batchKeysStatement returns a statement reading the primary keys of up to limit rows of the table matching the condition
+// on the table aliased as t. A table with an empty primary key has a single row at most, which is read without columns.
+// Only the rows read are locked and deleted in the transaction, unlike a DML statement picking rows by a subquery,
+// which scans and locks the whole table.
+func batchKeysStatement(tableName string, keyColumns []string, condition string, limit int64) spanner.Statement {
+	columns := "1"
+	if len(keyColumns) > 0 {
+		keys := make([]string, len(keyColumns))
+		for i, column := range keyColumns {
+			keys[i] = "t." + quoteIdentifier(column)
+		}
+		columns = strings.Join(keys, ", ")
+	}
+	return spanner.Statement{
+		SQL:    fmt.Sprintf("SELECT %s FROM %s AS t WHERE %s LIMIT @limit", columns, quoteIdentifier(tableName), condition),
+		Params: map[string]interface{}{"limit": limit},
+	}
+}
+
+// deleteBatch reads the primary keys of rows with the statement of batchKeysStatement in the transaction,
+// and buffers mutations deleting the rows. It returns the number of rows to be deleted by the commit.
+func deleteBatch(ctx context.Context, txn *spanner.ReadWriteTransaction, tableName string, keyColumns []string, stmt spanner.Statement, opts spanner.QueryOptions) (int64, error) {
+	var mutations []*spanner.Mutation
+	if err := txn.QueryWithOptions(ctx, stmt, opts).Do(func(r *spanner.Row) error {
+		key := make(spanner.Key, len(keyColumns))
+		for i := range keyColumns {
+			var v spanner.GenericColumnValue
+			if err := r.Column(i, &v); err != nil {
+				return err
+			}
+			part, err := keyPart(v)
+			if err != nil {
+				return fmt.Errorf("failed to read key column %s: %w", keyColumns[i], err)
+			}
+			key[i] = part
+		}
+		mutations = append(mutations, spanner.Delete(tableName, key))
+		return nil
+	}); err != nil {
+		return 0, err
+	}
+	if len(mutations) == 0 {
+		return 0, nil
+	}
+	if err := txn.BufferWrite(mutations); err != nil {
+		return 0, err
+	}
+	return int64(len(mutations)), nil
+}
+
+// keyPart decodes a value of a primary key column into a part of spanner.Key. Key columns may be NULL.
+func keyPart(v spanner.GenericColumnValue) (interface{}, error) {
+	switch v.Type.GetCode() {
+	case sppb.TypeCode_BOOL:
+		var part spanner.NullBool
+		err := v.Decode(&part)
+		return part, err
+	case sppb.TypeCode_INT64:
+		var part spanner.NullInt64
+		err := v.Decode(&part)
+		return part, err
+	case sppb.TypeCode_FLOAT64:
+		var part spanner.NullFloat64
+		err := v.Decode(&part)
+		return part, err
+	case sppb.TypeCode_STRING:
+		var part spanner.NullString
+		err := v.Decode(&part)
+		return part, err
+	case sppb.TypeCode_BYTES:
+		var part []byte
+		err := v.Decode(&part)
+		return part, err
+	case sppb.TypeCode_TIMESTAMP:
+		var part spanner.NullTime
+		err := v.Decode(&part)
+		return part, err
+	case sppb.TypeCode_DATE:
+		var part spanner.NullDate
+		err := v.Decode(&part)
+		return part, err
+	case sppb.TypeCode_NUMERIC:
+		var part spanner.NullNumeric
+		err := v.Decode(&part)
+		return part, err
+	}
+	return nil, fmt.Errorf("unsupported type %s", v.Type.GetCode())
+}
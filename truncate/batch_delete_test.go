@@ -0,0 +1,157 @@
+//
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package truncate
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/spanner"
+	"github.com/google/go-cmp/cmp"
+	sppb "google.golang.org/genproto/googleapis/spanner/v1"
+	"google.golang.org/grpc/codes"
+	grpcstatus "google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+func TestBatchKeysStatement(t *testing.T) {
+	for _, tt := range []struct {
+		desc       string
+		keyColumns []string
+		want       spanner.Statement
+	}{
+		{
+			desc:       "single key",
+			keyColumns: []string{"SessionId"},
+			want: spanner.Statement{
+				SQL:    "SELECT t.`SessionId` FROM `Sessions` AS t WHERE true LIMIT @limit",
+				Params: map[string]interface{}{"limit": int64(500)},
+			},
+		},
+		{
+			desc:       "composite key",
+			keyColumns: []string{"UserId", "SessionId"},
+			want: spanner.Statement{
+				SQL:    "SELECT t.`UserId`, t.`SessionId` FROM `Sessions` AS t WHERE true LIMIT @limit",
+				Params: map[string]interface{}{"limit": int64(500)},
+			},
+		},
+		{
+			desc:       "empty key",
+			keyColumns: nil,
+			want: spanner.Statement{
+				SQL:    "SELECT 1 FROM `Sessions` AS t WHERE true LIMIT @limit",
+				Params: map[string]interface{}{"limit": int64(500)},
+			},
+		},
+	} {
+		t.Run(tt.desc, func(t *testing.T) {
+			if diff := cmp.Diff(batchKeysStatement("Sessions", tt.keyColumns, "true", 500), tt.want); diff != "" {
+				t.Errorf("mismatch (-got +want):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestKeyPart(t *testing.T) {
+	value := func(code sppb.TypeCode, v *structpb.Value) spanner.GenericColumnValue {
+		return spanner.GenericColumnValue{Type: &sppb.Type{Code: code}, Value: v}
+	}
+	for _, tt := range []struct {
+		desc    string
+		value   spanner.GenericColumnValue
+		want    interface{}
+		wantErr bool
+	}{
+		{desc: "int64", value: intKey(42), want: spanner.NullInt64{Int64: 42, Valid: true}},
+		{desc: "string", value: value(sppb.TypeCode_STRING, structpb.NewStringValue("abc")), want: spanner.NullString{StringVal: "abc", Valid: true}},
+		{desc: "bytes", value: value(sppb.TypeCode_BYTES, structpb.NewStringValue("YWJj")), want: []byte("abc")},
+		{desc: "NULL", value: value(sppb.TypeCode_INT64, structpb.NewNullValue()), want: spanner.NullInt64{}},
+		{desc: "unsupported", value: value(sppb.TypeCode_ARRAY, structpb.NewListValue(&structpb.ListValue{})), wantErr: true},
+	} {
+		t.Run(tt.desc, func(t *testing.T) {
+			got, err := keyPart(tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("keyPart() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if diff := cmp.Diff(got, tt.want); diff != "" {
+				t.Errorf("mismatch (-got +want):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestFallsBackToBatches(t *testing.T) {
+	exhausted := grpcstatus.Error(codes.ResourceExhausted, "Out of memory")
+	for _, tt := range []struct {
+		desc    string
+		deleter *deleter
+		err     error
+		want    bool
+	}{
+		{desc: "PDML", deleter: &deleter{}, err: exhausted, want: true},
+		{desc: "sharded PDML", deleter: &deleter{shards: 4}, err: exhausted, want: true},
+		{desc: "succeeded", deleter: &deleter{}, err: nil, want: false},
+		{desc: "other error", deleter: &deleter{}, err: grpcstatus.Error(codes.PermissionDenied, "denied"), want: false},
+		{desc: "disabled", deleter: &deleter{noFallback: true}, err: exhausted, want: false},
+		{desc: "mutation", deleter: &deleter{byMutation: true}, err: exhausted, want: false},
+		{desc: "batch size only", deleter: &deleter{batchRows: 1000}, err: exhausted, want: true},
+		{desc: "already batched", deleter: &deleter{batched: true}, err: exhausted, want: false},
+		{desc: "custom statement", deleter: &deleter{statementBuilder: func(string) spanner.Statement { return spanner.Statement{} }}, err: exhausted, want: false},
+	} {
+		t.Run(tt.desc, func(t *testing.T) {
+			if got := tt.deleter.fallsBackToBatches(context.Background(), tt.err); got != tt.want {
+				t.Errorf("fallsBackToBatches() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBatchSize(t *testing.T) {
+	for _, tt := range []struct {
+		desc    string
+		deleter *deleter
+		want    int64
+	}{
+		{desc: "unlimited", deleter: &deleter{}, want: maxMutationsPerCommit},
+		{desc: "unlimited with indexes", deleter: &deleter{indexes: 3}, want: maxMutationsPerCommit / 4},
+		{desc: "limited", deleter: &deleter{batchRows: 500}, want: 500},
+		{desc: "limited by the commit", deleter: &deleter{batchRows: maxMutationsPerCommit, indexes: 1}, want: maxMutationsPerCommit / 2},
+	} {
+		t.Run(tt.desc, func(t *testing.T) {
+			if got := tt.deleter.batchSize(); got != tt.want {
+				t.Errorf("batchSize() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFormatBatchSettings(t *testing.T) {
+	for _, tt := range []struct {
+		batchRows int64
+		interval  time.Duration
+		want      string
+	}{
+		{batchRows: 1000, interval: 100 * time.Millisecond, want: "read-write transactions of up to 1,000 rows each, pausing 100ms between transactions"},
+		{batchRows: 0, interval: 0, want: "read-write transactions as large as a commit can hold, without pauses"},
+	} {
+		if got := formatBatchSettings(tt.batchRows, tt.interval); got != tt.want {
+			t.Errorf("formatBatchSettings(%d, %s) = %q, want %q", tt.batchRows, tt.interval, got, tt.want)
+		}
+	}
+}
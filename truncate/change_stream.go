@@ -0,0 +1,123 @@
+//
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package truncate
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+)
+
+// Retention period of change streams which don't set it explicitly.
+const defaultChangeStreamRetentionPeriod = "1d"
+
+// changeStreamEstimate is an estimate of change records which a change stream generates by the deletion.
+type changeStreamEstimate struct {
+	changeStreamName string
+
+	// Number of target tables watched by the change stream.
+	tableCount int
+
+	// Number of data change records, one per deleted row. Tables whose row counts are unknown are not included.
+	records int64
+
+	// Bytes of old values captured in the records. Nil if unknown, or if old values are not captured.
+	oldValueBytes *int64
+
+	retentionPeriod string
+}
+
+// estimateChangeStreams estimates change records generated by deleting rows from the tables for each change stream.
+// Change streams which don't watch any of the tables are omitted.
+func estimateChangeStreams(changeStreams []*changeStreamSchema, schemas []*tableSchema, rowCounts, tableBytes map[string]int64) []*changeStreamEstimate {
+	var estimates []*changeStreamEstimate
+	for _, cs := range changeStreams {
+		e := &changeStreamEstimate{
+			changeStreamName: cs.changeStreamName,
+			retentionPeriod:  cs.retentionPeriod,
+		}
+		if e.retentionPeriod == "" {
+			e.retentionPeriod = defaultChangeStreamRetentionPeriod
+		}
+
+		var bytes int64
+		bytesKnown := cs.capturesOldValues()
+		for _, schema := range schemas {
+			if !cs.watches(schema.tableName) {
+				continue
+			}
+			e.tableCount++
+			count, ok := rowCounts[schema.tableName]
+			if !ok {
+				bytesKnown = false
+				continue
+			}
+			e.records += count
+			if b, ok := tableBytes[schema.tableName]; ok {
+				bytes += b
+			} else if count > 0 {
+				bytesKnown = false
+			}
+		}
+		if e.tableCount == 0 {
+			continue
+		}
+		if bytesKnown {
+			e.oldValueBytes = &bytes
+		}
+		estimates = append(estimates, e)
+	}
+	return estimates
+}
+
+// capturesOldValues returns true if records of deleted rows contain old values of the rows.
+func (c *changeStreamSchema) capturesOldValues() bool {
+	switch strings.ToUpper(c.valueCaptureType) {
+	case "NEW_VALUES", "NEW_ROW":
+		return false
+	default:
+		// OLD_AND_NEW_VALUES, which is the default, and NEW_ROW_AND_OLD_VALUES capture old values.
+		return true
+	}
+}
+
+// printChangeStreamEstimates prints how many change records the deletion will generate for each change stream.
+func printChangeStreamEstimates(out io.Writer, estimates []*changeStreamEstimate) {
+	var lines []string
+	for _, e := range estimates {
+		if e.records == 0 {
+			continue
+		}
+		desc := fmt.Sprintf("~%s records from %d tables", formatNumber(uint64(e.records)), e.tableCount)
+		if e.oldValueBytes != nil {
+			desc += fmt.Sprintf(", ~%s of old values", formatBytes(*e.oldValueBytes))
+		}
+		lines = append(lines, fmt.Sprintf("  %s\t%s, retained for %s\n", e.changeStreamName, desc, e.retentionPeriod))
+	}
+	if len(lines) == 0 {
+		return
+	}
+
+	fmt.Fprintf(out, "\nChange streams will record every deleted row, and the records use storage until their retention period passes:\n")
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	for _, line := range lines {
+		fmt.Fprint(w, line)
+	}
+	w.Flush()
+	fmt.Fprintf(out, "Let owners of pipelines reading these change streams know before the deletion.\n")
+}
@@ -0,0 +1,80 @@
+//
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package truncate
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestEstimateChangeStreams(t *testing.T) {
+	schemas := []*tableSchema{
+		{tableName: "Singers"},
+		{tableName: "Albums", parentTableName: "Singers", parentOnDeleteAction: deleteActionCascadeDelete},
+		{tableName: "Concerts"},
+	}
+	rowCounts := map[string]int64{"Singers": 6000, "Albums": 1800}
+	tableBytes := map[string]int64{"Singers": 3 << 20, "Albums": 1 << 20}
+
+	changeStreams := []*changeStreamSchema{
+		{changeStreamName: "AllStream", all: true, retentionPeriod: "7d"},
+		{changeStreamName: "AlbumsStream", tableNames: []string{"Albums"}},
+		{changeStreamName: "KeysOnlyStream", tableNames: []string{"Singers"}, valueCaptureType: "NEW_VALUES"},
+		{changeStreamName: "OtherStream", tableNames: []string{"Venues"}},
+	}
+
+	albumsBytes := int64(1 << 20)
+	want := []*changeStreamEstimate{
+		{changeStreamName: "AllStream", tableCount: 3, records: 7800, retentionPeriod: "7d"},
+		{changeStreamName: "AlbumsStream", tableCount: 1, records: 1800, oldValueBytes: &albumsBytes, retentionPeriod: "1d"},
+		{changeStreamName: "KeysOnlyStream", tableCount: 1, records: 6000, retentionPeriod: "1d"},
+	}
+	got := estimateChangeStreams(changeStreams, schemas, rowCounts, tableBytes)
+	if !cmp.Equal(got, want, cmp.AllowUnexported(changeStreamEstimate{})) {
+		t.Errorf("diff(+got, -want) = %v", cmp.Diff(got, want, cmp.AllowUnexported(changeStreamEstimate{})))
+	}
+}
+
+func TestPrintChangeStreamEstimates(t *testing.T) {
+	bytes4MiB := int64(4 << 20)
+	estimates := []*changeStreamEstimate{
+		{changeStreamName: "AllStream", tableCount: 2, records: 7800, oldValueBytes: &bytes4MiB, retentionPeriod: "7d"},
+		{changeStreamName: "KeysOnlyStream", tableCount: 1, records: 6000, retentionPeriod: "1d"},
+		{changeStreamName: "EmptyStream", tableCount: 1, records: 0, retentionPeriod: "1d"},
+	}
+
+	var out bytes.Buffer
+	printChangeStreamEstimates(&out, estimates)
+
+	want := `
+Change streams will record every deleted row, and the records use storage until their retention period passes:
+  AllStream       ~7,800 records from 2 tables, ~4.0 MiB of old values, retained for 7d
+  KeysOnlyStream  ~6,000 records from 1 tables, retained for 1d
+Let owners of pipelines reading these change streams know before the deletion.
+`
+	if got := out.String(); got != want {
+		t.Errorf("diff(+got, -want) = %v", cmp.Diff(got, want))
+	}
+
+	out.Reset()
+	printChangeStreamEstimates(&out, nil)
+	if got := out.String(); got != "" {
+		t.Errorf("printChangeStreamEstimates(nil) printed %q, but want nothing", got)
+	}
+}
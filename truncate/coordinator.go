@@ -20,9 +20,15 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
 	"time"
 
 	"cloud.google.com/go/spanner"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc/codes"
 )
 
 // table is an element of the tree which represents inter-table relationships.
@@ -34,30 +40,78 @@ type table struct {
 	referencedBy         []*table
 	hasGlobalIndex       bool
 	deleter              *deleter
+
+	// Tables referencing the table only by foreign keys with ON DELETE CASCADE. They don't block the deletion of the
+	// table, but still receive their own DELETE statements, as rows whose foreign keys are NULL aren't deleted in cascade.
+	referencedInCascadeBy []*table
+
+	// Tables in the same cycle of foreign keys including the table, and ones of them referencing the table,
+	// when the cycle is broken by deleting them at the same time. Nil otherwise.
+	cycle               []*table
+	referencedInCycleBy []*table
+
+	// Whether post hooks have been started. Accessed only by the coordinator goroutine.
+	postHooksStarted bool
+
+	// Status of the table last emitted as an event. Guarded by transitionMu of the coordinator.
+	emittedStatus status
 }
 
 // isDeletable returns true if the table is ready to be deleted.
 func (t *table) isDeletable() bool {
+	if len(t.blockers()) > 0 {
+		return false
+	}
+	// Tables in a cycle wait for each other's progress, so they are deleted at the same time.
+	for _, other := range t.cycle {
+		if other != t && len(other.blockers()) > 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// blockers returns reasons why the table can't be deleted yet.
+func (t *table) blockers() []string {
+	var blockers []string
 	for _, child := range t.childTables {
 		if child.parentOnDeleteAction == deleteActionNoAction && child.deleter.status != statusCompleted {
-			return false
+			blockers = append(blockers, fmt.Sprintf("child %s with ON DELETE NO ACTION is not deleted yet", child.tableName))
 		}
 		// Partitioned DML may not work perfectly if a child of the target table has global indexes.
 		if child.hasGlobalIndex && child.deleter.status != statusCompleted {
-			return false
-		}
-		if !child.isDeletable() {
-			return false
+			blockers = append(blockers, fmt.Sprintf("child %s with global indexes is not deleted yet", child.tableName))
 		}
+		blockers = append(blockers, child.blockers()...)
 	}
 
 	for _, referencing := range t.referencedBy {
 		if referencing.deleter.status != statusCompleted {
-			return false
+			blockers = append(blockers, fmt.Sprintf("referencing table %s is not deleted yet", referencing.tableName))
 		}
 	}
 
-	return true
+	return blockers
+}
+
+// rowsToDelete returns the number of rows deleted with the table, including rows of descendants deleted in cascade.
+// It returns false if any of them hasn't been counted yet.
+func (t *table) rowsToDelete() (int64, bool) {
+	if !t.deleter.hasRowCount() {
+		return 0, false
+	}
+	rows := int64(t.deleter.remainedRows)
+	for _, child := range t.childTables {
+		if child.deleter.status == statusCompleted {
+			continue
+		}
+		n, ok := child.rowsToDelete()
+		if !ok {
+			return 0, false
+		}
+		rows += n
+	}
+	return rows, true
 }
 
 // constructTableTree creates a table tree which represents inter-table relationships.
@@ -90,6 +144,11 @@ func findDeletableTables(tables []*table) []*table {
 		if s := table.deleter.status; s == statusDeleting || s == statusCompleted {
 			continue
 		}
+		if s := table.deleter.status; s == statusFailed || s == statusSkipped {
+			// Child tables are not deleted in cascade, so they may be deleted by themselves.
+			deletable = append(deletable, findDeletableTables(table.childTables)...)
+			continue
+		}
 		if table.isDeletable() {
 			deletable = append(deletable, table)
 			// Parent table will be deleted, so child tables will be also deleted.
@@ -105,15 +164,73 @@ func findDeletableTables(tables []*table) []*table {
 	return deletable
 }
 
+// wave is a set of tables whose deletions are started at the same time.
+type wave struct {
+	id        int
+	tables    []*table
+	completed bool
+	span      trace.Span // Ended when the wave is completed.
+}
+
 // coordinator initiates deleting rows from tables without violating database constraints.
 type coordinator struct {
 	tables  []*table
 	errChan chan error
+	emitter *emitter
+
+	// If true, a failure of a table doesn't abort the run, and independent tables keep going.
+	continueOnError bool
+
+	// If true, tables which the caller lacks permissions on are skipped instead of failed.
+	skipUnauthorized bool
+	warnings         *warnings
+	logger           *slog.Logger
+
+	// Tables with at most this number of rows, including descendants, are deleted with a mutation. Disabled if 0.
+	mutationMaxRows int64
+
+	// Tables with at least shardMinRows rows, including descendants, are deleted with this number of statements
+	// concurrently. Disabled if less than 2.
+	shardMinRows int64
+	shards       int
+
+	// If true, tables are deleted in batches of read-write transactions instead of PDML.
+	batched bool
+
+	// Maximum number of tables deleted at the same time. Unlimited if 0.
+	maxConcurrentDeletes int
+
+	// Estimates the progress of tables from statistics instead of counting rows if set.
+	statsProgress *statsProgress
+
+	// If true, rows of tables are counted by combined queries instead of a query for each table.
+	batchedRowCounts bool
+	client           *spanner.Client
+	limiter          *rateLimiter
+	reqOpts          requestOptions
+
+	// Records durations of table deletions if set.
+	metrics *databaseMetrics
+
+	// Closed when the coordinator finished with tables left undeleted because of skipped tables.
+	finished chan struct{}
+
+	// Decides what to do on a table failure if set. Calls are serialized by failureMu.
+	failureHandler FailureHandler
+	failureMu      sync.Mutex
+
+	mu       sync.Mutex // Guards waves and failures.
+	waves    []*wave
+	failures []error
+
+	// Serializes checks of table transitions, which run on both the coordinator goroutine and waitCompleted.
+	transitionMu sync.Mutex
 }
 
-func newCoordinator(schemas []*tableSchema, indexes []*indexSchema, client *spanner.Client) (*coordinator, error) {
+func newCoordinator(schemas []*tableSchema, indexes []*indexSchema, client *spanner.Client, warnings *warnings, emitter *emitter, opts *options) (*coordinator, error) {
 	var tables []*table
 	tableMap := map[string]*table{}
+	limiter := newRateLimiter(opts.maxQPS)
 	for _, schema := range schemas {
 		t := &table{
 			tableName:            schema.tableName,
@@ -122,6 +239,23 @@ func newCoordinator(schemas []*tableSchema, indexes []*indexSchema, client *span
 			deleter: &deleter{
 				tableName: schema.tableName,
 				client:    client,
+				warnings:  warnings,
+
+				statementBuilder: opts.statementBuilders[schema.tableName],
+				preHooks:         opts.preHooks[schema.tableName],
+				postHooks:        opts.postHooks[schema.tableName],
+				skipUnauthorized: opts.skipUnauthorized,
+				limiter:          limiter,
+				reqOpts:          opts.requestOptions,
+				batchRows:        opts.batchRows,
+				batchPause:       opts.batchPause,
+				noFallback:       opts.noBatchedFallback,
+				filter:           opts.rowFilters[schema.tableName],
+				uncounted:        opts.noRowCounts,
+				countMaxRows:     opts.countMaxRows,
+				retryPolicy:      opts.deleteRetries,
+				timeout:          opts.tableTimeout,
+				logger:           orDiscard(opts.logger).With("table", schema.tableName),
 			},
 			referencedBy: []*table{},
 		}
@@ -130,24 +264,46 @@ func newCoordinator(schemas []*tableSchema, indexes []*indexSchema, client *span
 	}
 
 	// Construct FK reference relationships.
+	// Tables out of the list, e.g. excluded ones, can't be waited for, so their references are reported all at once.
+	var external []string
 	for _, schema := range schemas {
-		if len(schema.referencedBy) == 0 {
-			continue
-		}
-
 		table := tableMap[schema.tableName]
 		for _, referencing := range schema.referencedBy {
-			if _, ok := tableMap[referencing]; !ok {
-				return nil, fmt.Errorf("%s is referenced by %s, but %s is not in the table list", schema.tableName, referencing, referencing)
+			r, ok := tableMap[referencing]
+			switch {
+			case !ok:
+				external = append(external, fmt.Sprintf("%s is referenced by %s", schema.tableName, referencing))
+			case referencing == schema.tableName && !schema.isReferencedInCascade(referencing):
+				// A table never waits for itself, but deletes rows which aren't referenced by other rows first.
+				table.deleter.leafReferences = schema.selfReferences()
+			case schema.isReferencedInCascade(referencing):
+				table.referencedInCascadeBy = append(table.referencedInCascadeBy, r)
+			default:
+				table.referencedBy = append(table.referencedBy, r)
 			}
-			table.referencedBy = append(table.referencedBy, tableMap[referencing])
+		}
+	}
+	if len(external) > 0 {
+		return nil, fmt.Errorf("tables are referenced by foreign keys of tables not in the table list, whose rows would block the deletion: %s", strings.Join(external, ", "))
+	}
+
+	if opts.breakCycles {
+		schemaMap := make(map[string]*tableSchema, len(schemas))
+		for _, schema := range schemas {
+			schemaMap[schema.tableName] = schema
+		}
+		for _, cycle := range findCycles(tables) {
+			breakCycle(cycle, schemaMap, tableMap)
 		}
 	}
 
 	// Mark tables that has at least one global index.
 	for _, idx := range indexes {
+		if table, ok := tableMap[idx.baseTableName]; ok {
+			table.deleter.indexes++
+		}
 		// A global index isn't interleaved in any table.
-		if idx.parentTableName == "" {
+		if idx.isGlobal() && !idx.isSearch {
 			if table, ok := tableMap[idx.baseTableName]; ok {
 				table.hasGlobalIndex = true
 			}
@@ -169,36 +325,91 @@ func newCoordinator(schemas []*tableSchema, indexes []*indexSchema, client *span
 	}
 
 	return &coordinator{
-		tables:  topLevelTables,
-		errChan: make(chan error),
+		tables:          topLevelTables,
+		errChan:         make(chan error),
+		emitter:         emitter,
+		continueOnError: opts.continueOnError,
+
+		skipUnauthorized: opts.skipUnauthorized,
+		warnings:         warnings,
+		logger:           opts.logger,
+		finished:         make(chan struct{}),
+		failureHandler:   opts.failureHandler,
+		mutationMaxRows:  opts.mutationMaxRows,
+		shardMinRows:     opts.shardMinRows,
+		shards:           opts.shards,
+		batched:          opts.batched,
+
+		maxConcurrentDeletes: opts.maxConcurrentDeletes,
+		batchedRowCounts:     opts.batchedRowCounts,
+		client:               client,
+		limiter:              limiter,
+		reqOpts:              opts.requestOptions,
 	}, nil
 }
 
 // start starts coordination in another goroutine.
 func (c *coordinator) start(ctx context.Context) {
 	go func() {
+		var counted []*deleter
 		for _, table := range flattenTables(c.tables) {
-			table.deleter.startRowCountUpdater(ctx)
+			switch {
+			case table.deleter.isDone():
+				// Completed before the start, e.g. because it's already empty.
+			case table.deleter.uncounted:
+				// Nothing to analyze without counting rows.
+				table.deleter.status = statusWaiting
+			case c.statsProgress.tracks(table.tableName) && table.deleter.filter == nil:
+				table.deleter.uncounted = true
+			case c.batchedRowCounts:
+				counted = append(counted, table.deleter)
+			default:
+				table.deleter.startRowCountUpdater(ctx)
+			}
+		}
+		if len(counted) > 0 {
+			go runBatchedRowCounts(ctx, c.client, c.limiter, c.reqOpts, counted)
+		}
+		if c.statsProgress != nil {
+			go c.statsProgress.run(ctx, c.tables)
 		}
 
 		ticker := time.NewTicker(time.Second)
 		for {
 			select {
 			case <-ticker.C:
-				tables := findDeletableTables(c.tables)
+				c.checkTableTransitions()
+				c.checkWavesCompleted()
+				c.startPostHooks(ctx)
+
+				tables := limitConcurrentDeletes(findDeletableTables(c.tables), c.tables, c.maxConcurrentDeletes)
 				if len(tables) == 0 {
 					if !isAllTablesDeleted(c.tables) && !isAnyTableDeleting(c.tables) {
+						untouched := extractTableNames(findUntouchedTables(c.tables))
+						if failures := c.failureList(); len(failures) > 0 {
+							c.errChan <- &PartialFailureError{
+								Failures:        failures,
+								UntouchedTables: untouched,
+							}
+							return
+						}
+						if hasSkippedTables(c.tables) {
+							for _, name := range untouched {
+								c.warnings.add(name, "UNTOUCHED because it depends on skipped tables")
+							}
+							close(c.finished)
+							return
+						}
 						c.errChan <- errors.New("no deletable tables found, probably there is circular dependencies between tables")
 					}
 				}
 
+				waveCtx := ctx
+				if len(tables) > 0 {
+					waveCtx = c.startWave(ctx, tables)
+				}
 				for _, table := range tables {
-					go func() {
-						if err := table.deleter.deleteRows(ctx); err != nil {
-							c.errChan <- err
-						}
-					}()
-					cascadeDelete(table.childTables)
+					c.startDeletion(waveCtx, table)
 				}
 			case <-ctx.Done():
 				c.errChan <- ctx.Err()
@@ -207,15 +418,145 @@ func (c *coordinator) start(ctx context.Context) {
 	}()
 }
 
+// startDeletion starts deleting rows from the table in another goroutine.
+func (c *coordinator) startDeletion(ctx context.Context, table *table) {
+	// Setting up PDML takes seconds, which dominates the time to delete a small table.
+	rows, counted := table.rowsToDelete()
+	// A table in a cycle can't be emptied in a single commit while the other tables still reference it.
+	// A mutation deletes all rows of the table, so it can't delete only the rows matching a filter.
+	table.deleter.byMutation = c.mutationMaxRows > 0 && table.deleter.statementBuilder == nil && table.deleter.filter == nil && table.deleter.cycle == nil && counted && rows <= c.mutationMaxRows
+	table.deleter.shards = 0
+	table.deleter.batched = false
+	switch {
+	case table.deleter.byMutation:
+		table.deleter.strategy = strategyMutation
+	case table.deleter.deletesLeavesFirst():
+		table.deleter.strategy = strategyLeavesFirst
+	case c.batched && table.deleter.statementBuilder == nil:
+		table.deleter.batched = true
+		table.deleter.strategy = strategyBatched
+	case c.shards > 1 && table.deleter.statementBuilder == nil && table.deleter.filter == nil && counted && rows >= c.shardMinRows:
+		table.deleter.shards = c.shards
+		table.deleter.strategy = strategySharded
+	default:
+		table.deleter.strategy = strategyPDML
+	}
+	orDiscard(c.logger).Info("deleting table", "table", table.tableName, "by_mutation", table.deleter.byMutation, "cascade", extractTableNames(flattenTables(table.childTables)))
+	// Mark as deleting before starting goroutine so that the table isn't picked up again by the next tick.
+	table.deleter.status = statusDeleting
+	hooked := findPreHookTargets(table)
+	go func() {
+		for _, t := range hooked {
+			if err := t.deleter.runPreHooks(ctx); err != nil {
+				c.fail(table, err)
+				return
+			}
+		}
+		begin := time.Now()
+		err := table.deleter.deleteRows(ctx)
+		elapsed := time.Since(begin)
+		table.deleter.deleteDuration += elapsed
+		c.metrics.observeDeletion(elapsed)
+		if err != nil {
+			c.fail(table, err)
+			return
+		}
+		// Children which aren't counted never find themselves empty.
+		if table.deleter.statementBuilder != nil || hasUncountedTables(table.childTables) {
+			completeCascade(table.childTables)
+		}
+	}()
+	cascadeDelete(table.childTables)
+}
+
+// fail handles a failure of the table deletion.
+// If the failure handler is set, it retries or skips the table, or aborts the whole run as the handler decides.
+// If the coordinator continues on error, it marks the table as failed and lets other tables go on.
+// Otherwise, it aborts the whole run.
+func (c *coordinator) fail(table *table, err error) {
+	orDiscard(c.logger).Error("table failed", "table", table.tableName, "error", err)
+	if c.skipUnauthorized && errorCode(err) == codes.PermissionDenied {
+		c.warnings.add(table.tableName, "SKIPPED due to insufficient permissions: %v", err)
+		table.deleter.failure = err
+		table.deleter.status = statusSkipped
+		resetCascade(table.childTables)
+		return
+	}
+	if c.failureHandler != nil {
+		switch c.handleFailure(table.tableName, err) {
+		case FailureActionRetry:
+			// The table is picked up again by the next tick, and its descendants are deleted in cascade again.
+			table.deleter.status = statusWaiting
+			resetCascade(table.childTables)
+			return
+		case FailureActionSkip:
+			c.warnings.add(table.tableName, "SKIPPED after a failure: %v", err)
+			table.deleter.failure = err
+			table.deleter.status = statusSkipped
+			resetCascade(table.childTables)
+			return
+		default:
+			table.deleter.failure = err
+			c.errChan <- err
+			return
+		}
+	}
+	table.deleter.failure = err
+	if !c.recordFailure(err) {
+		return
+	}
+
+	table.deleter.status = statusFailed
+	// Descendants aren't deleted in cascade anymore, so they need their own deletion.
+	resetCascade(table.childTables)
+}
+
+// handleFailure asks the failure handler what to do with the failed table, one failure at a time.
+func (c *coordinator) handleFailure(tableName string, err error) FailureAction {
+	c.failureMu.Lock()
+	defer c.failureMu.Unlock()
+	return c.failureHandler(tableName, err)
+}
+
+// recordFailure records the error if the coordinator continues on error and returns true.
+// Otherwise, it aborts the whole run and returns false.
+func (c *coordinator) recordFailure(err error) bool {
+	if !c.continueOnError {
+		c.errChan <- err
+		return false
+	}
+
+	c.mu.Lock()
+	c.failures = append(c.failures, err)
+	c.mu.Unlock()
+	return true
+}
+
+// failureList returns a snapshot of failures recorded so far.
+func (c *coordinator) failureList() []error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]error(nil), c.failures...)
+}
+
 // waitCompleted blocks until all deletions are completed.
 func (c *coordinator) waitCompleted() error {
 	ticker := time.NewTicker(time.Second)
 	for {
 		select {
 		case <-ticker.C:
-			if isAllTablesDeleted(c.tables) {
+			if isAllTablesDeleted(c.tables) && isAllPostHooksDone(c.tables) {
+				c.checkTableTransitions()
+				c.checkWavesCompleted()
+				if failures := c.failureList(); len(failures) > 0 {
+					return &PartialFailureError{Failures: failures}
+				}
 				return nil
 			}
+		case <-c.finished:
+			c.checkTableTransitions()
+			c.checkWavesCompleted()
+			return nil
 		case err := <-c.errChan:
 			if err != nil {
 				return err
@@ -224,6 +565,146 @@ func (c *coordinator) waitCompleted() error {
 	}
 }
 
+// startPostHooks starts post hooks of the tables which are ready.
+func (c *coordinator) startPostHooks(ctx context.Context) {
+	for _, table := range findPostHookReadyTables(c.tables) {
+		table := table
+		table.postHooksStarted = true
+		go func() {
+			if err := table.deleter.runPostHooks(ctx); err != nil {
+				c.recordFailure(err)
+			}
+			// Marked done only after the failure is recorded, so that the run doesn't complete without it.
+			table.deleter.finishPostHooks()
+		}()
+	}
+}
+
+// findPostHookReadyTables returns tables whose post hooks can be started,
+// that is, the table and its ancestors have been deleted.
+func findPostHookReadyTables(tables []*table) []*table {
+	var ready []*table
+	for _, table := range tables {
+		if table.deleter.status != statusCompleted {
+			continue
+		}
+		if len(table.deleter.postHooks) > 0 && !table.postHooksStarted {
+			ready = append(ready, table)
+		}
+		ready = append(ready, findPostHookReadyTables(table.childTables)...)
+	}
+	return ready
+}
+
+// findPreHookTargets returns the table and its descendants which will be deleted in cascade,
+// whose pre hooks must be executed before the deletion of the table.
+func findPreHookTargets(t *table) []*table {
+	return append([]*table{t}, findCascadeTargets(t.childTables)...)
+}
+
+// findCascadeTargets returns descendants which will be deleted in cascade by deleting their parent.
+func findCascadeTargets(tables []*table) []*table {
+	var targets []*table
+	for _, table := range tables {
+		if table.deleter.status == statusCompleted {
+			continue
+		}
+		targets = append(targets, table)
+		targets = append(targets, findCascadeTargets(table.childTables)...)
+	}
+	return targets
+}
+
+func isAllPostHooksDone(tables []*table) bool {
+	for _, table := range tables {
+		if !table.deleter.isPostHooksDone() {
+			return false
+		}
+		if !isAllPostHooksDone(table.childTables) {
+			return false
+		}
+	}
+	return true
+}
+
+// startWave records a new wave consisting of the given tables, and returns ctx with the span of the wave.
+func (c *coordinator) startWave(ctx context.Context, tables []*table) context.Context {
+	c.mu.Lock()
+	w := &wave{id: len(c.waves) + 1, tables: tables}
+	ctx, w.span = startSpan(ctx, "wave", attribute.Int("truncate.wave", w.id), attribute.StringSlice("truncate.tables", extractTableNames(tables)))
+	c.waves = append(c.waves, w)
+	c.mu.Unlock()
+
+	c.emitter.emit(Event{Type: EventWaveStarted, Wave: w.id, Tables: extractTableNames(tables)})
+	return ctx
+}
+
+// endWaveSpans ends spans of waves which haven't been completed, e.g. when the run is aborted.
+func (c *coordinator) endWaveSpans() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, w := range c.waves {
+		if !w.completed {
+			endSpan(w.span, errors.New("wave not completed"))
+		}
+	}
+}
+
+// checkTableTransitions emits events of tables whose status has changed since the last check.
+// Statuses are checked on every tick, so a table going through multiple statuses within a tick emits only the last one.
+func (c *coordinator) checkTableTransitions() {
+	c.transitionMu.Lock()
+	defer c.transitionMu.Unlock()
+	for _, table := range flattenTables(c.tables) {
+		s := table.deleter.status
+		if s == statusCascadeDeleting {
+			s = statusDeleting
+		}
+		if s == table.emittedStatus {
+			continue
+		}
+		table.emittedStatus = s
+
+		event := Event{Tables: []string{table.tableName}}
+		switch s {
+		case statusWaiting:
+			event.Type = EventTableWaiting
+		case statusDeleting:
+			event.Type = EventTableDeleting
+		case statusCompleted:
+			event.Type = EventTableCompleted
+		case statusFailed:
+			event.Type = EventTableFailed
+		case statusSkipped:
+			event.Type = EventTableSkipped
+		default:
+			continue
+		}
+		if (s == statusFailed || s == statusSkipped) && table.deleter.failure != nil {
+			event.Message = table.deleter.failure.Error()
+		}
+		c.emitter.emit(event)
+	}
+}
+
+// checkWavesCompleted emits completion events of waves whose tables have been deleted.
+func (c *coordinator) checkWavesCompleted() {
+	c.mu.Lock()
+	var completed []*wave
+	for _, w := range c.waves {
+		if !w.completed && isAllTablesDeleted(w.tables) {
+			w.completed = true
+			completed = append(completed, w)
+		}
+	}
+	c.mu.Unlock()
+
+	for _, w := range completed {
+		w.span.End()
+		c.emitter.emit(Event{Type: EventWaveCompleted, Wave: w.id, Tables: extractTableNames(w.tables)})
+	}
+}
+
 func isAllTablesDeleted(tables []*table) bool {
 	for _, table := range tables {
 		if table.deleter.status != statusCompleted {
@@ -255,3 +736,61 @@ func cascadeDelete(tables []*table) {
 		cascadeDelete(table.childTables)
 	}
 }
+
+// resetCascade marks all of child tables being deleted in cascade as waiting again.
+func resetCascade(tables []*table) {
+	for _, table := range tables {
+		if table.deleter.status == statusCascadeDeleting {
+			table.deleter.status = statusWaiting
+			table.deleter.strategy = ""
+		}
+		resetCascade(table.childTables)
+	}
+}
+
+func hasSkippedTables(tables []*table) bool {
+	for _, table := range flattenTables(tables) {
+		if table.deleter.status == statusSkipped {
+			return true
+		}
+	}
+	return false
+}
+
+// findUntouchedTables returns tables which are neither deleted, failed, nor skipped.
+func findUntouchedTables(tables []*table) []*table {
+	var untouched []*table
+	for _, table := range flattenTables(tables) {
+		if !table.deleter.isDone() {
+			untouched = append(untouched, table)
+		}
+	}
+	return untouched
+}
+
+// completeCascade marks all of child tables being deleted in cascade as completed.
+func completeCascade(tables []*table) {
+	for _, table := range tables {
+		table.deleter.parentDeletionCompleted()
+		completeCascade(table.childTables)
+	}
+}
+
+// hasUncountedTables returns true if any of the tables or their descendants isn't counted.
+func hasUncountedTables(tables []*table) bool {
+	for _, table := range flattenTables(tables) {
+		if table.deleter.uncounted {
+			return true
+		}
+	}
+	return false
+}
+
+// extractTableNames returns names of the given tables.
+func extractTableNames(tables []*table) []string {
+	names := make([]string, len(tables))
+	for i, table := range tables {
+		names[i] = table.tableName
+	}
+	return names
+}
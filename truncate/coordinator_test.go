@@ -17,9 +17,14 @@
 package truncate
 
 import (
+	"context"
+	"errors"
 	"testing"
+	"time"
 
 	"github.com/google/go-cmp/cmp"
+	"google.golang.org/grpc/codes"
+	grpcstatus "google.golang.org/grpc/status"
 )
 
 func TestNewCoordinator(t *testing.T) {
@@ -104,6 +109,14 @@ func TestNewCoordinator(t *testing.T) {
 			},
 			wantErr: true,
 		},
+		{
+			desc: "Foreign Key referencing table not exist among others",
+			schemas: []*tableSchema{
+				{tableName: "A", parentTableName: "", referencedBy: []string{"B", "analytics.C"}},
+				{tableName: "B", parentTableName: ""},
+			},
+			wantErr: true,
+		},
 		{
 			desc: "Child table has an interleaved index",
 			schemas: []*tableSchema{
@@ -132,7 +145,7 @@ func TestNewCoordinator(t *testing.T) {
 		},
 	} {
 		t.Run(test.desc, func(t *testing.T) {
-			coordinator, err := newCoordinator(test.schemas, test.indexes, nil)
+			coordinator, err := newCoordinator(test.schemas, test.indexes, nil, nil, nil, newOptions(nil))
 			if test.wantErr {
 				if err == nil {
 					t.Errorf("test wants error, but no error returned")
@@ -437,14 +450,6 @@ func TestTableIsDeletable(t *testing.T) {
 	}
 }
 
-func extractTableNames(tables []*table) []string {
-	names := make([]string, len(tables))
-	for i, table := range tables {
-		names[i] = table.tableName
-	}
-	return names
-}
-
 func compareTables(tables1, tables2 []*table) bool {
 	if len(tables1) != len(tables2) {
 		return false
@@ -467,3 +472,305 @@ func compareTables(tables1, tables2 []*table) bool {
 	}
 	return true
 }
+
+func TestWaveEvents(t *testing.T) {
+	var events []Event
+	c := &coordinator{emitter: newEmitter(func(e Event) { events = append(events, e) })}
+
+	tableA := &table{tableName: "A", deleter: &deleter{}}
+	tableB := &table{tableName: "B", deleter: &deleter{}}
+	tableC := &table{tableName: "C", deleter: &deleter{}}
+	tableA.childTables = []*table{tableB}
+
+	c.startWave(context.Background(), []*table{tableA})
+	c.startWave(context.Background(), []*table{tableC})
+
+	// Child table is not deleted yet, so the first wave isn't completed.
+	tableA.deleter.status = statusCompleted
+	tableC.deleter.status = statusCompleted
+	c.checkWavesCompleted()
+
+	tableB.deleter.status = statusCompleted
+	c.checkWavesCompleted()
+	c.checkWavesCompleted() // Completion should be emitted only once.
+
+	type summary struct {
+		Type   EventType
+		Wave   int
+		Tables []string
+	}
+	var got []summary
+	for _, e := range events {
+		got = append(got, summary{e.Type, e.Wave, e.Tables})
+	}
+	want := []summary{
+		{EventWaveStarted, 1, []string{"A"}},
+		{EventWaveStarted, 2, []string{"C"}},
+		{EventWaveCompleted, 2, []string{"C"}},
+		{EventWaveCompleted, 1, []string{"A"}},
+	}
+	if diff := cmp.Diff(got, want); diff != "" {
+		t.Errorf("mismatch (-got +want):\n%s", diff)
+	}
+}
+
+func TestTableEvents(t *testing.T) {
+	var events []Event
+	c := &coordinator{emitter: newEmitter(func(e Event) { events = append(events, e) })}
+
+	tableA := &table{tableName: "A", deleter: &deleter{}}
+	tableB := &table{tableName: "B", deleter: &deleter{}}
+	tableC := &table{tableName: "C", deleter: &deleter{}}
+	tableA.childTables = []*table{tableB}
+	c.tables = []*table{tableA, tableC}
+
+	c.checkTableTransitions() // Nothing is emitted while analyzing.
+
+	tableA.deleter.status = statusWaiting
+	tableB.deleter.status = statusWaiting
+	tableC.deleter.status = statusCompleted
+	c.checkTableTransitions()
+
+	tableA.deleter.status = statusDeleting
+	tableB.deleter.status = statusCascadeDeleting
+	c.checkTableTransitions()
+	c.checkTableTransitions() // Transitions should be emitted only once.
+
+	tableA.deleter.status = statusFailed
+	tableA.deleter.failure = errors.New("deadline exceeded")
+	tableB.deleter.status = statusWaiting
+	c.checkTableTransitions()
+
+	type summary struct {
+		Type    EventType
+		Tables  []string
+		Message string
+	}
+	var got []summary
+	for _, e := range events {
+		got = append(got, summary{e.Type, e.Tables, e.Message})
+	}
+	want := []summary{
+		{EventTableWaiting, []string{"A"}, ""},
+		{EventTableWaiting, []string{"B"}, ""},
+		{EventTableCompleted, []string{"C"}, ""},
+		{EventTableDeleting, []string{"A"}, ""},
+		{EventTableDeleting, []string{"B"}, ""},
+		{EventTableFailed, []string{"A"}, "deadline exceeded"},
+		{EventTableWaiting, []string{"B"}, ""},
+	}
+	if diff := cmp.Diff(got, want); diff != "" {
+		t.Errorf("mismatch (-got +want):\n%s", diff)
+	}
+}
+
+func TestFindPostHookReadyTables(t *testing.T) {
+	tableA := &table{tableName: "A", deleter: &deleter{status: statusCompleted, postHooks: []string{"INSERT"}}}
+	tableB := &table{tableName: "B", deleter: &deleter{status: statusCompleted, postHooks: []string{"INSERT"}}}
+	tableC := &table{tableName: "C", deleter: &deleter{status: statusCascadeDeleting}}
+	tableD := &table{tableName: "D", deleter: &deleter{status: statusCompleted, postHooks: []string{"INSERT"}}}
+	tableE := &table{tableName: "E", deleter: &deleter{status: statusCompleted, postHooks: []string{"INSERT"}}, postHooksStarted: true}
+
+	// A -- B, C -- D
+	tableA.childTables = []*table{tableB}
+	tableC.childTables = []*table{tableD}
+
+	got := extractTableNames(findPostHookReadyTables([]*table{tableA, tableC, tableE}))
+	want := []string{"A", "B"}
+	if !cmp.Equal(got, want) {
+		t.Errorf("diff(+got, -want) = %v", cmp.Diff(got, want))
+	}
+}
+
+func TestCoordinatorPostHookFailure(t *testing.T) {
+	// The post hook fails waiting for the rate limit with a cancelled context, without issuing any statement.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	limiter := &rateLimiter{interval: time.Hour, next: time.Now().Add(time.Hour)}
+	tableA := &table{tableName: "A", deleter: &deleter{tableName: "A", status: statusCompleted, postHooks: []string{"INSERT"}, limiter: limiter}}
+
+	c := &coordinator{tables: []*table{tableA}, errChan: make(chan error)}
+	c.startPostHooks(ctx)
+	// Until the failure is received, the post hooks must not be regarded as done, or the run would complete without it.
+	time.Sleep(100 * time.Millisecond)
+	if isAllPostHooksDone(c.tables) {
+		t.Errorf("isAllPostHooksDone() = true before the failure is recorded")
+	}
+	if err := <-c.errChan; err == nil {
+		t.Errorf("got nil from errChan, but want the error of the post hook")
+	}
+	if err := c.waitCompleted(); err != nil {
+		t.Errorf("waitCompleted() = %v after the failure was received, but want nil", err)
+	}
+}
+
+func TestCoordinatorFailContinueOnError(t *testing.T) {
+	// A -- B (cascade), C references A, D is independent.
+	tableA := &table{tableName: "A", deleter: &deleter{status: statusDeleting}}
+	tableB := &table{tableName: "B", deleter: &deleter{status: statusCascadeDeleting}}
+	tableC := &table{tableName: "C", deleter: &deleter{status: statusCompleted}}
+	tableD := &table{tableName: "D", deleter: &deleter{status: statusWaiting}}
+	tableA.childTables = []*table{tableB}
+	tableB.parentTableName = "A"
+	tableB.parentOnDeleteAction = deleteActionCascadeDelete
+
+	c := &coordinator{tables: []*table{tableA, tableC, tableD}, continueOnError: true}
+	c.fail(tableA, errors.New("permission denied"))
+
+	if got := tableA.deleter.status; got != statusFailed {
+		t.Errorf("status of A = %v, but want = %v", got, statusFailed)
+	}
+	if got := tableB.deleter.status; got != statusWaiting {
+		t.Errorf("status of B = %v, but want = %v", got, statusWaiting)
+	}
+	if got := len(c.failureList()); got != 1 {
+		t.Errorf("got %d failures, but want 1", got)
+	}
+
+	// B can be deleted by itself, but A can't be deleted again.
+	if got, want := extractTableNames(findDeletableTables(c.tables)), []string{"B", "D"}; !cmp.Equal(got, want) {
+		t.Errorf("diff(+got, -want) = %v", cmp.Diff(got, want))
+	}
+	if got, want := extractTableNames(findUntouchedTables(c.tables)), []string{"B", "D"}; !cmp.Equal(got, want) {
+		t.Errorf("diff(+got, -want) = %v", cmp.Diff(got, want))
+	}
+}
+
+func TestCoordinatorFailSkipUnauthorized(t *testing.T) {
+	// A -- B (cascade), D is independent.
+	tableA := &table{tableName: "A", deleter: &deleter{status: statusDeleting}}
+	tableB := &table{tableName: "B", deleter: &deleter{status: statusCascadeDeleting}}
+	tableD := &table{tableName: "D", deleter: &deleter{status: statusWaiting}}
+	tableA.childTables = []*table{tableB}
+	tableB.parentTableName = "A"
+	tableB.parentOnDeleteAction = deleteActionCascadeDelete
+
+	ws := newWarnings(nil)
+	c := &coordinator{tables: []*table{tableA, tableD}, skipUnauthorized: true, warnings: ws}
+	c.fail(tableA, newTableError("A", OpDelete, "DELETE FROM A WHERE true", grpcstatus.Error(codes.PermissionDenied, "denied")))
+
+	if got := tableA.deleter.status; got != statusSkipped {
+		t.Errorf("status of A = %v, but want = %v", got, statusSkipped)
+	}
+	if got := len(c.failureList()); got != 0 {
+		t.Errorf("got %d failures, but want 0", got)
+	}
+	if got := len(ws.all()); got != 1 {
+		t.Errorf("got %d warnings, but want 1", got)
+	}
+	if !hasSkippedTables(c.tables) {
+		t.Errorf("hasSkippedTables() = false, but want true")
+	}
+	if got, want := extractTableNames(findDeletableTables(c.tables)), []string{"B", "D"}; !cmp.Equal(got, want) {
+		t.Errorf("diff(+got, -want) = %v", cmp.Diff(got, want))
+	}
+}
+
+func TestCoordinatorFailWithFailureHandler(t *testing.T) {
+	for _, test := range []struct {
+		desc       string
+		action     FailureAction
+		wantStatus status
+		wantChild  status
+		wantAbort  bool
+	}{
+		{desc: "retry", action: FailureActionRetry, wantStatus: statusWaiting, wantChild: statusWaiting},
+		{desc: "skip", action: FailureActionSkip, wantStatus: statusSkipped, wantChild: statusWaiting},
+		{desc: "abort", action: FailureActionAbort, wantStatus: statusDeleting, wantChild: statusCascadeDeleting, wantAbort: true},
+	} {
+		t.Run(test.desc, func(t *testing.T) {
+			// A -- B (cascade)
+			tableA := &table{tableName: "A", deleter: &deleter{status: statusDeleting}}
+			tableB := &table{tableName: "B", deleter: &deleter{status: statusCascadeDeleting}}
+			tableA.childTables = []*table{tableB}
+
+			var handled []string
+			c := &coordinator{
+				tables:   []*table{tableA},
+				errChan:  make(chan error, 1),
+				warnings: newWarnings(nil),
+				failureHandler: func(tableName string, err error) FailureAction {
+					handled = append(handled, tableName)
+					return test.action
+				},
+			}
+			c.fail(tableA, errors.New("deadline exceeded"))
+
+			if want := []string{"A"}; !cmp.Equal(handled, want) {
+				t.Errorf("handled tables: diff(+got, -want) = %v", cmp.Diff(handled, want))
+			}
+			if got := tableA.deleter.status; got != test.wantStatus {
+				t.Errorf("status of A = %v, but want = %v", got, test.wantStatus)
+			}
+			if got := tableB.deleter.status; got != test.wantChild {
+				t.Errorf("status of B = %v, but want = %v", got, test.wantChild)
+			}
+			if got := len(c.errChan) == 1; got != test.wantAbort {
+				t.Errorf("aborted = %v, but want = %v", got, test.wantAbort)
+			}
+		})
+	}
+}
+
+func TestTableRowsToDelete(t *testing.T) {
+	for _, tt := range []struct {
+		desc        string
+		tableFunc   func() *table
+		wantRows    int64
+		wantCounted bool
+	}{
+		{
+			desc: "Single table",
+			tableFunc: func() *table {
+				return &table{tableName: "A", deleter: &deleter{status: statusWaiting, remainedRows: 10}}
+			},
+			wantRows:    10,
+			wantCounted: true,
+		},
+		{
+			desc: "Descendants deleted in cascade",
+			tableFunc: func() *table {
+				parent := &table{tableName: "Parent", deleter: &deleter{status: statusWaiting, remainedRows: 10}}
+				child := &table{tableName: "Child", deleter: &deleter{status: statusWaiting, remainedRows: 20}}
+				grandchild := &table{tableName: "Grandchild", deleter: &deleter{status: statusWaiting, remainedRows: 30}}
+				completed := &table{tableName: "Completed", deleter: &deleter{status: statusCompleted, remainedRows: 40}}
+				parent.childTables = []*table{child, completed}
+				child.childTables = []*table{grandchild}
+				return parent
+			},
+			wantRows:    60,
+			wantCounted: true,
+		},
+		{
+			desc: "Table not counted yet",
+			tableFunc: func() *table {
+				return &table{tableName: "A", deleter: &deleter{status: statusAnalyzing}}
+			},
+			wantCounted: false,
+		},
+		{
+			desc: "Descendant not counted yet",
+			tableFunc: func() *table {
+				parent := &table{tableName: "Parent", deleter: &deleter{status: statusWaiting, remainedRows: 10}}
+				child := &table{tableName: "Child", deleter: &deleter{status: statusAnalyzing}}
+				parent.childTables = []*table{child}
+				return parent
+			},
+			wantCounted: false,
+		},
+		{
+			desc: "Table never counted",
+			tableFunc: func() *table {
+				return &table{tableName: "A", deleter: &deleter{status: statusWaiting, uncounted: true}}
+			},
+			wantCounted: false,
+		},
+	} {
+		t.Run(tt.desc, func(t *testing.T) {
+			rows, counted := tt.tableFunc().rowsToDelete()
+			if rows != tt.wantRows || counted != tt.wantCounted {
+				t.Errorf("rowsToDelete() = (%d, %v), but want (%d, %v)", rows, counted, tt.wantRows, tt.wantCounted)
+			}
+		})
+	}
+}
@@ -0,0 +1,222 @@
+//
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package truncate
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+// cycleProgressInterval is the interval to check whether other tables in a cycle have deleted rows.
+const cycleProgressInterval = time.Second
+
+// WithBreakCycles deletes tables referencing each other by foreign keys in a cycle, which otherwise fail the run.
+// Tables in a cycle are deleted at the same time, each repeatedly deleting its rows which no remaining rows of the
+// tables reference, until all of them are empty. It fails if rows remain which reference each other in a cycle.
+// Tables interleaved in other target tables or with custom statements can't break cycles.
+func WithBreakCycles() Option {
+	return func(o *options) {
+		o.breakCycles = true
+	}
+}
+
+// findCycles returns sets of tables referencing each other by foreign keys in cycles, i.e. strongly connected
+// components of more than one table. Tables in each set are sorted by name.
+func findCycles(tables []*table) [][]*table {
+	var (
+		index   = map[*table]int{}
+		lowlink = map[*table]int{}
+		onStack = map[*table]bool{}
+		stack   []*table
+		cycles  [][]*table
+	)
+	var visit func(t *table)
+	visit = func(t *table) {
+		index[t] = len(index)
+		lowlink[t] = index[t]
+		stack = append(stack, t)
+		onStack[t] = true
+		for _, referencing := range t.referencedBy {
+			if _, ok := index[referencing]; !ok {
+				visit(referencing)
+				lowlink[t] = min(lowlink[t], lowlink[referencing])
+			} else if onStack[referencing] {
+				lowlink[t] = min(lowlink[t], index[referencing])
+			}
+		}
+		if lowlink[t] != index[t] {
+			return
+		}
+		var component []*table
+		for {
+			n := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			onStack[n] = false
+			component = append(component, n)
+			if n == t {
+				break
+			}
+		}
+		if len(component) > 1 {
+			sort.Slice(component, func(i, j int) bool { return component[i].tableName < component[j].tableName })
+			cycles = append(cycles, component)
+		}
+	}
+	for _, t := range tables {
+		if _, ok := index[t]; !ok {
+			visit(t)
+		}
+	}
+	return cycles
+}
+
+// breakCycle lets the tables in the cycle be deleted at the same time leaf rows first, instead of waiting for each other.
+// It leaves the tables as they are if any of them can't be deleted that way.
+func breakCycle(cycle []*table, schemas map[string]*tableSchema, tableMap map[string]*table) {
+	inCycle := make(map[string]bool, len(cycle))
+	for _, t := range cycle {
+		if _, ok := tableMap[t.parentTableName]; ok || t.deleter.statementBuilder != nil {
+			return
+		}
+		inCycle[t.tableName] = true
+	}
+
+	group := newCycleGroup(cycle)
+	for _, t := range cycle {
+		var blocking []*table
+		for _, referencing := range t.referencedBy {
+			if inCycle[referencing.tableName] {
+				t.referencedInCycleBy = append(t.referencedInCycleBy, referencing)
+			} else {
+				blocking = append(blocking, referencing)
+			}
+		}
+		t.referencedBy = blocking
+		t.cycle = cycle
+		t.deleter.cycle = group
+		// References from the table itself are already there.
+		for _, fk := range schemas[t.tableName].foreignKeys {
+			if inCycle[fk.referencingTable] && fk.referencingTable != t.tableName && fk.onDelete != deleteActionCascadeDelete {
+				t.deleter.leafReferences = append(t.deleter.leafReferences, fk)
+			}
+		}
+	}
+}
+
+// cycleGroup tracks the progress of tables in a cycle, so that each of them can wait for the others to delete rows
+// referencing its remaining rows, and find out when none of them can delete rows anymore.
+// It's safe for concurrent use, and a nil group is regarded as having no other tables.
+type cycleGroup struct {
+	deleters []*deleter
+
+	mu       sync.Mutex
+	progress int             // Incremented whenever rows are deleted from any of the tables.
+	stalled  map[string]int  // Progress at which tables found none of their remaining rows deletable.
+	done     map[string]bool // Tables which stopped deleting rows, because they are empty or failed.
+}
+
+func newCycleGroup(tables []*table) *cycleGroup {
+	deleters := make([]*deleter, len(tables))
+	for i, t := range tables {
+		deleters[i] = t.deleter
+	}
+	return &cycleGroup{
+		deleters: deleters,
+		stalled:  map[string]int{},
+		done:     map[string]bool{},
+	}
+}
+
+// started records that the table started deleting rows.
+func (g *cycleGroup) started(tableName string) {
+	if g == nil {
+		return
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	delete(g.done, tableName)
+	delete(g.stalled, tableName)
+}
+
+// deleted records that rows were deleted from the table.
+func (g *cycleGroup) deleted(tableName string) {
+	if g == nil {
+		return
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.progress++
+	delete(g.stalled, tableName)
+}
+
+// finished records that the table stopped deleting rows, because it's empty or failed.
+func (g *cycleGroup) finished(tableName string) {
+	if g == nil {
+		return
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.done[tableName] = true
+	delete(g.stalled, tableName)
+}
+
+// waitProgress blocks until any table in the group deletes rows, after the table found none of its remaining rows
+// deletable. It returns false if no tables can delete rows anymore, i.e. all of them are done or waiting.
+func (g *cycleGroup) waitProgress(ctx context.Context, tableName string) (bool, error) {
+	if g == nil {
+		return false, nil
+	}
+	g.mu.Lock()
+	seen := g.progress
+	g.stalled[tableName] = seen
+	g.mu.Unlock()
+
+	ticker := time.NewTicker(cycleProgressInterval)
+	defer ticker.Stop()
+	for {
+		g.mu.Lock()
+		progressed, stuck := g.progress != seen, g.isStuck()
+		g.mu.Unlock()
+		switch {
+		case progressed:
+			return true, nil
+		case stuck:
+			return false, nil
+		}
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return false, ctx.Err()
+		}
+	}
+}
+
+// isStuck returns true if every table is done or waiting for progress which never happens. Tables which haven't
+// started yet are waited for unless they are skipped or failed. g.mu must be held.
+func (g *cycleGroup) isStuck() bool {
+	for _, d := range g.deleters {
+		if g.done[d.tableName] || d.isDone() {
+			continue
+		}
+		if at, ok := g.stalled[d.tableName]; !ok || at != g.progress {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,160 @@
+//
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package truncate
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/spanner"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestFindCycles(t *testing.T) {
+	a := &table{tableName: "A"}
+	b := &table{tableName: "B"}
+	c := &table{tableName: "C"}
+	d := &table{tableName: "D"}
+	e := &table{tableName: "E"}
+	// A <-> B, C -> D -> E -> C, and B -> C which isn't in a cycle.
+	a.referencedBy = []*table{b}
+	b.referencedBy = []*table{a}
+	c.referencedBy = []*table{b, e}
+	d.referencedBy = []*table{c}
+	e.referencedBy = []*table{d}
+
+	var got [][]string
+	for _, cycle := range findCycles([]*table{a, b, c, d, e}) {
+		got = append(got, extractTableNames(cycle))
+	}
+	want := [][]string{{"A", "B"}, {"C", "D", "E"}}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("findCycles() mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestBreakCycle(t *testing.T) {
+	schemas := []*tableSchema{
+		{
+			tableName: "Singers",
+			foreignKeys: []*foreignKey{
+				{constraintName: "FK_Albums", referencingTable: "Albums", referencedTable: "Singers", onDelete: deleteActionNoAction},
+			},
+			referencedBy: []string{"Albums", "Concerts"},
+		},
+		{
+			tableName: "Albums",
+			foreignKeys: []*foreignKey{
+				{constraintName: "FK_Singers", referencingTable: "Singers", referencedTable: "Albums", onDelete: deleteActionNoAction},
+			},
+			referencedBy: []string{"Singers"},
+		},
+		{tableName: "Concerts"},
+	}
+	for _, tt := range []struct {
+		desc       string
+		opts       []Option
+		wantWaves  [][]string
+		wantStuck  bool
+		wantLeaves map[string]int
+	}{
+		{
+			desc:      "cycle isn't broken by default",
+			wantStuck: true,
+		},
+		{
+			desc:       "cycle is broken",
+			opts:       []Option{WithBreakCycles()},
+			wantWaves:  [][]string{{"Concerts"}, {"Singers", "Albums"}},
+			wantLeaves: map[string]int{"Singers": 1, "Albums": 1},
+		},
+		{
+			desc:      "cycle with a custom statement isn't broken",
+			opts:      []Option{WithBreakCycles(), WithStatementBuilder("Albums", func(string) spanner.Statement { return spanner.Statement{} })},
+			wantStuck: true,
+		},
+	} {
+		t.Run(tt.desc, func(t *testing.T) {
+			c, err := newCoordinator(schemas, nil, nil, nil, nil, newOptions(tt.opts))
+			if err != nil {
+				t.Fatalf("newCoordinator() error = %v", err)
+			}
+			plan, stuck := simulateDeletion(c.tables)
+			if got := len(stuck) > 0; got != tt.wantStuck {
+				t.Fatalf("stuck = %v, want %v", got, tt.wantStuck)
+			}
+			if tt.wantStuck {
+				return
+			}
+			if diff := cmp.Diff(tt.wantWaves, plan.waves); diff != "" {
+				t.Errorf("waves mismatch (-want +got):\n%s", diff)
+			}
+			for _, table := range c.tables {
+				if got := len(table.deleter.leafReferences); got != tt.wantLeaves[table.tableName] {
+					t.Errorf("%s has %d leaf references, want %d", table.tableName, got, tt.wantLeaves[table.tableName])
+				}
+			}
+		})
+	}
+}
+
+func TestCycleGroupWaitProgress(t *testing.T) {
+	a := &table{tableName: "A", deleter: &deleter{tableName: "A", status: statusDeleting}}
+	b := &table{tableName: "B", deleter: &deleter{tableName: "B", status: statusDeleting}}
+	ctx := context.Background()
+
+	g := newCycleGroup([]*table{a, b})
+	g.started("A")
+	g.started("B")
+	g.deleted("B")
+
+	// A waits for B, which deletes rows again.
+	done := make(chan bool)
+	go func() {
+		progressed, _ := g.waitProgress(ctx, "A")
+		done <- progressed
+	}()
+	for !isStalled(g, "A") {
+		time.Sleep(time.Millisecond)
+	}
+	g.deleted("B")
+	if progressed := <-done; !progressed {
+		t.Errorf("waitProgress() = false after B deleted rows, want true")
+	}
+
+	// B becomes empty, so A can't delete its remaining rows anymore.
+	g.finished("B")
+	if progressed, err := g.waitProgress(ctx, "A"); progressed || err != nil {
+		t.Errorf("waitProgress() = %v, %v after B finished, want false", progressed, err)
+	}
+
+	// A table skipped before starting isn't waited for.
+	c := &table{tableName: "C", deleter: &deleter{tableName: "C", status: statusSkipped}}
+	g = newCycleGroup([]*table{a, c})
+	g.started("A")
+	if progressed, err := g.waitProgress(ctx, "A"); progressed || err != nil {
+		t.Errorf("waitProgress() = %v, %v with a skipped table, want false", progressed, err)
+	}
+}
+
+func isStalled(g *cycleGroup, tableName string) bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	_, ok := g.stalled[tableName]
+	return ok
+}
@@ -0,0 +1,186 @@
+//
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package truncate
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+	"sync"
+	"text/tabwriter"
+
+	"cloud.google.com/go/spanner"
+	adminapi "cloud.google.com/go/spanner/admin/database/apiv1"
+	"google.golang.org/api/iterator"
+	"google.golang.org/api/option"
+	adminpb "google.golang.org/genproto/googleapis/spanner/admin/database/v1"
+)
+
+// maxConcurrentDatabases is the maximum number of databases inspected at the same time by ListDatabases.
+const maxConcurrentDatabases = 5
+
+// DatabaseInfo is a summary of a database in an instance.
+type DatabaseInfo struct {
+	// Database ID, not the fully qualified name.
+	Name string
+
+	// State of the database, e.g. "READY".
+	State string
+
+	// Dialect of the database, "GOOGLE_STANDARD_SQL" or "POSTGRESQL". Blank if unknown.
+	Dialect string
+
+	// Number of user tables. Nil if unknown.
+	TableCount *int64
+
+	// Bytes used by the tables, taken from the latest table size statistics. Nil if unknown.
+	UsedBytes *int64
+}
+
+// ListDatabases prints databases in the instance with their dialects, table counts and sizes.
+// Details which can't be fetched, e.g. for lack of permissions, are printed as unknown.
+// Only WithClientOptions is used among opts.
+func ListDatabases(ctx context.Context, projectID, instanceID string, out io.Writer, opts ...Option) error {
+	o := newOptions(opts)
+	adminClient, err := adminapi.NewDatabaseAdminClient(ctx, o.clientOptions...)
+	if err != nil {
+		return fmt.Errorf("failed to create Cloud Spanner admin client: %v", err)
+	}
+	defer adminClient.Close()
+
+	var dbs []*DatabaseInfo
+	iter := adminClient.ListDatabases(ctx, &adminpb.ListDatabasesRequest{
+		Parent: fmt.Sprintf("projects/%s/instances/%s", projectID, instanceID),
+	})
+	for {
+		db, err := iter.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("failed to list databases: %v", err)
+		}
+		dbs = append(dbs, &DatabaseInfo{
+			Name:  path.Base(db.GetName()),
+			State: db.GetState().String(),
+		})
+	}
+
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, maxConcurrentDatabases)
+	for _, db := range dbs {
+		if db.State != adminpb.Database_READY.String() {
+			continue
+		}
+		db := db
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			inspectDatabase(ctx, fmt.Sprintf("projects/%s/instances/%s/databases/%s", projectID, instanceID, db.Name), db, o.clientOptions)
+		}()
+	}
+	wg.Wait()
+
+	printDatabases(out, dbs)
+	return nil
+}
+
+// inspectDatabase fills details of the database on a best-effort basis.
+func inspectDatabase(ctx context.Context, database string, db *DatabaseInfo, clientOpts []option.ClientOption) {
+	// Keep the session pool small as the client is used only for a few queries.
+	client, err := spanner.NewClientWithConfig(ctx, database, spanner.ClientConfig{
+		SessionPoolConfig: spanner.SessionPoolConfig{MinOpened: 1},
+	}, clientOpts...)
+	if err != nil {
+		return
+	}
+	defer client.Close()
+
+	// Queries are written in lower case so that they work on both GoogleSQL and PostgreSQL dialects.
+	if dialect, err := queryString(ctx, client, "SELECT option_value FROM information_schema.database_options WHERE option_name = 'database_dialect'"); err == nil {
+		db.Dialect = dialect
+	}
+	if count, err := queryInt64(ctx, client, "SELECT COUNT(*) FROM information_schema.tables WHERE table_type = 'BASE TABLE' AND LOWER(table_schema) NOT IN ('information_schema', 'spanner_sys', 'pg_catalog')"); err == nil {
+		db.TableCount = &count
+	}
+	if bytes, err := queryInt64(ctx, client, "SELECT CAST(SUM(used_bytes) AS INT64) FROM spanner_sys.table_sizes_stats_1hour WHERE interval_end = (SELECT MAX(interval_end) FROM spanner_sys.table_sizes_stats_1hour)"); err == nil {
+		db.UsedBytes = &bytes
+	}
+}
+
+func queryString(ctx context.Context, client *spanner.Client, sql string) (string, error) {
+	var s spanner.NullString
+	if err := querySingleValue(ctx, client, sql, &s); err != nil {
+		return "", err
+	}
+	return s.StringVal, nil
+}
+
+func queryInt64(ctx context.Context, client *spanner.Client, sql string) (int64, error) {
+	var n spanner.NullInt64
+	if err := querySingleValue(ctx, client, sql, &n); err != nil {
+		return 0, err
+	}
+	if !n.Valid {
+		return 0, fmt.Errorf("no value returned by %q", sql)
+	}
+	return n.Int64, nil
+}
+
+func querySingleValue(ctx context.Context, client *spanner.Client, sql string, ptr interface{}) error {
+	iter := client.Single().Query(ctx, spanner.NewStatement(sql))
+	defer iter.Stop()
+
+	row, err := iter.Next()
+	if err != nil {
+		return err
+	}
+	return row.Column(0, ptr)
+}
+
+// printDatabases prints databases as a table.
+func printDatabases(out io.Writer, dbs []*DatabaseInfo) {
+	if len(dbs) == 0 {
+		fmt.Fprintf(out, "No databases found.\n")
+		return
+	}
+
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, strings.Join([]string{"DATABASE", "DIALECT", "STATE", "TABLES", "SIZE"}, "\t"))
+	for _, db := range dbs {
+		dialect := db.Dialect
+		if dialect == "" {
+			dialect = "unknown"
+		}
+		tables := "unknown"
+		if db.TableCount != nil {
+			tables = formatNumber(uint64(*db.TableCount))
+		}
+		size := "unknown"
+		if db.UsedBytes != nil {
+			size = formatBytes(*db.UsedBytes)
+		}
+		fmt.Fprintln(w, strings.Join([]string{db.Name, dialect, db.State, tables, size}, "\t"))
+	}
+	w.Flush()
+}
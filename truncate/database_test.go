@@ -0,0 +1,58 @@
+//
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package truncate
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestPrintDatabases(t *testing.T) {
+	tableCount := int64(12)
+	usedBytes := int64(3 * 1024 * 1024)
+	for _, test := range []struct {
+		desc string
+		dbs  []*DatabaseInfo
+		want string
+	}{
+		{
+			desc: "no databases",
+			want: "No databases found.\n",
+		},
+		{
+			desc: "databases",
+			dbs: []*DatabaseInfo{
+				{Name: "db1", State: "READY", Dialect: "GOOGLE_STANDARD_SQL", TableCount: &tableCount, UsedBytes: &usedBytes},
+				{Name: "db2", State: "CREATING"},
+			},
+			want: `DATABASE  DIALECT              STATE     TABLES   SIZE
+db1       GOOGLE_STANDARD_SQL  READY     12       3.0 MiB
+db2       unknown              CREATING  unknown  unknown
+`,
+		},
+	} {
+		t.Run(test.desc, func(t *testing.T) {
+			var out bytes.Buffer
+			printDatabases(&out, test.dbs)
+			if got := out.String(); got != test.want {
+				t.Errorf("diff(+got, -want) = %v", cmp.Diff(got, test.want))
+			}
+		})
+	}
+}
@@ -18,10 +18,18 @@ package truncate
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"cloud.google.com/go/spanner"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc/codes"
 )
 
 // Status is a delete status.
@@ -33,13 +41,110 @@ const (
 	statusDeleting                      // Status for deleting rows.
 	statusCascadeDeleting               // Status for deleting rows by parent in cascaded way.
 	statusCompleted                     // Status for delete completed.
+	statusFailed                        // Status for delete failed.
+	statusSkipped                       // Status for skipped without deleting rows.
 )
 
+func (s status) String() string {
+	switch s {
+	case statusAnalyzing:
+		return "analyzing"
+	case statusWaiting:
+		return "waiting"
+	case statusDeleting:
+		return "deleting"
+	case statusCascadeDeleting:
+		return "cascade deleting"
+	case statusCompleted:
+		return "completed"
+	case statusFailed:
+		return "failed"
+	case statusSkipped:
+		return "skipped"
+	default:
+		return "unknown"
+	}
+}
+
 // deleter deletes all rows from the table.
 type deleter struct {
 	tableName string
 	client    *spanner.Client
 	status    status
+	warnings  *warnings
+
+	// Custom statement builder for deleting rows. If nil, all rows are deleted.
+	statementBuilder StatementBuilder
+
+	// Statements executed before and after deleting rows.
+	preHooks  []string
+	postHooks []string
+
+	// Set to 1 once post hooks have been executed. Accessed atomically.
+	postHooksDone int32
+
+	// If true, the table is skipped when the caller lacks permissions to count rows.
+	skipUnauthorized bool
+
+	// Limits the rate of statements shared by all deleters. Nil if unlimited.
+	limiter *rateLimiter
+
+	// Options applied to all requests.
+	reqOpts requestOptions
+
+	// Logs the deletion of the table. Nil if not logged.
+	logger *slog.Logger
+
+	// If true, rows aren't counted during the deletion, so the table is regarded as completed once the deletion returns.
+	uncounted bool
+
+	// Rows are no longer counted once the table is found to have more than this number of rows. Disabled if 0.
+	countMaxRows int64
+
+	// Estimates the remaining time of the deletion from the progress of counts.
+	rate rateTracker
+
+	// If true, the table was found empty before the deletion started, and completed without being deleted.
+	alreadyEmpty bool
+
+	// How the table is deleted, one of the strategy constants.
+	// Blank until the deletion starts.
+	strategy string
+
+	// Total time the table's own deletions took, and the error which failed or skipped the table, for the report.
+	deleteDuration time.Duration
+	failure        error
+
+	// Foreign keys whose referencing rows must be deleted before the rows they reference, i.e. ones by which the table
+	// references itself, or ones of other tables in the same cycle. If any, rows are deleted leaf rows first.
+	leafReferences []*foreignKey
+
+	// Tables in the same cycle of foreign keys, which are deleted at the same time. Nil if the table isn't in a cycle.
+	cycle *cycleGroup
+
+	// If true, rows are deleted with a mutation instead of PDML. Decided by the coordinator when the deletion starts.
+	byMutation bool
+
+	// Number of Partitioned DML statements deleting key ranges of the table concurrently. Not sharded if less than 2.
+	shards int
+
+	// If true, rows are deleted in batches of read-write transactions instead of PDML.
+	// Decided by the coordinator when the deletion starts.
+	batched bool
+
+	// Maximum number of rows deleted in a read-write transaction, and the pause between transactions, when rows are
+	// deleted in transactions instead of PDML. A transaction deletes as many rows as a commit can hold if batchRows is 0.
+	batchRows  int64
+	batchPause time.Duration
+
+	// If true, the table fails when PDML is unsuitable for it, instead of being deleted in batches.
+	noFallback bool
+
+	// Restricts the rows deleted and counted. Nil if all rows are deleted.
+	filter *rowFilter
+
+	// Number of indexes on the table, whose entries count toward the mutation limit when rows are deleted in a transaction.
+	indexes int
 
 	// Total rows in the table.
 	// Once set, we don't update this number even if new rows are added to the table.
@@ -47,35 +152,366 @@ type deleter struct {
 
 	// Remained rows in the table.
 	remainedRows uint64
+
+	// Retries which occurred while deleting rows and running hooks.
+	retries retryCounter
+
+	// How deletions failed with transient errors are retried. Never retried if zero.
+	retryPolicy retryPolicy
+
+	// Timeout of the table's own deletion including retries. Unlimited if zero.
+	timeout time.Duration
+
+	mu         sync.Mutex // Guards inFlight and inFlightAt.
+	inFlight   string     // Operation being executed, e.g. OpDelete. Blank if none.
+	inFlightAt time.Time  // When the operation in flight started.
 }
 
-// deleteRows deletes rows from the table using PDML.
-func (d *deleter) deleteRows(ctx context.Context) error {
+// deleteRows deletes rows from the table using PDML, or a mutation if the table is small enough.
+// A deletion failed with a transient error is retried according to the retry policy.
+func (d *deleter) deleteRows(ctx context.Context) (err error) {
+	ctx, span := startSpan(ctx, "delete table", attribute.String("spanner.table", d.tableName), attribute.Bool("truncate.by_mutation", d.byMutation))
+	defer func() { endSpan(span, err) }()
 	d.status = statusDeleting
-	stmt := spanner.NewStatement(fmt.Sprintf("DELETE FROM `%s` WHERE true", d.tableName))
-	_, err := d.client.PartitionedUpdate(ctx, stmt)
-	return err
+	if d.timeout > 0 {
+		parent := ctx
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, d.timeout)
+		defer cancel()
+		defer func() {
+			if err != nil && ctx.Err() == context.DeadlineExceeded && parent.Err() == nil {
+				err = &TableTimeoutError{TableName: d.tableName, Timeout: d.timeout, Err: err}
+			}
+		}()
+	}
+	onRetry := func(err error, backoff time.Duration) {
+		atomic.AddInt64(&d.retries.deleteRetries, 1)
+		orDiscard(d.logger).Warn("retrying deletion", "error", err, "backoff", backoff)
+	}
+	deleteOnce := func() error {
+		switch {
+		case d.byMutation:
+			return d.deleteRowsByMutation(ctx)
+		case d.deletesLeavesFirst():
+			return d.deleteRowsLeavesFirst(ctx)
+		case d.batched:
+			return d.deleteRowsBatched(ctx)
+		case d.shards > 1:
+			return d.deleteRowsSharded(ctx)
+		}
+		return d.deleteRowsByPDML(ctx)
+	}
+	err = d.retryPolicy.do(ctx, onRetry, deleteOnce)
+	if !d.fallsBackToBatches(ctx, err) {
+		return err
+	}
+	// Batches as large as a commit can hold delete the table almost as fast as PDML would.
+	d.warnings.add(d.tableName, "falling back to batched DML as Partitioned DML failed: %v", err)
+	orDiscard(d.logger).Warn("falling back to batched DML", "error", err)
+	d.batched = true
+	d.shards = 0
+	d.strategy = strategyBatched
+	return d.retryPolicy.do(ctx, onRetry, deleteOnce)
+}
+
+// deletesLeavesFirst returns true if rows are deleted leaf rows first, because the table references itself or is in a cycle.
+// A mutation deletes all rows of a table referencing itself in a single commit, so it doesn't have to,
+// and a custom statement is up to the user.
+func (d *deleter) deletesLeavesFirst() bool {
+	return len(d.leafReferences) > 0 && !d.byMutation && d.statementBuilder == nil
+}
+
+// deleteRowsLeavesFirst deletes rows of a table referencing itself or in a cycle, by repeatedly deleting rows which
+// no other rows reference. Partitioned DML can't do it, as it can neither delete a row before the rows referencing it
+// nor join tables, so each pass reads the keys of leaf rows and deletes them in a read-write transaction.
+// Each pass deletes a batch of rows sized to stay under the mutation limit of a commit, and the batch is halved
+// whenever the transaction turns out to be too large, e.g. because of rows of interleaved descendants.
+// When rows remain but none of them can be deleted, a table in a cycle waits for the other tables to delete rows.
+// It fails if rows remain which reference each other in a cycle.
+func (d *deleter) deleteRowsLeavesFirst(ctx context.Context) error {
+	keyColumns, err := fetchKeyColumns(ctx, d.client, d.tableName)
+	if err != nil {
+		return newTableError(d.tableName, OpDelete, "", fmt.Errorf("failed to fetch the primary key: %w", err))
+	}
+	batchRows := d.batchSize()
+	stmt := leafRowsBatchStatement(d.tableName, keyColumns, d.leafReferences, d.filter, batchRows)
+	ctx = withRetryCounter(ctx, &d.retries)
+	defer d.beginOperation(OpDelete)()
+	d.cycle.started(d.tableName)
+	defer d.cycle.finished(d.tableName)
+	var deleted int64
+	for {
+		if err := d.limiter.wait(ctx); err != nil {
+			return newTableError(d.tableName, OpDelete, stmt.SQL, err)
+		}
+		var count int64
+		var remains bool
+		attempts := 0
+		if _, err := d.client.ReadWriteTransactionWithOptions(ctx, func(ctx context.Context, txn *spanner.ReadWriteTransaction) error {
+			// The function is called again when the transaction is aborted.
+			if attempts++; attempts > 1 {
+				atomic.AddInt64(&d.retries.transactionAborts, 1)
+			}
+			var err error
+			if count, err = deleteBatch(ctx, txn, d.tableName, keyColumns, stmt, d.reqOpts.query()); err != nil || count > 0 {
+				return err
+			}
+			// Nothing was deleted, so the table is empty or the remaining rows reference each other.
+			remaining := d.filter.withParams(spanner.NewStatement(fmt.Sprintf("SELECT 1 FROM %s%s LIMIT 1", quoteIdentifier(d.tableName), d.filter.where())))
+			iter := txn.QueryWithOptions(ctx, remaining, d.reqOpts.query())
+			return iter.Do(func(*spanner.Row) error {
+				remains = true
+				return nil
+			})
+		}, d.reqOpts.transaction()); err != nil {
+			if isTransactionTooLarge(err) && batchRows > 1 && len(keyColumns) > 0 {
+				batchRows /= 2
+				stmt = leafRowsBatchStatement(d.tableName, keyColumns, d.leafReferences, d.filter, batchRows)
+				orDiscard(d.logger).Debug("transaction too large, shrinking the batch", "rows", batchRows)
+				continue
+			}
+			return newTableError(d.tableName, OpDelete, stmt.SQL, err)
+		}
+		if count > 0 {
+			deleted += count
+			d.cycle.deleted(d.tableName)
+			orDiscard(d.logger).Debug("leaf rows deleted", "rows", count)
+			if err := d.pauseBatch(ctx); err != nil {
+				return newTableError(d.tableName, OpDelete, stmt.SQL, err)
+			}
+			continue
+		}
+		if !remains {
+			break
+		}
+		// Rows of the other tables in the cycle may still reference the remaining rows.
+		progressed, err := d.cycle.waitProgress(ctx, d.tableName)
+		if err != nil {
+			return newTableError(d.tableName, OpDelete, stmt.SQL, err)
+		}
+		if !progressed {
+			return newTableError(d.tableName, OpDelete, stmt.SQL, errors.New("rows remain which reference each other in a cycle"))
+		}
+	}
+	orDiscard(d.logger).Info("leaf rows deleted until empty", "rows", deleted)
+	trace.SpanFromContext(ctx).SetAttributes(attribute.Int64("truncate.deleted_rows", deleted))
+
+	if d.uncounted {
+		if d.totalRows == 0 {
+			d.totalRows = uint64(deleted)
+		}
+		d.remainedRows = 0
+		d.status = statusCompleted
+	}
+	return nil
+}
+
+// maxMutationsPerCommit is the maximum number of mutations in a commit of a transaction.
+const maxMutationsPerCommit = 80000
+
+// leafBatchRows returns the number of leaf rows deleted in a transaction, so that deleting the rows and their index
+// entries doesn't exceed the mutation limit of a commit.
+func (d *deleter) leafBatchRows() int64 {
+	return maxMutationsPerCommit / int64(1+d.indexes)
+}
+
+// leafRowsStatement returns a statement deleting rows of the table which no other rows reference by the foreign keys.
+// A row referencing itself doesn't prevent its own deletion.
+func leafRowsStatement(tableName string, fks []*foreignKey) spanner.Statement {
+	return spanner.NewStatement(fmt.Sprintf("DELETE FROM %s AS t WHERE %s", quoteIdentifier(tableName), leafRowsCondition(tableName, fks)))
+}
+
+// leafRowsBatchStatement returns a statement reading the primary keys of up to limit leaf rows of the table matching the filter.
+func leafRowsBatchStatement(tableName string, keyColumns []string, fks []*foreignKey, filter *rowFilter, limit int64) spanner.Statement {
+	return filter.withParams(batchKeysStatement(tableName, keyColumns, filter.and(leafRowsCondition(tableName, fks)), limit))
+}
+
+// leafRowsCondition returns a condition matching rows of the table, aliased as t, which no other rows reference.
+func leafRowsCondition(tableName string, fks []*foreignKey) string {
+	conds := make([]string, len(fks))
+	for i, fk := range fks {
+		var references, sameRow []string
+		for j, column := range fk.referencingColumns {
+			referenced := quoteIdentifier(fk.referencedColumns[j])
+			references = append(references, fmt.Sprintf("r.%s = t.%s", quoteIdentifier(column), referenced))
+			sameRow = append(sameRow, fmt.Sprintf("r.%s = t.%s", referenced, referenced))
+		}
+		if fk.referencingTable == tableName {
+			references = append(references, fmt.Sprintf("NOT IFNULL(%s, FALSE)", strings.Join(sameRow, " AND ")))
+		}
+		conds[i] = fmt.Sprintf("NOT EXISTS (SELECT 1 FROM %s AS r WHERE %s)", quoteIdentifier(fk.referencingTable), strings.Join(references, " AND "))
+	}
+	return strings.Join(conds, " AND ")
+}
+
+// deleteRowsByPDML deletes rows from the table with a Partitioned DML statement.
+func (d *deleter) deleteRowsByPDML(ctx context.Context) error {
+	stmt := d.deleteStatement()
+	if err := d.limiter.wait(ctx); err != nil {
+		return newTableError(d.tableName, OpDelete, stmt.SQL, err)
+	}
+	ctx = withRetryCounter(ctx, &d.retries)
+	defer d.beginOperation(OpDelete)()
+	atomic.AddInt64(&d.retries.pdmlRuns, 1)
+	count, err := d.client.PartitionedUpdateWithOptions(ctx, stmt, d.reqOpts.query())
+	if err != nil {
+		return newTableError(d.tableName, OpDelete, stmt.SQL, err)
+	}
+	// The count is a lower bound, which doesn't include rows deleted in cascade.
+	orDiscard(d.logger).Info("partitioned DML finished", "rows", count)
+	trace.SpanFromContext(ctx).SetAttributes(attribute.Int64("truncate.deleted_rows", count))
+
+	switch {
+	case d.statementBuilder != nil:
+		// A custom statement may not delete all rows, so we don't wait for the table being empty.
+		d.totalRows = uint64(count)
+		d.remainedRows = 0
+		d.status = statusCompleted
+	case d.uncounted:
+		// Nobody finds the table empty by counting, so the end of the statement is regarded as the completion.
+		if d.totalRows == 0 {
+			d.totalRows = uint64(count)
+		}
+		d.remainedRows = 0
+		d.status = statusCompleted
+	}
+	return nil
+}
+
+// deleteRowsByMutation deletes all rows from the table with a mutation in a single commit.
+// Rows of descendants are deleted in cascade as well as PDML.
+func (d *deleter) deleteRowsByMutation(ctx context.Context) error {
+	if err := d.limiter.wait(ctx); err != nil {
+		return newTableError(d.tableName, OpDelete, "", err)
+	}
+	ctx = withRetryCounter(ctx, &d.retries)
+	defer d.beginOperation(OpDelete)()
+	if _, err := d.client.Apply(ctx, []*spanner.Mutation{spanner.Delete(d.tableName, spanner.AllKeys())}, d.reqOpts.apply()...); err != nil {
+		return newTableError(d.tableName, OpDelete, "", err)
+	}
+	orDiscard(d.logger).Info("mutation applied")
+	if d.uncounted {
+		d.remainedRows = 0
+		d.status = statusCompleted
+	}
+	return nil
+}
+
+// deleteStatement returns a statement to delete rows from the table.
+func (d *deleter) deleteStatement() spanner.Statement {
+	if d.statementBuilder != nil {
+		return d.statementBuilder(d.tableName)
+	}
+	if d.filter != nil {
+		return d.filter.withParams(spanner.NewStatement(fmt.Sprintf("DELETE FROM %s%s", quoteIdentifier(d.tableName), d.filter.where())))
+	}
+	return spanner.NewStatement(fmt.Sprintf("DELETE FROM %s WHERE true", quoteIdentifier(d.tableName)))
+}
+
+// When a parent deletion completed, regard its child as completed because the rows were deleted in cascade,
+// or, with a custom statement, rows which the statement didn't delete will never be deleted in cascade.
+func (d *deleter) parentDeletionCompleted() {
+	if d.status == statusCascadeDeleting {
+		d.status = statusCompleted
+	}
+}
+
+// isDone returns true if the deleter has nothing to do anymore.
+func (d *deleter) isDone() bool {
+	return d.status == statusCompleted || d.status == statusFailed || d.status == statusSkipped
 }
 
 // When parent deletion started, change child status unless the child deletion has already completed.
 func (d *deleter) parentDeletionStarted() {
 	if d.status != statusCompleted {
 		d.status = statusCascadeDeleting
+		d.strategy = strategyCascade
 	}
 }
 
+// runPreHooks executes statements registered to be executed before the deletion.
+func (d *deleter) runPreHooks(ctx context.Context) error {
+	return d.runHooks(ctx, OpPreHook, d.preHooks)
+}
+
+// runPostHooks executes statements registered to be executed after the deletion.
+// The caller marks them done with finishPostHooks once their failure, if any, has been recorded.
+func (d *deleter) runPostHooks(ctx context.Context) error {
+	return d.runHooks(ctx, OpPostHook, d.postHooks)
+}
+
+// finishPostHooks marks post hooks as done. They are regarded as done even if they failed, because they are never retried.
+func (d *deleter) finishPostHooks() {
+	atomic.StoreInt32(&d.postHooksDone, 1)
+}
+
+// isPostHooksDone returns true if there are no pending post hooks.
+func (d *deleter) isPostHooksDone() bool {
+	return len(d.postHooks) == 0 || atomic.LoadInt32(&d.postHooksDone) == 1
+}
+
+func (d *deleter) runHooks(ctx context.Context, op string, sqls []string) error {
+	if len(sqls) > 0 {
+		defer d.beginOperation(op)()
+	}
+	ctx = withRetryCounter(ctx, &d.retries)
+	for _, sql := range sqls {
+		orDiscard(d.logger).Info("running hook", "op", op, "sql", sql)
+		stmt := spanner.NewStatement(sql)
+		if err := d.limiter.wait(ctx); err != nil {
+			return newTableError(d.tableName, op, stmt.SQL, err)
+		}
+		attempts := 0
+		if _, err := d.client.ReadWriteTransactionWithOptions(ctx, func(ctx context.Context, txn *spanner.ReadWriteTransaction) error {
+			// The function is called again when the transaction is aborted.
+			if attempts++; attempts > 1 {
+				atomic.AddInt64(&d.retries.transactionAborts, 1)
+			}
+			_, err := txn.UpdateWithOptions(ctx, stmt, d.reqOpts.query())
+			return err
+		}, d.reqOpts.transaction()); err != nil {
+			return newTableError(d.tableName, op, stmt.SQL, err)
+		}
+	}
+	return nil
+}
+
+// beginOperation records the operation as in flight, and returns a function to be called when it ends.
+func (d *deleter) beginOperation(op string) func() {
+	d.mu.Lock()
+	d.inFlight, d.inFlightAt = op, time.Now()
+	d.mu.Unlock()
+	return func() {
+		d.mu.Lock()
+		d.inFlight, d.inFlightAt = "", time.Time{}
+		d.mu.Unlock()
+	}
+}
+
+// operationInFlight returns the operation being executed and when it started. It returns a blank op if none.
+func (d *deleter) operationInFlight() (op string, startedAt time.Time) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.inFlight, d.inFlightAt
+}
+
 // startRowCountUpdater starts periodical row count in another goroutine.
 func (d *deleter) startRowCountUpdater(ctx context.Context) {
 	go func() {
 		for {
-			if d.status == statusCompleted {
+			if d.isDone() || d.uncounted {
+				return
+			}
+			// Wait outside of the measured time, so that throttling doesn't lengthen the sleep below.
+			if err := d.limiter.wait(ctx); err != nil {
 				return
 			}
 
 			begin := time.Now()
 
-			// Ignore error as it could be a temporal error.
-			d.updateRowCount(ctx)
+			if err := d.updateRowCount(ctx); err != nil && ctx.Err() == nil {
+				d.handleCountError(err)
+			}
 
 			// Sleep for a while to minimize the impact on CPU usage caused by SELECT COUNT(*) queries.
 			time.Sleep(time.Since(begin) * 10)
@@ -83,17 +519,33 @@ func (d *deleter) startRowCountUpdater(ctx context.Context) {
 	}()
 }
 
-func (d *deleter) updateRowCount(ctx context.Context) error {
-	stmt := spanner.NewStatement(fmt.Sprintf("SELECT COUNT(*) as count FROM `%s`", d.tableName))
-	var count int64
+// handleCountError reports a failure to count rows as a warning, without stopping counting as it could be a temporal error.
+// A table which the caller lacks permissions on is skipped instead if skipUnauthorized is set and it isn't deleting yet.
+func (d *deleter) handleCountError(err error) {
+	if d.skipUnauthorized && errorCode(err) == codes.PermissionDenied && (d.status == statusAnalyzing || d.status == statusWaiting) {
+		d.status = statusSkipped
+		d.warnings.add(d.tableName, "SKIPPED due to insufficient permissions: %v", errors.Unwrap(err))
+		return
+	}
+	d.warnings.add(d.tableName, "failed to count rows: %v", errors.Unwrap(err))
+}
 
-	// Use stale read to minimize the impact on the leader replica.
-	txn := d.client.Single().WithTimestampBound(spanner.ExactStaleness(time.Second))
-	if err := txn.Query(ctx, stmt).Do(func(r *spanner.Row) error {
-		return r.ColumnByName("count", &count)
-	}); err != nil {
+func (d *deleter) updateRowCount(ctx context.Context) error {
+	count, err := countRows(ctx, d.client, d.tableName, d.filter, d.reqOpts)
+	if err != nil {
 		return err
 	}
+	orDiscard(d.logger).Debug("counted rows", "rows", count)
+	d.setRowCount(count)
+	return nil
+}
+
+// setRowCount updates the remaining rows by the count, and the status if the table is found empty or ready.
+func (d *deleter) setRowCount(count int64) {
+	// The deletion may have finished while counting.
+	if d.isDone() {
+		return
+	}
 
 	if d.totalRows == 0 {
 		d.totalRows = uint64(count)
@@ -106,5 +558,47 @@ func (d *deleter) updateRowCount(ctx context.Context) error {
 		d.status = statusWaiting
 	}
 
-	return nil
+	if d.countMaxRows > 0 && count > d.countMaxRows && !d.isDone() {
+		// Counting a huge table takes long and competes with the deletion, so it's regarded as completed once
+		// the deletion returns, as well as a table which isn't counted at all.
+		orDiscard(d.logger).Info("stopped counting rows", "rows", count, "threshold", d.countMaxRows)
+		d.uncounted = true
+	}
+}
+
+// estimateRemaining returns the estimated time until all rows are deleted, while the table is being deleted and counted.
+func (d *deleter) estimateRemaining(now time.Time) (time.Duration, bool) {
+	if (d.status != statusDeleting && d.status != statusCascadeDeleting) || d.uncounted || !d.hasRowCount() {
+		return 0, false
+	}
+	return d.rate.estimate(now, d.totalRows-d.remainedRows, d.totalRows)
+}
+
+// hasRowCount returns true if the number of rows in the table is known, by counting, estimating, or deleting them.
+func (d *deleter) hasRowCount() bool {
+	return d.status != statusAnalyzing && (!d.uncounted || d.totalRows > 0)
+}
+
+// setEstimatedRows updates the remaining rows by an estimate. Unlike a count, an estimate never completes the table,
+// and never increases the remaining rows.
+func (d *deleter) setEstimatedRows(rows int64) {
+	if d.isDone() || uint64(rows) >= d.remainedRows {
+		return
+	}
+	d.remainedRows = uint64(rows)
+}
+
+// countRows counts rows in the table matching the filter.
+func countRows(ctx context.Context, client *spanner.Client, tableName string, filter *rowFilter, reqOpts requestOptions) (int64, error) {
+	stmt := filter.withParams(spanner.NewStatement(fmt.Sprintf("SELECT COUNT(*) as count FROM %s%s", quoteIdentifier(tableName), filter.where())))
+	var count int64
+
+	// Use stale read to minimize the impact on the leader replica.
+	txn := client.Single().WithTimestampBound(spanner.ExactStaleness(time.Second))
+	if err := txn.QueryWithOptions(ctx, stmt, reqOpts.query()).Do(func(r *spanner.Row) error {
+		return r.ColumnByName("count", &count)
+	}); err != nil {
+		return 0, newTableError(tableName, OpCount, stmt.SQL, err)
+	}
+	return count, nil
 }
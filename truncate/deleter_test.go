@@ -0,0 +1,244 @@
+//
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package truncate
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/spanner"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestDeleteStatement(t *testing.T) {
+	for _, tt := range []struct {
+		desc    string
+		deleter *deleter
+		want    spanner.Statement
+	}{
+		{
+			desc:    "Delete all rows",
+			deleter: &deleter{tableName: "Events"},
+			want:    spanner.NewStatement("DELETE FROM `Events` WHERE true"),
+		},
+		{
+			desc:    "Delete all rows in a named schema",
+			deleter: &deleter{tableName: "analytics.Events"},
+			want:    spanner.NewStatement("DELETE FROM `analytics`.`Events` WHERE true"),
+		},
+		{
+			desc: "Custom statement",
+			deleter: &deleter{
+				tableName: "Events",
+				statementBuilder: func(tableName string) spanner.Statement {
+					return spanner.Statement{
+						SQL:    "DELETE FROM " + tableName + " WHERE TenantId = @tid",
+						Params: map[string]interface{}{"tid": "tenant1"},
+					}
+				},
+			},
+			want: spanner.Statement{
+				SQL:    "DELETE FROM Events WHERE TenantId = @tid",
+				Params: map[string]interface{}{"tid": "tenant1"},
+			},
+		},
+	} {
+		t.Run(tt.desc, func(t *testing.T) {
+			if diff := cmp.Diff(tt.deleter.deleteStatement(), tt.want); diff != "" {
+				t.Errorf("mismatch (-got +want):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestLeafRowsStatement(t *testing.T) {
+	for _, tt := range []struct {
+		desc      string
+		tableName string
+		fks       []*foreignKey
+		want      string
+	}{
+		{
+			desc:      "single column",
+			tableName: "Employees",
+			fks: []*foreignKey{
+				{referencingTable: "Employees", referencingColumns: []string{"ManagerId"}, referencedColumns: []string{"EmployeeId"}},
+			},
+			want: "DELETE FROM `Employees` AS t WHERE NOT EXISTS (SELECT 1 FROM `Employees` AS r WHERE r.`ManagerId` = t.`EmployeeId` AND NOT IFNULL(r.`EmployeeId` = t.`EmployeeId`, FALSE))",
+		},
+		{
+			desc:      "another table in a cycle",
+			tableName: "Singers",
+			fks: []*foreignKey{
+				{referencingTable: "Albums", referencingColumns: []string{"SingerId"}, referencedColumns: []string{"SingerId"}},
+			},
+			want: "DELETE FROM `Singers` AS t WHERE NOT EXISTS (SELECT 1 FROM `Albums` AS r WHERE r.`SingerId` = t.`SingerId`)",
+		},
+		{
+			desc:      "multiple foreign keys with composite columns",
+			tableName: "hr.Employees",
+			fks: []*foreignKey{
+				{referencingTable: "hr.Employees", referencingColumns: []string{"OrgId", "ManagerId"}, referencedColumns: []string{"OrgId", "EmployeeId"}},
+				{referencingTable: "hr.Employees", referencingColumns: []string{"MentorId"}, referencedColumns: []string{"EmployeeId"}},
+			},
+			want: "DELETE FROM `hr`.`Employees` AS t WHERE " +
+				"NOT EXISTS (SELECT 1 FROM `hr`.`Employees` AS r WHERE r.`OrgId` = t.`OrgId` AND r.`ManagerId` = t.`EmployeeId` AND NOT IFNULL(r.`OrgId` = t.`OrgId` AND r.`EmployeeId` = t.`EmployeeId`, FALSE)) AND " +
+				"NOT EXISTS (SELECT 1 FROM `hr`.`Employees` AS r WHERE r.`MentorId` = t.`EmployeeId` AND NOT IFNULL(r.`EmployeeId` = t.`EmployeeId`, FALSE))",
+		},
+	} {
+		t.Run(tt.desc, func(t *testing.T) {
+			if got := leafRowsStatement(tt.tableName, tt.fks).SQL; got != tt.want {
+				t.Errorf("leafRowsStatement() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLeafRowsBatchStatement(t *testing.T) {
+	fks := []*foreignKey{
+		{referencingTable: "Employees", referencingColumns: []string{"ManagerId"}, referencedColumns: []string{"EmployeeId"}},
+	}
+	for _, tt := range []struct {
+		desc       string
+		keyColumns []string
+		want       spanner.Statement
+	}{
+		{
+			desc:       "composite key",
+			keyColumns: []string{"OrgId", "EmployeeId"},
+			want: spanner.Statement{
+				SQL: "SELECT t.`OrgId`, t.`EmployeeId` FROM `Employees` AS t WHERE " +
+					"NOT EXISTS (SELECT 1 FROM `Employees` AS r WHERE r.`ManagerId` = t.`EmployeeId` AND NOT IFNULL(r.`EmployeeId` = t.`EmployeeId`, FALSE)) LIMIT @limit",
+				Params: map[string]interface{}{"limit": int64(1000)},
+			},
+		},
+		{
+			desc:       "empty key",
+			keyColumns: nil,
+			want: spanner.Statement{
+				SQL: "SELECT 1 FROM `Employees` AS t WHERE " +
+					"NOT EXISTS (SELECT 1 FROM `Employees` AS r WHERE r.`ManagerId` = t.`EmployeeId` AND NOT IFNULL(r.`EmployeeId` = t.`EmployeeId`, FALSE)) LIMIT @limit",
+				Params: map[string]interface{}{"limit": int64(1000)},
+			},
+		},
+	} {
+		t.Run(tt.desc, func(t *testing.T) {
+			if diff := cmp.Diff(leafRowsBatchStatement("Employees", tt.keyColumns, fks, nil, 1000), tt.want); diff != "" {
+				t.Errorf("mismatch (-got +want):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestLeafBatchRows(t *testing.T) {
+	if got, want := (&deleter{indexes: 3}).leafBatchRows(), int64(20000); got != want {
+		t.Errorf("leafBatchRows() = %d, want %d", got, want)
+	}
+}
+
+func TestCompleteCascade(t *testing.T) {
+	parent := &table{tableName: "A", deleter: &deleter{status: statusCompleted}}
+	child := &table{tableName: "B", deleter: &deleter{status: statusCascadeDeleting}}
+	grandChild := &table{tableName: "C", deleter: &deleter{status: statusCascadeDeleting}}
+	waiting := &table{tableName: "D", deleter: &deleter{status: statusWaiting}}
+	parent.childTables = []*table{child, waiting}
+	child.childTables = []*table{grandChild}
+
+	completeCascade(parent.childTables)
+
+	for _, tt := range []struct {
+		table *table
+		want  status
+	}{
+		{child, statusCompleted},
+		{grandChild, statusCompleted},
+		{waiting, statusWaiting},
+	} {
+		if got := tt.table.deleter.status; got != tt.want {
+			t.Errorf("status of %s = %v, but want = %v", tt.table.tableName, got, tt.want)
+		}
+	}
+}
+
+func TestSetRowCountThreshold(t *testing.T) {
+	for _, tt := range []struct {
+		desc          string
+		count         int64
+		wantUncounted bool
+		wantStatus    status
+	}{
+		{desc: "below the threshold", count: 100, wantUncounted: false, wantStatus: statusWaiting},
+		{desc: "above the threshold", count: 101, wantUncounted: true, wantStatus: statusWaiting},
+		{desc: "empty", count: 0, wantUncounted: false, wantStatus: statusCompleted},
+	} {
+		t.Run(tt.desc, func(t *testing.T) {
+			d := &deleter{tableName: "Events", countMaxRows: 100}
+			d.setRowCount(tt.count)
+			if d.uncounted != tt.wantUncounted || d.status != tt.wantStatus {
+				t.Errorf("uncounted = %v, status = %v, want %v, %v", d.uncounted, d.status, tt.wantUncounted, tt.wantStatus)
+			}
+			// The first count is kept for the decisions depending on the number of rows.
+			if !d.hasRowCount() {
+				t.Errorf("hasRowCount() = false, want true")
+			}
+		})
+	}
+}
+
+func TestHasUncountedTables(t *testing.T) {
+	counted := &table{tableName: "A", deleter: &deleter{}}
+	uncounted := &table{tableName: "B", deleter: &deleter{uncounted: true}}
+	parent := &table{tableName: "C", deleter: &deleter{}, childTables: []*table{uncounted}}
+
+	for _, tt := range []struct {
+		desc   string
+		tables []*table
+		want   bool
+	}{
+		{desc: "counted", tables: []*table{counted}, want: false},
+		{desc: "uncounted", tables: []*table{counted, uncounted}, want: true},
+		{desc: "uncounted descendant", tables: []*table{parent}, want: true},
+		{desc: "none", tables: nil, want: false},
+	} {
+		t.Run(tt.desc, func(t *testing.T) {
+			if got := hasUncountedTables(tt.tables); got != tt.want {
+				t.Errorf("hasUncountedTables() = %v, but want = %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDeleteRowsTableTimeout(t *testing.T) {
+	// The limiter allows an operation per hour and has just been used, so the deletion waits until the table times out.
+	limiter := newRateLimiter(1.0 / 3600)
+	limiter.reserve(time.Now())
+	d := &deleter{tableName: "Singers", byMutation: true, limiter: limiter, timeout: 10 * time.Millisecond}
+
+	err := d.deleteRows(context.Background())
+	var timeoutErr *TableTimeoutError
+	if !errors.As(err, &timeoutErr) {
+		t.Fatalf("deleteRows() = %v, want *TableTimeoutError", err)
+	}
+	if timeoutErr.TableName != "Singers" || timeoutErr.Timeout != 10*time.Millisecond {
+		t.Errorf("got table %s and timeout %s, want Singers and 10ms", timeoutErr.TableName, timeoutErr.Timeout)
+	}
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("errors.Is(err, context.DeadlineExceeded) = false, but want true")
+	}
+}
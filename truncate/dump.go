@@ -0,0 +1,144 @@
+//
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package truncate
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+// maxDumpedWarnings is the maximum number of recent warnings included in a state dump.
+const maxDumpedWarnings = 10
+
+// WithStateDump dumps the internal state of the run to out each time trigger receives a value, without interrupting the run.
+// The dump includes statuses of tables, reasons why waiting tables are blocked, operations in flight,
+// and recent failures and warnings. It's useful for debugging a run which seems to hang.
+func WithStateDump(trigger <-chan struct{}, out io.Writer) Option {
+	return func(o *options) {
+		o.stateDumpTrigger = trigger
+		o.stateDumpOut = out
+	}
+}
+
+// stateDumper dumps the state of a run on demand.
+type stateDumper struct {
+	database string
+	warnings *warnings
+
+	mu          sync.Mutex // Guards phase and coordinator.
+	phase       string
+	coordinator *coordinator
+}
+
+func newStateDumper(database string, warnings *warnings) *stateDumper {
+	return &stateDumper{database: database, warnings: warnings}
+}
+
+// setPhase records what the run is doing. It is safe to call setPhase on a nil receiver.
+func (d *stateDumper) setPhase(phase string) {
+	if d == nil {
+		return
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.phase = phase
+}
+
+// setCoordinator makes dumps include the state of deletion. It is safe to call setCoordinator on a nil receiver.
+func (d *stateDumper) setCoordinator(c *coordinator) {
+	if d == nil {
+		return
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.coordinator = c
+}
+
+// listen dumps the state to out on each trigger until ctx is done.
+func (d *stateDumper) listen(ctx context.Context, trigger <-chan struct{}, out io.Writer) {
+	go func() {
+		for {
+			select {
+			case <-trigger:
+				d.dump(out, time.Now())
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+}
+
+func (d *stateDumper) dump(out io.Writer, now time.Time) {
+	d.mu.Lock()
+	phase, c := d.phase, d.coordinator
+	d.mu.Unlock()
+
+	fmt.Fprintf(out, "=== State dump of %s at %s ===\n", d.database, now.Format(time.RFC3339))
+	fmt.Fprintf(out, "Phase: %s\n", phase)
+
+	if c != nil {
+		c.mu.Lock()
+		var started, completed int
+		for _, w := range c.waves {
+			started++
+			if w.completed {
+				completed++
+			}
+		}
+		c.mu.Unlock()
+		fmt.Fprintf(out, "Waves: %d started, %d completed\n", started, completed)
+
+		fmt.Fprintf(out, "Tables:\n")
+		for _, t := range flattenTables(c.tables) {
+			status := t.deleter.status
+			line := fmt.Sprintf("  %s: %s", t.tableName, status)
+			if status != statusAnalyzing {
+				line += fmt.Sprintf(" (%s / %s rows deleted)", formatNumber(t.deleter.totalRows-t.deleter.remainedRows), formatNumber(t.deleter.totalRows))
+			}
+			if op, startedAt := t.deleter.operationInFlight(); op != "" {
+				line += fmt.Sprintf(", %s in flight for %s", op, now.Sub(startedAt).Truncate(time.Second))
+			}
+			fmt.Fprintln(out, line)
+			if status == statusWaiting || status == statusAnalyzing {
+				for _, b := range t.blockers() {
+					fmt.Fprintf(out, "    blocked: %s\n", b)
+				}
+			}
+		}
+
+		if failures := c.failureList(); len(failures) > 0 {
+			fmt.Fprintf(out, "Failures:\n")
+			for _, err := range failures {
+				fmt.Fprintf(out, "  - %v\n", err)
+			}
+		}
+	}
+
+	if list := d.warnings.all(); len(list) > 0 {
+		if len(list) > maxDumpedWarnings {
+			list = list[len(list)-maxDumpedWarnings:]
+		}
+		fmt.Fprintf(out, "Recent warnings:\n")
+		for _, w := range list {
+			fmt.Fprintf(out, "  - %s\n", w.String())
+		}
+	}
+	fmt.Fprintf(out, "===\n")
+}
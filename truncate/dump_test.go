@@ -0,0 +1,65 @@
+//
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package truncate
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestStateDump(t *testing.T) {
+	now := time.Date(2021, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	// Singers -- Albums (NO ACTION), Concerts references Singers.
+	singers := &table{tableName: "Singers", deleter: &deleter{status: statusWaiting, totalRows: 6000, remainedRows: 6000}}
+	albums := &table{tableName: "Albums", parentOnDeleteAction: deleteActionNoAction, deleter: &deleter{status: statusDeleting, totalRows: 1800, remainedRows: 800}}
+	concerts := &table{tableName: "Concerts", deleter: &deleter{status: statusAnalyzing}}
+	singers.childTables = []*table{albums}
+	singers.referencedBy = []*table{concerts}
+	albums.deleter.inFlight, albums.deleter.inFlightAt = OpDelete, now.Add(-42*time.Second)
+
+	ws := newWarnings(nil)
+	ws.add("Concerts", "failed to count rows: deadline exceeded")
+	c := &coordinator{tables: []*table{concerts, singers}, waves: []*wave{{id: 1, tables: []*table{albums}}}}
+
+	d := newStateDumper("projects/p/instances/i/databases/d", ws)
+	d.setPhase("deleting rows")
+	d.setCoordinator(c)
+
+	var buf bytes.Buffer
+	d.dump(&buf, now)
+
+	want := `=== State dump of projects/p/instances/i/databases/d at 2021-06-01T12:00:00Z ===
+Phase: deleting rows
+Waves: 1 started, 0 completed
+Tables:
+  Concerts: analyzing
+  Singers: waiting (0 / 6,000 rows deleted)
+    blocked: child Albums with ON DELETE NO ACTION is not deleted yet
+    blocked: referencing table Concerts is not deleted yet
+  Albums: deleting (1,000 / 1,800 rows deleted), delete in flight for 42s
+Recent warnings:
+  - Concerts: failed to count rows: deadline exceeded
+===
+`
+	if got := buf.String(); got != want {
+		t.Errorf("dump() mismatch (-got +want):\n%s", cmp.Diff(got, want))
+	}
+}
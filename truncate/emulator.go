@@ -0,0 +1,57 @@
+//
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package truncate
+
+import (
+	"context"
+	"os"
+
+	"cloud.google.com/go/spanner"
+)
+
+// emulatorHostEnv is the environment variable with which Cloud Spanner clients connect to the emulator.
+const emulatorHostEnv = "SPANNER_EMULATOR_HOST"
+
+// WithEmulator adapts the run to Cloud Spanner Emulator, which lacks IAM, statistics and some INFORMATION_SCHEMA tables.
+// Permissions are not verified, optional metadata which can't be fetched is silently ignored, and foreign keys are
+// ignored on old emulators lacking INFORMATION_SCHEMA.REFERENTIAL_CONSTRAINTS.
+// It's enabled automatically if SPANNER_EMULATOR_HOST is set, as Cloud Spanner clients connect to the emulator then.
+func WithEmulator() Option {
+	return func(o *options) {
+		o.emulator = true
+	}
+}
+
+// onEmulator returns true if the run targets the emulator.
+func (o *options) onEmulator() bool {
+	return o.emulator || os.Getenv(emulatorHostEnv) != ""
+}
+
+// fetchTableSchemasOn fetches table schemas like fetchTableSchemas. On the emulator, it falls back to ignore
+// foreign keys if INFORMATION_SCHEMA.REFERENTIAL_CONSTRAINTS isn't available, and reports it by fkIgnored.
+func fetchTableSchemasOn(ctx context.Context, client *spanner.Client, o *options) (schemas []*tableSchema, fkIgnored bool, err error) {
+	schemas, err = fetchTableSchemas(ctx, client, o.schemaScope())
+	if err == nil || !o.onEmulator() {
+		return schemas, false, err
+	}
+	schemas, fallbackErr := fetchTableSchemasWithoutForeignKeys(ctx, client, o.schemaScope())
+	if fallbackErr != nil {
+		// The fallback failed for another reason, so the original error is more informative.
+		return nil, false, err
+	}
+	return schemas, true, nil
+}
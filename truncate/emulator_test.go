@@ -0,0 +1,51 @@
+//
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package truncate
+
+import (
+	"os"
+	"testing"
+)
+
+func TestOnEmulator(t *testing.T) {
+	orig, ok := os.LookupEnv(emulatorHostEnv)
+	defer func() {
+		if ok {
+			os.Setenv(emulatorHostEnv, orig)
+		} else {
+			os.Unsetenv(emulatorHostEnv)
+		}
+	}()
+
+	for _, tt := range []struct {
+		desc string
+		host string
+		opts []Option
+		want bool
+	}{
+		{desc: "default", want: false},
+		{desc: "option", opts: []Option{WithEmulator()}, want: true},
+		{desc: "environment variable", host: "localhost:9010", want: true},
+	} {
+		t.Run(tt.desc, func(t *testing.T) {
+			os.Setenv(emulatorHostEnv, tt.host)
+			if got := newOptions(tt.opts).onEmulator(); got != tt.want {
+				t.Errorf("onEmulator() = %v, but want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,173 @@
+//
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package truncate
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/spanner"
+	"google.golang.org/grpc/codes"
+	grpcstatus "google.golang.org/grpc/status"
+)
+
+// Operations which may fail on a table.
+const (
+	OpDelete   = "delete"    // Deleting rows from the table.
+	OpCount    = "count"     // Counting rows in the table.
+	OpSample   = "sample"    // Sampling rows from the table.
+	OpVerify   = "verify"    // Verifying indexes of the table are empty.
+	OpPreHook  = "pre-hook"  // Executing a statement before deleting rows from the table.
+	OpPostHook = "post-hook" // Executing a statement after deleting rows from the table.
+)
+
+// maxStatementLength is the maximum length of a statement embedded in error messages.
+const maxStatementLength = 200
+
+// TableError is an error that occurred while operating on a specific table.
+// Library users can extract it from errors returned by Run or RunWithClient using errors.As.
+type TableError struct {
+	TableName string // Table on which the operation failed.
+	Op        string // Operation which failed, e.g. OpDelete.
+	Statement string // Sanitized SQL statement which failed. Blank if no statement was issued.
+	Err       error  // Underlying error.
+}
+
+func (e *TableError) Error() string {
+	if e.Statement == "" {
+		return fmt.Sprintf("%s on table %s failed: %v", e.Op, e.TableName, e.Err)
+	}
+	return fmt.Sprintf("%s on table %s failed (statement: %q): %v", e.Op, e.TableName, e.Statement, e.Err)
+}
+
+// Unwrap returns the underlying error.
+func (e *TableError) Unwrap() error {
+	return e.Err
+}
+
+// newTableError wraps err with the table name, operation and statement. It returns nil if err is nil.
+func newTableError(tableName, op, sql string, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &TableError{
+		TableName: tableName,
+		Op:        op,
+		Statement: sanitizeStatement(sql),
+		Err:       err,
+	}
+}
+
+// sanitizeStatement collapses whitespaces in the statement and truncates it if it's too long,
+// so that it fits in a single line of an error message.
+func sanitizeStatement(sql string) string {
+	s := strings.Join(strings.Fields(sql), " ")
+	if len(s) > maxStatementLength {
+		s = s[:maxStatementLength] + "..."
+	}
+	return s
+}
+
+// PartialFailureError is returned when some tables failed to be deleted while the run continued on error.
+type PartialFailureError struct {
+	// Errors of the failed tables, typically *TableError.
+	Failures []error
+
+	// Tables which were left untouched because they depend on the failed tables.
+	UntouchedTables []string
+}
+
+func (e *PartialFailureError) Error() string {
+	msgs := make([]string, len(e.Failures))
+	for i, err := range e.Failures {
+		msgs[i] = err.Error()
+	}
+	s := fmt.Sprintf("%d failures: %s", len(e.Failures), strings.Join(msgs, "; "))
+	if len(e.UntouchedTables) > 0 {
+		s += fmt.Sprintf("; untouched tables: %s", strings.Join(e.UntouchedTables, ", "))
+	}
+	return s
+}
+
+// TimeoutError is returned when the run is aborted because it exceeded the timeout given by WithTimeout.
+type TimeoutError struct {
+	Timeout time.Duration
+	Err     error // Error which the run failed with when the timeout fired.
+}
+
+func (e *TimeoutError) Error() string {
+	return fmt.Sprintf("run exceeded timeout of %s: %v", e.Timeout, e.Err)
+}
+
+// Unwrap returns the underlying error.
+func (e *TimeoutError) Unwrap() error {
+	return e.Err
+}
+
+// TableTimeoutError is the failure of a table whose deletion exceeded the timeout given by WithTableTimeout.
+type TableTimeoutError struct {
+	TableName string
+	Timeout   time.Duration
+	Err       error // Error which the deletion failed with when the timeout fired.
+}
+
+func (e *TableTimeoutError) Error() string {
+	return fmt.Sprintf("deletion of table %s exceeded timeout of %s: %v", e.TableName, e.Timeout, e.Err)
+}
+
+// Unwrap returns the underlying error.
+func (e *TableTimeoutError) Unwrap() error {
+	return e.Err
+}
+
+// isTransactionTooLarge returns true if the commit of a transaction was rejected because it has too many mutations
+// or is too large in bytes.
+func isTransactionTooLarge(err error) bool {
+	if errorCode(err) != codes.InvalidArgument {
+		return false
+	}
+	msg := strings.ToLower(err.Error())
+	return strings.Contains(msg, "too many mutations") || strings.Contains(msg, "too large")
+}
+
+// isPDMLUnsuitable returns true if a Partitioned DML statement was rejected as unsupported, or failed because the
+// statement needs more resources than it can get, e.g. memory for a huge table. Rows may still be deleted in batches.
+func isPDMLUnsuitable(err error) bool {
+	switch errorCode(err) {
+	case codes.ResourceExhausted:
+		return true
+	case codes.InvalidArgument, codes.FailedPrecondition, codes.Unimplemented:
+		msg := strings.ToLower(err.Error())
+		return strings.Contains(msg, "partitioned dml") && strings.Contains(msg, "not supported")
+	}
+	return false
+}
+
+// errorCode returns the gRPC status code of the error, looking into wrapped errors.
+func errorCode(err error) codes.Code {
+	var se *spanner.Error
+	if errors.As(err, &se) {
+		return spanner.ErrCode(se)
+	}
+	var ge interface{ GRPCStatus() *grpcstatus.Status }
+	if errors.As(err, &ge) {
+		return ge.GRPCStatus().Code()
+	}
+	return grpcstatus.Code(err)
+}
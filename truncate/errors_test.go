@@ -0,0 +1,154 @@
+//
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package truncate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	grpcstatus "google.golang.org/grpc/status"
+)
+
+func TestTableError(t *testing.T) {
+	cause := errors.New("permission denied")
+	err := fmt.Errorf("failed to delete: %w", newTableError("Singers", OpDelete, "DELETE FROM `Singers`\n\t\tWHERE true", cause))
+
+	var tableErr *TableError
+	if !errors.As(err, &tableErr) {
+		t.Fatalf("errors.As(%v) = false, but want true", err)
+	}
+	if tableErr.TableName != "Singers" || tableErr.Op != OpDelete {
+		t.Errorf("unexpected table error: %#v", tableErr)
+	}
+	if !errors.Is(err, cause) {
+		t.Errorf("errors.Is(%v, %v) = false, but want true", err, cause)
+	}
+
+	want := "failed to delete: delete on table Singers failed (statement: \"DELETE FROM `Singers` WHERE true\"): permission denied"
+	if got := err.Error(); got != want {
+		t.Errorf("Error() = %q, but want = %q", got, want)
+	}
+}
+
+func TestNewTableErrorNil(t *testing.T) {
+	if err := newTableError("Singers", OpCount, "SELECT 1", nil); err != nil {
+		t.Errorf("newTableError with nil error = %v, but want nil", err)
+	}
+}
+
+func TestSanitizeStatement(t *testing.T) {
+	for _, tt := range []struct {
+		input string
+		want  string
+	}{
+		{"SELECT 1", "SELECT 1"},
+		{"  SELECT *\n\tFROM  t  ", "SELECT * FROM t"},
+		{"SELECT '" + strings.Repeat("x", 300) + "'", "SELECT '" + strings.Repeat("x", maxStatementLength-8) + "..."},
+	} {
+		if got := sanitizeStatement(tt.input); got != tt.want {
+			t.Errorf("sanitizeStatement(%q) = %q, but want = %q", tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestPartialFailureError(t *testing.T) {
+	err := &PartialFailureError{
+		Failures: []error{
+			newTableError("Singers", OpDelete, "DELETE FROM `Singers` WHERE true", errors.New("permission denied")),
+			errors.New("context canceled"),
+		},
+		UntouchedTables: []string{"Venues"},
+	}
+
+	want := "2 failures: delete on table Singers failed (statement: \"DELETE FROM `Singers` WHERE true\"): permission denied; context canceled; untouched tables: Venues"
+	if got := err.Error(); got != want {
+		t.Errorf("Error() = %q, but want = %q", got, want)
+	}
+}
+
+func TestTimeoutError(t *testing.T) {
+	err := &TimeoutError{Timeout: time.Hour, Err: context.DeadlineExceeded}
+
+	want := "run exceeded timeout of 1h0m0s: context deadline exceeded"
+	if got := err.Error(); got != want {
+		t.Errorf("Error() = %q, but want = %q", got, want)
+	}
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("errors.Is(err, context.DeadlineExceeded) = false, but want true")
+	}
+}
+
+func TestIsPDMLUnsuitable(t *testing.T) {
+	for _, test := range []struct {
+		desc string
+		err  error
+		want bool
+	}{
+		{desc: "resource exhausted", err: newTableError("A", OpDelete, "", grpcstatus.Error(codes.ResourceExhausted, "Out of memory")), want: true},
+		{desc: "unsupported statement", err: grpcstatus.Error(codes.InvalidArgument, "This statement is not supported in Partitioned DML"), want: true},
+		{desc: "other invalid argument", err: grpcstatus.Error(codes.InvalidArgument, "Syntax error"), want: false},
+		{desc: "other code", err: grpcstatus.Error(codes.Aborted, "Transaction was aborted"), want: false},
+	} {
+		t.Run(test.desc, func(t *testing.T) {
+			if got := isPDMLUnsuitable(test.err); got != test.want {
+				t.Errorf("isPDMLUnsuitable() = %v, but want = %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestErrorCode(t *testing.T) {
+	for _, test := range []struct {
+		desc string
+		err  error
+		want codes.Code
+	}{
+		{desc: "plain error", err: errors.New("error"), want: codes.Unknown},
+		{desc: "status error", err: grpcstatus.Error(codes.PermissionDenied, "denied"), want: codes.PermissionDenied},
+		{desc: "wrapped status error", err: newTableError("A", OpCount, "", grpcstatus.Error(codes.PermissionDenied, "denied")), want: codes.PermissionDenied},
+	} {
+		t.Run(test.desc, func(t *testing.T) {
+			if got := errorCode(test.err); got != test.want {
+				t.Errorf("errorCode() = %v, but want = %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestIsTransactionTooLarge(t *testing.T) {
+	for _, test := range []struct {
+		desc string
+		err  error
+		want bool
+	}{
+		{desc: "too many mutations", err: grpcstatus.Error(codes.InvalidArgument, "The transaction contains too many mutations."), want: true},
+		{desc: "too large", err: newTableError("A", OpDelete, "", grpcstatus.Error(codes.InvalidArgument, "Transaction is too large")), want: true},
+		{desc: "other invalid argument", err: grpcstatus.Error(codes.InvalidArgument, "Syntax error"), want: false},
+		{desc: "other code", err: grpcstatus.Error(codes.Aborted, "too many mutations"), want: false},
+	} {
+		t.Run(test.desc, func(t *testing.T) {
+			if got := isTransactionTooLarge(test.err); got != test.want {
+				t.Errorf("isTransactionTooLarge() = %v, but want = %v", got, test.want)
+			}
+		})
+	}
+}
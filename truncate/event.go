@@ -0,0 +1,150 @@
+//
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package truncate
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+)
+
+// EventType is a type of Event.
+type EventType string
+
+const (
+	// EventWaveStarted is emitted when deletions of a set of tables are started at the same time.
+	EventWaveStarted EventType = "wave_started"
+	// EventWaveCompleted is emitted when all tables in a wave, including their descendants deleted in cascade, are deleted.
+	EventWaveCompleted EventType = "wave_completed"
+	// EventWarning is emitted when a non-fatal issue occurred.
+	EventWarning EventType = "warning"
+	// EventSchemaFetched is emitted when the target tables are determined from the schema.
+	EventSchemaFetched EventType = "schema_fetched"
+	// EventTableWaiting is emitted when a table is counted, or retried, and waits for its dependencies to be deleted.
+	EventTableWaiting EventType = "table_waiting"
+	// EventTableDeleting is emitted when rows of a table start being deleted, including in cascade by its parent.
+	EventTableDeleting EventType = "table_deleting"
+	// EventTableCompleted is emitted when a table is deleted, or found already empty.
+	EventTableCompleted EventType = "table_completed"
+	// EventTableFailed is emitted when the deletion of a table failed.
+	EventTableFailed EventType = "table_failed"
+	// EventTableSkipped is emitted when a table is skipped without being deleted.
+	EventTableSkipped EventType = "table_skipped"
+	// EventRunCompleted is emitted when the run finishes, whether it succeeded or not.
+	EventRunCompleted EventType = "run_completed"
+)
+
+// Event is a notification of the progress of a run.
+// It is encoded as a JSON object with the field names in the tags, e.g. for streaming events as JSON lines.
+type Event struct {
+	Type EventType `json:"type"`
+	Time time.Time `json:"time"`
+
+	// Name of the database the event happened in.
+	Database string `json:"database,omitempty"`
+
+	// Sequence number of the wave starting from 1. Set for wave events.
+	Wave int `json:"wave,omitempty"`
+
+	// Tables related to the event. For wave events, tables whose deletion is started in the wave.
+	// For schema fetched events, the target tables. For table events, the table.
+	Tables []string `json:"tables,omitempty"`
+
+	// Status of the run, completed, dry_run, cancelled or failed. Set for run completed events.
+	Status string `json:"status,omitempty"`
+
+	// Human readable message. Set for warning events, and failure events with the error.
+	Message string `json:"message,omitempty"`
+}
+
+// EventHandler is a function called on each event.
+// Events are delivered one at a time, so the handler doesn't have to be goroutine-safe.
+// The handler should return quickly because it blocks the run.
+type EventHandler func(Event)
+
+// WithEventHandler registers a handler which receives events during a run.
+func WithEventHandler(handler EventHandler) Option {
+	return func(o *options) {
+		o.eventHandler = handler
+	}
+}
+
+// emitter delivers events to the handler one at a time.
+// Events are logged as well if the logger is set.
+type emitter struct {
+	mu       sync.Mutex
+	handler  EventHandler
+	logger   *slog.Logger
+	database string
+}
+
+func newEmitter(handler EventHandler) *emitter {
+	return &emitter{handler: handler}
+}
+
+// emit delivers the event to the handler. It is safe to call emit on a nil receiver, which discards the event.
+func (e *emitter) emit(event Event) {
+	if e == nil {
+		return
+	}
+	e.log(event)
+	if e.handler == nil {
+		return
+	}
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+	if event.Database == "" {
+		event.Database = e.database
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.handler(event)
+}
+
+// log logs the event, warnings at the warning level and the others at the info level.
+func (e *emitter) log(event Event) {
+	level := slog.LevelInfo
+	var msg string
+	var attrs []slog.Attr
+	switch event.Type {
+	case EventWarning:
+		level = slog.LevelWarn
+		msg = event.Message
+	case EventWaveStarted:
+		msg = "wave started"
+		attrs = append(attrs, slog.Int("wave", event.Wave))
+	case EventWaveCompleted:
+		msg = "wave completed"
+		attrs = append(attrs, slog.Int("wave", event.Wave))
+	case EventSchemaFetched, EventRunCompleted:
+		// Logged by the run with more details.
+		return
+	case EventTableWaiting, EventTableDeleting, EventTableCompleted, EventTableFailed, EventTableSkipped:
+		level = slog.LevelDebug
+		msg = strings.ReplaceAll(string(event.Type), "_", " ")
+	default:
+		msg = string(event.Type)
+	}
+	if len(event.Tables) > 0 {
+		attrs = append(attrs, slog.Any("tables", event.Tables))
+	}
+	orDiscard(e.logger).LogAttrs(context.Background(), level, msg, attrs...)
+}
@@ -0,0 +1,211 @@
+//
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package truncate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"cloud.google.com/go/spanner"
+)
+
+// rowFilter restricts the rows of a table which are deleted and counted, e.g. to the rows under a key prefix.
+// A nil filter matches all rows. Parameters of filters with the same name must have the same value across tables,
+// as a query counting multiple tables shares them.
+type rowFilter struct {
+	condition string                 // Boolean expression on columns of the table.
+	params    map[string]interface{} // Parameters referred to by the condition.
+}
+
+// where returns the WHERE clause of the filter, or blank if all rows match.
+func (f *rowFilter) where() string {
+	if f == nil {
+		return ""
+	}
+	return " WHERE " + f.condition
+}
+
+// and returns the condition combined with the filter.
+func (f *rowFilter) and(condition string) string {
+	if f == nil {
+		return condition
+	}
+	return fmt.Sprintf("(%s) AND (%s)", condition, f.condition)
+}
+
+// withParams returns the statement with the parameters of the filter added.
+func (f *rowFilter) withParams(stmt spanner.Statement) spanner.Statement {
+	if f == nil || len(f.params) == 0 {
+		return stmt
+	}
+	params := make(map[string]interface{}, len(stmt.Params)+len(f.params))
+	for k, v := range stmt.Params {
+		params[k] = v
+	}
+	for k, v := range f.params {
+		params[k] = v
+	}
+	stmt.Params = params
+	return stmt
+}
+
+// keyPrefix is the table and the leading values of its primary key given by WithKeyPrefix.
+type keyPrefix struct {
+	tableName string
+	values    spanner.Key
+}
+
+func (p *keyPrefix) String() string {
+	return fmt.Sprintf("%s%s", p.tableName, p.values)
+}
+
+// WithKeyPrefix deletes only the rows of the table whose primary key starts with the values, and the rows of its
+// interleaved descendants under them, e.g. the data of a tenant in a schema interleaving tenant data in a Tenants table.
+// The table and its descendants are the target tables, so it can't be used with WithTables or WithExcludeTables.
+// Row counts count only the matching rows, and the rows are deleted with Partitioned DML or in batches,
+// never with mutations or in shards.
+func WithKeyPrefix(tableName string, values spanner.Key) Option {
+	return func(o *options) {
+		o.keyPrefix = &keyPrefix{tableName: tableName, values: values}
+	}
+}
+
+// keyPrefixFilters returns filters restricting the tables to the rows under the key prefix of the root table,
+// keyed by the table name. Descendants share the filter, as their primary keys start with the key columns of the root.
+func keyPrefixFilters(ctx context.Context, client *spanner.Client, prefix *keyPrefix, root string, schemas []*tableSchema) (map[string]*rowFilter, error) {
+	keyColumns, err := fetchKeyColumns(ctx, client, root)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch the primary key of %s: %v", root, err)
+	}
+	if len(prefix.values) > len(keyColumns) {
+		return nil, fmt.Errorf("key prefix %s has more values than the %d key columns of %s", prefix, len(keyColumns), root)
+	}
+	filter := keyPrefixFilter(keyColumns[:len(prefix.values)], prefix.values)
+	filters := make(map[string]*rowFilter, len(schemas))
+	for _, schema := range schemas {
+		filters[schema.tableName] = filter
+	}
+	return filters, nil
+}
+
+// keyPrefixFilter returns a filter matching rows whose leading key columns equal the values.
+func keyPrefixFilter(keyColumns []string, values spanner.Key) *rowFilter {
+	conds := make([]string, len(keyColumns))
+	params := map[string]interface{}{}
+	for i, column := range keyColumns {
+		if values[i] == nil {
+			conds[i] = fmt.Sprintf("%s IS NULL", quoteIdentifier(column))
+			continue
+		}
+		name := fmt.Sprintf("prefix%d", i)
+		conds[i] = fmt.Sprintf("%s = @%s", quoteIdentifier(column), name)
+		params[name] = values[i]
+	}
+	return &rowFilter{condition: strings.Join(conds, " AND "), params: params}
+}
+
+// ParseKeyPrefix parses a key prefix like "Singers(42)" or "Albums(42, 'Total Junk')" into the table name and the
+// key values. Values are integers, floating point numbers, strings quoted with single or double quotes,
+// TRUE, FALSE or NULL.
+func ParseKeyPrefix(s string) (string, spanner.Key, error) {
+	open := strings.IndexByte(s, '(')
+	if open < 0 || !strings.HasSuffix(strings.TrimSpace(s), ")") {
+		return "", nil, fmt.Errorf("invalid key prefix %q: must be like Table(value, ...)", s)
+	}
+	tableName := strings.TrimSpace(s[:open])
+	if tableName == "" {
+		return "", nil, fmt.Errorf("invalid key prefix %q: no table name", s)
+	}
+	body := strings.TrimSpace(s)
+	body = body[open+1 : len(body)-1]
+
+	var values spanner.Key
+	for rest := strings.TrimSpace(body); rest != ""; {
+		value, n, err := parseKeyValue(rest)
+		if err != nil {
+			return "", nil, fmt.Errorf("invalid key prefix %q: %v", s, err)
+		}
+		values = append(values, value)
+		rest = strings.TrimSpace(rest[n:])
+		if rest == "" {
+			break
+		}
+		if rest[0] != ',' {
+			return "", nil, fmt.Errorf("invalid key prefix %q: values must be separated by commas", s)
+		}
+		rest = strings.TrimSpace(rest[1:])
+		if rest == "" {
+			return "", nil, fmt.Errorf("invalid key prefix %q: missing value after comma", s)
+		}
+	}
+	if len(values) == 0 {
+		return "", nil, fmt.Errorf("invalid key prefix %q: no key values", s)
+	}
+	return tableName, values, nil
+}
+
+// parseKeyValue parses the key value at the beginning of s, and returns it with the number of bytes consumed.
+func parseKeyValue(s string) (interface{}, int, error) {
+	if q := s[0]; q == '\'' || q == '"' {
+		var b strings.Builder
+		for i := 1; i < len(s); i++ {
+			switch c := s[i]; {
+			case c == q:
+				return b.String(), i + 1, nil
+			case c == '\\' && i+1 < len(s):
+				i++
+				switch s[i] {
+				case 'n':
+					b.WriteByte('\n')
+				case 't':
+					b.WriteByte('\t')
+				case 'r':
+					b.WriteByte('\r')
+				default:
+					b.WriteByte(s[i])
+				}
+			default:
+				b.WriteByte(c)
+			}
+		}
+		return nil, 0, errors.New("unterminated string")
+	}
+
+	n := strings.IndexByte(s, ',')
+	if n < 0 {
+		n = len(s)
+	}
+	token := strings.TrimSpace(s[:n])
+	switch strings.ToUpper(token) {
+	case "NULL":
+		return nil, n, nil
+	case "TRUE":
+		return true, n, nil
+	case "FALSE":
+		return false, n, nil
+	}
+	if i, err := strconv.ParseInt(token, 10, 64); err == nil {
+		return i, n, nil
+	}
+	if f, err := strconv.ParseFloat(token, 64); err == nil {
+		return f, n, nil
+	}
+	return nil, 0, fmt.Errorf("invalid value %q: strings must be quoted", token)
+}
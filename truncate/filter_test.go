@@ -0,0 +1,99 @@
+//
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package truncate
+
+import (
+	"testing"
+
+	"cloud.google.com/go/spanner"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestParseKeyPrefix(t *testing.T) {
+	for _, tt := range []struct {
+		desc      string
+		input     string
+		wantTable string
+		wantKey   spanner.Key
+		wantErr   bool
+	}{
+		{desc: "integer", input: "Singers(42)", wantTable: "Singers", wantKey: spanner.Key{int64(42)}},
+		{desc: "composite", input: ` Albums ( 42, 'Total Junk' , "It\'s", TRUE, null, -1.5 ) `, wantTable: "Albums", wantKey: spanner.Key{int64(42), "Total Junk", "It's", true, nil, -1.5}},
+		{desc: "comma in string", input: "Tenants('a,b')", wantTable: "Tenants", wantKey: spanner.Key{"a,b"}},
+		{desc: "named schema", input: "analytics.Events(1)", wantTable: "analytics.Events", wantKey: spanner.Key{int64(1)}},
+		{desc: "no parentheses", input: "Singers", wantErr: true},
+		{desc: "no table", input: "(42)", wantErr: true},
+		{desc: "no values", input: "Singers()", wantErr: true},
+		{desc: "unquoted string", input: "Singers(abc)", wantErr: true},
+		{desc: "unterminated string", input: "Singers('abc)", wantErr: true},
+		{desc: "trailing comma", input: "Singers(1,)", wantErr: true},
+		{desc: "missing comma", input: "Singers('a' 'b')", wantErr: true},
+	} {
+		t.Run(tt.desc, func(t *testing.T) {
+			table, key, err := ParseKeyPrefix(tt.input)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("ParseKeyPrefix(%q) succeeded, want error", tt.input)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseKeyPrefix(%q) failed: %v", tt.input, err)
+			}
+			if table != tt.wantTable {
+				t.Errorf("table = %q, want %q", table, tt.wantTable)
+			}
+			if diff := cmp.Diff(key, tt.wantKey); diff != "" {
+				t.Errorf("key mismatch (-got +want):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestKeyPrefixFilter(t *testing.T) {
+	f := keyPrefixFilter([]string{"TenantId", "Region"}, spanner.Key{"acme", nil})
+	want := &rowFilter{condition: "`TenantId` = @prefix0 AND `Region` IS NULL", params: map[string]interface{}{"prefix0": "acme"}}
+	if diff := cmp.Diff(f, want, cmp.AllowUnexported(rowFilter{})); diff != "" {
+		t.Errorf("mismatch (-got +want):\n%s", diff)
+	}
+}
+
+func TestFilteredStatements(t *testing.T) {
+	filter := keyPrefixFilter([]string{"SingerId"}, spanner.Key{int64(42)})
+	d := &deleter{tableName: "Albums", filter: filter}
+	params := map[string]interface{}{"prefix0": int64(42)}
+
+	if diff := cmp.Diff(d.deleteStatement(), spanner.Statement{SQL: "DELETE FROM `Albums` WHERE `SingerId` = @prefix0", Params: params}); diff != "" {
+		t.Errorf("deleteStatement() mismatch (-got +want):\n%s", diff)
+	}
+
+	want := spanner.Statement{
+		SQL:    "SELECT 0 AS i, COUNT(*) AS count FROM `Singers` UNION ALL SELECT 1 AS i, COUNT(*) AS count FROM `Albums` WHERE `SingerId` = @prefix0",
+		Params: params,
+	}
+	if diff := cmp.Diff(batchedCountStatement([]*deleter{{tableName: "Singers"}, d}), want); diff != "" {
+		t.Errorf("batchedCountStatement() mismatch (-got +want):\n%s", diff)
+	}
+
+	want = spanner.Statement{
+		SQL:    "SELECT t.`AlbumId` FROM `Albums` AS t WHERE (true) AND (`SingerId` = @prefix0) LIMIT @limit",
+		Params: map[string]interface{}{"limit": int64(100), "prefix0": int64(42)},
+	}
+	if diff := cmp.Diff(filter.withParams(batchKeysStatement("Albums", []string{"AlbumId"}, filter.and("true"), 100)), want); diff != "" {
+		t.Errorf("batchKeysStatement() mismatch (-got +want):\n%s", diff)
+	}
+}
@@ -0,0 +1,215 @@
+//
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package truncate
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+
+	"cloud.google.com/go/spanner"
+	adminapi "cloud.google.com/go/spanner/admin/database/apiv1"
+	"google.golang.org/api/option"
+)
+
+// WithForeignKeyDrop drops foreign keys among the target tables before deleting rows, and restores them afterwards,
+// even if the deletion fails. Tables referencing each other are then deleted in parallel instead of in the order of references.
+// Before restoring a foreign key, rows referencing missing rows are counted, and foreign keys with such orphan rows
+// are left dropped with their DDL printed, as they can't be restored until the orphans are fixed.
+// Foreign keys with ON DELETE CASCADE are kept, as they don't constrain the order of deletion.
+func WithForeignKeyDrop() Option {
+	return func(o *options) {
+		o.dropForeignKeys = true
+	}
+}
+
+// foreignKeyDrop is a set of foreign keys dropped before deletion and restored after it.
+type foreignKeyDrop struct {
+	foreignKeys []*foreignKey
+}
+
+// planForeignKeyDrop picks foreign keys without ON DELETE CASCADE whose both ends are target tables.
+// Foreign keys from tables out of the targets are kept, as their rows must not be left referencing deleted rows.
+func planForeignKeyDrop(schemas []*tableSchema) *foreignKeyDrop {
+	targets := map[string]bool{}
+	for _, schema := range schemas {
+		targets[schema.tableName] = true
+	}
+	drop := &foreignKeyDrop{}
+	for _, schema := range schemas {
+		for _, fk := range schema.foreignKeys {
+			if fk.onDelete != deleteActionCascadeDelete && targets[fk.referencingTable] {
+				drop.foreignKeys = append(drop.foreignKeys, fk)
+			}
+		}
+	}
+	return drop
+}
+
+// detach removes the foreign keys from the tables they reference, so that the deletion isn't ordered by them.
+func (d *foreignKeyDrop) detach(schemas []*tableSchema) {
+	for _, schema := range schemas {
+		var fks []*foreignKey
+		var referencedBy []string
+		for _, fk := range schema.foreignKeys {
+			if d.has(fk) {
+				continue
+			}
+			fks = append(fks, fk)
+			if !slices.Contains(referencedBy, fk.referencingTable) {
+				referencedBy = append(referencedBy, fk.referencingTable)
+			}
+		}
+		schema.foreignKeys = fks
+		schema.referencedBy = referencedBy
+	}
+}
+
+func (d *foreignKeyDrop) has(fk *foreignKey) bool {
+	return slices.Contains(d.foreignKeys, fk)
+}
+
+func (d *foreignKeyDrop) names() []string {
+	names := make([]string, len(d.foreignKeys))
+	for i, fk := range d.foreignKeys {
+		names[i] = fk.constraintName
+	}
+	return names
+}
+
+// dropStatement returns the statement dropping the foreign key.
+func (fk *foreignKey) dropStatement() string {
+	return fmt.Sprintf("ALTER TABLE %s DROP CONSTRAINT %s", quoteIdentifier(fk.referencingTable), quoteIdentifier(fk.constraintName))
+}
+
+// addStatement returns the statement restoring the foreign key.
+func (fk *foreignKey) addStatement() string {
+	stmt := fmt.Sprintf("ALTER TABLE %s ADD CONSTRAINT %s FOREIGN KEY (%s) REFERENCES %s (%s)",
+		quoteIdentifier(fk.referencingTable), quoteIdentifier(fk.constraintName),
+		quoteColumns(fk.referencingColumns), quoteIdentifier(fk.referencedTable), quoteColumns(fk.referencedColumns))
+	if fk.onDelete == deleteActionCascadeDelete {
+		stmt += " ON DELETE CASCADE"
+	}
+	return stmt
+}
+
+// orphansStatement returns the statement counting rows referencing missing rows by the foreign key.
+// Rows with a NULL in any of the referencing columns are not checked by the foreign key, and not counted.
+func (fk *foreignKey) orphansStatement() string {
+	var notNulls, references []string
+	for i, column := range fk.referencingColumns {
+		notNulls = append(notNulls, fmt.Sprintf("t.%s IS NOT NULL", quoteIdentifier(column)))
+		references = append(references, fmt.Sprintf("r.%s = t.%s", quoteIdentifier(fk.referencedColumns[i]), quoteIdentifier(column)))
+	}
+	return fmt.Sprintf("SELECT COUNT(*) AS count FROM %s AS t WHERE %s AND NOT EXISTS (SELECT 1 FROM %s AS r WHERE %s)",
+		quoteIdentifier(fk.referencingTable), strings.Join(notNulls, " AND "),
+		quoteIdentifier(fk.referencedTable), strings.Join(references, " AND "))
+}
+
+func quoteColumns(columns []string) string {
+	quoted := make([]string, len(columns))
+	for i, column := range columns {
+		quoted[i] = quoteIdentifier(column)
+	}
+	return strings.Join(quoted, ", ")
+}
+
+// drop drops the foreign keys and waits for the schema change, and returns a function restoring them.
+// If adminClient is nil, a new admin client is created with clientOpts and closed after restoring.
+func (d *foreignKeyDrop) drop(ctx context.Context, client *spanner.Client, adminClient *adminapi.DatabaseAdminClient, out io.Writer, clientOpts ...option.ClientOption) (func(context.Context) error, error) {
+	closeClient := func() {}
+	if adminClient == nil {
+		var err error
+		adminClient, err = adminapi.NewDatabaseAdminClient(ctx, clientOpts...)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create Cloud Spanner admin client: %v", err)
+		}
+		closeClient = func() { adminClient.Close() }
+	}
+
+	fmt.Fprintf(out, "\nDropping %d foreign keys: %s\n", len(d.foreignKeys), strings.Join(d.names(), ", "))
+	drops := make([]string, len(d.foreignKeys))
+	for i, fk := range d.foreignKeys {
+		drops[i] = fk.dropStatement()
+	}
+	if err := updateDatabaseDDL(ctx, adminClient, client.DatabaseName(), drops); err != nil {
+		// Statements are applied one by one, so the foreign keys before the failed one may have been dropped.
+		fmt.Fprintf(out, "Failed to drop foreign keys. Restore the dropped ones with the following statements:\n")
+		for _, fk := range d.foreignKeys {
+			fmt.Fprintf(out, "  %s;\n", fk.addStatement())
+		}
+		closeClient()
+		return nil, err
+	}
+
+	restore := func(ctx context.Context) error {
+		defer closeClient()
+		return d.restore(ctx, client, adminClient, out)
+	}
+	return restore, nil
+}
+
+// restore adds the foreign keys back, except for those with orphan rows, or whose orphan rows can't be counted.
+// The statements of the foreign keys not restored are printed so that they can be restored manually.
+func (d *foreignKeyDrop) restore(ctx context.Context, client *spanner.Client, adminClient *adminapi.DatabaseAdminClient, out io.Writer) error {
+	fmt.Fprintf(out, "\nVerifying no orphan rows remain and restoring %d foreign keys...\n", len(d.foreignKeys))
+	var adds, skipped []string
+	var problems []string
+	for _, fk := range d.foreignKeys {
+		count, err := countOrphans(ctx, client, fk)
+		switch {
+		case err != nil:
+			problems = append(problems, fmt.Sprintf("%s: %v", fk.constraintName, err))
+		case count > 0:
+			problems = append(problems, fmt.Sprintf("%s: %s rows of %s reference missing rows of %s", fk.constraintName, formatNumber(uint64(count)), fk.referencingTable, fk.referencedTable))
+		default:
+			adds = append(adds, fk.addStatement())
+			continue
+		}
+		skipped = append(skipped, fk.addStatement())
+	}
+
+	if len(adds) > 0 {
+		if err := updateDatabaseDDL(ctx, adminClient, client.DatabaseName(), adds); err != nil {
+			skipped = append(adds, skipped...)
+			problems = append(problems, err.Error())
+		}
+	}
+	if len(problems) == 0 {
+		fmt.Fprintf(out, "Foreign keys have been restored.\n")
+		return nil
+	}
+	fmt.Fprintf(out, "Failed to restore foreign keys. Restore the missing ones with the following statements once the orphan rows are fixed:\n")
+	for _, stmt := range skipped {
+		fmt.Fprintf(out, "  %s;\n", stmt)
+	}
+	return fmt.Errorf("failed to restore foreign keys: %s", strings.Join(problems, "; "))
+}
+
+// countOrphans counts rows referencing missing rows by the foreign key.
+func countOrphans(ctx context.Context, client *spanner.Client, fk *foreignKey) (int64, error) {
+	stmt := spanner.NewStatement(fk.orphansStatement())
+	var count int64
+	if err := client.Single().Query(ctx, stmt).Do(func(r *spanner.Row) error {
+		return r.ColumnByName("count", &count)
+	}); err != nil {
+		return 0, newTableError(fk.referencingTable, OpVerify, stmt.SQL, err)
+	}
+	return count, nil
+}
@@ -0,0 +1,109 @@
+//
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package truncate
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestForeignKeyDrop(t *testing.T) {
+	// Singers and Albums reference each other, Employees references itself, and Reviews references Albums in cascade.
+	// Contracts out of the targets references Singers.
+	latestAlbum := &foreignKey{constraintName: "FK_LatestAlbum", referencingTable: "Singers", referencedTable: "Albums", onDelete: deleteActionNoAction}
+	albumSinger := &foreignKey{constraintName: "FK_AlbumSinger", referencingTable: "Albums", referencedTable: "Singers", onDelete: deleteActionNoAction}
+	contractSinger := &foreignKey{constraintName: "FK_ContractSinger", referencingTable: "Contracts", referencedTable: "Singers", onDelete: deleteActionNoAction}
+	reviewAlbum := &foreignKey{constraintName: "FK_ReviewAlbum", referencingTable: "Reviews", referencedTable: "Albums", onDelete: deleteActionCascadeDelete}
+	manager := &foreignKey{constraintName: "FK_Manager", referencingTable: "Employees", referencedTable: "Employees", onDelete: deleteActionNoAction}
+	singers := &tableSchema{tableName: "Singers", foreignKeys: []*foreignKey{albumSinger, contractSinger}, referencedBy: []string{"Albums", "Contracts"}}
+	albums := &tableSchema{tableName: "Albums", foreignKeys: []*foreignKey{latestAlbum, reviewAlbum}, referencedBy: []string{"Singers", "Reviews"}}
+	reviews := &tableSchema{tableName: "Reviews"}
+	employees := &tableSchema{tableName: "Employees", foreignKeys: []*foreignKey{manager}, referencedBy: []string{"Employees"}}
+	schemas := []*tableSchema{singers, albums, reviews, employees}
+
+	drop := planForeignKeyDrop(schemas)
+	if want := []string{"FK_AlbumSinger", "FK_LatestAlbum", "FK_Manager"}; !cmp.Equal(drop.names(), want) {
+		t.Errorf("names: diff(+got, -want) = %v", cmp.Diff(drop.names(), want))
+	}
+
+	drop.detach(schemas)
+	for _, test := range []struct {
+		schema           *tableSchema
+		wantForeignKeys  []*foreignKey
+		wantReferencedBy []string
+	}{
+		{schema: singers, wantForeignKeys: []*foreignKey{contractSinger}, wantReferencedBy: []string{"Contracts"}},
+		{schema: albums, wantForeignKeys: []*foreignKey{reviewAlbum}, wantReferencedBy: []string{"Reviews"}},
+		{schema: employees},
+	} {
+		if len(test.schema.foreignKeys) != len(test.wantForeignKeys) || !cmp.Equal(test.schema.referencedBy, test.wantReferencedBy) {
+			t.Errorf("%s: got foreign keys %d, referenced by %v, want %d, %v",
+				test.schema.tableName, len(test.schema.foreignKeys), test.schema.referencedBy, len(test.wantForeignKeys), test.wantReferencedBy)
+			continue
+		}
+		for i, fk := range test.schema.foreignKeys {
+			if fk != test.wantForeignKeys[i] {
+				t.Errorf("%s: foreign key %d is %s, want %s", test.schema.tableName, i, fk.constraintName, test.wantForeignKeys[i].constraintName)
+			}
+		}
+	}
+}
+
+func TestForeignKeyStatements(t *testing.T) {
+	for _, test := range []struct {
+		desc        string
+		fk          *foreignKey
+		wantDrop    string
+		wantAdd     string
+		wantOrphans string
+	}{
+		{
+			desc: "single column",
+			fk: &foreignKey{
+				constraintName: "FK_AlbumSinger", referencingTable: "Albums", referencedTable: "Singers", onDelete: deleteActionNoAction,
+				referencingColumns: []string{"SingerId"}, referencedColumns: []string{"SingerId"},
+			},
+			wantDrop:    "ALTER TABLE `Albums` DROP CONSTRAINT `FK_AlbumSinger`",
+			wantAdd:     "ALTER TABLE `Albums` ADD CONSTRAINT `FK_AlbumSinger` FOREIGN KEY (`SingerId`) REFERENCES `Singers` (`SingerId`)",
+			wantOrphans: "SELECT COUNT(*) AS count FROM `Albums` AS t WHERE t.`SingerId` IS NOT NULL AND NOT EXISTS (SELECT 1 FROM `Singers` AS r WHERE r.`SingerId` = t.`SingerId`)",
+		},
+		{
+			desc: "multiple columns in a named schema with cascade",
+			fk: &foreignKey{
+				constraintName: "sales.FK_Item", referencingTable: "sales.Items", referencedTable: "sales.Orders", onDelete: deleteActionCascadeDelete,
+				referencingColumns: []string{"ShopId", "OrderNo"}, referencedColumns: []string{"ShopId", "OrderId"},
+			},
+			wantDrop: "ALTER TABLE `sales`.`Items` DROP CONSTRAINT `sales`.`FK_Item`",
+			wantAdd:  "ALTER TABLE `sales`.`Items` ADD CONSTRAINT `sales`.`FK_Item` FOREIGN KEY (`ShopId`, `OrderNo`) REFERENCES `sales`.`Orders` (`ShopId`, `OrderId`) ON DELETE CASCADE",
+			wantOrphans: "SELECT COUNT(*) AS count FROM `sales`.`Items` AS t WHERE t.`ShopId` IS NOT NULL AND t.`OrderNo` IS NOT NULL " +
+				"AND NOT EXISTS (SELECT 1 FROM `sales`.`Orders` AS r WHERE r.`ShopId` = t.`ShopId` AND r.`OrderId` = t.`OrderNo`)",
+		},
+	} {
+		t.Run(test.desc, func(t *testing.T) {
+			if got := test.fk.dropStatement(); got != test.wantDrop {
+				t.Errorf("dropStatement() = %q, want %q", got, test.wantDrop)
+			}
+			if got := test.fk.addStatement(); got != test.wantAdd {
+				t.Errorf("addStatement() = %q, want %q", got, test.wantAdd)
+			}
+			if got := test.fk.orphansStatement(); got != test.wantOrphans {
+				t.Errorf("orphansStatement() = %q, want %q", got, test.wantOrphans)
+			}
+		})
+	}
+}
@@ -0,0 +1,104 @@
+//
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package truncate
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strconv"
+
+	"cloud.google.com/go/spanner"
+)
+
+// WriteDependencyGraph writes the graph of the target tables in DOT format of Graphviz, which shows interleaving and
+// foreign keys deciding the order of deletion, the wave in which each table is deleted, and tables stuck in
+// circular dependencies. The target tables are filtered as FetchTableSchemas does. No rows are counted or deleted.
+func WriteDependencyGraph(ctx context.Context, client *spanner.Client, w io.Writer, targetTables, excludeTables []string, opts ...Option) error {
+	o := newOptions(opts)
+	schemas, err := fetchFilteredTableSchemas(ctx, client, targetTables, excludeTables, o)
+	if err != nil {
+		return fmt.Errorf("failed to fetch table schema: %v", err)
+	}
+	indexes, err := loadIndexSchemas(ctx, client, o)
+	if err != nil {
+		return fmt.Errorf("failed to fetch index schema: %v", err)
+	}
+	c, err := newCoordinator(schemas, indexes, nil, nil, nil, o)
+	if err != nil {
+		return err
+	}
+	plan, stuck := simulateDeletion(c.tables)
+	writeDOT(w, c.tables, plan, stuck)
+	return nil
+}
+
+// writeDOT writes the tables as a graph in DOT format.
+// An edge A -> B means A is deleted before B, except for cascade edges from a table to the table whose rows it deletes,
+// i.e. its interleaved child or a table referencing it by foreign keys with ON DELETE CASCADE.
+func writeDOT(w io.Writer, tables []*table, plan *deletionPlan, stuck []*table) {
+	wave := map[string]int{}
+	for i, names := range plan.waves {
+		for _, name := range names {
+			wave[name] = i + 1
+		}
+	}
+	isStuck := map[string]bool{}
+	for _, t := range stuck {
+		isStuck[t.tableName] = true
+	}
+
+	fmt.Fprint(w, "digraph spanner_truncate {\n")
+	fmt.Fprint(w, "  // A -> B: A is deleted before B. Dashed edges: A deletes rows of B in cascade.\n")
+	fmt.Fprint(w, "  rankdir=LR;\n")
+	fmt.Fprint(w, "  node [shape=box];\n")
+	flattened := flattenTables(tables)
+	for _, t := range flattened {
+		var label, attrs string
+		switch {
+		case isStuck[t.tableName]:
+			label, attrs = "circular dependency", ", color=red, fontcolor=red"
+		case plan.cascadeFrom[t.tableName] != "":
+			label, attrs = "cascade from "+plan.cascadeFrom[t.tableName], ", style=dashed"
+		default:
+			label = fmt.Sprintf("wave %d", wave[t.tableName])
+		}
+		fmt.Fprintf(w, "  %s [label=%s%s];\n", strconv.Quote(t.tableName), strconv.Quote(t.tableName+"\n"+label), attrs)
+	}
+	for _, t := range flattened {
+		for _, child := range t.childTables {
+			switch {
+			case child.parentOnDeleteAction == deleteActionNoAction:
+				fmt.Fprintf(w, "  %s -> %s [label=\"interleaved, ON DELETE NO ACTION\"];\n", strconv.Quote(child.tableName), strconv.Quote(t.tableName))
+			case child.hasGlobalIndex:
+				fmt.Fprintf(w, "  %s -> %s [label=\"interleaved, global index\"];\n", strconv.Quote(child.tableName), strconv.Quote(t.tableName))
+			default:
+				fmt.Fprintf(w, "  %s -> %s [label=\"interleaved, ON DELETE CASCADE\", style=dashed];\n", strconv.Quote(t.tableName), strconv.Quote(child.tableName))
+			}
+		}
+		for _, referencing := range t.referencedBy {
+			fmt.Fprintf(w, "  %s -> %s [label=\"foreign key\"];\n", strconv.Quote(referencing.tableName), strconv.Quote(t.tableName))
+		}
+		for _, referencing := range t.referencedInCycleBy {
+			fmt.Fprintf(w, "  %s -> %s [label=\"foreign key, cycle broken\", style=dotted];\n", strconv.Quote(referencing.tableName), strconv.Quote(t.tableName))
+		}
+		for _, referencing := range t.referencedInCascadeBy {
+			fmt.Fprintf(w, "  %s -> %s [label=\"foreign key, ON DELETE CASCADE\", style=dashed];\n", strconv.Quote(t.tableName), strconv.Quote(referencing.tableName))
+		}
+	}
+	fmt.Fprint(w, "}\n")
+}
@@ -0,0 +1,61 @@
+//
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package truncate
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestWriteDOT(t *testing.T) {
+	schemas := []*tableSchema{
+		{tableName: "Singers", referencedBy: []string{"Concerts"}},
+		{tableName: "Albums", parentTableName: "Singers", parentOnDeleteAction: deleteActionCascadeDelete},
+		{tableName: "Songs", parentTableName: "Albums", parentOnDeleteAction: deleteActionNoAction},
+		{tableName: "Concerts"},
+		{tableName: "A", referencedBy: []string{"B"}},
+		{tableName: "B", referencedBy: []string{"A"}},
+	}
+	c, err := newCoordinator(schemas, nil, nil, nil, nil, newOptions(nil))
+	if err != nil {
+		t.Fatalf("newCoordinator() error = %v", err)
+	}
+	plan, stuck := simulateDeletion(c.tables)
+
+	var out bytes.Buffer
+	writeDOT(&out, c.tables, plan, stuck)
+	want := `digraph spanner_truncate {
+  // A -> B: A is deleted before B. Dashed edges: A deletes rows of B in cascade.
+  rankdir=LR;
+  node [shape=box];
+  "Singers" [label="Singers\nwave 2"];
+  "Albums" [label="Albums\ncascade from Singers", style=dashed];
+  "Songs" [label="Songs\nwave 1"];
+  "Concerts" [label="Concerts\nwave 1"];
+  "A" [label="A\ncircular dependency", color=red, fontcolor=red];
+  "B" [label="B\ncircular dependency", color=red, fontcolor=red];
+  "Singers" -> "Albums" [label="interleaved, ON DELETE CASCADE", style=dashed];
+  "Concerts" -> "Singers" [label="foreign key"];
+  "Songs" -> "Albums" [label="interleaved, ON DELETE NO ACTION"];
+  "B" -> "A" [label="foreign key"];
+  "A" -> "B" [label="foreign key"];
+}
+`
+	if got := out.String(); got != want {
+		t.Errorf("writeDOT() = %s, but want %s", got, want)
+	}
+}
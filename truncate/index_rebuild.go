@@ -0,0 +1,167 @@
+//
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package truncate
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+
+	adminapi "cloud.google.com/go/spanner/admin/database/apiv1"
+	"google.golang.org/api/option"
+	adminpb "google.golang.org/genproto/googleapis/spanner/admin/database/v1"
+)
+
+const (
+	permissionGetDDL    = "spanner.databases.getDdl"
+	permissionUpdateDDL = "spanner.databases.updateDdl"
+)
+
+// WithIndexRebuild drops secondary indexes of the target tables before deleting rows, and recreates them afterwards,
+// even if the deletion fails. Deletions don't have to maintain the dropped indexes,
+// and tables with global indexes no longer have to wait for the deletion of their child tables.
+// Indexes managed by Cloud Spanner, e.g. backing foreign keys, can't be dropped and are kept.
+func WithIndexRebuild() Option {
+	return func(o *options) {
+		o.rebuildIndexes = true
+	}
+}
+
+// createIndexRegexp matches a CREATE INDEX statement, and captures whether it's a search index and the index name.
+var createIndexRegexp = regexp.MustCompile("(?is)^\\s*CREATE\\s+(?:UNIQUE\\s+)?(?:NULL_FILTERED\\s+)?(SEARCH\\s+)?INDEX\\s+(?:IF\\s+NOT\\s+EXISTS\\s+)?([`\\w.]+)")
+
+// indexRebuild is a set of indexes dropped before deletion and recreated after it.
+type indexRebuild struct {
+	indexes []*indexSchema
+	creates []string // CREATE statements of the indexes, in the order of the database DDL.
+}
+
+// planIndexRebuild picks indexes on the target tables which can be recreated from the DDL statements,
+// and returns them along with the other indexes, which are kept during deletion.
+func planIndexRebuild(statements []string, indexes []*indexSchema, schemas []*tableSchema) (*indexRebuild, []*indexSchema) {
+	targets := map[string]bool{}
+	for _, schema := range schemas {
+		targets[schema.tableName] = true
+	}
+	creates := map[string]string{}
+	for _, stmt := range statements {
+		if m := createIndexRegexp.FindStringSubmatch(stmt); m != nil {
+			creates[strings.ReplaceAll(m[2], "`", "")] = stmt
+		}
+	}
+
+	rebuild := &indexRebuild{}
+	var kept []*indexSchema
+	for _, idx := range indexes {
+		if !targets[idx.baseTableName] || creates[idx.indexName] == "" {
+			kept = append(kept, idx)
+			continue
+		}
+		rebuild.indexes = append(rebuild.indexes, idx)
+	}
+	for _, stmt := range statements {
+		if m := createIndexRegexp.FindStringSubmatch(stmt); m != nil && rebuild.has(strings.ReplaceAll(m[2], "`", "")) {
+			rebuild.creates = append(rebuild.creates, stmt)
+		}
+	}
+	return rebuild, kept
+}
+
+func (r *indexRebuild) has(indexName string) bool {
+	for _, idx := range r.indexes {
+		if idx.indexName == indexName {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *indexRebuild) names() []string {
+	names := make([]string, len(r.indexes))
+	for i, idx := range r.indexes {
+		names[i] = idx.indexName
+	}
+	return names
+}
+
+// dropStatements returns DROP statements of the indexes.
+func (r *indexRebuild) dropStatements() []string {
+	stmts := make([]string, len(r.indexes))
+	for i, idx := range r.indexes {
+		if idx.isSearch {
+			stmts[i] = fmt.Sprintf("DROP SEARCH INDEX %s", quoteIdentifier(idx.indexName))
+		} else {
+			stmts[i] = fmt.Sprintf("DROP INDEX %s", quoteIdentifier(idx.indexName))
+		}
+	}
+	return stmts
+}
+
+// drop drops the indexes and waits for the schema change, and returns a function recreating them.
+// If recreating fails, the CREATE statements are printed so that the indexes can be recreated manually.
+// If adminClient is nil, a new admin client is created with clientOpts and closed after recreating.
+func (r *indexRebuild) drop(ctx context.Context, adminClient *adminapi.DatabaseAdminClient, out io.Writer, database string, clientOpts ...option.ClientOption) (func(context.Context) error, error) {
+	closeClient := func() {}
+	if adminClient == nil {
+		var err error
+		adminClient, err = adminapi.NewDatabaseAdminClient(ctx, clientOpts...)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create Cloud Spanner admin client: %v", err)
+		}
+		closeClient = func() { adminClient.Close() }
+	}
+
+	fmt.Fprintf(out, "\nDropping %d indexes: %s\n", len(r.indexes), strings.Join(r.names(), ", "))
+	if err := updateDatabaseDDL(ctx, adminClient, database, r.dropStatements()); err != nil {
+		// Statements are applied one by one, so the indexes before the failed one may have been dropped.
+		fmt.Fprintf(out, "Failed to drop indexes. Recreate the dropped ones with the following statements:\n")
+		for _, stmt := range r.creates {
+			fmt.Fprintf(out, "  %s;\n", stmt)
+		}
+		closeClient()
+		return nil, err
+	}
+
+	recreate := func(ctx context.Context) error {
+		defer closeClient()
+		fmt.Fprintf(out, "\nRecreating %d indexes. This may take a while...\n", len(r.indexes))
+		if err := updateDatabaseDDL(ctx, adminClient, database, r.creates); err != nil {
+			fmt.Fprintf(out, "Failed to recreate indexes. Recreate the missing ones with the following statements:\n")
+			for _, stmt := range r.creates {
+				fmt.Fprintf(out, "  %s;\n", stmt)
+			}
+			return fmt.Errorf("failed to recreate indexes %s: %v", strings.Join(r.names(), ", "), err)
+		}
+		fmt.Fprintf(out, "Indexes have been recreated.\n")
+		return nil
+	}
+	return recreate, nil
+}
+
+// updateDatabaseDDL applies the DDL statements to the database and waits for the schema change.
+func updateDatabaseDDL(ctx context.Context, adminClient *adminapi.DatabaseAdminClient, database string, statements []string) error {
+	op, err := adminClient.UpdateDatabaseDdl(ctx, &adminpb.UpdateDatabaseDdlRequest{
+		Database:   database,
+		Statements: statements,
+	})
+	if err != nil {
+		return err
+	}
+	return op.Wait(ctx)
+}
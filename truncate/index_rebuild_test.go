@@ -0,0 +1,70 @@
+//
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package truncate
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestPlanIndexRebuild(t *testing.T) {
+	statements := []string{
+		"CREATE TABLE Singers (\n  SingerId INT64 NOT NULL,\n) PRIMARY KEY(SingerId)",
+		"CREATE INDEX SingersByName ON Singers(Name)",
+		"CREATE UNIQUE NULL_FILTERED INDEX `AlbumsByTitle` ON Albums(Title), INTERLEAVE IN Singers",
+		"CREATE SEARCH INDEX SingersByBio ON Singers(BioTokens)",
+		"CREATE INDEX analytics.EventsByTime ON analytics.Events(Time)",
+		"CREATE INDEX ConcertsByDate ON Concerts(Date)",
+	}
+	singersByName := &indexSchema{indexName: "SingersByName", baseTableName: "Singers"}
+	albumsByTitle := &indexSchema{indexName: "AlbumsByTitle", baseTableName: "Albums", parentTableName: "Singers"}
+	singersByBio := &indexSchema{indexName: "SingersByBio", baseTableName: "Singers", isSearch: true}
+	eventsByTime := &indexSchema{indexName: "analytics.EventsByTime", baseTableName: "analytics.Events"}
+	concertsByDate := &indexSchema{indexName: "ConcertsByDate", baseTableName: "Concerts"}
+	// Managed by Cloud Spanner for a foreign key, so it doesn't appear in the DDL.
+	managed := &indexSchema{indexName: "IDX_Albums_SingerId_U_1234", baseTableName: "Albums"}
+	indexes := []*indexSchema{albumsByTitle, singersByName, singersByBio, eventsByTime, concertsByDate, managed}
+	schemas := []*tableSchema{{tableName: "Singers"}, {tableName: "Albums"}, {tableName: "analytics.Events"}}
+
+	rebuild, kept := planIndexRebuild(statements, indexes, schemas)
+	if want := []*indexSchema{albumsByTitle, singersByName, singersByBio, eventsByTime}; !cmp.Equal(rebuild.indexes, want, cmp.AllowUnexported(indexSchema{})) {
+		t.Errorf("indexes: diff(+got, -want) = %v", cmp.Diff(rebuild.indexes, want, cmp.AllowUnexported(indexSchema{})))
+	}
+	if want := statements[1:5]; !cmp.Equal(rebuild.creates, want) {
+		t.Errorf("creates: diff(+got, -want) = %v", cmp.Diff(rebuild.creates, want))
+	}
+	if want := []*indexSchema{concertsByDate, managed}; !cmp.Equal(kept, want, cmp.AllowUnexported(indexSchema{})) {
+		t.Errorf("kept: diff(+got, -want) = %v", cmp.Diff(kept, want, cmp.AllowUnexported(indexSchema{})))
+	}
+}
+
+func TestIndexRebuildDropStatements(t *testing.T) {
+	rebuild := &indexRebuild{indexes: []*indexSchema{
+		{indexName: "SingersByName", baseTableName: "Singers"},
+		{indexName: "SingersByBio", baseTableName: "Singers", isSearch: true},
+		{indexName: "analytics.EventsByTime", baseTableName: "analytics.Events"},
+	}}
+	want := []string{
+		"DROP INDEX `SingersByName`",
+		"DROP SEARCH INDEX `SingersByBio`",
+		"DROP INDEX `analytics`.`EventsByTime`",
+	}
+	if got := rebuild.dropStatements(); !cmp.Equal(got, want) {
+		t.Errorf("diff(+got, -want) = %v", cmp.Diff(got, want))
+	}
+}
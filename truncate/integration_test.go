@@ -26,7 +26,10 @@ import (
 
 	"cloud.google.com/go/spanner"
 	adminapi "cloud.google.com/go/spanner/admin/database/apiv1"
+	instanceapi "cloud.google.com/go/spanner/admin/instance/apiv1"
 	adminpb "google.golang.org/genproto/googleapis/spanner/admin/database/v1"
+	instancepb "google.golang.org/genproto/googleapis/spanner/admin/instance/v1"
+	"google.golang.org/grpc/codes"
 )
 
 const (
@@ -46,19 +49,67 @@ var (
 )
 
 func TestMain(m *testing.M) {
-	initialize()
+	if err := initialize(); err != nil {
+		fmt.Fprintf(os.Stderr, "failed to initialize integration test: %v\n", err)
+		os.Exit(1)
+	}
 	os.Exit(m.Run())
 }
 
-func initialize() {
-	if os.Getenv(envTestProjectID) == "" || os.Getenv(envTestInstanceID) == "" || os.Getenv(envTestDatabaseID) == "" {
-		skipIntegrateTest = true
-		return
-	}
-
+func initialize() error {
 	testProjectID = os.Getenv(envTestProjectID)
 	testInstanceID = os.Getenv(envTestInstanceID)
 	testDatabaseID = os.Getenv(envTestDatabaseID)
+
+	// On the emulator, the instance and the database are created if not specified.
+	if os.Getenv(emulatorHostEnv) != "" && (testProjectID == "" || testInstanceID == "" || testDatabaseID == "") {
+		testProjectID, testInstanceID, testDatabaseID = "emulator-project", "test-instance", "test-database"
+		return setupEmulator(context.Background())
+	}
+	if testProjectID == "" || testInstanceID == "" || testDatabaseID == "" {
+		skipIntegrateTest = true
+	}
+	return nil
+}
+
+// setupEmulator creates the test instance and database on the emulator, unless they exist.
+func setupEmulator(ctx context.Context) error {
+	instanceClient, err := instanceapi.NewInstanceAdminClient(ctx)
+	if err != nil {
+		return err
+	}
+	defer instanceClient.Close()
+	instanceOp, err := instanceClient.CreateInstance(ctx, &instancepb.CreateInstanceRequest{
+		Parent:     fmt.Sprintf("projects/%s", testProjectID),
+		InstanceId: testInstanceID,
+		Instance: &instancepb.Instance{
+			Config:    fmt.Sprintf("projects/%s/instanceConfigs/emulator-config", testProjectID),
+			NodeCount: 1,
+		},
+	})
+	if err == nil {
+		_, err = instanceOp.Wait(ctx)
+	}
+	if err != nil && errorCode(err) != codes.AlreadyExists {
+		return fmt.Errorf("failed to create instance: %v", err)
+	}
+
+	adminClient, err := adminapi.NewDatabaseAdminClient(ctx)
+	if err != nil {
+		return err
+	}
+	defer adminClient.Close()
+	databaseOp, err := adminClient.CreateDatabase(ctx, &adminpb.CreateDatabaseRequest{
+		Parent:          fmt.Sprintf("projects/%s/instances/%s", testProjectID, testInstanceID),
+		CreateStatement: fmt.Sprintf("CREATE DATABASE `%s`", testDatabaseID),
+	})
+	if err == nil {
+		_, err = databaseOp.Wait(ctx)
+	}
+	if err != nil && errorCode(err) != codes.AlreadyExists {
+		return fmt.Errorf("failed to create database: %v", err)
+	}
+	return nil
 }
 
 func setup(t *testing.T, ctx context.Context, ddls, dmls []string) *spanner.Client {
@@ -126,8 +177,7 @@ func generateUniqueTableID() string {
 	return fmt.Sprintf("spanner_truncate_test_%d_%d", time.Now().Unix(), count)
 }
 
-// NOTE that this integration test doesn't work for Cloud Spanner Emulator
-// since INFORMATION_SCHEMA.TABLE_CONSTRAINTS table is not available in the emulator.
+// On Cloud Spanner Emulator, set SPANNER_EMULATOR_HOST to run this test without the other environment variables.
 func TestIntegrationTest(t *testing.T) {
 	if skipIntegrateTest {
 		t.Skip("skip integration test")
@@ -197,7 +247,8 @@ func TestIntegrationTest(t *testing.T) {
 	if err != nil {
 		t.Fatalf("failed to open /dev/null: %v", err)
 	}
-	if err := Run(ctx, testProjectID, testInstanceID, testDatabaseID, true, devNull, nil, nil); err != nil {
+	database := fmt.Sprintf("projects/%s/instances/%s/databases/%s", testProjectID, testInstanceID, testDatabaseID)
+	if err := Run(ctx, database, WithQuiet(), WithOut(devNull)); err != nil {
 		t.Fatalf("run spanner-truncate failed: %v", err)
 	}
 
@@ -217,3 +268,49 @@ func TestIntegrationTest(t *testing.T) {
 		}
 	}
 }
+
+// A table whose rows are no longer counted is completed once the sharded statements return.
+func TestIntegrationTestShardedUncounted(t *testing.T) {
+	if skipIntegrateTest {
+		t.Skip("skip integration test")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 180*time.Second)
+	defer cancel()
+
+	table := generateUniqueTableID()
+	ddls := []string{
+		fmt.Sprintf(`CREATE TABLE %s (
+  Id INT64 NOT NULL,
+) PRIMARY KEY(Id)`, table),
+	}
+	var dmls []string
+	for i := 1; i <= 10; i++ {
+		dmls = append(dmls, fmt.Sprintf("INSERT INTO `%s` (`Id`) VALUES (%d);", table, i))
+	}
+	client := setup(t, ctx, ddls, dmls)
+	defer tearDown(t, ctx, []string{fmt.Sprintf("DROP TABLE %s", table)})
+
+	devNull, err := os.Open(os.DevNull)
+	if err != nil {
+		t.Fatalf("failed to open /dev/null: %v", err)
+	}
+	database := fmt.Sprintf("projects/%s/instances/%s/databases/%s", testProjectID, testInstanceID, testDatabaseID)
+	if err := Run(ctx, database, WithQuiet(), WithOut(devNull), WithCountThreshold(1), WithShardedDeletion(1, 2)); err != nil {
+		t.Fatalf("run spanner-truncate failed: %v", err)
+	}
+
+	iter := client.Single().Query(ctx, spanner.NewStatement(fmt.Sprintf("SELECT COUNT(*) FROM %s", table)))
+	if err := iter.Do(func(r *spanner.Row) error {
+		var count int64
+		if err := r.Column(0, &count); err != nil {
+			return err
+		}
+		if count != 0 {
+			t.Errorf("deleted all rows from %q table, but %d rows remained", table, count)
+		}
+		return nil
+	}); err != nil {
+		t.Errorf("failed to count rows: %v", err)
+	}
+}
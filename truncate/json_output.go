@@ -0,0 +1,194 @@
+//
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package truncate
+
+import (
+	"encoding/json"
+	"io"
+	"sync"
+	"time"
+)
+
+// WithJSONOutput writes the output of the run to out as JSON lines instead of human-readable text and progress bars:
+// a "tables" record of the target tables, "progress" records of tables whose progress changed, and a final "summary" record.
+// Prompts can't be shown in this mode, so use it in quiet mode or with WithDryRun.
+func WithJSONOutput() Option {
+	return func(o *options) {
+		o.jsonOutput = true
+	}
+}
+
+// Types of records written by WithJSONOutput.
+const (
+	jsonRecordTables   = "tables"
+	jsonRecordProgress = "progress"
+	jsonRecordSummary  = "summary"
+)
+
+// jsonRecord is a line of JSON output. Fields which don't apply to the type are omitted.
+type jsonRecord struct {
+	Type     string      `json:"type"`
+	Time     time.Time   `json:"time"`
+	Database string      `json:"database"`
+	Tables   interface{} `json:"tables,omitempty"`
+
+	// Set for summary records.
+	Status   string   `json:"status,omitempty"`
+	Warnings []string `json:"warnings,omitempty"`
+	Error    string   `json:"error,omitempty"`
+}
+
+// jsonTable is a target table in a tables record.
+type jsonTable struct {
+	Name     string `json:"name"`
+	Parent   string `json:"parent,omitempty"`
+	RowCount *int64 `json:"row_count,omitempty"`
+	Bytes    *int64 `json:"bytes,omitempty"`
+
+	// Table name deleting rows of the table in cascade. Blank if the table receives its own DELETE statement.
+	CascadeFrom string `json:"cascade_from,omitempty"`
+
+	// Expression of the row deletion policy of the table, if any.
+	RowDeletionPolicy string `json:"row_deletion_policy,omitempty"`
+}
+
+// jsonTableProgress is the progress of a table in progress and summary records.
+type jsonTableProgress struct {
+	Name        string      `json:"name"`
+	Status      string      `json:"status"`
+	DeletedRows uint64      `json:"deleted_rows"`
+	TotalRows   uint64      `json:"total_rows"`
+	Retries     *RetryStats `json:"retries,omitempty"`
+
+	// Set in summary records. See TableReport for the meanings.
+	Strategy        string  `json:"strategy,omitempty"`
+	AlreadyEmpty    bool    `json:"already_empty,omitempty"`
+	DurationSeconds float64 `json:"duration_seconds,omitempty"`
+	Error           string  `json:"error,omitempty"`
+}
+
+// jsonOutput writes records as JSON lines. It is safe to call methods on a nil receiver, which writes nothing.
+type jsonOutput struct {
+	database string
+
+	mu     sync.Mutex // Guards enc and tables.
+	enc    *json.Encoder
+	tables []*table // Tables being deleted. Nil until the deletion starts.
+}
+
+func newJSONOutput(out io.Writer, database string) *jsonOutput {
+	return &jsonOutput{database: database, enc: json.NewEncoder(out)}
+}
+
+func (j *jsonOutput) write(record jsonRecord) {
+	if j == nil {
+		return
+	}
+	if record.Time.IsZero() {
+		record.Time = time.Now()
+	}
+	record.Database = j.database
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	// Errors are ignored as well as text output.
+	_ = j.enc.Encode(record)
+}
+
+// writeTables writes the target tables and how they are deleted.
+func (j *jsonOutput) writeTables(schemas []*tableSchema, rowCounts, tableBytes map[string]int64, plan *deletionPlan) {
+	if j == nil {
+		return
+	}
+	tables := make([]jsonTable, len(schemas))
+	for i, schema := range schemas {
+		t := jsonTable{Name: schema.tableName, Parent: schema.parentTableName, CascadeFrom: plan.cascadeFrom[schema.tableName], RowDeletionPolicy: schema.rowDeletionPolicy}
+		if count, ok := rowCounts[schema.tableName]; ok {
+			t.RowCount = &count
+		}
+		if bytes, ok := tableBytes[schema.tableName]; ok {
+			t.Bytes = &bytes
+		}
+		tables[i] = t
+	}
+	j.write(jsonRecord{Type: jsonRecordTables, Tables: tables})
+}
+
+// writeProgress writes the progress of the tables.
+func (j *jsonOutput) writeProgress(now time.Time, tables []*table) {
+	if j == nil || len(tables) == 0 {
+		return
+	}
+	progresses := make([]jsonTableProgress, len(tables))
+	for i, t := range tables {
+		progresses[i] = newJSONTableProgress(t, false)
+	}
+	j.write(jsonRecord{Type: jsonRecordProgress, Time: now, Tables: progresses})
+}
+
+// setTables sets the tables being deleted, whose final states are written in the summary.
+func (j *jsonOutput) setTables(tables []*table) {
+	if j == nil {
+		return
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.tables = flattenTables(tables)
+}
+
+// writeSummary writes the result of the run. status is one of "completed", "dry_run", "cancelled" and "failed".
+func (j *jsonOutput) writeSummary(status string, warnings *warnings, err error) {
+	if j == nil {
+		return
+	}
+	record := jsonRecord{Type: jsonRecordSummary, Status: status}
+	j.mu.Lock()
+	if len(j.tables) > 0 {
+		progresses := make([]jsonTableProgress, len(j.tables))
+		for i, t := range j.tables {
+			progresses[i] = newJSONTableProgress(t, true)
+		}
+		record.Tables = progresses
+	}
+	j.mu.Unlock()
+	record.Warnings = warnings.strings()
+	if err != nil {
+		record.Error = err.Error()
+	}
+	j.write(record)
+}
+
+// newJSONTableProgress creates the progress of the table. If final is true, the result of the deletion is included.
+func newJSONTableProgress(t *table, final bool) jsonTableProgress {
+	p := jsonTableProgress{
+		Name:        t.tableName,
+		Status:      t.deleter.status.String(),
+		DeletedRows: t.deleter.totalRows - t.deleter.remainedRows,
+		TotalRows:   t.deleter.totalRows,
+	}
+	if final {
+		stats := t.deleter.retries.stats()
+		p.Retries = &stats
+		p.Strategy = t.deleter.strategy
+		p.AlreadyEmpty = t.deleter.alreadyEmpty
+		p.DurationSeconds = t.deleter.deleteDuration.Seconds()
+		if t.deleter.failure != nil {
+			p.Error = t.deleter.failure.Error()
+		}
+	}
+	return p
+}
@@ -0,0 +1,124 @@
+//
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package truncate
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+// decodeJSONLines decodes JSON lines, dropping "time" fields which vary by runs.
+func decodeJSONLines(t *testing.T, s string) []map[string]interface{} {
+	t.Helper()
+	var records []map[string]interface{}
+	for _, line := range strings.Split(strings.TrimSpace(s), "\n") {
+		var record map[string]interface{}
+		if err := json.Unmarshal([]byte(line), &record); err != nil {
+			t.Fatalf("failed to decode %q: %v", line, err)
+		}
+		delete(record, "time")
+		records = append(records, record)
+	}
+	return records
+}
+
+func TestJSONOutput(t *testing.T) {
+	schemas := []*tableSchema{
+		{tableName: "Singers"},
+		{tableName: "Albums", parentTableName: "Singers", parentOnDeleteAction: deleteActionCascadeDelete},
+	}
+	plan := &deletionPlan{waves: [][]string{{"Singers"}}, cascadeFrom: map[string]string{"Albums": "Singers"}}
+	singers := &table{tableName: "Singers", deleter: &deleter{status: statusDeleting, totalRows: 6000, remainedRows: 6000}}
+	albums := &table{tableName: "Albums", deleter: &deleter{status: statusCascadeDeleting, totalRows: 1800, remainedRows: 1800}}
+	singers.childTables = []*table{albums}
+
+	var buf bytes.Buffer
+	j := newJSONOutput(&buf, "projects/p/instances/i/databases/d")
+	j.writeTables(schemas, map[string]int64{"Singers": 6000, "Albums": 1800}, map[string]int64{"Singers": 1024}, plan)
+
+	began := time.Date(2021, 6, 1, 12, 0, 0, 0, time.UTC)
+	l := &progressLines{json: j, tables: flattenTables([]*table{singers}), began: began, last: map[string]string{}}
+	l.print(began)
+	// Only Singers changed.
+	singers.deleter.remainedRows = 1000
+	l.print(began.Add(5 * time.Second))
+	// Nothing changed.
+	l.print(began.Add(10 * time.Second))
+
+	singers.deleter.status, singers.deleter.remainedRows = statusCompleted, 0
+	albums.deleter.status, albums.deleter.remainedRows = statusCompleted, 0
+	j.setTables([]*table{singers})
+	w := newWarnings(nil)
+	w.add("Albums", "failed to count rows")
+	j.writeSummary("failed", w, errors.New("deadline exceeded"))
+
+	noRetries := map[string]interface{}{"transaction_aborts": 0.0, "pdml_restarts": 0.0, "rpc_retries": 0.0, "delete_retries": 0.0}
+	want := []map[string]interface{}{
+		{
+			"type":     "tables",
+			"database": "projects/p/instances/i/databases/d",
+			"tables": []interface{}{
+				map[string]interface{}{"name": "Singers", "row_count": 6000.0, "bytes": 1024.0},
+				map[string]interface{}{"name": "Albums", "parent": "Singers", "row_count": 1800.0, "cascade_from": "Singers"},
+			},
+		},
+		{
+			"type":     "progress",
+			"database": "projects/p/instances/i/databases/d",
+			"tables": []interface{}{
+				map[string]interface{}{"name": "Singers", "status": "deleting", "deleted_rows": 0.0, "total_rows": 6000.0},
+				map[string]interface{}{"name": "Albums", "status": "cascade deleting", "deleted_rows": 0.0, "total_rows": 1800.0},
+			},
+		},
+		{
+			"type":     "progress",
+			"database": "projects/p/instances/i/databases/d",
+			"tables": []interface{}{
+				map[string]interface{}{"name": "Singers", "status": "deleting", "deleted_rows": 5000.0, "total_rows": 6000.0},
+			},
+		},
+		{
+			"type":     "summary",
+			"database": "projects/p/instances/i/databases/d",
+			"status":   "failed",
+			"tables": []interface{}{
+				map[string]interface{}{"name": "Singers", "status": "completed", "deleted_rows": 6000.0, "total_rows": 6000.0, "retries": noRetries},
+				map[string]interface{}{"name": "Albums", "status": "completed", "deleted_rows": 1800.0, "total_rows": 1800.0, "retries": noRetries},
+			},
+			"warnings": []interface{}{"Albums: failed to count rows"},
+			"error":    "deadline exceeded",
+		},
+	}
+	if got := decodeJSONLines(t, buf.String()); !cmp.Equal(got, want) {
+		t.Errorf("diff(+got, -want) = %v", cmp.Diff(got, want))
+	}
+}
+
+func TestJSONOutputNil(t *testing.T) {
+	var j *jsonOutput
+	// None of them should panic.
+	j.writeTables(nil, nil, nil, &deletionPlan{})
+	j.writeProgress(time.Now(), []*table{{tableName: "Singers", deleter: &deleter{}}})
+	j.setTables(nil)
+	j.writeSummary("completed", nil, nil)
+}
@@ -0,0 +1,50 @@
+//
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package truncate
+
+import (
+	"context"
+	"log/slog"
+)
+
+// WithLogHandler makes the run log what it does, e.g. fetching the schema, deleting tables, failures and warnings,
+// to the handler. Logs are discarded by default. The table listing, the confirmation and the progress are still
+// written to out, so the handler usually writes to another destination such as stderr.
+func WithLogHandler(handler slog.Handler) Option {
+	return func(o *options) {
+		o.logger = slog.New(handler)
+	}
+}
+
+// discardLogger is used when no log handler is given.
+var discardLogger = slog.New(discardHandler{})
+
+// discardHandler is a slog.Handler which discards all records.
+type discardHandler struct{}
+
+func (discardHandler) Enabled(context.Context, slog.Level) bool  { return false }
+func (discardHandler) Handle(context.Context, slog.Record) error { return nil }
+func (h discardHandler) WithAttrs([]slog.Attr) slog.Handler      { return h }
+func (h discardHandler) WithGroup(string) slog.Handler           { return h }
+
+// orDiscard returns the logger, or the discarding logger if it's nil, e.g. for structs built in tests.
+func orDiscard(logger *slog.Logger) *slog.Logger {
+	if logger == nil {
+		return discardLogger
+	}
+	return logger
+}
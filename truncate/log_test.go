@@ -0,0 +1,67 @@
+//
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package truncate
+
+import (
+	"bytes"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestEmitterLog(t *testing.T) {
+	var buf bytes.Buffer
+	// Drop the time to make the output stable.
+	handler := slog.NewTextHandler(&buf, &slog.HandlerOptions{ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+		if a.Key == slog.TimeKey && len(groups) == 0 {
+			return slog.Attr{}
+		}
+		return a
+	}})
+	var events []Event
+	e := newEmitter(func(e Event) { events = append(events, e) })
+	e.logger = slog.New(handler)
+
+	e.emit(Event{Type: EventWaveStarted, Wave: 1, Tables: []string{"Singers", "Concerts"}})
+	e.emit(Event{Type: EventWarning, Tables: []string{"Albums"}, Message: "failed to count rows"})
+	e.emit(Event{Type: EventWaveCompleted, Wave: 1, Tables: []string{"Singers", "Concerts"}})
+
+	want := `level=INFO msg="wave started" wave=1 tables="[Singers Concerts]"
+level=WARN msg="failed to count rows" tables=[Albums]
+level=INFO msg="wave completed" wave=1 tables="[Singers Concerts]"
+`
+	if got := buf.String(); got != want {
+		t.Errorf("log mismatch (-got +want):\n%s", cmp.Diff(got, want))
+	}
+	if len(events) != 3 {
+		t.Errorf("%d events were delivered, but want 3", len(events))
+	}
+}
+
+func TestEmitterLogWithoutHandler(t *testing.T) {
+	var buf bytes.Buffer
+	e := newEmitter(nil)
+	e.logger = slog.New(slog.NewTextHandler(&buf, nil))
+
+	e.emit(Event{Type: EventWarning, Message: "logged without event handler"})
+
+	if got := buf.String(); !strings.Contains(got, "logged without event handler") {
+		t.Errorf("log = %q, but want the warning", got)
+	}
+}
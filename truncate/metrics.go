@@ -0,0 +1,163 @@
+//
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package truncate
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// deleteDurationBuckets are upper bounds in seconds of the buckets of the delete duration histogram.
+var deleteDurationBuckets = []float64{1, 5, 30, 60, 300, 1800, 3600, 21600}
+
+// Metrics exposes metrics of runs in the Prometheus text format when served over HTTP, e.g. at /metrics.
+// Register it to runs with WithMetrics. A Metrics can be shared by runs of multiple databases,
+// whose metrics are distinguished by the database label.
+type Metrics struct {
+	mu        sync.Mutex
+	databases map[string]*databaseMetrics
+}
+
+// NewMetrics creates a Metrics without any runs.
+func NewMetrics() *Metrics {
+	return &Metrics{databases: map[string]*databaseMetrics{}}
+}
+
+// WithMetrics makes the run report the progress of deletion to the metrics.
+func WithMetrics(metrics *Metrics) Option {
+	return func(o *options) {
+		o.metrics = metrics
+	}
+}
+
+// databaseMetrics holds metrics of the latest run for a database.
+type databaseMetrics struct {
+	tables []*table
+
+	mu        sync.Mutex // Guards the histogram below.
+	buckets   []uint64   // Cumulative counts of deletions per bucket.
+	count     uint64
+	sumSecond float64
+}
+
+// addDatabase registers the tables of a run, replacing metrics of the previous run for the database if any.
+func (m *Metrics) addDatabase(database string, tables []*table) *databaseMetrics {
+	d := &databaseMetrics{tables: flattenTables(tables), buckets: make([]uint64, len(deleteDurationBuckets))}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.databases[database] = d
+	return d
+}
+
+// observeDeletion records the duration of a table deletion. It is safe to call it on a nil receiver, which discards it.
+func (d *databaseMetrics) observeDeletion(elapsed time.Duration) {
+	if d == nil {
+		return
+	}
+	seconds := elapsed.Seconds()
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for i, bound := range deleteDurationBuckets {
+		if seconds <= bound {
+			d.buckets[i]++
+		}
+	}
+	d.count++
+	d.sumSecond += seconds
+}
+
+// ServeHTTP writes the metrics in the Prometheus text format.
+func (m *Metrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	m.write(w)
+}
+
+func (m *Metrics) write(w io.Writer) {
+	m.mu.Lock()
+	databases := make([]string, 0, len(m.databases))
+	for name := range m.databases {
+		databases = append(databases, name)
+	}
+	sort.Strings(databases)
+	snapshot := make([]*databaseMetrics, len(databases))
+	for i, name := range databases {
+		snapshot[i] = m.databases[name]
+	}
+	m.mu.Unlock()
+
+	fmt.Fprint(w, "# HELP spanner_truncate_rows_deleted Number of rows deleted from the table, as counted during the deletion.\n")
+	fmt.Fprint(w, "# TYPE spanner_truncate_rows_deleted gauge\n")
+	for i, d := range snapshot {
+		for _, t := range d.tables {
+			fmt.Fprintf(w, "spanner_truncate_rows_deleted{database=%s,table=%s} %d\n", quoteLabel(databases[i]), quoteLabel(t.tableName), t.deleter.totalRows-t.deleter.remainedRows)
+		}
+	}
+
+	for _, g := range []struct {
+		name   string
+		help   string
+		filter func(status) bool
+	}{
+		{"spanner_truncate_tables_waiting", "Number of tables waiting for the deletion of other tables, or being analyzed.", func(s status) bool {
+			return s == statusAnalyzing || s == statusWaiting
+		}},
+		{"spanner_truncate_tables_deleting", "Number of tables being deleted by their own statements or in cascade.", func(s status) bool {
+			return s == statusDeleting || s == statusCascadeDeleting
+		}},
+		{"spanner_truncate_tables_completed", "Number of tables whose rows have been deleted.", func(s status) bool {
+			return s == statusCompleted
+		}},
+	} {
+		fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s gauge\n", g.name, g.help, g.name)
+		for i, d := range snapshot {
+			var n int
+			for _, t := range d.tables {
+				if g.filter(t.deleter.status) {
+					n++
+				}
+			}
+			fmt.Fprintf(w, "%s{database=%s} %d\n", g.name, quoteLabel(databases[i]), n)
+		}
+	}
+
+	fmt.Fprint(w, "# HELP spanner_truncate_delete_duration_seconds Duration of DELETE statements and mutations deleting tables.\n")
+	fmt.Fprint(w, "# TYPE spanner_truncate_delete_duration_seconds histogram\n")
+	for i, d := range snapshot {
+		database := quoteLabel(databases[i])
+		d.mu.Lock()
+		for j, bound := range deleteDurationBuckets {
+			fmt.Fprintf(w, "spanner_truncate_delete_duration_seconds_bucket{database=%s,le=\"%g\"} %d\n", database, bound, d.buckets[j])
+		}
+		fmt.Fprintf(w, "spanner_truncate_delete_duration_seconds_bucket{database=%s,le=\"+Inf\"} %d\n", database, d.count)
+		fmt.Fprintf(w, "spanner_truncate_delete_duration_seconds_sum{database=%s} %g\n", database, d.sumSecond)
+		fmt.Fprintf(w, "spanner_truncate_delete_duration_seconds_count{database=%s} %d\n", database, d.count)
+		d.mu.Unlock()
+	}
+}
+
+// labelEscaper escapes label values as the Prometheus text format requires.
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// quoteLabel quotes the label value.
+func quoteLabel(value string) string {
+	return `"` + labelEscaper.Replace(value) + `"`
+}
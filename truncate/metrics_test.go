@@ -0,0 +1,95 @@
+//
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package truncate
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestMetrics(t *testing.T) {
+	singers := &table{tableName: "Singers", deleter: &deleter{status: statusDeleting, totalRows: 6000, remainedRows: 3600}}
+	albums := &table{tableName: "Albums", deleter: &deleter{status: statusCascadeDeleting, totalRows: 1800, remainedRows: 1800}}
+	concerts := &table{tableName: "Concerts", deleter: &deleter{status: statusCompleted, totalRows: 1200}}
+	venues := &table{tableName: "Venues", deleter: &deleter{status: statusWaiting, totalRows: 10, remainedRows: 10}}
+	singers.childTables = []*table{albums}
+
+	m := NewMetrics()
+	d := m.addDatabase("projects/p/instances/i/databases/db1", []*table{singers, concerts, venues})
+	d.observeDeletion(3 * time.Second)
+	d.observeDeletion(90 * time.Second)
+	m.addDatabase(`projects/p/instances/i/databases/"db2"`, nil)
+	// Deletions of the coordinator without metrics are discarded.
+	var none *databaseMetrics
+	none.observeDeletion(time.Second)
+
+	rec := httptest.NewRecorder()
+	m.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+
+	want := `# HELP spanner_truncate_rows_deleted Number of rows deleted from the table, as counted during the deletion.
+# TYPE spanner_truncate_rows_deleted gauge
+spanner_truncate_rows_deleted{database="projects/p/instances/i/databases/db1",table="Singers"} 2400
+spanner_truncate_rows_deleted{database="projects/p/instances/i/databases/db1",table="Albums"} 0
+spanner_truncate_rows_deleted{database="projects/p/instances/i/databases/db1",table="Concerts"} 1200
+spanner_truncate_rows_deleted{database="projects/p/instances/i/databases/db1",table="Venues"} 0
+# HELP spanner_truncate_tables_waiting Number of tables waiting for the deletion of other tables, or being analyzed.
+# TYPE spanner_truncate_tables_waiting gauge
+spanner_truncate_tables_waiting{database="projects/p/instances/i/databases/\"db2\""} 0
+spanner_truncate_tables_waiting{database="projects/p/instances/i/databases/db1"} 1
+# HELP spanner_truncate_tables_deleting Number of tables being deleted by their own statements or in cascade.
+# TYPE spanner_truncate_tables_deleting gauge
+spanner_truncate_tables_deleting{database="projects/p/instances/i/databases/\"db2\""} 0
+spanner_truncate_tables_deleting{database="projects/p/instances/i/databases/db1"} 2
+# HELP spanner_truncate_tables_completed Number of tables whose rows have been deleted.
+# TYPE spanner_truncate_tables_completed gauge
+spanner_truncate_tables_completed{database="projects/p/instances/i/databases/\"db2\""} 0
+spanner_truncate_tables_completed{database="projects/p/instances/i/databases/db1"} 1
+# HELP spanner_truncate_delete_duration_seconds Duration of DELETE statements and mutations deleting tables.
+# TYPE spanner_truncate_delete_duration_seconds histogram
+spanner_truncate_delete_duration_seconds_bucket{database="projects/p/instances/i/databases/\"db2\"",le="1"} 0
+spanner_truncate_delete_duration_seconds_bucket{database="projects/p/instances/i/databases/\"db2\"",le="5"} 0
+spanner_truncate_delete_duration_seconds_bucket{database="projects/p/instances/i/databases/\"db2\"",le="30"} 0
+spanner_truncate_delete_duration_seconds_bucket{database="projects/p/instances/i/databases/\"db2\"",le="60"} 0
+spanner_truncate_delete_duration_seconds_bucket{database="projects/p/instances/i/databases/\"db2\"",le="300"} 0
+spanner_truncate_delete_duration_seconds_bucket{database="projects/p/instances/i/databases/\"db2\"",le="1800"} 0
+spanner_truncate_delete_duration_seconds_bucket{database="projects/p/instances/i/databases/\"db2\"",le="3600"} 0
+spanner_truncate_delete_duration_seconds_bucket{database="projects/p/instances/i/databases/\"db2\"",le="21600"} 0
+spanner_truncate_delete_duration_seconds_bucket{database="projects/p/instances/i/databases/\"db2\"",le="+Inf"} 0
+spanner_truncate_delete_duration_seconds_sum{database="projects/p/instances/i/databases/\"db2\""} 0
+spanner_truncate_delete_duration_seconds_count{database="projects/p/instances/i/databases/\"db2\""} 0
+spanner_truncate_delete_duration_seconds_bucket{database="projects/p/instances/i/databases/db1",le="1"} 0
+spanner_truncate_delete_duration_seconds_bucket{database="projects/p/instances/i/databases/db1",le="5"} 1
+spanner_truncate_delete_duration_seconds_bucket{database="projects/p/instances/i/databases/db1",le="30"} 1
+spanner_truncate_delete_duration_seconds_bucket{database="projects/p/instances/i/databases/db1",le="60"} 1
+spanner_truncate_delete_duration_seconds_bucket{database="projects/p/instances/i/databases/db1",le="300"} 2
+spanner_truncate_delete_duration_seconds_bucket{database="projects/p/instances/i/databases/db1",le="1800"} 2
+spanner_truncate_delete_duration_seconds_bucket{database="projects/p/instances/i/databases/db1",le="3600"} 2
+spanner_truncate_delete_duration_seconds_bucket{database="projects/p/instances/i/databases/db1",le="21600"} 2
+spanner_truncate_delete_duration_seconds_bucket{database="projects/p/instances/i/databases/db1",le="+Inf"} 2
+spanner_truncate_delete_duration_seconds_sum{database="projects/p/instances/i/databases/db1"} 93
+spanner_truncate_delete_duration_seconds_count{database="projects/p/instances/i/databases/db1"} 2
+`
+	if diff := cmp.Diff(rec.Body.String(), want); diff != "" {
+		t.Errorf("metrics mismatch (-got +want):\n%s", diff)
+	}
+	if got := rec.Header().Get("Content-Type"); got != "text/plain; version=0.0.4; charset=utf-8" {
+		t.Errorf("Content-Type = %q", got)
+	}
+}
@@ -0,0 +1,101 @@
+//
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package truncate
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// notifyTimeout is the timeout of posting a notification to a webhook.
+const notifyTimeout = 10 * time.Second
+
+// WithNotification posts the report of the run as JSON to the URL when the run finishes, whether it succeeded or failed,
+// e.g. to alert on nightly data resets. If slack is true, a Slack-compatible payload with a one-line summary is posted
+// instead, which Slack incoming webhooks and many chat tools accept. A failure to notify is logged and printed,
+// but doesn't fail the run. It can be given multiple times to notify multiple webhooks.
+func WithNotification(url string, slack bool) Option {
+	return func(o *options) {
+		o.webhooks = append(o.webhooks, webhook{url: url, slack: slack})
+	}
+}
+
+// webhook is a destination of the notification of a run.
+type webhook struct {
+	url   string
+	slack bool
+}
+
+// notify posts the report to the webhooks, and returns the errors of all of them joined.
+func notify(ctx context.Context, webhooks []webhook, report *Report) error {
+	var errs []error
+	for _, w := range webhooks {
+		if err := w.post(ctx, report); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (w webhook) post(ctx context.Context, report *Report) error {
+	var payload interface{} = report
+	if w.slack {
+		payload = map[string]string{"text": formatReportSummary(report)}
+	}
+	b, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, notifyTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.url, bytes.NewReader(b))
+	if err != nil {
+		return fmt.Errorf("failed to notify %s: %w", w.url, err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to notify %s: %w", w.url, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("failed to notify %s: %s", w.url, resp.Status)
+	}
+	return nil
+}
+
+// formatReportSummary formats the result of the run in a line.
+func formatReportSummary(r *Report) string {
+	var deleted uint64
+	for _, t := range r.Tables {
+		if t.DeletedRows != nil {
+			deleted += *t.DeletedRows
+		}
+	}
+	elapsed := (time.Duration(r.DurationSeconds * float64(time.Second))).Round(time.Second)
+	s := fmt.Sprintf("spanner-truncate %s on %s: %s rows deleted from %d tables in %s", r.Status, r.Database, formatNumber(deleted), len(r.Tables), elapsed)
+	if r.Error != "" {
+		s += ": " + r.Error
+	}
+	return s
+}
@@ -0,0 +1,99 @@
+//
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package truncate
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestNotify(t *testing.T) {
+	rows := func(n uint64) *uint64 { return &n }
+	report := &Report{
+		Database: "projects/p/instances/i/databases/d",
+		Tables: []*TableReport{
+			{Name: "Singers", DeletedRows: rows(6000)},
+			{Name: "Albums", DeletedRows: rows(1800)},
+		},
+		Status:          "completed",
+		DurationSeconds: 35.8,
+	}
+
+	for _, test := range []struct {
+		desc  string
+		slack bool
+		want  map[string]interface{}
+	}{
+		{
+			desc: "JSON",
+			want: map[string]interface{}{"database": "projects/p/instances/i/databases/d", "status": "completed"},
+		},
+		{
+			desc:  "Slack",
+			slack: true,
+			want:  map[string]interface{}{"text": "spanner-truncate completed on projects/p/instances/i/databases/d: 7,800 rows deleted from 2 tables in 36s"},
+		},
+	} {
+		t.Run(test.desc, func(t *testing.T) {
+			var got map[string]interface{}
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if ct := r.Header.Get("Content-Type"); ct != "application/json" {
+					t.Errorf("Content-Type = %q, want application/json", ct)
+				}
+				b, _ := io.ReadAll(r.Body)
+				if err := json.Unmarshal(b, &got); err != nil {
+					t.Errorf("invalid payload %s: %v", b, err)
+				}
+			}))
+			defer server.Close()
+
+			if err := notify(context.Background(), []webhook{{url: server.URL, slack: test.slack}}, report); err != nil {
+				t.Fatalf("notify() failed: %v", err)
+			}
+			for k, v := range test.want {
+				if diff := cmp.Diff(v, got[k]); diff != "" {
+					t.Errorf("payload[%q]: diff = %v", k, diff)
+				}
+			}
+		})
+	}
+}
+
+func TestNotifyError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	defer server.Close()
+
+	ok := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer ok.Close()
+
+	err := notify(context.Background(), []webhook{{url: server.URL}, {url: ok.URL}}, &Report{Status: "failed"})
+	if err == nil {
+		t.Fatalf("notify() succeeded, want error")
+	}
+	want := "failed to notify " + server.URL + ": 403 Forbidden"
+	if diff := cmp.Diff(want, err.Error()); diff != "" {
+		t.Errorf("notify(): diff = %v", diff)
+	}
+}
@@ -0,0 +1,355 @@
+//
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package truncate
+
+import (
+	"io"
+	"log/slog"
+	"os"
+	"time"
+
+	"cloud.google.com/go/spanner"
+	adminapi "cloud.google.com/go/spanner/admin/database/apiv1"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/api/option"
+)
+
+// Option configures optional behaviors of Run and RunWithClient.
+type Option func(*options)
+
+// options holds optional settings applied by Option.
+type options struct {
+	targetTables         []string
+	excludeTables        []string
+	quiet                bool
+	out                  io.Writer
+	statementBuilders    map[string]StatementBuilder
+	eventHandler         EventHandler
+	preHooks             map[string][]string
+	postHooks            map[string][]string
+	hideEmptyTables      bool
+	ignoreMissingTables  bool
+	dryRun               bool
+	reportHandler        func(*Report)
+	baselineReport       *Report
+	continueOnError      bool
+	skipUnauthorized     bool
+	hideProgressBars     bool
+	adminClient          *adminapi.DatabaseAdminClient
+	clientOptions        []option.ClientOption
+	sessionPoolConfig    *spanner.SessionPoolConfig
+	maxRows              *int64
+	maxBytes             *int64
+	failureHandler       FailureHandler
+	failFast             bool
+	stateDumpTrigger     <-chan struct{}
+	stateDumpOut         io.Writer
+	schemas              []string
+	allSchemas           bool
+	plainProgress        bool
+	reportDDL            bool
+	sampleRows           int
+	verifyIndexes        bool
+	jsonOutput           bool
+	mutationMaxRows      int64
+	shardMinRows         int64
+	shards               int
+	batched              bool
+	batchRows            int64
+	batchPause           time.Duration
+	noBatchedFallback    bool
+	keyPrefix            *keyPrefix
+	tenant               *tenant
+	olderThan            time.Duration
+	timestampColumns     map[string]timestampColumn
+	scrubs               map[string]map[string]string
+	seeds                []seed
+	rowFilters           map[string]*rowFilter
+	maxConcurrentDeletes int
+	maxQPS               float64
+	requestOptions       requestOptions
+	requestTag           *string
+	backupRetention      time.Duration
+	timeout              time.Duration
+	tableTimeout         time.Duration
+	emulator             bool
+	statsProgress        bool
+	batchedRowCounts     bool
+	countMaxRows         int64
+	skipEmptyTables      bool
+	webhooks             []webhook
+	planHandler          func(*Plan)
+	approvedPlan         *Plan
+	schemaSnapshot       *SchemaSnapshot
+	transactionTag       *string
+	noRowCounts          bool
+	logger               *slog.Logger
+	tracerProvider       trace.TracerProvider
+	metrics              *Metrics
+	deleteRetries        retryPolicy
+	breakCycles          bool
+	rebuildIndexes       bool
+	dropForeignKeys      bool
+	skipTTLTables        bool
+	progressReporter     ProgressReporter
+	tablesHandler        func([]*table) // Receives the tables being deleted, for Truncator.
+	warningsHandler      func([]string) // Receives the warnings at the end of the run, for Truncator.
+}
+
+func newOptions(opts []Option) *options {
+	o := &options{
+		out:               os.Stdout,
+		statementBuilders: map[string]StatementBuilder{},
+		timestampColumns:  map[string]timestampColumn{},
+		scrubs:            map[string]map[string]string{},
+		preHooks:          map[string][]string{},
+		postHooks:         map[string][]string{},
+		deleteRetries:     retryPolicy{maxRetries: defaultDeleteRetries, initialBackoff: defaultDeleteRetryBackoff},
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+func (o *options) handleReport(report *Report) {
+	if o.reportHandler != nil {
+		o.reportHandler(report)
+	}
+}
+
+// WithTables deletes rows only from the specified tables, instead of all tables in the database.
+// Table names may be globs like "tmp_*" or regular expressions enclosed in slashes like "/^audit_.*/".
+// Tables interleaved in the specified tables with ON DELETE CASCADE are deleted as well.
+// It can't be used with WithExcludeTables.
+func WithTables(tables ...string) Option {
+	return func(o *options) {
+		o.targetTables = append(o.targetTables, tables...)
+	}
+}
+
+// WithExcludeTables excludes the specified tables, and their ancestors which would delete them in cascade,
+// from the deleted tables. Table names may be patterns like WithTables. It can't be used with WithTables.
+func WithExcludeTables(tables ...string) Option {
+	return func(o *options) {
+		o.excludeTables = append(o.excludeTables, tables...)
+	}
+}
+
+// WithIgnoreMissingTables skips names in WithTables and WithExcludeTables which don't exist in the database
+// with a warning, e.g. for tables created only in some environments. By default, the run fails before deleting
+// any rows, so that a typo doesn't silently leave a table undeleted or delete a table meant to be excluded.
+// Patterns matching no tables are always warned only.
+func WithIgnoreMissingTables() Option {
+	return func(o *options) {
+		o.ignoreMissingTables = true
+	}
+}
+
+// WithQuiet disables all interactive prompts, so that rows are deleted without confirmation.
+func WithQuiet() Option {
+	return func(o *options) {
+		o.quiet = true
+	}
+}
+
+// WithOut writes the table listing, prompts, progress and results to out instead of stdout.
+func WithOut(out io.Writer) Option {
+	return func(o *options) {
+		o.out = out
+	}
+}
+
+// StatementBuilder builds a statement to delete rows from the given table.
+type StatementBuilder func(tableName string) spanner.Statement
+
+// WithStatementBuilder overrides the DELETE statement issued for the specified table.
+// The statement is executed with Partitioned DML, so it must satisfy the requirements of Partitioned DML.
+// It's useful to delete only a subset of rows, e.g. "DELETE FROM Events WHERE TenantId = @tid".
+// Because such a statement may not delete all rows, the table and its descendants deleted in cascade
+// are regarded as completed once the statement finishes.
+func WithStatementBuilder(tableName string, builder StatementBuilder) Option {
+	return func(o *options) {
+		o.statementBuilders[tableName] = builder
+	}
+}
+
+// WithHooks registers SQL statements executed before and after deleting rows from the specified table.
+// Pre hooks are executed right before rows of the table start to be deleted, including deletion in cascade by its parent.
+// Post hooks are executed after all rows of the table and its ancestors in the run have been deleted.
+// Each statement is executed in its own read-write transaction in the given order.
+func WithHooks(tableName string, preSQLs, postSQLs []string) Option {
+	return func(o *options) {
+		o.preHooks[tableName] = append(o.preHooks[tableName], preSQLs...)
+		o.postHooks[tableName] = append(o.postHooks[tableName], postSQLs...)
+	}
+}
+
+// WithHideEmptyTables omits tables which are already empty from the listing printed before deletion.
+// Rows of the omitted tables are still verified and deleted if any.
+func WithHideEmptyTables() Option {
+	return func(o *options) {
+		o.hideEmptyTables = true
+	}
+}
+
+// WithContinueOnError keeps deleting rows from tables which don't depend on a failed table,
+// instead of aborting the whole run on the first failure.
+// If any table failed, a *PartialFailureError is returned after all the other deletions finished.
+func WithContinueOnError() Option {
+	return func(o *options) {
+		o.continueOnError = true
+	}
+}
+
+// WithSkipUnauthorized skips tables which the caller lacks permissions to count or delete rows from,
+// e.g. with fine-grained access control, instead of failing the run. Skipped tables are reported as warnings.
+func WithSkipUnauthorized() Option {
+	return func(o *options) {
+		o.skipUnauthorized = true
+	}
+}
+
+// WithoutProgressBars disables progress bars shown during deletion.
+// It's useful when out is not a terminal, or multiple runs share the same terminal.
+// Use WithEventHandler to follow the progress instead.
+func WithoutProgressBars() Option {
+	return func(o *options) {
+		o.hideProgressBars = true
+	}
+}
+
+// WithPlainProgress shows the progress of deletion as plain lines printed on changes, instead of progress bars
+// redrawn in place with ANSI escape sequences. It's useful for consoles without ANSI support and for logs.
+func WithPlainProgress() Option {
+	return func(o *options) {
+		o.plainProgress = true
+	}
+}
+
+// WithoutRowCounts disables COUNT(*) queries, both before and during deletion. The progress is shown as state
+// transitions of tables, e.g. waiting, deleting and completed, in plain lines. It's useful for batch jobs
+// where the progress isn't watched and the load of counting rows is unwelcome. It can't be used with WithSkipLargerThanRows.
+func WithoutRowCounts() Option {
+	return func(o *options) {
+		o.noRowCounts = true
+	}
+}
+
+// WithCountThreshold stops counting rows of tables which have more than maxRows rows at the first count, so that
+// repeated COUNT(*) scans of huge tables don't compete with their deletion. The progress of such tables is shown
+// coarsely, as the remaining rows stay at the first count until the deletion returns. Disabled if 0.
+func WithCountThreshold(maxRows int64) Option {
+	return func(o *options) {
+		o.countMaxRows = maxRows
+	}
+}
+
+// WithAdminClient makes the run use the given admin client, e.g. to verify permissions,
+// instead of creating a new one. The client is not closed by the run, so it can be shared among runs.
+func WithAdminClient(client *adminapi.DatabaseAdminClient) Option {
+	return func(o *options) {
+		o.adminClient = client
+	}
+}
+
+// WithClientOptions passes the options to Cloud Spanner clients created by the run, e.g. option.WithQuotaProject
+// to bill API calls to another project. Clients given by the caller are not affected.
+func WithClientOptions(opts ...option.ClientOption) Option {
+	return func(o *options) {
+		o.clientOptions = append(o.clientOptions, opts...)
+	}
+}
+
+// WithSessionPoolConfig configures the session pool of the Cloud Spanner client created by Run, e.g. to raise
+// MaxOpened for a run deleting many tables in parallel, which otherwise stalls behind session acquisition.
+// Zero fields are filled by the client library as in spanner.NewClientWithConfig. Clients given by the caller are not affected.
+func WithSessionPoolConfig(config spanner.SessionPoolConfig) Option {
+	return func(o *options) {
+		o.sessionPoolConfig = &config
+	}
+}
+
+// WithMutationDeletion deletes tables with at most maxRows rows with a mutation in a single commit instead of PDML,
+// as PDML takes seconds to set up even for a tiny table. Tables deleted with custom statements always use PDML.
+// A mutation fails if it deletes too many rows, including rows of descendants and index entries, so keep maxRows small.
+func WithMutationDeletion(maxRows int64) Option {
+	return func(o *options) {
+		o.mutationMaxRows = maxRows
+	}
+}
+
+// FailureAction is what to do when deleting rows from a table failed.
+type FailureAction int
+
+const (
+	// FailureActionAbort aborts the whole run.
+	FailureActionAbort FailureAction = iota
+	// FailureActionRetry deletes rows from the table again, including its pre hooks.
+	FailureActionRetry
+	// FailureActionSkip leaves the table and tables depending on it, and lets other tables go on.
+	FailureActionSkip
+)
+
+// FailureHandler decides what to do when deleting rows from a table failed.
+// Failures are delivered one at a time, and the failed table waits for the decision
+// while the other tables keep going.
+type FailureHandler func(tableName string, err error) FailureAction
+
+// WithFailureHandler registers a handler which decides what to do on a table failure.
+// It takes precedence over WithContinueOnError.
+// Without a handler, a run which isn't quiet asks what to do on the prompt, unless WithContinueOnError or WithFailFast is given.
+func WithFailureHandler(handler FailureHandler) Option {
+	return func(o *options) {
+		o.failureHandler = handler
+	}
+}
+
+// WithFailFast aborts the whole run on the first table failure without asking on the prompt.
+func WithFailFast() Option {
+	return func(o *options) {
+		o.failFast = true
+	}
+}
+
+// WithSchemas scopes the run to tables in the schemas, e.g. "analytics", where a blank name is the default schema.
+// Tables in named schemas are named with the schema like "analytics.Events". If only a named schema is given,
+// target and exclude tables may be given without the schema. Otherwise, target and exclude tables without
+// the schema refer to tables in the default schema.
+func WithSchemas(schemas ...string) Option {
+	return func(o *options) {
+		o.schemas = schemas
+	}
+}
+
+// WithAllSchemas extends the run to tables in all schemas, including the default schema and named schemas.
+func WithAllSchemas() Option {
+	return func(o *options) {
+		o.allSchemas = true
+	}
+}
+
+// schemaScope returns the schemas to fetch tables from. It's the default schema only if no schemas are given.
+func (o *options) schemaScope() schemaScope {
+	if o.allSchemas {
+		return schemaScope{all: true}
+	}
+	if len(o.schemas) == 0 {
+		return schemaScope{names: []string{""}}
+	}
+	return schemaScope{names: o.schemas}
+}
@@ -0,0 +1,100 @@
+//
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package truncate
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// isTablePattern returns true if the table name is a pattern, i.e. a glob like "tmp_*" or a regular expression
+// enclosed in slashes like "/^audit_.*/".
+func isTablePattern(name string) bool {
+	return isRegexpPattern(name) || strings.ContainsAny(name, "*?[")
+}
+
+func isRegexpPattern(name string) bool {
+	return len(name) >= 2 && strings.HasPrefix(name, "/") && strings.HasSuffix(name, "/")
+}
+
+// expandTablePatterns replaces patterns in the names with the names of the matching tables, keeping the other names
+// as is. Globs match whole table names, while regular expressions match any part of them unless anchored.
+// Both are matched against table names qualified with their schemas, e.g. "analytics.Events".
+// It also returns patterns which match no tables.
+func expandTablePatterns(names []string, tables []*tableSchema) (expanded, unmatched []string, err error) {
+	seen := make(map[string]bool, len(names))
+	add := func(name string) {
+		if !seen[name] {
+			seen[name] = true
+			expanded = append(expanded, name)
+		}
+	}
+	for _, name := range names {
+		if !isTablePattern(name) {
+			add(name)
+			continue
+		}
+		match, err := tablePatternMatcher(name)
+		if err != nil {
+			return nil, nil, err
+		}
+		var matched bool
+		for _, t := range tables {
+			if match(t.tableName) {
+				matched = true
+				add(t.tableName)
+			}
+		}
+		if !matched {
+			unmatched = append(unmatched, name)
+		}
+	}
+	return expanded, unmatched, nil
+}
+
+// tablePatternMatcher returns a function which reports whether a table name matches the pattern.
+func tablePatternMatcher(pattern string) (func(string) bool, error) {
+	if isRegexpPattern(pattern) {
+		re, err := regexp.Compile(pattern[1 : len(pattern)-1])
+		if err != nil {
+			return nil, fmt.Errorf("invalid table pattern %q: %v", pattern, err)
+		}
+		return re.MatchString, nil
+	}
+	if _, err := path.Match(pattern, ""); err != nil {
+		return nil, fmt.Errorf("invalid table pattern %q: %v", pattern, err)
+	}
+	return func(name string) bool {
+		ok, _ := path.Match(pattern, name)
+		return ok
+	}, nil
+}
+
+// expandTargetTables expands patterns in the target tables like expandTablePatterns. It fails if the patterns match
+// no tables and no other names are given, as empty target tables would mean all tables.
+func expandTargetTables(names []string, tables []*tableSchema) (expanded, unmatched []string, err error) {
+	expanded, unmatched, err = expandTablePatterns(names, tables)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(names) > 0 && len(expanded) == 0 {
+		return nil, nil, fmt.Errorf("no tables match the target tables: %s", strings.Join(unmatched, ", "))
+	}
+	return expanded, unmatched, nil
+}
@@ -0,0 +1,81 @@
+//
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package truncate
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestExpandTablePatterns(t *testing.T) {
+	tables := []*tableSchema{
+		{tableName: "Singers"},
+		{tableName: "audit_logs"},
+		{tableName: "audit_events"},
+		{tableName: "tmp_1"},
+		{tableName: "tmp_2"},
+		{tableName: "analytics.tmp_3"},
+	}
+
+	for _, tt := range []struct {
+		desc          string
+		names         []string
+		want          []string
+		wantUnmatched []string
+		wantErr       bool
+	}{
+		{desc: "names", names: []string{"Singers", "Unknown"}, want: []string{"Singers", "Unknown"}},
+		{desc: "glob", names: []string{"tmp_*"}, want: []string{"tmp_1", "tmp_2"}},
+		{desc: "qualified glob", names: []string{"analytics.tmp_?"}, want: []string{"analytics.tmp_3"}},
+		{desc: "regexp", names: []string{"/^audit_/"}, want: []string{"audit_logs", "audit_events"}},
+		{desc: "unanchored regexp", names: []string{"/tmp_[13]/"}, want: []string{"tmp_1", "analytics.tmp_3"}},
+		{desc: "duplicates", names: []string{"tmp_1", "tmp_*"}, want: []string{"tmp_1", "tmp_2"}},
+		{desc: "unmatched", names: []string{"Singers", "foo_*"}, want: []string{"Singers"}, wantUnmatched: []string{"foo_*"}},
+		{desc: "invalid glob", names: []string{"tmp_["}, wantErr: true},
+		{desc: "invalid regexp", names: []string{"/tmp_(/"}, wantErr: true},
+	} {
+		t.Run(tt.desc, func(t *testing.T) {
+			got, unmatched, err := expandTablePatterns(tt.names, tables)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("expandTablePatterns() error = %v, but wantErr %v", err, tt.wantErr)
+			}
+			if diff := cmp.Diff(got, tt.want); diff != "" {
+				t.Errorf("diff(+got, -want) = %v", diff)
+			}
+			if diff := cmp.Diff(unmatched, tt.wantUnmatched); diff != "" {
+				t.Errorf("unmatched: diff(+got, -want) = %v", diff)
+			}
+		})
+	}
+}
+
+func TestExpandTargetTables(t *testing.T) {
+	tables := []*tableSchema{{tableName: "Singers"}}
+
+	// Patterns matching nothing must not end up with empty target tables, which mean all tables.
+	if _, _, err := expandTargetTables([]string{"tmp_*"}, tables); err == nil {
+		t.Errorf("expandTargetTables() should fail if no tables match the patterns")
+	}
+	got, _, err := expandTargetTables(nil, tables)
+	if err != nil {
+		t.Fatalf("expandTargetTables() error = %v", err)
+	}
+	if len(got) != 0 {
+		t.Errorf("expandTargetTables(nil) = %v, but want empty", got)
+	}
+}
@@ -0,0 +1,102 @@
+//
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package truncate
+
+import (
+	"context"
+	"fmt"
+
+	adminapi "cloud.google.com/go/spanner/admin/database/apiv1"
+	"google.golang.org/api/option"
+	iampb "google.golang.org/genproto/googleapis/iam/v1"
+)
+
+const (
+	permissionSelect         = "spanner.databases.select"
+	permissionWrite          = "spanner.databases.write"
+	permissionPartitionedDML = "spanner.databases.beginPartitionedDmlTransaction"
+	permissionReadWrite      = "spanner.databases.beginOrRollbackReadWriteTransaction"
+)
+
+// requiredPermissions returns IAM permissions on the database required for the run to delete the tables.
+func requiredPermissions(opts *options, schemas []*tableSchema) []string {
+	permissions := []string{permissionSelect, permissionWrite, permissionPartitionedDML}
+	if len(opts.preHooks) > 0 || len(opts.postHooks) > 0 || mayDeleteLeavesFirst(opts, schemas) {
+		// Hooks and deletions leaf rows first are executed in read-write transactions.
+		permissions = append(permissions, permissionReadWrite)
+	}
+	if opts.backupRetention > 0 {
+		permissions = append(permissions, permissionCreateBackup)
+	}
+	if opts.rebuildIndexes {
+		permissions = append(permissions, permissionGetDDL, permissionUpdateDDL)
+	}
+	if opts.dropForeignKeys && !opts.rebuildIndexes {
+		permissions = append(permissions, permissionUpdateDDL)
+	}
+	return permissions
+}
+
+// checkPermissions asks Cloud Spanner which of the given permissions the caller holds on the database,
+// and returns the missing ones.
+// If adminClient is nil, a new admin client is created with clientOpts and closed on return.
+func checkPermissions(ctx context.Context, adminClient *adminapi.DatabaseAdminClient, database string, permissions []string, clientOpts ...option.ClientOption) ([]string, error) {
+	if adminClient == nil {
+		var err error
+		adminClient, err = adminapi.NewDatabaseAdminClient(ctx, clientOpts...)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create Cloud Spanner admin client: %v", err)
+		}
+		defer adminClient.Close()
+	}
+
+	resp, err := adminClient.TestIamPermissions(ctx, &iampb.TestIamPermissionsRequest{
+		Resource:    database,
+		Permissions: permissions,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return findMissingPermissions(permissions, resp.GetPermissions()), nil
+}
+
+// findMissingPermissions returns required permissions which are not included in granted permissions.
+func findMissingPermissions(required, granted []string) []string {
+	grantedSet := make(map[string]bool, len(granted))
+	for _, p := range granted {
+		grantedSet[p] = true
+	}
+
+	var missing []string
+	for _, p := range required {
+		if !grantedSet[p] {
+			missing = append(missing, p)
+		}
+	}
+	return missing
+}
+
+// mayDeleteLeavesFirst returns true if any of the tables may be deleted leaf rows first, because it references itself,
+// or it's referenced by foreign keys and cycles are broken.
+func mayDeleteLeavesFirst(opts *options, schemas []*tableSchema) bool {
+	for _, schema := range schemas {
+		if len(schema.selfReferences()) > 0 || (opts.breakCycles && len(schema.referencedBy) > 0) {
+			return true
+		}
+	}
+	return false
+}
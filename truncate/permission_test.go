@@ -0,0 +1,123 @@
+//
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package truncate
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestRequiredPermissions(t *testing.T) {
+	employees := &tableSchema{
+		tableName:    "Employees",
+		foreignKeys:  []*foreignKey{{constraintName: "FK_Manager", referencingTable: "Employees", referencedTable: "Employees", onDelete: deleteActionNoAction}},
+		referencedBy: []string{"Employees"},
+	}
+	singers := &tableSchema{tableName: "Singers", referencedBy: []string{"Albums"}}
+	for _, test := range []struct {
+		desc    string
+		opts    []Option
+		schemas []*tableSchema
+		want    []string
+	}{
+		{
+			desc: "default",
+			want: []string{permissionSelect, permissionWrite, permissionPartitionedDML},
+		},
+		{
+			desc: "with hooks",
+			opts: []Option{WithHooks("Singers", nil, []string{"INSERT INTO Singers (SingerId) VALUES (1)"})},
+			want: []string{permissionSelect, permissionWrite, permissionPartitionedDML, permissionReadWrite},
+		},
+		{
+			desc: "with backup",
+			opts: []Option{WithBackup(24 * time.Hour)},
+			want: []string{permissionSelect, permissionWrite, permissionPartitionedDML, permissionCreateBackup},
+		},
+		{
+			desc:    "with a self-referencing table",
+			schemas: []*tableSchema{employees},
+			want:    []string{permissionSelect, permissionWrite, permissionPartitionedDML, permissionReadWrite},
+		},
+		{
+			desc:    "with foreign keys",
+			schemas: []*tableSchema{singers},
+			want:    []string{permissionSelect, permissionWrite, permissionPartitionedDML},
+		},
+		{
+			desc:    "with foreign keys and cycles broken",
+			opts:    []Option{WithBreakCycles()},
+			schemas: []*tableSchema{singers},
+			want:    []string{permissionSelect, permissionWrite, permissionPartitionedDML, permissionReadWrite},
+		},
+		{
+			desc: "with indexes rebuilt",
+			opts: []Option{WithIndexRebuild()},
+			want: []string{permissionSelect, permissionWrite, permissionPartitionedDML, permissionGetDDL, permissionUpdateDDL},
+		},
+		{
+			desc: "with foreign keys dropped",
+			opts: []Option{WithForeignKeyDrop()},
+			want: []string{permissionSelect, permissionWrite, permissionPartitionedDML, permissionUpdateDDL},
+		},
+		{
+			desc: "with indexes rebuilt and foreign keys dropped",
+			opts: []Option{WithIndexRebuild(), WithForeignKeyDrop()},
+			want: []string{permissionSelect, permissionWrite, permissionPartitionedDML, permissionGetDDL, permissionUpdateDDL},
+		},
+	} {
+		t.Run(test.desc, func(t *testing.T) {
+			got := requiredPermissions(newOptions(test.opts), test.schemas)
+			if !cmp.Equal(got, test.want) {
+				t.Errorf("diff(+got, -want) = %v", cmp.Diff(got, test.want))
+			}
+		})
+	}
+}
+
+func TestFindMissingPermissions(t *testing.T) {
+	for _, test := range []struct {
+		desc    string
+		granted []string
+		want    []string
+	}{
+		{
+			desc:    "all granted",
+			granted: []string{permissionSelect, permissionWrite, permissionPartitionedDML},
+			want:    nil,
+		},
+		{
+			desc:    "partially granted",
+			granted: []string{permissionSelect},
+			want:    []string{permissionWrite, permissionPartitionedDML},
+		},
+		{
+			desc:    "nothing granted",
+			granted: nil,
+			want:    []string{permissionSelect, permissionWrite, permissionPartitionedDML},
+		},
+	} {
+		t.Run(test.desc, func(t *testing.T) {
+			got := findMissingPermissions([]string{permissionSelect, permissionWrite, permissionPartitionedDML}, test.granted)
+			if !cmp.Equal(got, test.want) {
+				t.Errorf("diff(+got, -want) = %v", cmp.Diff(got, test.want))
+			}
+		})
+	}
+}
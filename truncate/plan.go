@@ -0,0 +1,367 @@
+//
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package truncate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+
+	"cloud.google.com/go/spanner"
+)
+
+// maxConcurrentCounts is the maximum number of COUNT(*) queries issued at the same time before deletion.
+const maxConcurrentCounts = 10
+
+// fetchRowCounts counts rows of the tables matching their filters concurrently.
+// Tables which failed to be counted are not included in the result and reported as warnings.
+func fetchRowCounts(ctx context.Context, client *spanner.Client, schemas []*tableSchema, filters map[string]*rowFilter, reqOpts requestOptions, warnings *warnings) map[string]int64 {
+	var (
+		mu     sync.Mutex
+		wg     sync.WaitGroup
+		counts = make(map[string]int64, len(schemas))
+		sem    = make(chan struct{}, maxConcurrentCounts)
+	)
+	for _, schema := range schemas {
+		tableName := schema.tableName
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+
+			count, err := countRows(ctx, client, tableName, filters[tableName], reqOpts)
+			if err != nil {
+				warnings.add(tableName, "failed to count rows: %v", errors.Unwrap(err))
+				return
+			}
+			mu.Lock()
+			counts[tableName] = count
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+	return counts
+}
+
+// deletionPlan describes how rows of each table are going to be deleted.
+type deletionPlan struct {
+	// Sets of tables whose own DELETE statements are issued at the same time, in order.
+	waves [][]string
+
+	// Table name which deletes rows of the table in cascade, keyed by the table name.
+	// Tables which receive their own DELETE statements are not included.
+	cascadeFrom map[string]string
+}
+
+// isDirect returns true if the table receives its own DELETE statement.
+func (p *deletionPlan) isDirect(tableName string) bool {
+	_, ok := p.cascadeFrom[tableName]
+	return !ok
+}
+
+// planDeletion simulates the coordinator to find out the order of deletion and how each table is deleted,
+// assuming every deletion is completed before the next wave.
+func planDeletion(schemas []*tableSchema, indexes []*indexSchema, opts *options) (*deletionPlan, error) {
+	c, err := newCoordinator(schemas, indexes, nil, nil, nil, opts)
+	if err != nil {
+		return nil, err
+	}
+	plan, stuck := simulateDeletion(c.tables)
+	if len(stuck) > 0 {
+		return nil, errors.New("no deletable tables found, probably there is circular dependencies between tables")
+	}
+	return plan, nil
+}
+
+// simulateDeletion marks the tables deleted wave by wave, and returns the plan as far as the tables can be deleted.
+// Tables which can never be deleted, e.g. because of circular dependencies, are returned as stuck.
+func simulateDeletion(allTables []*table) (plan *deletionPlan, stuck []*table) {
+	plan = &deletionPlan{cascadeFrom: map[string]string{}}
+	for !isAllTablesDeleted(allTables) {
+		tables := findDeletableTables(allTables)
+		if len(tables) == 0 {
+			for _, table := range flattenTables(allTables) {
+				if table.deleter.status != statusCompleted {
+					stuck = append(stuck, table)
+				}
+			}
+			return plan, stuck
+		}
+
+		for _, table := range tables {
+			table.deleter.status = statusCompleted
+			for _, cascaded := range findCascadeTargets(table.childTables) {
+				cascaded.deleter.status = statusCompleted
+				plan.cascadeFrom[cascaded.tableName] = table.tableName
+			}
+		}
+		plan.waves = append(plan.waves, extractTableNames(tables))
+	}
+	return plan, nil
+}
+
+// printTables prints the target tables as a tree of interleave hierarchy,
+// with their row counts, approximate sizes, how they are deleted, and related objects such as indexes and change streams
+// which are the main drivers of deletion cost and side effects.
+// If hideEmpty is true, tables which are empty together with all their descendants are omitted.
+func printTables(out io.Writer, schemas []*tableSchema, indexes []*indexSchema, changeStreams []*changeStreamSchema, rowCounts, tableBytes map[string]int64, plan *deletionPlan, hideEmpty bool) {
+	exists := make(map[string]bool, len(schemas))
+	for _, schema := range schemas {
+		exists[schema.tableName] = true
+	}
+	var roots []*tableSchema
+	children := map[string][]*tableSchema{}
+	for _, schema := range schemas {
+		if exists[schema.parentTableName] {
+			children[schema.parentTableName] = append(children[schema.parentTableName], schema)
+		} else {
+			roots = append(roots, schema)
+		}
+	}
+	tableIndexes := map[string][]*indexSchema{}
+	for _, index := range indexes {
+		tableIndexes[index.baseTableName] = append(tableIndexes[index.baseTableName], index)
+	}
+
+	var isEmpty func(schema *tableSchema) bool
+	isEmpty = func(schema *tableSchema) bool {
+		if count, ok := rowCounts[schema.tableName]; !ok || count != 0 {
+			return false
+		}
+		for _, child := range children[schema.tableName] {
+			if !isEmpty(child) {
+				return false
+			}
+		}
+		return true
+	}
+
+	type line struct {
+		label, count, size, method, notes string
+	}
+	var lines []line
+	var hidden int
+	var walk func(schema *tableSchema, depth int)
+	walk = func(schema *tableSchema, depth int) {
+		if hideEmpty && isEmpty(schema) {
+			hidden += len(flattenTableSchemas(schema, children))
+			return
+		}
+
+		label := strings.Repeat("  ", depth) + schema.tableName
+		if !schema.isRoot() {
+			if depth == 0 {
+				label += fmt.Sprintf(" (interleaved in %s, %s)", schema.parentTableName, formatDeleteAction(schema.parentOnDeleteAction))
+			} else {
+				label += fmt.Sprintf(" (%s)", formatDeleteAction(schema.parentOnDeleteAction))
+			}
+		}
+
+		method := "DELETE"
+		if parent, ok := plan.cascadeFrom[schema.tableName]; ok {
+			method = "cascade from " + parent
+		}
+
+		var notes []string
+		if len(schema.referencedBy) > 0 {
+			referencing := make([]string, len(schema.referencedBy))
+			for i, name := range schema.referencedBy {
+				referencing[i] = name
+				if schema.isReferencedInCascade(name) {
+					referencing[i] += " (ON DELETE CASCADE)"
+				}
+			}
+			notes = append(notes, "referenced by: "+strings.Join(referencing, ", "))
+		}
+		if len(schema.selfReferences()) > 0 {
+			notes = append(notes, "references itself")
+		}
+		if schema.rowDeletionPolicy != "" {
+			notes = append(notes, "row deletion policy: "+schema.rowDeletionPolicy)
+		}
+		if idxs := tableIndexes[schema.tableName]; len(idxs) > 0 {
+			descs := make([]string, len(idxs))
+			for i, idx := range idxs {
+				descs[i] = fmt.Sprintf("%s (%s)", idx.indexName, formatIndexType(idx))
+			}
+			notes = append(notes, "indexes: "+strings.Join(descs, ", "))
+		}
+		var watchers []string
+		for _, cs := range changeStreams {
+			if cs.watches(schema.tableName) {
+				if cs.all {
+					watchers = append(watchers, cs.changeStreamName+" (all tables)")
+				} else {
+					watchers = append(watchers, cs.changeStreamName)
+				}
+			}
+		}
+		if len(watchers) > 0 {
+			notes = append(notes, "change streams: "+strings.Join(watchers, ", "))
+		}
+
+		lines = append(lines, line{
+			label:  label,
+			count:  formatRowCount(rowCounts, schema.tableName),
+			size:   formatTableBytes(tableBytes, schema.tableName),
+			method: method,
+			notes:  strings.Join(notes, "; "),
+		})
+		for _, child := range children[schema.tableName] {
+			walk(child, depth+1)
+		}
+	}
+	for _, root := range roots {
+		walk(root, 0)
+	}
+
+	var labelWidth, countWidth, sizeWidth, methodWidth int
+	for _, l := range lines {
+		if n := len(l.size); n > sizeWidth {
+			sizeWidth = n
+		}
+		if n := len(l.label); n > labelWidth {
+			labelWidth = n
+		}
+		if n := len(l.count); n > countWidth {
+			countWidth = n
+		}
+		if n := len(l.method); n > methodWidth {
+			methodWidth = n
+		}
+	}
+	for _, l := range lines {
+		s := fmt.Sprintf("%-*s  %-*s  ", labelWidth, l.label, countWidth, l.count)
+		// Omit the size column entirely if no sizes are known.
+		if sizeWidth > 0 {
+			s += fmt.Sprintf("%-*s  ", sizeWidth, l.size)
+		}
+		s += fmt.Sprintf("%-*s  %s", methodWidth, l.method, l.notes)
+		fmt.Fprintln(out, strings.TrimRight(s, " "))
+	}
+	if hidden > 0 {
+		fmt.Fprintf(out, "(%d empty tables are omitted, but they are still verified)\n", hidden)
+	}
+}
+
+// flattenTableSchemas returns the table and all its descendants.
+func flattenTableSchemas(schema *tableSchema, children map[string][]*tableSchema) []*tableSchema {
+	flatten := []*tableSchema{schema}
+	for _, child := range children[schema.tableName] {
+		flatten = append(flatten, flattenTableSchemas(child, children)...)
+	}
+	return flatten
+}
+
+func formatDeleteAction(action deleteActionType) string {
+	switch action {
+	case deleteActionCascadeDelete:
+		return "ON DELETE CASCADE"
+	case deleteActionNoAction:
+		return "ON DELETE NO ACTION"
+	default:
+		return "ON DELETE unknown"
+	}
+}
+
+func formatIndexType(index *indexSchema) string {
+	var typ string
+	if index.isSearch {
+		typ = "search, "
+	}
+	if index.isGlobal() {
+		return typ + "global"
+	}
+	return typ + "interleaved in " + index.parentTableName
+}
+
+// formatRowCount formats the row count of the table. It returns "unknown rows" if the table hasn't been counted.
+func formatRowCount(rowCounts map[string]int64, tableName string) string {
+	count, ok := rowCounts[tableName]
+	if !ok {
+		return "unknown rows"
+	}
+	if count == 1 {
+		return "1 row"
+	}
+	return formatNumber(uint64(count)) + " rows"
+}
+
+// formatTotals formats the total rows and approximate size of the tables, along with the largest table,
+// so that users can tell whether they are about to delete much more than expected.
+func formatTotals(schemas []*tableSchema, rowCounts, tableBytes map[string]int64) string {
+	var rows, bytes int64
+	var uncounted, sized int
+	for _, schema := range schemas {
+		if count, ok := rowCounts[schema.tableName]; ok {
+			rows += count
+		} else {
+			uncounted++
+		}
+		if b, ok := tableBytes[schema.tableName]; ok {
+			bytes += b
+			sized++
+		}
+	}
+	// The largest table is decided by bytes if known, as bytes reflect wide rows and indexes, or by rows otherwise.
+	sizes := rowCounts
+	if sized > 0 {
+		sizes = tableBytes
+	}
+	var largest string
+	for _, schema := range schemas {
+		if largest == "" || sizes[schema.tableName] > sizes[largest] {
+			largest = schema.tableName
+		}
+	}
+
+	var total string
+	switch {
+	case uncounted == len(schemas):
+		total = "unknown rows"
+	case uncounted > 0:
+		total = "at least " + formatNumber(uint64(rows)) + " rows"
+	default:
+		total = formatNumber(uint64(rows)) + " rows"
+	}
+	if sized > 0 {
+		total += " (~" + formatBytes(bytes) + ")"
+	}
+	s := fmt.Sprintf("In total, %s in %d tables", total, len(schemas))
+	if len(schemas) > 1 {
+		s += fmt.Sprintf(", the largest of which is %s with %s", largest, formatRowCount(rowCounts, largest))
+		if size := formatTableBytes(tableBytes, largest); size != "" {
+			s += " (" + size + ")"
+		}
+	}
+	return s + "."
+}
+
+// formatTableBytes formats the approximate size of the table. It returns a blank string if the size is unknown.
+func formatTableBytes(tableBytes map[string]int64, tableName string) string {
+	bytes, ok := tableBytes[tableName]
+	if !ok {
+		return ""
+	}
+	return "~" + formatBytes(bytes)
+}
@@ -0,0 +1,185 @@
+//
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package truncate
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Plan is a serialized plan of deletion, which can be reviewed and then executed exactly with WithPlan.
+type Plan struct {
+	Database    string       `json:"database"`
+	GeneratedAt time.Time    `json:"generated_at"`
+	Tables      []*PlanTable `json:"tables"`
+
+	// Sets of tables whose own DELETE statements are issued at the same time, in order.
+	Waves [][]string `json:"waves"`
+}
+
+// PlanTable is a target table in Plan, with the relationships which determine how it's deleted.
+type PlanTable struct {
+	Name                 string   `json:"name"`
+	ParentTableName      string   `json:"parent_table_name,omitempty"`
+	ParentOnDeleteAction string   `json:"parent_on_delete_action,omitempty"`
+	ReferencedBy         []string `json:"referenced_by,omitempty"`
+
+	// Table which deletes rows of this table in cascade. Blank if the table receives its own DELETE statement.
+	CascadeFrom string `json:"cascade_from,omitempty"`
+
+	// Number of rows when the plan was generated, for reviewers. It's not compared when the plan is applied.
+	RowCount *int64 `json:"row_count,omitempty"`
+}
+
+// TableNames returns the names of the target tables in the plan.
+func (p *Plan) TableNames() []string {
+	names := make([]string, len(p.Tables))
+	for i, t := range p.Tables {
+		names[i] = t.Name
+	}
+	return names
+}
+
+// WithPlanHandler registers a handler which receives the plan of deletion after the target tables are listed,
+// both in dry runs and before rows are deleted.
+func WithPlanHandler(handler func(*Plan)) Option {
+	return func(o *options) {
+		o.planHandler = handler
+	}
+}
+
+// WithPlan makes the run execute exactly the plan generated by a previous run, typically a dry run with WithPlanHandler.
+// The run fails without deleting anything if the live schema, the target tables or the order of deletion differ from the plan.
+// Pass Plan.TableNames as the target tables so that the same tables are targeted.
+func WithPlan(plan *Plan) Option {
+	return func(o *options) {
+		o.approvedPlan = plan
+	}
+}
+
+func newPlan(database string, schemas []*tableSchema, rowCounts map[string]int64, deletion *deletionPlan) *Plan {
+	plan := &Plan{
+		Database:    database,
+		GeneratedAt: time.Now(),
+		Waves:       deletion.waves,
+	}
+	for _, schema := range schemas {
+		exported := schema.export()
+		t := &PlanTable{
+			Name:                 exported.Name,
+			ParentTableName:      exported.ParentTableName,
+			ParentOnDeleteAction: exported.ParentOnDeleteAction,
+			ReferencedBy:         exported.ReferencedBy,
+			CascadeFrom:          deletion.cascadeFrom[schema.tableName],
+		}
+		if count, ok := rowCounts[schema.tableName]; ok {
+			t.RowCount = &count
+		}
+		plan.Tables = append(plan.Tables, t)
+	}
+	return plan
+}
+
+// diffPlans returns differences of the live plan from the approved plan as human-readable lines.
+// Row counts and generation times are ignored.
+func diffPlans(approved, live *Plan) []string {
+	var diffs []string
+	if approved.Database != live.Database {
+		diffs = append(diffs, fmt.Sprintf("database: %s in the plan, but %s", approved.Database, live.Database))
+	}
+
+	approvedTables := make(map[string]*PlanTable, len(approved.Tables))
+	for _, t := range approved.Tables {
+		approvedTables[t.Name] = t
+	}
+	liveTables := make(map[string]*PlanTable, len(live.Tables))
+	for _, t := range live.Tables {
+		liveTables[t.Name] = t
+	}
+	for _, name := range sortedPlanTableNames(approvedTables) {
+		if _, ok := liveTables[name]; !ok {
+			diffs = append(diffs, fmt.Sprintf("table %s: in the plan, but not a target anymore", name))
+		}
+	}
+	for _, name := range sortedPlanTableNames(liveTables) {
+		a, ok := approvedTables[name]
+		if !ok {
+			diffs = append(diffs, fmt.Sprintf("table %s: not in the plan", name))
+			continue
+		}
+		if d := diffPlanTables(a, liveTables[name]); d != "" {
+			diffs = append(diffs, fmt.Sprintf("table %s: %s", name, d))
+		}
+	}
+
+	if !reflect.DeepEqual(normalizeWaves(approved.Waves), normalizeWaves(live.Waves)) {
+		diffs = append(diffs, fmt.Sprintf("order of deletion: %s in the plan, but %s", formatWaves(approved.Waves), formatWaves(live.Waves)))
+	}
+	return diffs
+}
+
+// diffPlanTables returns how the relationships of the table differ, or blank if they don't.
+func diffPlanTables(approved, live *PlanTable) string {
+	var diffs []string
+	if approved.ParentTableName != live.ParentTableName || approved.ParentOnDeleteAction != live.ParentOnDeleteAction {
+		diffs = append(diffs, fmt.Sprintf("parent %q ON DELETE %q in the plan, but %q ON DELETE %q",
+			approved.ParentTableName, approved.ParentOnDeleteAction, live.ParentTableName, live.ParentOnDeleteAction))
+	}
+	if !reflect.DeepEqual(sortedStrings(approved.ReferencedBy), sortedStrings(live.ReferencedBy)) {
+		diffs = append(diffs, fmt.Sprintf("referenced by [%s] in the plan, but [%s]", strings.Join(approved.ReferencedBy, ", "), strings.Join(live.ReferencedBy, ", ")))
+	}
+	if approved.CascadeFrom != live.CascadeFrom {
+		diffs = append(diffs, fmt.Sprintf("deleted in cascade from %q in the plan, but %q", approved.CascadeFrom, live.CascadeFrom))
+	}
+	return strings.Join(diffs, "; ")
+}
+
+func sortedPlanTableNames(tables map[string]*PlanTable) []string {
+	names := make([]string, 0, len(tables))
+	for name := range tables {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// sortedStrings returns a sorted copy of the strings, which is never nil so that empty slices are compared equal.
+func sortedStrings(s []string) []string {
+	sorted := append([]string{}, s...)
+	sort.Strings(sorted)
+	return sorted
+}
+
+// normalizeWaves sorts tables in each wave, as the order within a wave doesn't matter.
+func normalizeWaves(waves [][]string) [][]string {
+	normalized := make([][]string, len(waves))
+	for i, wave := range waves {
+		normalized[i] = sortedStrings(wave)
+	}
+	return normalized
+}
+
+func formatWaves(waves [][]string) string {
+	formatted := make([]string, len(waves))
+	for i, wave := range waves {
+		formatted[i] = "[" + strings.Join(wave, ", ") + "]"
+	}
+	return strings.Join(formatted, " -> ")
+}
@@ -0,0 +1,112 @@
+//
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package truncate
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestNewPlan(t *testing.T) {
+	schemas := []*tableSchema{
+		{tableName: "Singers", referencedBy: []string{"Concerts"}},
+		{tableName: "Albums", parentTableName: "Singers", parentOnDeleteAction: deleteActionCascadeDelete},
+		{tableName: "Concerts"},
+	}
+	deletion := &deletionPlan{
+		waves:       [][]string{{"Concerts"}, {"Singers"}},
+		cascadeFrom: map[string]string{"Albums": "Singers"},
+	}
+	count := int64(10)
+
+	got := newPlan("projects/p/instances/i/databases/d", schemas, map[string]int64{"Singers": count}, deletion)
+	want := &Plan{
+		Database: "projects/p/instances/i/databases/d",
+		Tables: []*PlanTable{
+			{Name: "Singers", ReferencedBy: []string{"Concerts"}, RowCount: &count},
+			{Name: "Albums", ParentTableName: "Singers", ParentOnDeleteAction: "CASCADE", CascadeFrom: "Singers"},
+			{Name: "Concerts"},
+		},
+		Waves: [][]string{{"Concerts"}, {"Singers"}},
+	}
+	if diff := cmp.Diff(got, want, cmp.FilterPath(func(p cmp.Path) bool { return p.String() == "GeneratedAt" }, cmp.Ignore())); diff != "" {
+		t.Errorf("diff(+got, -want) = %v", diff)
+	}
+	if diff := cmp.Diff(got.TableNames(), []string{"Singers", "Albums", "Concerts"}); diff != "" {
+		t.Errorf("TableNames(): diff(+got, -want) = %v", diff)
+	}
+}
+
+func TestDiffPlans(t *testing.T) {
+	approved := &Plan{
+		Database: "db",
+		Tables: []*PlanTable{
+			{Name: "Singers", ReferencedBy: []string{"Concerts"}},
+			{Name: "Albums", ParentTableName: "Singers", ParentOnDeleteAction: "CASCADE", CascadeFrom: "Singers"},
+			{Name: "Concerts"},
+		},
+		Waves: [][]string{{"Concerts"}, {"Singers"}},
+	}
+
+	for _, tt := range []struct {
+		desc string
+		live *Plan
+		want []string
+	}{
+		{
+			desc: "same except for row counts and order in waves",
+			live: &Plan{
+				Database: "db",
+				Tables: []*PlanTable{
+					{Name: "Concerts", RowCount: new(int64)},
+					{Name: "Singers", ReferencedBy: []string{"Concerts"}},
+					{Name: "Albums", ParentTableName: "Singers", ParentOnDeleteAction: "CASCADE", CascadeFrom: "Singers"},
+				},
+				Waves: [][]string{{"Concerts"}, {"Singers"}},
+			},
+			want: nil,
+		},
+		{
+			desc: "drifted",
+			live: &Plan{
+				Database: "other",
+				Tables: []*PlanTable{
+					{Name: "Singers"},
+					{Name: "Albums", ParentTableName: "Singers", ParentOnDeleteAction: "NO ACTION"},
+					{Name: "Tickets"},
+				},
+				Waves: [][]string{{"Albums", "Tickets"}, {"Singers"}},
+			},
+			want: []string{
+				"database: db in the plan, but other",
+				"table Concerts: in the plan, but not a target anymore",
+				`table Albums: parent "Singers" ON DELETE "CASCADE" in the plan, but "Singers" ON DELETE "NO ACTION"; deleted in cascade from "Singers" in the plan, but ""`,
+				"table Singers: referenced by [Concerts] in the plan, but []",
+				"table Tickets: not in the plan",
+				"order of deletion: [Concerts] -> [Singers] in the plan, but [Albums, Tickets] -> [Singers]",
+			},
+		},
+	} {
+		t.Run(tt.desc, func(t *testing.T) {
+			got := diffPlans(approved, tt.live)
+			if diff := cmp.Diff(got, tt.want); diff != "" {
+				t.Errorf("diff(+got, -want) = %v", diff)
+			}
+		})
+	}
+}
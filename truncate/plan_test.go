@@ -0,0 +1,242 @@
+//
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package truncate
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestPrintTables(t *testing.T) {
+	schemas := []*tableSchema{
+		{tableName: "Albums", parentTableName: "Singers", parentOnDeleteAction: deleteActionCascadeDelete},
+		{tableName: "Concerts"},
+		{tableName: "Singers", referencedBy: []string{"Concerts"}},
+		{tableName: "Songs", parentTableName: "Albums", parentOnDeleteAction: deleteActionNoAction},
+		{tableName: "Tracks", parentTableName: "Records", parentOnDeleteAction: deleteActionCascadeDelete},
+	}
+	indexes := []*indexSchema{
+		{indexName: "SingersByName", baseTableName: "Singers"},
+		{indexName: "SongsByTitle", baseTableName: "Songs", parentTableName: "Albums", isSearch: true},
+	}
+	changeStreams := []*changeStreamSchema{
+		{changeStreamName: "EverythingStream", all: true},
+		{changeStreamName: "SingersStream", tableNames: []string{"Singers"}},
+	}
+	rowCounts := map[string]int64{
+		"Singers":  1200,
+		"Albums":   1,
+		"Concerts": 0,
+		"Songs":    10,
+	}
+	tableBytes := map[string]int64{
+		"Singers": 3 * 1024 * 1024 * 1024,
+		"Albums":  512,
+	}
+	plan := &deletionPlan{cascadeFrom: map[string]string{"Albums": "Singers"}}
+
+	var buf bytes.Buffer
+	printTables(&buf, schemas, indexes, changeStreams, rowCounts, tableBytes, plan, false)
+
+	want := `Concerts                                            0 rows                  DELETE                change streams: EverythingStream (all tables)
+Singers                                             1,200 rows    ~3.0 GiB  DELETE                referenced by: Concerts; indexes: SingersByName (global); change streams: EverythingStream (all tables), SingersStream
+  Albums (ON DELETE CASCADE)                        1 row         ~512 B    cascade from Singers  change streams: EverythingStream (all tables)
+    Songs (ON DELETE NO ACTION)                     10 rows                 DELETE                indexes: SongsByTitle (search, interleaved in Albums); change streams: EverythingStream (all tables)
+Tracks (interleaved in Records, ON DELETE CASCADE)  unknown rows            DELETE                change streams: EverythingStream (all tables)
+`
+	if got := buf.String(); got != want {
+		t.Errorf("printTables() mismatch (-got +want):\n%s", cmp.Diff(got, want))
+	}
+}
+
+func TestPrintTablesHideEmpty(t *testing.T) {
+	schemas := []*tableSchema{
+		{tableName: "Albums", parentTableName: "Singers", parentOnDeleteAction: deleteActionCascadeDelete},
+		{tableName: "Concerts"},
+		{tableName: "Singers"},
+		{tableName: "Songs", parentTableName: "Albums", parentOnDeleteAction: deleteActionCascadeDelete},
+		{tableName: "Venues"},
+	}
+	rowCounts := map[string]int64{
+		"Albums":   0,
+		"Concerts": 0,
+		"Singers":  0,
+		"Songs":    5,
+	}
+	plan := &deletionPlan{cascadeFrom: map[string]string{}}
+
+	var buf bytes.Buffer
+	printTables(&buf, schemas, nil, nil, rowCounts, nil, plan, true)
+
+	// Singers and Albums are empty, but they are printed because Songs is not empty.
+	want := `Singers                        0 rows        DELETE
+  Albums (ON DELETE CASCADE)   0 rows        DELETE
+    Songs (ON DELETE CASCADE)  5 rows        DELETE
+Venues                         unknown rows  DELETE
+(1 empty tables are omitted, but they are still verified)
+`
+	if got := buf.String(); got != want {
+		t.Errorf("printTables() mismatch (-got +want):\n%s", cmp.Diff(got, want))
+	}
+}
+
+func TestFormatTotals(t *testing.T) {
+	schemas := []*tableSchema{{tableName: "Singers"}, {tableName: "Albums"}, {tableName: "Events"}}
+	for _, tt := range []struct {
+		desc       string
+		schemas    []*tableSchema
+		rowCounts  map[string]int64
+		tableBytes map[string]int64
+		want       string
+	}{
+		{
+			desc:       "counted and sized",
+			schemas:    schemas,
+			rowCounts:  map[string]int64{"Singers": 1000, "Albums": 5000, "Events": 2000},
+			tableBytes: map[string]int64{"Singers": 1 << 20, "Albums": 2 << 20, "Events": 8 << 20},
+			want:       "In total, 8,000 rows (~11.0 MiB) in 3 tables, the largest of which is Events with 2,000 rows (~8.0 MiB).",
+		},
+		{
+			desc:      "counted without sizes",
+			schemas:   schemas,
+			rowCounts: map[string]int64{"Singers": 1000, "Albums": 5000, "Events": 2000},
+			want:      "In total, 8,000 rows in 3 tables, the largest of which is Albums with 5,000 rows.",
+		},
+		{
+			desc:      "partially counted",
+			schemas:   schemas,
+			rowCounts: map[string]int64{"Singers": 1000},
+			want:      "In total, at least 1,000 rows in 3 tables, the largest of which is Singers with 1,000 rows.",
+		},
+		{
+			desc:    "single uncounted table",
+			schemas: schemas[:1],
+			want:    "In total, unknown rows in 1 tables.",
+		},
+	} {
+		t.Run(tt.desc, func(t *testing.T) {
+			if got := formatTotals(tt.schemas, tt.rowCounts, tt.tableBytes); got != tt.want {
+				t.Errorf("formatTotals() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPlanDeletion(t *testing.T) {
+	for _, tt := range []struct {
+		desc            string
+		schemas         []*tableSchema
+		indexes         []*indexSchema
+		wantWaves       [][]string
+		wantCascadeFrom map[string]string
+		wantErr         bool
+	}{
+		{
+			desc: "Cascade delete",
+			schemas: []*tableSchema{
+				{tableName: "Singers"},
+				{tableName: "Albums", parentTableName: "Singers", parentOnDeleteAction: deleteActionCascadeDelete},
+				{tableName: "Songs", parentTableName: "Albums", parentOnDeleteAction: deleteActionCascadeDelete},
+			},
+			wantWaves:       [][]string{{"Singers"}},
+			wantCascadeFrom: map[string]string{"Albums": "Singers", "Songs": "Singers"},
+		},
+		{
+			desc: "No action child is deleted first",
+			schemas: []*tableSchema{
+				{tableName: "Singers"},
+				{tableName: "Albums", parentTableName: "Singers", parentOnDeleteAction: deleteActionNoAction},
+			},
+			wantWaves:       [][]string{{"Albums"}, {"Singers"}},
+			wantCascadeFrom: map[string]string{},
+		},
+		{
+			desc: "Child with global index is deleted first",
+			schemas: []*tableSchema{
+				{tableName: "Singers"},
+				{tableName: "Albums", parentTableName: "Singers", parentOnDeleteAction: deleteActionCascadeDelete},
+			},
+			indexes:         []*indexSchema{{indexName: "AlbumsByTitle", baseTableName: "Albums"}},
+			wantWaves:       [][]string{{"Albums"}, {"Singers"}},
+			wantCascadeFrom: map[string]string{},
+		},
+		{
+			desc: "Children of a blocked parent are deleted directly",
+			schemas: []*tableSchema{
+				{tableName: "Singers", referencedBy: []string{"Concerts"}},
+				{tableName: "Albums", parentTableName: "Singers", parentOnDeleteAction: deleteActionCascadeDelete},
+				{tableName: "Concerts"},
+			},
+			wantWaves:       [][]string{{"Albums", "Concerts"}, {"Singers"}},
+			wantCascadeFrom: map[string]string{},
+		},
+		{
+			desc: "Tables referencing by foreign keys with ON DELETE CASCADE are deleted at the same time",
+			schemas: []*tableSchema{
+				{
+					tableName:    "Singers",
+					foreignKeys:  []*foreignKey{{constraintName: "FK_Concerts", referencingTable: "Concerts", referencedTable: "Singers", onDelete: deleteActionCascadeDelete}},
+					referencedBy: []string{"Concerts"},
+				},
+				{tableName: "Concerts"},
+			},
+			wantWaves:       [][]string{{"Singers", "Concerts"}},
+			wantCascadeFrom: map[string]string{},
+		},
+		{
+			desc: "Self-referencing table isn't stuck",
+			schemas: []*tableSchema{
+				{
+					tableName:    "Employees",
+					foreignKeys:  []*foreignKey{{constraintName: "FK_Manager", referencingTable: "Employees", referencedTable: "Employees", onDelete: deleteActionNoAction}},
+					referencedBy: []string{"Employees"},
+				},
+			},
+			wantWaves:       [][]string{{"Employees"}},
+			wantCascadeFrom: map[string]string{},
+		},
+		{
+			desc: "Circular dependencies",
+			schemas: []*tableSchema{
+				{tableName: "A", referencedBy: []string{"B"}},
+				{tableName: "B", referencedBy: []string{"A"}},
+			},
+			wantErr: true,
+		},
+	} {
+		t.Run(tt.desc, func(t *testing.T) {
+			got, err := planDeletion(tt.schemas, tt.indexes, newOptions(nil))
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("test wants error, but no error returned")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("planDeletion() returned error: %v", err)
+			}
+			if diff := cmp.Diff(got.waves, tt.wantWaves); diff != "" {
+				t.Errorf("waves mismatch (-got +want):\n%s", diff)
+			}
+			if diff := cmp.Diff(got.cascadeFrom, tt.wantCascadeFrom); diff != "" {
+				t.Errorf("cascadeFrom mismatch (-got +want):\n%s", diff)
+			}
+		})
+	}
+}
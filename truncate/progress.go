@@ -0,0 +1,208 @@
+//
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package truncate
+
+import (
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+const (
+	// progressLinesInterval is the interval to check the progress in lines mode.
+	progressLinesInterval = 5 * time.Second
+	// progressLinesHeartbeat is the interval to print the overall progress in lines mode when no table has changed,
+	// so that logs of a long deletion don't look stalled.
+	progressLinesHeartbeat = time.Minute
+)
+
+// progressLines prints the progress of tables as plain lines, only for tables whose progress changed.
+// If json is not nil, the progress is written as JSON records instead.
+type progressLines struct {
+	out           io.Writer
+	json          *jsonOutput
+	tables        []*table
+	maxNameLength int
+	began         time.Time
+	last          map[string]string // Last printed progress keyed by table name.
+	lastPrintedAt time.Time
+	rate          *rateTracker // Estimates the remaining time of the run if set.
+
+	stopCh chan struct{}
+	doneCh chan struct{}
+}
+
+func startProgressLines(out io.Writer, json *jsonOutput, tables []*table, maxNameLength int, rate *rateTracker) *progressLines {
+	var shown []*table
+	for _, table := range flattenTables(tables) {
+		// Tables found empty before the deletion have no progress to show.
+		if !table.deleter.alreadyEmpty {
+			shown = append(shown, table)
+		}
+	}
+	l := &progressLines{
+		out:           out,
+		json:          json,
+		tables:        shown,
+		maxNameLength: maxNameLength,
+		began:         time.Now(),
+		last:          map[string]string{},
+		rate:          rate,
+		stopCh:        make(chan struct{}),
+		doneCh:        make(chan struct{}),
+	}
+	go func() {
+		defer close(l.doneCh)
+		ticker := time.NewTicker(progressLinesInterval)
+		defer ticker.Stop()
+		l.print(time.Now())
+		for {
+			select {
+			case now := <-ticker.C:
+				l.print(now)
+			case <-l.stopCh:
+				l.print(time.Now())
+				return
+			}
+		}
+	}()
+	return l
+}
+
+// stop prints the latest progress and stops printing.
+func (l *progressLines) stop() {
+	close(l.stopCh)
+	<-l.doneCh
+}
+
+func (l *progressLines) print(now time.Time) {
+	elapsed := int(now.Sub(l.began).Seconds())
+	var changed []*table
+	for _, table := range l.tables {
+		progress := formatTableProgress(table)
+		// The estimate is taken on every check to follow the rate, but a new estimate alone isn't a change.
+		eta, hasETA := table.deleter.estimateRemaining(now)
+		if l.last[table.tableName] == progress {
+			continue
+		}
+		l.last[table.tableName] = progress
+		changed = append(changed, table)
+		if l.json == nil {
+			if hasETA {
+				progress += ", " + formatETA(eta)
+			}
+			fmt.Fprintf(l.out, "%5ds %-*s%s\n", elapsed, l.maxNameLength+2, table.tableName+": ", progress)
+		}
+	}
+	eta, hasETA := estimateOverallRemaining(l.rate, now, l.tables)
+	if l.json == nil {
+		if len(changed) > 0 {
+			l.lastPrintedAt = now
+		} else if now.Sub(l.lastPrintedAt) >= progressLinesHeartbeat {
+			progress := formatOverallProgress(l.tables)
+			if hasETA {
+				progress += ", " + formatETA(eta)
+			}
+			fmt.Fprintf(l.out, "%5ds %s\n", elapsed, progress)
+			l.lastPrintedAt = now
+		}
+	}
+	l.json.writeProgress(now, changed)
+}
+
+// formatOverallProgress formats the number of finished tables and deleted rows of all the tables.
+func formatOverallProgress(tables []*table) string {
+	var done int
+	for _, table := range tables {
+		if table.deleter.isDone() {
+			done++
+		}
+	}
+	deletedRows, _ := overallRows(tables)
+	return fmt.Sprintf("%d of %d tables done, %s rows deleted", done, len(tables), formatNumber(deletedRows))
+}
+
+// overallRows returns the numbers of deleted rows and total rows of all the tables.
+func overallRows(tables []*table) (deleted, total uint64) {
+	for _, table := range tables {
+		deleted += table.deleter.totalRows - table.deleter.remainedRows
+		total += table.deleter.totalRows
+	}
+	return deleted, total
+}
+
+// rateTracker estimates the remaining time of a deletion from the rate of rows deleted since its first estimate.
+type rateTracker struct {
+	mu          sync.Mutex
+	startedAt   time.Time
+	startedRows uint64
+}
+
+// estimate returns the remaining time to delete all rows at the rate observed so far. It returns false until
+// some rows are deleted after the first call, or once all rows are deleted. A nil tracker never estimates.
+func (r *rateTracker) estimate(now time.Time, deleted, total uint64) (time.Duration, bool) {
+	if r == nil {
+		return 0, false
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.startedAt.IsZero() {
+		r.startedAt, r.startedRows = now, deleted
+		return 0, false
+	}
+	if deleted <= r.startedRows || deleted >= total {
+		return 0, false
+	}
+	elapsed := now.Sub(r.startedAt)
+	return time.Duration(float64(elapsed) * float64(total-deleted) / float64(deleted-r.startedRows)), true
+}
+
+// estimateOverallRemaining returns the remaining time of the run, once any table is being deleted.
+// Tables whose rows aren't counted are left out, as their progress is unknown.
+func estimateOverallRemaining(rate *rateTracker, now time.Time, tables []*table) (time.Duration, bool) {
+	var counted []*table
+	var deleting bool
+	for _, table := range tables {
+		if table.deleter.status == statusDeleting || table.deleter.status == statusCascadeDeleting {
+			deleting = true
+		}
+		if !table.deleter.uncounted {
+			counted = append(counted, table)
+		}
+	}
+	if !deleting {
+		return 0, false
+	}
+	deleted, total := overallRows(counted)
+	return rate.estimate(now, deleted, total)
+}
+
+// formatETA formats the estimated remaining time.
+func formatETA(d time.Duration) string {
+	return "ETA " + d.Round(time.Second).String()
+}
+
+// formatTableProgress formats the status and the number of deleted rows of the table.
+func formatTableProgress(table *table) string {
+	status := table.deleter.status
+	if !table.deleter.hasRowCount() {
+		return status.String()
+	}
+	deletedRows := table.deleter.totalRows - table.deleter.remainedRows
+	return fmt.Sprintf("%s (%s / %s)", status, formatNumber(deletedRows), formatNumber(table.deleter.totalRows))
+}
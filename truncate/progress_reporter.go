@@ -0,0 +1,164 @@
+//
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package truncate
+
+import (
+	"time"
+)
+
+// progressReportInterval is the interval to check the progress of tables for a ProgressReporter.
+const progressReportInterval = time.Second
+
+// ProgressReporter receives the progress of a run, so that applications can render their own UI or log it.
+// Methods are called one at a time from a single goroutine, so they don't have to be goroutine-safe,
+// and they should return quickly as the progress is checked every second.
+type ProgressReporter interface {
+	// OnTableStart is called when rows of the table start to be deleted, by its own statement or in cascade.
+	OnTableStart(progress TableProgress)
+	// OnTableProgress is called when the number of deleted rows of the table changes while deleting.
+	OnTableProgress(progress TableProgress)
+	// OnTableComplete is called when the table has completed, failed or been skipped.
+	OnTableComplete(progress TableProgress)
+	// OnRunComplete is called when the run finishes, including dry runs and runs aborted before deletion.
+	OnRunComplete(result RunResult)
+}
+
+// TableProgress is the progress of deleting rows from a table.
+type TableProgress struct {
+	Table string
+
+	// Status of the table, one of "analyzing", "waiting", "deleting", "cascade deleting", "completed", "failed" and "skipped".
+	Status string
+
+	// Deleted rows and total rows of the table, which are estimates unless the rows are counted.
+	// Both are zero if the table has no row count yet.
+	DeletedRows uint64
+	TotalRows   uint64
+
+	// Error which failed or skipped the table.
+	Err error
+}
+
+// RunResult is the result of a run.
+type RunResult struct {
+	// Status of the run, one of "completed", "dry_run", "cancelled" and "failed".
+	Status string
+	// Error returned by the run, if any.
+	Err     error
+	Elapsed time.Duration
+}
+
+// WithProgressReporter reports the progress of the run to the reporter, in addition to the output.
+// Combine it with WithoutProgressBars to render the progress only by the reporter.
+func WithProgressReporter(reporter ProgressReporter) Option {
+	return func(o *options) {
+		o.progressReporter = reporter
+	}
+}
+
+func newTableProgress(table *table) TableProgress {
+	d := table.deleter
+	progress := TableProgress{Table: table.tableName, Status: d.status.String(), Err: d.failure}
+	if d.hasRowCount() {
+		progress.DeletedRows = d.totalRows - d.remainedRows
+		progress.TotalRows = d.totalRows
+	}
+	return progress
+}
+
+// progressReports checks the progress of tables periodically and reports changes to a ProgressReporter.
+// It is safe to call methods on a nil receiver, which reports nothing.
+type progressReports struct {
+	reporter ProgressReporter
+	tables   []*table
+	started  map[string]bool
+	done     map[string]bool
+	deleted  map[string]uint64
+
+	stopCh chan struct{}
+	doneCh chan struct{}
+}
+
+func startProgressReports(reporter ProgressReporter, tables []*table) *progressReports {
+	if reporter == nil {
+		return nil
+	}
+	r := &progressReports{
+		reporter: reporter,
+		tables:   flattenTables(tables),
+		started:  map[string]bool{},
+		done:     map[string]bool{},
+		deleted:  map[string]uint64{},
+		stopCh:   make(chan struct{}),
+		doneCh:   make(chan struct{}),
+	}
+	go func() {
+		defer close(r.doneCh)
+		ticker := time.NewTicker(progressReportInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				r.report()
+			case <-r.stopCh:
+				r.report()
+				return
+			}
+		}
+	}()
+	return r
+}
+
+// stop reports the latest progress and stops reporting.
+func (r *progressReports) stop() {
+	if r == nil {
+		return
+	}
+	close(r.stopCh)
+	<-r.doneCh
+}
+
+func (r *progressReports) report() {
+	for _, table := range r.tables {
+		if r.done[table.tableName] {
+			continue
+		}
+		d := table.deleter
+		progress := newTableProgress(table)
+		switch d.status {
+		case statusAnalyzing, statusWaiting:
+			continue
+		case statusDeleting, statusCascadeDeleting:
+			if !r.started[table.tableName] {
+				r.started[table.tableName] = true
+				r.deleted[table.tableName] = progress.DeletedRows
+				r.reporter.OnTableStart(progress)
+			} else if r.deleted[table.tableName] != progress.DeletedRows {
+				r.deleted[table.tableName] = progress.DeletedRows
+				r.reporter.OnTableProgress(progress)
+			}
+		default:
+			// Tables completing between two checks are reported as started first.
+			if !r.started[table.tableName] && d.status == statusCompleted {
+				r.started[table.tableName] = true
+				r.reporter.OnTableStart(progress)
+			}
+			r.done[table.tableName] = true
+			r.reporter.OnTableComplete(progress)
+		}
+	}
+}
@@ -0,0 +1,94 @@
+//
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package truncate
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+// recordingReporter records calls as lines.
+type recordingReporter struct {
+	calls []string
+}
+
+func (r *recordingReporter) record(method string, p TableProgress) {
+	line := fmt.Sprintf("%s %s %s %d/%d", method, p.Table, p.Status, p.DeletedRows, p.TotalRows)
+	if p.Err != nil {
+		line += " " + p.Err.Error()
+	}
+	r.calls = append(r.calls, line)
+}
+
+func (r *recordingReporter) OnTableStart(p TableProgress)    { r.record("start", p) }
+func (r *recordingReporter) OnTableProgress(p TableProgress) { r.record("progress", p) }
+func (r *recordingReporter) OnTableComplete(p TableProgress) { r.record("complete", p) }
+func (r *recordingReporter) OnRunComplete(result RunResult) {
+	r.calls = append(r.calls, "run "+result.Status)
+}
+
+func TestProgressReports(t *testing.T) {
+	singers := &table{tableName: "Singers", deleter: &deleter{status: statusWaiting, totalRows: 6000, remainedRows: 6000}}
+	albums := &table{tableName: "Albums", deleter: &deleter{status: statusWaiting, totalRows: 100, remainedRows: 100}}
+	venues := &table{tableName: "Venues", deleter: &deleter{status: statusAnalyzing}}
+	concerts := &table{tableName: "Concerts", deleter: &deleter{status: statusAnalyzing}}
+	singers.childTables = []*table{albums}
+
+	reporter := &recordingReporter{}
+	r := &progressReports{
+		reporter: reporter,
+		tables:   flattenTables([]*table{singers, venues, concerts}),
+		started:  map[string]bool{},
+		done:     map[string]bool{},
+		deleted:  map[string]uint64{},
+	}
+
+	r.report()
+	singers.deleter.status = statusDeleting
+	albums.deleter.status = statusCascadeDeleting
+	venues.deleter.status = statusFailed
+	venues.deleter.failure = errors.New("permission denied")
+	r.report()
+	// Nothing but Singers changed.
+	singers.deleter.remainedRows = 1000
+	r.report()
+	// Concerts completed without being seen deleting.
+	singers.deleter.status = statusCompleted
+	singers.deleter.remainedRows = 0
+	albums.deleter.status = statusCompleted
+	albums.deleter.remainedRows = 0
+	concerts.deleter.status = statusCompleted
+	r.report()
+	r.report()
+
+	want := []string{
+		"start Singers deleting 0/6000",
+		"start Albums cascade deleting 0/100",
+		"complete Venues failed 0/0 permission denied",
+		"progress Singers deleting 5000/6000",
+		"complete Singers completed 6000/6000",
+		"complete Albums completed 100/100",
+		"start Concerts completed 0/0",
+		"complete Concerts completed 0/0",
+	}
+	if !cmp.Equal(reporter.calls, want) {
+		t.Errorf("diff(+got, -want) = %v", cmp.Diff(reporter.calls, want))
+	}
+}
@@ -0,0 +1,152 @@
+//
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package truncate
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestProgressLines(t *testing.T) {
+	began := time.Date(2021, 6, 1, 12, 0, 0, 0, time.UTC)
+	singers := &table{tableName: "Singers", deleter: &deleter{status: statusDeleting, totalRows: 6000, remainedRows: 6000}}
+	albums := &table{tableName: "Albums", deleter: &deleter{status: statusAnalyzing}}
+	singers.childTables = []*table{albums}
+
+	var buf bytes.Buffer
+	l := &progressLines{out: &buf, tables: flattenTables([]*table{singers}), maxNameLength: 7, began: began, last: map[string]string{}}
+
+	l.print(began)
+	// Only Singers changed.
+	singers.deleter.remainedRows = 1000
+	l.print(began.Add(5 * time.Second))
+	// Nothing changed.
+	l.print(began.Add(10 * time.Second))
+
+	want := `    0s Singers: deleting (0 / 6,000)
+    0s Albums:  analyzing
+    5s Singers: deleting (5,000 / 6,000), ETA 1s
+`
+	if got := buf.String(); got != want {
+		t.Errorf("print() mismatch (-got +want):\n%s", cmp.Diff(got, want))
+	}
+}
+
+func TestProgressLinesUncounted(t *testing.T) {
+	began := time.Date(2021, 6, 1, 12, 0, 0, 0, time.UTC)
+	singers := &table{tableName: "Singers", deleter: &deleter{status: statusWaiting, uncounted: true}}
+	albums := &table{tableName: "Albums", deleter: &deleter{status: statusWaiting, uncounted: true}}
+	singers.childTables = []*table{albums}
+
+	var buf bytes.Buffer
+	l := &progressLines{out: &buf, tables: flattenTables([]*table{singers}), maxNameLength: 7, began: began, last: map[string]string{}}
+
+	l.print(began)
+	singers.deleter.status = statusDeleting
+	albums.deleter.status = statusCascadeDeleting
+	l.print(began.Add(5 * time.Second))
+	// The number of deleted rows is known once the deletion returned.
+	singers.deleter.status = statusCompleted
+	singers.deleter.totalRows = 6000
+	albums.deleter.status = statusCompleted
+	l.print(began.Add(35 * time.Second))
+
+	want := `    0s Singers: waiting
+    0s Albums:  waiting
+    5s Singers: deleting
+    5s Albums:  cascade deleting
+   35s Singers: completed (6,000 / 6,000)
+   35s Albums:  completed
+`
+	if got := buf.String(); got != want {
+		t.Errorf("print() mismatch (-got +want):\n%s", cmp.Diff(got, want))
+	}
+}
+
+func TestProgressLinesHeartbeat(t *testing.T) {
+	began := time.Date(2021, 6, 1, 12, 0, 0, 0, time.UTC)
+	singers := &table{tableName: "Singers", deleter: &deleter{status: statusDeleting, totalRows: 6000, remainedRows: 6000}}
+	albums := &table{tableName: "Albums", deleter: &deleter{status: statusCompleted}}
+
+	var buf bytes.Buffer
+	l := &progressLines{out: &buf, tables: flattenTables([]*table{singers, albums}), maxNameLength: 7, began: began, last: map[string]string{}}
+
+	l.print(began)
+	singers.deleter.remainedRows = 3600
+	l.print(began.Add(5 * time.Second))
+	// Nothing changed for less than the heartbeat interval.
+	l.print(began.Add(60 * time.Second))
+	// Nothing changed for the heartbeat interval.
+	l.print(began.Add(65 * time.Second))
+	l.print(began.Add(70 * time.Second))
+
+	want := `    0s Singers: deleting (0 / 6,000)
+    0s Albums:  completed (0 / 0)
+    5s Singers: deleting (2,400 / 6,000), ETA 8s
+   65s 1 of 2 tables done, 2,400 rows deleted
+`
+	if got := buf.String(); got != want {
+		t.Errorf("print() mismatch (-got +want):\n%s", cmp.Diff(got, want))
+	}
+}
+
+func TestProgressLinesOverallETA(t *testing.T) {
+	began := time.Date(2021, 6, 1, 12, 0, 0, 0, time.UTC)
+	singers := &table{tableName: "Singers", deleter: &deleter{status: statusDeleting, totalRows: 6000, remainedRows: 6000}}
+	albums := &table{tableName: "Albums", deleter: &deleter{status: statusWaiting, totalRows: 4000, remainedRows: 4000}}
+
+	var buf bytes.Buffer
+	l := &progressLines{out: &buf, tables: flattenTables([]*table{singers, albums}), maxNameLength: 7, began: began, last: map[string]string{}, rate: &rateTracker{}}
+
+	l.print(began)
+	singers.deleter.remainedRows = 4000
+	l.print(began.Add(5 * time.Second))
+	// Nothing changed for the heartbeat interval.
+	l.print(began.Add(65 * time.Second))
+
+	want := `    0s Singers: deleting (0 / 6,000)
+    0s Albums:  waiting (0 / 4,000)
+    5s Singers: deleting (2,000 / 6,000), ETA 10s
+   65s 0 of 2 tables done, 2,000 rows deleted, ETA 4m20s
+`
+	if got := buf.String(); got != want {
+		t.Errorf("print() mismatch (-got +want):\n%s", cmp.Diff(got, want))
+	}
+}
+
+func TestRateTracker(t *testing.T) {
+	began := time.Date(2021, 6, 1, 12, 0, 0, 0, time.UTC)
+	var r rateTracker
+	if _, ok := r.estimate(began, 1000, 10000); ok {
+		t.Errorf("estimate() on the first call = true, want false")
+	}
+	if _, ok := r.estimate(began.Add(time.Minute), 1000, 10000); ok {
+		t.Errorf("estimate() without progress = true, want false")
+	}
+	if got, ok := r.estimate(began.Add(time.Minute), 4000, 10000); !ok || got != 2*time.Minute {
+		t.Errorf("estimate() = %v, %v, want %v, true", got, ok, 2*time.Minute)
+	}
+	if _, ok := r.estimate(began.Add(2*time.Minute), 10000, 10000); ok {
+		t.Errorf("estimate() after all rows deleted = true, want false")
+	}
+	if _, ok := (*rateTracker)(nil).estimate(began, 0, 10000); ok {
+		t.Errorf("estimate() of nil = true, want false")
+	}
+}
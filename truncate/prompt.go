@@ -0,0 +1,87 @@
+//
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package truncate
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// stdin is shared by all prompts so that input buffered by a prompt isn't lost for the next prompt.
+var stdin = bufio.NewScanner(os.Stdin)
+
+// confirm returns true if a user confirmed the message, otherwise returns false.
+// Answers are case-insensitive and an empty answer is regarded as defaultAnswer.
+// If the input is closed, it stops prompting and returns defaultAnswer.
+func confirm(in *bufio.Scanner, out io.Writer, msg string, defaultAnswer bool) bool {
+	choices := "[y/N]"
+	if defaultAnswer {
+		choices = "[Y/n]"
+	}
+	fmt.Fprintf(out, "%s %s ", msg, choices)
+
+	for {
+		if !in.Scan() {
+			fmt.Fprintf(out, "\nNo answer from input, regarded as %q.\n", formatAnswer(defaultAnswer))
+			return defaultAnswer
+		}
+		switch strings.ToLower(strings.TrimSpace(in.Text())) {
+		case "":
+			return defaultAnswer
+		case "y", "yes":
+			return true
+		case "n", "no":
+			return false
+		default:
+			fmt.Fprintf(out, "Please answer yes or no %s: ", choices)
+		}
+	}
+}
+
+// askFailureAction asks a user what to do with the table whose deletion failed.
+// An empty answer is regarded as abort. If the input is closed, it stops prompting and aborts.
+func askFailureAction(in *bufio.Scanner, out io.Writer, tableName string, err error) FailureAction {
+	fmt.Fprintf(out, "\nFailed to delete rows from %s: %v\n", tableName, err)
+	fmt.Fprintf(out, "[r]etry, [s]kip the table and tables depending on it, or [A]bort? ")
+
+	for {
+		if !in.Scan() {
+			fmt.Fprintf(out, "\nNo answer from input, regarded as \"abort\".\n")
+			return FailureActionAbort
+		}
+		switch strings.ToLower(strings.TrimSpace(in.Text())) {
+		case "", "a", "abort":
+			return FailureActionAbort
+		case "r", "retry":
+			return FailureActionRetry
+		case "s", "skip":
+			return FailureActionSkip
+		default:
+			fmt.Fprintf(out, "Please answer retry, skip or abort [r/s/A]: ")
+		}
+	}
+}
+
+func formatAnswer(answer bool) string {
+	if answer {
+		return "yes"
+	}
+	return "no"
+}
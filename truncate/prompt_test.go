@@ -0,0 +1,75 @@
+//
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package truncate
+
+import (
+	"bufio"
+	"errors"
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+func TestConfirm(t *testing.T) {
+	for _, tt := range []struct {
+		desc          string
+		input         string
+		defaultAnswer bool
+		want          bool
+	}{
+		{"Yes", "y\n", false, true},
+		{"Yes in upper case", "YES\n", false, true},
+		{"No", "n\n", true, false},
+		{"No in mixed case", "No\n", true, false},
+		{"Empty answer with default no", "\n", false, false},
+		{"Empty answer with default yes", "\n", true, true},
+		{"Re-prompt on invalid answer", "maybe\nyes\n", false, true},
+		{"Closed input with default no", "", false, false},
+		{"Closed input after invalid answer", "maybe\n", true, true},
+		{"Surrounding spaces", "  yes \n", false, true},
+	} {
+		t.Run(tt.desc, func(t *testing.T) {
+			in := bufio.NewScanner(strings.NewReader(tt.input))
+			if got := confirm(in, ioutil.Discard, "Continue?", tt.defaultAnswer); got != tt.want {
+				t.Errorf("confirm() with input %q = %v, but want = %v", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAskFailureAction(t *testing.T) {
+	for _, tt := range []struct {
+		desc  string
+		input string
+		want  FailureAction
+	}{
+		{"Retry", "r\n", FailureActionRetry},
+		{"Retry in full", "Retry\n", FailureActionRetry},
+		{"Skip", "s\n", FailureActionSkip},
+		{"Abort", "abort\n", FailureActionAbort},
+		{"Empty answer", "\n", FailureActionAbort},
+		{"Re-prompt on invalid answer", "later\nskip\n", FailureActionSkip},
+		{"Closed input", "", FailureActionAbort},
+	} {
+		t.Run(tt.desc, func(t *testing.T) {
+			in := bufio.NewScanner(strings.NewReader(tt.input))
+			if got := askFailureAction(in, ioutil.Discard, "Singers", errors.New("deadline exceeded")); got != tt.want {
+				t.Errorf("askFailureAction() with input %q = %v, but want = %v", tt.input, got, tt.want)
+			}
+		})
+	}
+}
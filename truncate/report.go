@@ -0,0 +1,350 @@
+//
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package truncate
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"time"
+
+	adminapi "cloud.google.com/go/spanner/admin/database/apiv1"
+	"google.golang.org/api/option"
+	adminpb "google.golang.org/genproto/googleapis/spanner/admin/database/v1"
+)
+
+// Report is a machine-readable record of a run, which can be used as a baseline of later runs.
+type Report struct {
+	Database    string         `json:"database"`
+	GeneratedAt time.Time      `json:"generated_at"`
+	DryRun      bool           `json:"dry_run"`
+	Tables      []*TableReport `json:"tables"`
+
+	// Result of the run, one of "completed", "dry_run", "cancelled" and "failed".
+	Status string `json:"status,omitempty"`
+
+	// Seconds from the start to the end of the run, including the time waiting for the confirmation.
+	DurationSeconds float64 `json:"duration_seconds,omitempty"`
+
+	// Error which failed the run.
+	Error string `json:"error,omitempty"`
+
+	// Warnings recorded during the run, which didn't stop it.
+	Warnings []string `json:"warnings,omitempty"`
+
+	// What the run does to the tables: "delete" for deleting all rows, "delete_filtered" for deleting only
+	// the rows described by RowFilter, e.g. "rows of tenant 42", or "scrub" for scrubbing columns.
+	Operation string `json:"operation,omitempty"`
+	RowFilter string `json:"row_filter,omitempty"`
+
+	// Tables skipped before deletion, e.g. by their sizes, row deletion policies or unknown ages, with the reasons.
+	// They are not in Tables. Tables skipped during the deletion are in Tables with the status "skipped" instead.
+	SkippedTables map[string]string `json:"skipped_tables,omitempty"`
+
+	// Maximum number of rows deleted in a read-write transaction, and seconds of the pause between transactions,
+	// by deletions without Partitioned DML. Zero if they aren't limited.
+	BatchRows            int64   `json:"batch_rows,omitempty"`
+	BatchIntervalSeconds float64 `json:"batch_interval_seconds,omitempty"`
+}
+
+// TableReport is a record of a table in Report.
+type TableReport struct {
+	Name string `json:"name"`
+
+	// Number of rows before deletion. Nil if the table couldn't be counted.
+	RowCount *int64 `json:"row_count,omitempty"`
+
+	// CREATE TABLE statement of the table at the time of deletion. Set only with WithReportDDL.
+	DDL string `json:"ddl,omitempty"`
+
+	// Retries which occurred while deleting rows. Nil in dry runs.
+	Retries *RetryStats `json:"retries,omitempty"`
+
+	// Status of the table at the end of the run, e.g. "completed", "failed" or "skipped". Blank if the deletion didn't start.
+	Status string `json:"status,omitempty"`
+
+	// Whether the table was completed without deletion because it was already empty, with WithSkipEmptyTables.
+	AlreadyEmpty bool `json:"already_empty,omitempty"`
+
+	// How the table was deleted: "pdml", "mutation", "leaves_first", "sharded_pdml" or "batched_dml" by its own
+	// deletion, or "cascade" by its ancestor.
+	// Blank if the table wasn't deleted, e.g. it was already empty.
+	Strategy string `json:"strategy,omitempty"`
+
+	// Number of rows deleted from the table, as counted during the deletion. Nil if the deletion didn't start.
+	DeletedRows *uint64 `json:"deleted_rows,omitempty"`
+
+	// Seconds the table's own deletions took. Zero for tables deleted in cascade.
+	DurationSeconds float64 `json:"duration_seconds,omitempty"`
+
+	// Error which failed or skipped the table.
+	Error string `json:"error,omitempty"`
+}
+
+// Operations of runs in Report.
+const (
+	operationDelete         = "delete"
+	operationDeleteFiltered = "delete_filtered"
+	operationScrub          = "scrub"
+)
+
+// Strategies of deleting tables in TableReport.
+const (
+	strategyPDML        = "pdml"
+	strategyMutation    = "mutation"
+	strategyCascade     = "cascade"
+	strategyLeavesFirst = "leaves_first"
+	strategySharded     = "sharded_pdml"
+	strategyBatched     = "batched_dml"
+)
+
+// WithDryRun lists the target tables and their row counts without deleting any rows.
+func WithDryRun() Option {
+	return func(o *options) {
+		o.dryRun = true
+	}
+}
+
+// WithReportHandler registers a handler which receives the report when the run finishes, even if the run failed
+// or was cancelled, once the target tables have been listed.
+func WithReportHandler(handler func(*Report)) Option {
+	return func(o *options) {
+		o.reportHandler = handler
+	}
+}
+
+// WithBaselineReport compares the target tables with a report of a previous run,
+// and prints new tables, removed tables, and large row count changes before deletion.
+func WithBaselineReport(baseline *Report) Option {
+	return func(o *options) {
+		o.baselineReport = baseline
+	}
+}
+
+// WithReportDDL captures the CREATE TABLE statement of each target table into the report,
+// so that audits can see what structure the deleted data had at the time of deletion.
+func WithReportDDL() Option {
+	return func(o *options) {
+		o.reportDDL = true
+	}
+}
+
+// createTableRegexp matches a CREATE TABLE statement and captures the table name, possibly quoted or qualified with a schema.
+var createTableRegexp = regexp.MustCompile("(?is)^\\s*CREATE\\s+TABLE\\s+(?:IF\\s+NOT\\s+EXISTS\\s+)?([`\\w.]+)")
+
+// fetchTableDDLs fetches CREATE TABLE statements of the database keyed by the table names.
+// If adminClient is nil, a new admin client is created with clientOpts and closed on return.
+func fetchTableDDLs(ctx context.Context, adminClient *adminapi.DatabaseAdminClient, database string, clientOpts ...option.ClientOption) (map[string]string, error) {
+	statements, err := fetchDatabaseDDL(ctx, adminClient, database, clientOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return extractTableDDLs(statements), nil
+}
+
+// fetchDatabaseDDL fetches the DDL statements of the database.
+// If adminClient is nil, a new admin client is created with clientOpts and closed on return.
+func fetchDatabaseDDL(ctx context.Context, adminClient *adminapi.DatabaseAdminClient, database string, clientOpts ...option.ClientOption) ([]string, error) {
+	if adminClient == nil {
+		var err error
+		adminClient, err = adminapi.NewDatabaseAdminClient(ctx, clientOpts...)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create Cloud Spanner admin client: %v", err)
+		}
+		defer adminClient.Close()
+	}
+
+	resp, err := adminClient.GetDatabaseDdl(ctx, &adminpb.GetDatabaseDdlRequest{Database: database})
+	if err != nil {
+		return nil, err
+	}
+	return resp.GetStatements(), nil
+}
+
+// extractTableDDLs picks CREATE TABLE statements from the DDL statements, keyed by the table names.
+func extractTableDDLs(statements []string) map[string]string {
+	ddls := map[string]string{}
+	for _, stmt := range statements {
+		m := createTableRegexp.FindStringSubmatch(stmt)
+		if m == nil {
+			continue
+		}
+		ddls[strings.ReplaceAll(m[1], "`", "")] = stmt
+	}
+	return ddls
+}
+
+// setDDLs sets the DDL of each table in the report.
+func (r *Report) setDDLs(ddls map[string]string) {
+	for _, t := range r.Tables {
+		t.DDL = ddls[t.Name]
+	}
+}
+
+// setSkippedTables records the tables skipped before deletion with the reasons.
+func (r *Report) setSkippedTables(skipped ...map[string]string) {
+	for _, reasons := range skipped {
+		for name, reason := range reasons {
+			if r.SkippedTables == nil {
+				r.SkippedTables = map[string]string{}
+			}
+			r.SkippedTables[name] = reason
+		}
+	}
+}
+
+// setTableResults sets how each table in the report was deleted, and its retries.
+func (r *Report) setTableResults(tables []*table) {
+	deleters := make(map[string]*deleter)
+	for _, t := range flattenTables(tables) {
+		deleters[t.tableName] = t.deleter
+	}
+	for _, t := range r.Tables {
+		d, ok := deleters[t.Name]
+		if !ok {
+			continue
+		}
+		stats := d.retries.stats()
+		deletedRows := d.totalRows - d.remainedRows
+		t.Retries = &stats
+		t.Status = d.status.String()
+		t.AlreadyEmpty = d.alreadyEmpty
+		t.Strategy = d.strategy
+		t.DeletedRows = &deletedRows
+		t.DurationSeconds = d.deleteDuration.Seconds()
+		if d.failure != nil {
+			t.Error = d.failure.Error()
+		}
+	}
+}
+
+// finish records the result of the run.
+func (r *Report) finish(status string, warnings *warnings, err error, elapsed time.Duration) {
+	r.Status = status
+	r.DurationSeconds = elapsed.Seconds()
+	r.Warnings = warnings.strings()
+	if err != nil {
+		r.Status = "failed"
+		r.Error = err.Error()
+	}
+}
+
+func newReport(database string, schemas []*tableSchema, rowCounts map[string]int64, dryRun bool) *Report {
+	report := &Report{
+		Database:    database,
+		GeneratedAt: time.Now(),
+		DryRun:      dryRun,
+	}
+	for _, schema := range schemas {
+		t := &TableReport{Name: schema.tableName}
+		if count, ok := rowCounts[schema.tableName]; ok {
+			t.RowCount = &count
+		}
+		report.Tables = append(report.Tables, t)
+	}
+	return report
+}
+
+// Thresholds to regard a row count change as large.
+const (
+	largeChangeRatio = 0.5 // Relative to the baseline row count.
+	largeChangeRows  = 10  // Absolute number of rows, to ignore noises in small tables.
+)
+
+// reportDiff is a difference of target tables between two reports.
+type reportDiff struct {
+	newTables     []string
+	removedTables []string
+	countChanges  []*countChange
+}
+
+type countChange struct {
+	tableName string
+	before    int64
+	after     int64
+}
+
+func (d *reportDiff) isEmpty() bool {
+	return len(d.newTables) == 0 && len(d.removedTables) == 0 && len(d.countChanges) == 0
+}
+
+// diffReports returns tables added to or removed from current since baseline, and tables whose row count changed largely.
+func diffReports(baseline, current *Report) *reportDiff {
+	before := make(map[string]*TableReport, len(baseline.Tables))
+	for _, t := range baseline.Tables {
+		before[t.Name] = t
+	}
+	after := make(map[string]*TableReport, len(current.Tables))
+	for _, t := range current.Tables {
+		after[t.Name] = t
+	}
+
+	diff := &reportDiff{}
+	for _, t := range current.Tables {
+		b, ok := before[t.Name]
+		if !ok {
+			diff.newTables = append(diff.newTables, t.Name)
+			continue
+		}
+		if b.RowCount == nil || t.RowCount == nil {
+			continue
+		}
+		if isLargeChange(*b.RowCount, *t.RowCount) {
+			diff.countChanges = append(diff.countChanges, &countChange{tableName: t.Name, before: *b.RowCount, after: *t.RowCount})
+		}
+	}
+	for _, t := range baseline.Tables {
+		if _, ok := after[t.Name]; !ok {
+			diff.removedTables = append(diff.removedTables, t.Name)
+		}
+	}
+	return diff
+}
+
+func isLargeChange(before, after int64) bool {
+	delta := after - before
+	if delta < 0 {
+		delta = -delta
+	}
+	if delta < largeChangeRows {
+		return false
+	}
+	if before == 0 {
+		return true
+	}
+	return float64(delta)/float64(before) >= largeChangeRatio
+}
+
+// printReportDiff prints the difference from the baseline report.
+func printReportDiff(out io.Writer, baseline *Report, diff *reportDiff) {
+	fmt.Fprintf(out, "Changes since the baseline report generated at %s:\n", baseline.GeneratedAt.Format(time.RFC3339))
+	if diff.isEmpty() {
+		fmt.Fprintf(out, "  (no significant changes)\n")
+		return
+	}
+	for _, name := range diff.newTables {
+		fmt.Fprintf(out, "  + %s (new table)\n", name)
+	}
+	for _, name := range diff.removedTables {
+		fmt.Fprintf(out, "  - %s (removed table)\n", name)
+	}
+	for _, c := range diff.countChanges {
+		fmt.Fprintf(out, "  ~ %s: %s rows -> %s rows\n", c.tableName, formatNumber(uint64(c.before)), formatNumber(uint64(c.after)))
+	}
+}
@@ -0,0 +1,146 @@
+//
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package truncate
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestDiffReports(t *testing.T) {
+	count := func(n int64) *int64 { return &n }
+
+	baseline := &Report{
+		Tables: []*TableReport{
+			{Name: "Albums", RowCount: count(100)},
+			{Name: "Concerts", RowCount: count(100)},
+			{Name: "Singers", RowCount: count(5)},
+			{Name: "Songs", RowCount: count(0)},
+			{Name: "Venues", RowCount: count(10)},
+		},
+	}
+	current := &Report{
+		Tables: []*TableReport{
+			{Name: "Albums", RowCount: count(120)},
+			{Name: "Concerts", RowCount: count(40)},
+			{Name: "Singers", RowCount: count(12)},
+			{Name: "Songs", RowCount: count(30)},
+			{Name: "Tickets", RowCount: count(1)},
+		},
+	}
+
+	got := diffReports(baseline, current)
+	want := &reportDiff{
+		newTables:     []string{"Tickets"},
+		removedTables: []string{"Venues"},
+		countChanges: []*countChange{
+			{tableName: "Concerts", before: 100, after: 40},
+			{tableName: "Songs", before: 0, after: 30},
+		},
+	}
+	if diff := cmp.Diff(got, want, cmp.AllowUnexported(reportDiff{}, countChange{})); diff != "" {
+		t.Errorf("mismatch (-got +want):\n%s", diff)
+	}
+}
+
+func TestPrintReportDiff(t *testing.T) {
+	baseline := &Report{GeneratedAt: time.Date(2021, 6, 1, 12, 0, 0, 0, time.UTC)}
+	diff := &reportDiff{
+		newTables:     []string{"Tickets"},
+		removedTables: []string{"Venues"},
+		countChanges:  []*countChange{{tableName: "Singers", before: 6000, after: 2000000}},
+	}
+
+	var buf bytes.Buffer
+	printReportDiff(&buf, baseline, diff)
+
+	want := `Changes since the baseline report generated at 2021-06-01T12:00:00Z:
+  + Tickets (new table)
+  - Venues (removed table)
+  ~ Singers: 6,000 rows -> 2,000,000 rows
+`
+	if got := buf.String(); got != want {
+		t.Errorf("printReportDiff() = %q, but want = %q", got, want)
+	}
+}
+
+func TestExtractTableDDLs(t *testing.T) {
+	statements := []string{
+		"CREATE TABLE Singers (\n  SingerId INT64 NOT NULL,\n) PRIMARY KEY(SingerId)",
+		"CREATE TABLE `Order` (\n  OrderId INT64 NOT NULL,\n) PRIMARY KEY(OrderId)",
+		"create table IF NOT EXISTS analytics.Events (\n  EventId INT64 NOT NULL,\n) PRIMARY KEY(EventId)",
+		"CREATE INDEX SingersByName ON Singers(Name)",
+		"CREATE SCHEMA analytics",
+	}
+	want := map[string]string{
+		"Singers":          statements[0],
+		"Order":            statements[1],
+		"analytics.Events": statements[2],
+	}
+	if got := extractTableDDLs(statements); !cmp.Equal(got, want) {
+		t.Errorf("diff(+got, -want) = %v", cmp.Diff(got, want))
+	}
+}
+
+func TestReportSetSkippedTables(t *testing.T) {
+	report := &Report{}
+	report.setSkippedTables(nil, map[string]string{"Events": "it has more than 1,000 rows"}, map[string]string{"Logs": "it has a row deletion policy"})
+	want := map[string]string{"Events": "it has more than 1,000 rows", "Logs": "it has a row deletion policy"}
+	if diff := cmp.Diff(report.SkippedTables, want); diff != "" {
+		t.Errorf("mismatch (-got +want):\n%s", diff)
+	}
+
+	empty := &Report{}
+	empty.setSkippedTables(nil, map[string]string{})
+	if empty.SkippedTables != nil {
+		t.Errorf("SkippedTables = %v, but want nil", empty.SkippedTables)
+	}
+}
+
+func TestReportSetTableResults(t *testing.T) {
+	singers := &table{tableName: "Singers", deleter: &deleter{status: statusCompleted, strategy: strategyPDML, totalRows: 6000, deleteDuration: 90 * time.Second}}
+	albums := &table{tableName: "Albums", deleter: &deleter{status: statusCompleted, strategy: strategyCascade, totalRows: 1800}}
+	venues := &table{tableName: "Venues", deleter: &deleter{status: statusFailed, strategy: strategyMutation, totalRows: 10, remainedRows: 10, deleteDuration: time.Second, failure: errors.New("permission denied")}}
+	singers.childTables = []*table{albums}
+
+	report := &Report{Tables: []*TableReport{{Name: "Singers"}, {Name: "Albums"}, {Name: "Venues"}, {Name: "Skipped"}}}
+	report.setTableResults([]*table{singers, venues})
+	ws := newWarnings(nil)
+	ws.add("Venues", "failed to count rows: %v", "timeout")
+	report.finish("completed", ws, errors.New("failed to delete"), 2*time.Minute)
+
+	rows := func(n uint64) *uint64 { return &n }
+	want := &Report{
+		Tables: []*TableReport{
+			{Name: "Singers", Retries: &RetryStats{}, Status: "completed", Strategy: "pdml", DeletedRows: rows(6000), DurationSeconds: 90},
+			{Name: "Albums", Retries: &RetryStats{}, Status: "completed", Strategy: "cascade", DeletedRows: rows(1800)},
+			{Name: "Venues", Retries: &RetryStats{}, Status: "failed", Strategy: "mutation", DeletedRows: rows(0), DurationSeconds: 1, Error: "permission denied"},
+			{Name: "Skipped"},
+		},
+		Status:          "failed",
+		DurationSeconds: 120,
+		Error:           "failed to delete",
+		Warnings:        []string{"Venues: failed to count rows: timeout"},
+	}
+	if diff := cmp.Diff(report, want); diff != "" {
+		t.Errorf("mismatch (-got +want):\n%s", diff)
+	}
+}
@@ -0,0 +1,50 @@
+//
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package truncate
+
+import (
+	"cloud.google.com/go/spanner"
+	sppb "google.golang.org/genproto/googleapis/spanner/v1"
+)
+
+// WithPriority sets the RPC priority of requests issued to delete and count rows, e.g. sppb.RequestOptions_PRIORITY_LOW
+// so that the run yields to user traffic. Requests use the default priority, which is high, if not set.
+func WithPriority(priority sppb.RequestOptions_Priority) Option {
+	return func(o *options) {
+		o.requestOptions.priority = priority
+	}
+}
+
+// requestOptions are options applied to requests issued by a run.
+type requestOptions struct {
+	priority sppb.RequestOptions_Priority
+}
+
+// query returns options for queries and DML statements.
+func (r requestOptions) query() spanner.QueryOptions {
+	return spanner.QueryOptions{Priority: r.priority}
+}
+
+// transaction returns options for read-write transactions.
+func (r requestOptions) transaction() spanner.TransactionOptions {
+	return spanner.TransactionOptions{CommitPriority: r.priority}
+}
+
+// apply returns options for applying mutations.
+func (r requestOptions) apply() []spanner.ApplyOption {
+	return []spanner.ApplyOption{spanner.Priority(r.priority)}
+}
@@ -0,0 +1,47 @@
+//
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package truncate
+
+import (
+	"testing"
+
+	sppb "google.golang.org/genproto/googleapis/spanner/v1"
+)
+
+func TestRequestOptions(t *testing.T) {
+	for _, tt := range []struct {
+		desc string
+		opts []Option
+		want sppb.RequestOptions_Priority
+	}{
+		{desc: "default", opts: nil, want: sppb.RequestOptions_PRIORITY_UNSPECIFIED},
+		{desc: "low", opts: []Option{WithPriority(sppb.RequestOptions_PRIORITY_LOW)}, want: sppb.RequestOptions_PRIORITY_LOW},
+	} {
+		t.Run(tt.desc, func(t *testing.T) {
+			r := newOptions(tt.opts).requestOptions
+			if got := r.query().Priority; got != tt.want {
+				t.Errorf("query().Priority = %v, but want %v", got, tt.want)
+			}
+			if got := r.transaction().CommitPriority; got != tt.want {
+				t.Errorf("transaction().CommitPriority = %v, but want %v", got, tt.want)
+			}
+			if got := len(r.apply()); got != 1 {
+				t.Errorf("len(apply()) = %d, but want 1", got)
+			}
+		})
+	}
+}
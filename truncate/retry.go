@@ -0,0 +1,181 @@
+//
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package truncate
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"google.golang.org/api/option"
+	sppb "google.golang.org/genproto/googleapis/spanner/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	grpcstatus "google.golang.org/grpc/status"
+)
+
+// RetryStats is the number of retries which occurred while deleting rows from a table.
+// Many retries indicate the deletion is contending with live traffic.
+type RetryStats struct {
+	// Read-write transactions aborted and retried, by hooks.
+	TransactionAborts int64 `json:"transaction_aborts"`
+
+	// Partitioned DML transactions restarted from the beginning.
+	PDMLRestarts int64 `json:"pdml_restarts"`
+
+	// RPCs which failed with a transient error and were retried by the client.
+	RPCRetries int64 `json:"rpc_retries"`
+
+	// Deletions which failed with a transient error and were retried after a backoff.
+	DeleteRetries int64 `json:"delete_retries"`
+}
+
+// isZero returns true if no retries occurred.
+func (s RetryStats) isZero() bool {
+	return s == RetryStats{}
+}
+
+func (s RetryStats) String() string {
+	return fmt.Sprintf("%d transaction aborts, %d PDML restarts, %d RPC retries, %d deletion retries", s.TransactionAborts, s.PDMLRestarts, s.RPCRetries, s.DeleteRetries)
+}
+
+// retryCounter counts retries of a table. It's safe for concurrent use.
+type retryCounter struct {
+	transactionAborts int64
+	pdmlBegins        int64 // Partitioned DML transactions begun, including the first one of each deletion.
+	pdmlRuns          int64 // Calls of PartitionedUpdate.
+	rpcRetries        int64
+	deleteRetries     int64
+}
+
+// stats returns a snapshot of the counted retries.
+func (c *retryCounter) stats() RetryStats {
+	restarts := atomic.LoadInt64(&c.pdmlBegins) - atomic.LoadInt64(&c.pdmlRuns)
+	if restarts < 0 {
+		// Restarts are unknown if the client isn't created with RetryStatsClientOption.
+		restarts = 0
+	}
+	return RetryStats{
+		TransactionAborts: atomic.LoadInt64(&c.transactionAborts),
+		PDMLRestarts:      restarts,
+		RPCRetries:        atomic.LoadInt64(&c.rpcRetries),
+		DeleteRetries:     atomic.LoadInt64(&c.deleteRetries),
+	}
+}
+
+type retryCounterKey struct{}
+
+// withRetryCounter returns a context whose RPCs are counted by the counter.
+func withRetryCounter(ctx context.Context, c *retryCounter) context.Context {
+	return context.WithValue(ctx, retryCounterKey{}, c)
+}
+
+// RetryStatsClientOption returns a client option to be passed to spanner.NewClient,
+// which enables RunWithClient to count PDML restarts and RPC retries.
+// Without it, only transaction aborts are counted.
+func RetryStatsClientOption() option.ClientOption {
+	return option.WithGRPCDialOption(grpc.WithChainUnaryInterceptor(countRetries))
+}
+
+// countRetries is a gRPC interceptor which counts retries of RPCs issued with a context carrying a retry counter.
+func countRetries(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+	err := invoker(ctx, method, req, reply, cc, opts...)
+	c, ok := ctx.Value(retryCounterKey{}).(*retryCounter)
+	if !ok {
+		return err
+	}
+
+	if r, ok := req.(*sppb.BeginTransactionRequest); ok && r.GetOptions().GetPartitionedDml() != nil {
+		atomic.AddInt64(&c.pdmlBegins, 1)
+	}
+	if err != nil && ctx.Err() == nil && isTransientCode(grpcstatus.Code(err)) {
+		atomic.AddInt64(&c.rpcRetries, 1)
+	}
+	return err
+}
+
+// isTransientCode returns true if the code is of a transient error which the client retries.
+func isTransientCode(code codes.Code) bool {
+	return code == codes.Unavailable || code == codes.ResourceExhausted
+}
+
+const (
+	defaultDeleteRetries      = 3
+	defaultDeleteRetryBackoff = 10 * time.Second
+	maxDeleteRetryBackoff     = 5 * time.Minute // Backoffs don't grow beyond this.
+)
+
+// WithDeleteRetries retries the deletion of a table up to maxRetries times when it fails with a transient error,
+// e.g. an aborted transaction or an unavailable server. It waits initialBackoff before the first retry, and twice as
+// long as the previous one before each of the following retries, up to 5 minutes.
+// The table fails only after the retries are exhausted. By default, a deletion is retried 3 times from 10 seconds,
+// and it's never retried if maxRetries is 0.
+func WithDeleteRetries(maxRetries int, initialBackoff time.Duration) Option {
+	return func(o *options) {
+		o.deleteRetries = retryPolicy{maxRetries: maxRetries, initialBackoff: initialBackoff}
+	}
+}
+
+// retryPolicy decides how many times and how long after failures an operation is retried.
+type retryPolicy struct {
+	maxRetries     int
+	initialBackoff time.Duration
+}
+
+// backoff returns how long to wait before the n-th retry, counted from 0.
+func (p retryPolicy) backoff(n int) time.Duration {
+	backoff := p.initialBackoff
+	for i := 0; i < n && backoff < maxDeleteRetryBackoff; i++ {
+		backoff *= 2
+	}
+	if backoff > maxDeleteRetryBackoff {
+		return maxDeleteRetryBackoff
+	}
+	return backoff
+}
+
+// do calls f until it succeeds, fails with an error which isn't retryable, or the retries are exhausted,
+// and returns the last error. onRetry is called with the error and the backoff before waiting for each retry.
+// It stops retrying when the context is done.
+func (p retryPolicy) do(ctx context.Context, onRetry func(err error, backoff time.Duration), f func() error) error {
+	for n := 0; ; n++ {
+		err := f()
+		if err == nil || n >= p.maxRetries || !isRetryableError(ctx, err) {
+			return err
+		}
+		backoff := p.backoff(n)
+		onRetry(err, backoff)
+		timer := time.NewTimer(backoff)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		}
+	}
+}
+
+// isRetryableError returns true if the operation failed with an error which may not occur again on retrying it.
+// Deadline exceeded is retryable only if it's not caused by the deadline of the context, e.g. the timeout of the run.
+func isRetryableError(ctx context.Context, err error) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	code := errorCode(err)
+	return code == codes.Aborted || code == codes.DeadlineExceeded || isTransientCode(code)
+}
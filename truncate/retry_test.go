@@ -0,0 +1,226 @@
+//
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package truncate
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	sppb "google.golang.org/genproto/googleapis/spanner/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	grpcstatus "google.golang.org/grpc/status"
+)
+
+func TestCountRetries(t *testing.T) {
+	pdmlBegin := &sppb.BeginTransactionRequest{
+		Options: &sppb.TransactionOptions{
+			Mode: &sppb.TransactionOptions_PartitionedDml_{PartitionedDml: &sppb.TransactionOptions_PartitionedDml{}},
+		},
+	}
+	readWriteBegin := &sppb.BeginTransactionRequest{
+		Options: &sppb.TransactionOptions{
+			Mode: &sppb.TransactionOptions_ReadWrite_{ReadWrite: &sppb.TransactionOptions_ReadWrite{}},
+		},
+	}
+
+	type call struct {
+		req interface{}
+		err error
+	}
+	for _, test := range []struct {
+		desc  string
+		runs  int64
+		calls []call
+		want  RetryStats
+	}{
+		{
+			desc: "no retries",
+			runs: 1,
+			calls: []call{
+				{req: pdmlBegin},
+				{req: &sppb.ExecuteSqlRequest{}},
+			},
+			want: RetryStats{},
+		},
+		{
+			desc: "PDML restarted after an abort",
+			runs: 1,
+			calls: []call{
+				{req: pdmlBegin},
+				{req: &sppb.ExecuteSqlRequest{}, err: grpcstatus.Error(codes.Aborted, "aborted")},
+				{req: pdmlBegin},
+				{req: &sppb.ExecuteSqlRequest{}},
+			},
+			want: RetryStats{PDMLRestarts: 1},
+		},
+		{
+			desc: "transient errors",
+			runs: 1,
+			calls: []call{
+				{req: pdmlBegin, err: grpcstatus.Error(codes.Unavailable, "unavailable")},
+				{req: readWriteBegin, err: grpcstatus.Error(codes.ResourceExhausted, "exhausted")},
+				{req: &sppb.ExecuteSqlRequest{}, err: grpcstatus.Error(codes.InvalidArgument, "invalid")},
+			},
+			want: RetryStats{RPCRetries: 2},
+		},
+		{
+			desc: "client without the interceptor",
+			runs: 1,
+			want: RetryStats{},
+		},
+	} {
+		t.Run(test.desc, func(t *testing.T) {
+			c := &retryCounter{pdmlRuns: test.runs}
+			ctx := withRetryCounter(context.Background(), c)
+			for _, call := range test.calls {
+				invoker := func(context.Context, string, interface{}, interface{}, *grpc.ClientConn, ...grpc.CallOption) error {
+					return call.err
+				}
+				if err := countRetries(ctx, "/google.spanner.v1.Spanner/Method", call.req, nil, nil, invoker); err != call.err {
+					t.Errorf("countRetries() = %v, but want %v", err, call.err)
+				}
+			}
+			if got := c.stats(); !cmp.Equal(got, test.want) {
+				t.Errorf("diff(+got, -want) = %v", cmp.Diff(got, test.want))
+			}
+		})
+	}
+}
+
+func TestCountRetriesWithoutCounter(t *testing.T) {
+	invoker := func(context.Context, string, interface{}, interface{}, *grpc.ClientConn, ...grpc.CallOption) error {
+		return grpcstatus.Error(codes.Unavailable, "unavailable")
+	}
+	if err := countRetries(context.Background(), "/google.spanner.v1.Spanner/Method", &sppb.ExecuteSqlRequest{}, nil, nil, invoker); grpcstatus.Code(err) != codes.Unavailable {
+		t.Errorf("countRetries() = %v, but want an unavailable error", err)
+	}
+}
+
+func TestPrintRetryStats(t *testing.T) {
+	singers := &table{tableName: "Singers", deleter: &deleter{}}
+	albums := &table{tableName: "Albums", deleter: &deleter{retries: retryCounter{transactionAborts: 2}}}
+	singers.childTables = []*table{albums}
+
+	var out bytes.Buffer
+	printRetryStats(&out, []*table{singers})
+	want := "\nRetries (the deletion may have been contending with other traffic):\n" +
+		"  Albums: 2 transaction aborts, 0 PDML restarts, 0 RPC retries, 0 deletion retries\n"
+	if got := out.String(); got != want {
+		t.Errorf("printRetryStats() = %q, want %q", got, want)
+	}
+}
+
+func TestRetryPolicyBackoff(t *testing.T) {
+	p := retryPolicy{maxRetries: 10, initialBackoff: 10 * time.Second}
+	for _, tt := range []struct {
+		n    int
+		want time.Duration
+	}{
+		{n: 0, want: 10 * time.Second},
+		{n: 1, want: 20 * time.Second},
+		{n: 4, want: 160 * time.Second},
+		{n: 5, want: maxDeleteRetryBackoff},
+		{n: 100, want: maxDeleteRetryBackoff},
+	} {
+		if got := p.backoff(tt.n); got != tt.want {
+			t.Errorf("backoff(%d) = %v, want %v", tt.n, got, tt.want)
+		}
+	}
+}
+
+func TestRetryPolicyDo(t *testing.T) {
+	aborted := grpcstatus.Error(codes.Aborted, "aborted")
+	for _, tt := range []struct {
+		desc       string
+		maxRetries int
+		errs       []error // Errors returned by each call, followed by nil.
+		canceled   bool
+		wantCalls  int
+		wantErr    bool
+	}{
+		{
+			desc:       "success",
+			maxRetries: 3,
+			wantCalls:  1,
+		},
+		{
+			desc:       "retried until success",
+			maxRetries: 3,
+			errs:       []error{aborted, grpcstatus.Error(codes.Unavailable, "unavailable"), grpcstatus.Error(codes.DeadlineExceeded, "deadline exceeded")},
+			wantCalls:  4,
+		},
+		{
+			desc:       "retries exhausted",
+			maxRetries: 2,
+			errs:       []error{aborted, aborted, aborted, aborted},
+			wantCalls:  3,
+			wantErr:    true,
+		},
+		{
+			desc:       "not retryable",
+			maxRetries: 3,
+			errs:       []error{grpcstatus.Error(codes.PermissionDenied, "permission denied")},
+			wantCalls:  1,
+			wantErr:    true,
+		},
+		{
+			desc:       "no retries",
+			maxRetries: 0,
+			errs:       []error{aborted},
+			wantCalls:  1,
+			wantErr:    true,
+		},
+		{
+			desc:       "context done",
+			maxRetries: 3,
+			errs:       []error{grpcstatus.Error(codes.DeadlineExceeded, "deadline exceeded")},
+			canceled:   true,
+			wantCalls:  1,
+			wantErr:    true,
+		},
+	} {
+		t.Run(tt.desc, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			if tt.canceled {
+				cancel()
+			}
+			p := retryPolicy{maxRetries: tt.maxRetries, initialBackoff: time.Millisecond}
+			var calls, retries int
+			err := p.do(ctx, func(error, time.Duration) { retries++ }, func() error {
+				calls++
+				if calls <= len(tt.errs) {
+					return tt.errs[calls-1]
+				}
+				return nil
+			})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("do() = %v, wantErr %v", err, tt.wantErr)
+			}
+			if calls != tt.wantCalls {
+				t.Errorf("calls = %d, want %d", calls, tt.wantCalls)
+			}
+			if retries != calls-1 {
+				t.Errorf("retries = %d, want %d", retries, calls-1)
+			}
+		})
+	}
+}
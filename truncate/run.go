@@ -18,123 +18,882 @@
 package truncate
 
 import (
-	"bufio"
 	"context"
+	"errors"
 	"fmt"
 	"io"
-	"os"
+	"io/ioutil"
+	"log/slog"
+	"sort"
+	"strings"
+	"sync"
 	"time"
 
 	"cloud.google.com/go/spanner"
 	"github.com/gosuri/uiprogress"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
-// Run starts a routine to delete all rows from the specified database.
-// If targetTables is not empty, it deletes from the specified tables.
-// Otherwise, it deletes from all tables in the database.
-// If excludeTables is not empty, those tables are excluded from the deleted tables.
+// Run starts a routine to delete all rows from the database, e.g. "projects/p/instances/i/databases/d".
+// By default, it deletes from all tables in the database after a confirmation prompt, printing to stdout.
+// Use WithTables or WithExcludeTables to choose the tables, and WithQuiet and WithOut for unattended runs.
 // This function internally creates and uses a Cloud Spanner client.
-func Run(ctx context.Context, projectID, instanceID, databaseID string, quiet bool, out io.Writer, targetTables, excludeTables []string) error {
-	database := fmt.Sprintf("projects/%s/instances/%s/databases/%s", projectID, instanceID, databaseID)
-
-	client, err := spanner.NewClient(ctx, database)
+func Run(ctx context.Context, database string, opts ...Option) error {
+	o := newOptions(opts)
+	config := spanner.ClientConfig{SessionPoolConfig: spanner.DefaultSessionPoolConfig}
+	if o.sessionPoolConfig != nil {
+		config.SessionPoolConfig = *o.sessionPoolConfig
+	}
+	client, err := spanner.NewClientWithConfig(ctx, database, config, append(o.clientOptions, ClientOptions()...)...)
 	if err != nil {
 		return fmt.Errorf("failed to create Cloud Spanner client: %v", err)
 	}
 	defer func() {
-		fmt.Fprintf(out, "Closing spanner client...\n")
+		orDiscard(o.logger).Debug("closing spanner client", "database", database)
 		client.Close()
 	}()
 
-	return RunWithClient(ctx, client, quiet, out, targetTables, excludeTables)
+	return RunWithClient(ctx, client, opts...)
 }
 
-// RunWithClient starts a routine to delete all rows using the given spanner client.
-// If targetTables is not empty, it deletes from the specified tables.
-// Otherwise, it deletes from all tables in the database.
-// If excludeTables is not empty, those tables are excluded from the deleted tables.
+// RunWithClient starts a routine to delete all rows from the database of the given spanner client, like Run.
 // This function uses an externally passed Cloud Spanner client.
-func RunWithClient(ctx context.Context, client *spanner.Client, quiet bool, out io.Writer, targetTables, excludeTables []string) error {
+func RunWithClient(ctx context.Context, client *spanner.Client, opts ...Option) (err error) {
+	r := newRun(client, opts)
+	ctx = withRequestTags(ctx, r.o.requestTags(time.Now()))
+	began := time.Now()
+	ctx, span := r.o.startRunSpan(ctx, client.DatabaseName())
+	// Registered first to run last, so that the final error and status are reported, logged and traced.
+	defer func() {
+		r.finish(ctx, span, began, err)
+	}()
+	// In JSON output mode, text output is discarded, and the summary is written when the run finishes.
+	if r.o.jsonOutput {
+		r.jsonOut = newJSONOutput(r.out, client.DatabaseName())
+		r.out = ioutil.Discard
+		defer func() {
+			if err != nil {
+				r.status = "failed"
+			}
+			r.jsonOut.writeSummary(r.status, r.warnings, err)
+		}()
+	}
+	defer printWarnings(r.out, r.warnings)
+
+	// The timeout wraps the error before it's written as the JSON summary, as deferred calls run in reverse order.
+	if r.o.timeout > 0 {
+		parent := ctx
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, r.o.timeout)
+		defer cancel()
+		defer func() {
+			if err != nil && ctx.Err() == context.DeadlineExceeded && parent.Err() == nil {
+				err = &TimeoutError{Timeout: r.o.timeout, Err: err}
+			}
+		}()
+	}
+
+	if r.o.stateDumpTrigger != nil {
+		r.dumper = newStateDumper(client.DatabaseName(), r.warnings)
+		r.dumper.setPhase("analyzing tables")
+		dumpCtx, stopDump := context.WithCancel(ctx)
+		defer stopDump()
+		r.dumper.listen(dumpCtx, r.o.stateDumpTrigger, r.o.stateDumpOut)
+	}
+
+	if err := r.fetchTables(ctx); err != nil {
+		return err
+	}
+	if err := r.filterRows(ctx); err != nil {
+		return err
+	}
+	if err := r.plan(ctx); err != nil {
+		return err
+	}
+	if r.o.dryRun {
+		fmt.Fprintf(r.out, "\nDry run: no rows were deleted.\n")
+		r.status = "dry_run"
+		return nil
+	}
+	if !r.confirm(ctx) {
+		return nil
+	}
+
+	// Indexes and foreign keys dropped before deletion are restored even if the deletion fails or times out.
+	defer func() {
+		err = r.restoreSchema(ctx, err)
+	}()
+	if err := r.execute(ctx); err != nil {
+		return err
+	}
+	return r.finalize(ctx)
+}
+
+// run is the state of a run of RunWithClient, passed from a phase to the next.
+type run struct {
+	client   *spanner.Client
+	o        *options
+	out      io.Writer
+	logger   *slog.Logger
+	emitter  *emitter
+	warnings *warnings
+	jsonOut  *jsonOutput
+	dumper   *stateDumper
+
+	// Status of the run, one of "completed", "dry_run" and "cancelled". It's reported as "failed" if the run fails.
+	status string
+	report *Report
+
+	// Set by fetchTables, filterRows and plan, the tables to delete and what is known about them.
+	targetTables     []string
+	schemas          []*tableSchema
+	indexes          []*indexSchema
+	rowCounts        map[string]int64
+	tableBytes       map[string]int64
+	sizesErr         error
+	skippedTables    map[string]string
+	ttlSkippedTables map[string]string
+	ageSkippedTables map[string]string
+	rowFilter        string // Describes the rows to delete if not all rows are, e.g. "rows of tenant 42".
+	rebuild          *indexRebuild
+	fkDrop           *foreignKeyDrop
+
+	// Set by execute, the deletion and the functions restoring what was dropped before it.
+	coordinator        *coordinator
+	recreateIndexes    func(context.Context) error
+	restoreForeignKeys func(context.Context) error
+}
+
+// newRun sets up a run with the options, which is cancelled unless it reaches the end.
+func newRun(client *spanner.Client, opts []Option) *run {
+	o := newOptions(opts)
+	logger := orDiscard(o.logger).With("database", client.DatabaseName())
+	o.logger = logger
+	emitter := newEmitter(o.eventHandler)
+	emitter.logger = logger
+	emitter.database = client.DatabaseName()
+	return &run{
+		client:   client,
+		o:        o,
+		out:      o.out,
+		logger:   logger,
+		emitter:  emitter,
+		warnings: newWarnings(emitter),
+		status:   "cancelled",
+	}
+}
+
+// finish reports, notifies, traces and logs the result of the run, after all the other phases.
+func (r *run) finish(ctx context.Context, span trace.Span, began time.Time, err error) {
+	client, o := r.client, r.o
+	if r.report != nil {
+		r.report.finish(r.status, r.warnings, err, time.Since(began))
+		o.handleReport(r.report)
+	}
+	if len(o.webhooks) > 0 {
+		// A run failing before listing the tables is notified without them.
+		report := r.report
+		if report == nil {
+			report = &Report{Database: client.DatabaseName(), GeneratedAt: began}
+			report.finish(r.status, r.warnings, err, time.Since(began))
+		}
+		if nerr := notify(context.WithoutCancel(ctx), o.webhooks, report); nerr != nil {
+			r.logger.Warn("notification failed", "error", nerr)
+			fmt.Fprintf(r.out, "WARNING: %v\n", nerr)
+		}
+	}
+	if o.warningsHandler != nil {
+		o.warningsHandler(r.warnings.strings())
+	}
+	span.SetAttributes(attribute.String("truncate.status", r.status))
+	endSpan(span, err)
+	if o.progressReporter != nil {
+		result := RunResult{Status: r.status, Err: err, Elapsed: time.Since(began)}
+		if err != nil {
+			result.Status = "failed"
+		}
+		o.progressReporter.OnRunComplete(result)
+	}
+	completed := Event{Type: EventRunCompleted, Status: r.status}
+	if err != nil {
+		completed.Status = "failed"
+		completed.Message = err.Error()
+	}
+	r.emitter.emit(completed)
+	if err != nil {
+		r.logger.Error("run failed", "error", err)
+		return
+	}
+	r.logger.Info("run finished", "status", r.status)
+}
+
+// fetchTables fetches the schema, and picks the tables to delete by the target and exclude tables.
+func (r *run) fetchTables(ctx context.Context) error {
+	client, o, out, warnings := r.client, r.o, r.out, r.warnings
+	targetTables, excludeTables := o.targetTables, o.excludeTables
 	fmt.Fprintf(out, "Fetching table schema from %s\n", client.DatabaseName())
-	schemas, err := fetchTableSchemas(ctx, client)
+	r.logger.Info("fetching table schema")
+	if o.schemaSnapshot != nil {
+		return errors.New("a schema snapshot can only be planned against, as rows are deleted from the live database")
+	}
+	if len(o.scrubs) > 0 {
+		if len(targetTables) > 0 || len(excludeTables) > 0 || o.keyPrefix != nil || o.tenant != nil || o.olderThan > 0 {
+			return errors.New("scrubbing can't be used with target or exclude tables, a key prefix, a tenant or an age")
+		}
+		for name := range o.scrubs {
+			targetTables = append(targetTables, name)
+		}
+		sort.Strings(targetTables)
+	}
+	if o.keyPrefix != nil {
+		if len(targetTables) > 0 || len(excludeTables) > 0 || o.tenant != nil {
+			return errors.New("a key prefix can't be used with target or exclude tables, or a tenant")
+		}
+		targetTables = []string{o.keyPrefix.tableName}
+	}
+	if o.olderThan > 0 && (o.keyPrefix != nil || o.tenant != nil) {
+		return errors.New("an age can't be used with a key prefix or a tenant")
+	}
+	if o.tenant != nil {
+		if len(targetTables) > 0 || len(excludeTables) > 0 {
+			return errors.New("a tenant can't be used with target or exclude tables")
+		}
+		if len(o.tenant.columns) == 0 {
+			return errors.New("no tables have tenant columns")
+		}
+		targetTables = sortedKeys(o.tenant.columns)
+	}
+	targetTables = qualifyNames(o.schemaScope().defaultSchema(), targetTables)
+	excludeTables = qualifyNames(o.schemaScope().defaultSchema(), excludeTables)
+	if o.backupRetention > 0 && o.onEmulator() {
+		return errors.New("backups are not supported by the emulator")
+	}
+	if o.noRowCounts && o.maxRows != nil {
+		return errors.New("tables can't be skipped by the number of rows without counting rows")
+	}
+	schemaCtx, schemaSpan := startSpan(ctx, "fetch table schema")
+	schemas, fkIgnored, err := fetchTableSchemasOn(schemaCtx, client, o)
+	endSpan(schemaSpan, err)
 	if err != nil {
 		return fmt.Errorf("failed to fetch table schema: %v", err)
 	}
+	if fkIgnored {
+		warnings.add("", "foreign keys are ignored as the emulator doesn't support INFORMATION_SCHEMA.REFERENTIAL_CONSTRAINTS, so tables may be deleted in a wrong order")
+	}
+	// Views and synonyms aren't deleted, but are looked up to resolve or explain names in the table lists.
+	objects, err := fetchSchemaObjects(ctx, client, o.schemaScope())
+	if err != nil && !o.onEmulator() {
+		warnings.add("", "failed to fetch views and synonyms: %v", err)
+	}
+	var synonyms map[string]string
+	targetTables, synonyms = objects.resolveSynonyms(targetTables)
+	for _, name := range sortedKeys(synonyms) {
+		fmt.Fprintf(out, "%s in target tables is a synonym of %s.\n", name, synonyms[name])
+	}
+	excludeTables, synonyms = objects.resolveSynonyms(excludeTables)
+	for _, name := range sortedKeys(synonyms) {
+		fmt.Fprintf(out, "%s in exclude tables is a synonym of %s.\n", name, synonyms[name])
+	}
+
+	targetTables, unmatchedTargets, err := expandTargetTables(targetTables, schemas)
+	if err != nil {
+		return err
+	}
+	excludeTables, unmatchedExcludes, err := expandTablePatterns(excludeTables, schemas)
+	if err != nil {
+		return err
+	}
+	for _, pattern := range unmatchedTargets {
+		warnings.add("", "pattern %s in target tables matches no tables", pattern)
+	}
+	for _, pattern := range unmatchedExcludes {
+		warnings.add("", "pattern %s in exclude tables matches no tables", pattern)
+	}
+	var missingTables []string
+	for _, name := range findUnknownTables(schemas, targetTables) {
+		if objects.isView(name) {
+			warnings.add(name, "view specified in target tables has no rows to delete, skipped")
+			continue
+		}
+		if !o.ignoreMissingTables {
+			missingTables = append(missingTables, fmt.Sprintf("%s: table specified in target tables does not exist%s", name, didYouMean(name, schemas)))
+			continue
+		}
+		warnings.add(name, "table specified in target tables does not exist, skipped%s", didYouMean(name, schemas))
+	}
+	for _, name := range findUnknownTables(schemas, excludeTables) {
+		if objects.isView(name) {
+			warnings.add(name, "view specified in exclude tables is not a table, ignored")
+			continue
+		}
+		if !o.ignoreMissingTables {
+			missingTables = append(missingTables, fmt.Sprintf("%s: table specified in exclude tables does not exist%s", name, didYouMean(name, schemas)))
+			continue
+		}
+		warnings.add(name, "table specified in exclude tables does not exist, ignored%s", didYouMean(name, schemas))
+	}
+	if len(missingTables) > 0 {
+		return fmt.Errorf("unknown tables are specified, no rows were deleted:\n  %s", strings.Join(missingTables, "\n  "))
+	}
 
 	schemas, err = filterTableSchemas(schemas, targetTables, excludeTables)
 	if err != nil {
 		return fmt.Errorf("failed to filter table schema: %v", err)
 	}
+	r.emitter.emit(Event{Type: EventSchemaFetched, Tables: tableSchemaNames(schemas)})
+	r.targetTables, r.schemas = targetTables, schemas
+	return nil
+}
 
-	for _, schema := range schemas {
-		fmt.Fprintf(out, "%s\n", schema.tableName)
+// filterRows narrows the deletion down to rows under the key prefix, of the tenant or older than the age,
+// or turns it into scrubbing columns, and skips tables which aren't to be deleted by their rows.
+func (r *run) filterRows(ctx context.Context) error {
+	client, o, out, warnings := r.client, r.o, r.out, r.warnings
+	var err error
+	if o.keyPrefix != nil && len(r.schemas) > 0 {
+		o.rowFilters, err = keyPrefixFilters(ctx, client, o.keyPrefix, r.targetTables[0], r.schemas)
+		if err != nil {
+			return err
+		}
+		r.rowFilter = fmt.Sprintf("rows under the key prefix %s", o.keyPrefix)
+		fmt.Fprintf(out, "Only %s are deleted from the following tables.\n", r.rowFilter)
+	}
+	if o.tenant != nil && len(r.schemas) > 0 {
+		o.rowFilters, err = tenantFilters(o.tenant, o.schemaScope().defaultSchema(), r.schemas)
+		if err != nil {
+			return err
+		}
+		r.rowFilter = fmt.Sprintf("rows of tenant %v", o.tenant.id)
+		fmt.Fprintf(out, "Only %s are deleted from the following tables.\n", r.rowFilter)
+	}
+	if len(o.scrubs) > 0 && len(r.schemas) > 0 {
+		r.schemas = scrubSchemas(r.schemas, o.scrubs, o.schemaScope().defaultSchema())
+		o.rowFilters = map[string]*rowFilter{}
+		for name, values := range scrubColumns(o.scrubs, o.schemaScope().defaultSchema()) {
+			o.rowFilters[name] = scrubFilter(values)
+			o.statementBuilders[name] = scrubStatementBuilder(values, o.rowFilters[name])
+		}
+		fmt.Fprintf(out, "Columns of the following tables are scrubbed instead of deleting rows.\n")
+	}
+	if o.olderThan > 0 && len(r.schemas) > 0 {
+		commitTimestamps, err := fetchCommitTimestampColumns(ctx, client, o.schemaScope())
+		if err != nil {
+			return fmt.Errorf("failed to fetch commit timestamp columns: %v", err)
+		}
+		var reasons map[string]string
+		o.rowFilters, reasons = ageFilters(r.schemas, commitTimestamps, o.olderThan, o.timestampColumns, o.schemaScope().defaultSchema(), time.Now())
+		r.schemas, r.ageSkippedTables = skipTables(r.schemas, reasons)
+		for _, name := range sortedKeys(r.ageSkippedTables) {
+			warnings.add(name, "SKIPPED because %s", r.ageSkippedTables[name])
+		}
+		r.rowFilter = fmt.Sprintf("rows older than %s", o.olderThan)
+		fmt.Fprintf(out, "Only %s are deleted from the following tables.\n", r.rowFilter)
 	}
-	fmt.Fprintf(out, "\n")
 
-	if !quiet {
-		if !confirm(out, "Rows in these tables will be deleted. Do you want to continue?") {
-			return nil
+	// Row deletion policies are only informative unless TTL tables are skipped, e.g. on an old emulator not supporting them.
+	policies, err := fetchRowDeletionPolicies(ctx, client, o.schemaScope())
+	switch {
+	case err != nil && o.skipTTLTables:
+		warnings.add("", "failed to fetch row deletion policies, no tables are skipped by them: %v", err)
+	case err != nil && !o.onEmulator():
+		warnings.add("", "failed to fetch row deletion policies: %v", err)
+	case err == nil:
+		attachRowDeletionPolicies(r.schemas, policies)
+		if o.skipTTLTables {
+			r.schemas, r.ttlSkippedTables = skipTTLTables(r.schemas)
+			for _, name := range sortedKeys(r.ttlSkippedTables) {
+				warnings.add(name, "SKIPPED because %s", r.ttlSkippedTables[name])
+			}
+		}
+	}
+	return nil
+}
+
+// plan counts rows, plans the order of deletion and prints it, and checks that the deletion can start as planned.
+func (r *run) plan(ctx context.Context) error {
+	client, o, out, warnings := r.client, r.o, r.out, r.warnings
+	r.rowCounts = map[string]int64{}
+	if !o.noRowCounts {
+		countCtx, countSpan := startSpan(ctx, "count rows", attribute.Int("truncate.tables", len(r.schemas)))
+		r.rowCounts = fetchRowCounts(countCtx, client, r.schemas, o.rowFilters, o.requestOptions, warnings)
+		countSpan.End()
+	}
+	// Table sizes are approximate as they come from statistics, which may not be available, e.g. on the emulator.
+	r.tableBytes, r.sizesErr = fetchTableSizes(ctx, client)
+
+	if o.maxRows != nil || o.maxBytes != nil {
+		if o.maxBytes != nil && r.sizesErr != nil {
+			warnings.add("", "failed to fetch table sizes, no tables are skipped by bytes: %v", r.sizesErr)
+		}
+		r.schemas, r.skippedTables = skipLargeTables(r.schemas, r.rowCounts, r.tableBytes, o.maxRows, o.maxBytes)
+		for _, name := range sortedKeys(r.skippedTables) {
+			warnings.add(name, "SKIPPED because %s", r.skippedTables[name])
 		}
-	} else {
-		fmt.Fprintf(out, "Rows in these tables will be deleted.\n")
 	}
 
-	indexes, err := fetchIndexSchemas(ctx, client)
+	var err error
+	r.indexes, err = fetchIndexSchemas(ctx, client, o.schemaScope())
 	if err != nil {
 		return fmt.Errorf("failed to fetch index schema: %v", err)
 	}
+	// Indexes to be rebuilt don't exist during deletion, so they are left out of the plan and the verification.
+	if o.rebuildIndexes {
+		statements, err := fetchDatabaseDDL(ctx, o.adminClient, client.DatabaseName(), o.clientOptions...)
+		if err != nil {
+			return fmt.Errorf("failed to fetch index DDL: %v", err)
+		}
+		r.rebuild, r.indexes = planIndexRebuild(statements, r.indexes, r.schemas)
+	}
+	// Likewise, foreign keys to be dropped don't order the deletion.
+	if o.dropForeignKeys {
+		r.fkDrop = planForeignKeyDrop(r.schemas)
+		r.fkDrop.detach(r.schemas)
+	}
 
-	coordinator, err := newCoordinator(schemas, indexes, client)
+	plan, err := planDeletion(r.schemas, r.indexes, o)
 	if err != nil {
-		return fmt.Errorf("failed to coordinate: %v", err)
+		return fmt.Errorf("failed to plan deletion: %v", err)
 	}
-	coordinator.start(ctx)
+	r.printPlan(ctx, plan)
 
-	// Show progress bars.
-	progress := uiprogress.New()
-	progress.SetOut(out)
-	progress.SetRefreshInterval(time.Millisecond * 500)
-	progress.Start()
-	var maxNameLength int
-	for _, schema := range schemas {
-		if l := len(schema.tableName); l > maxNameLength {
-			maxNameLength = l
+	r.report = newReport(client.DatabaseName(), r.schemas, r.rowCounts, o.dryRun)
+	r.report.BatchRows = o.batchRows
+	r.report.BatchIntervalSeconds = o.batchPause.Seconds()
+	r.report.setSkippedTables(r.skippedTables, r.ttlSkippedTables, r.ageSkippedTables)
+	switch {
+	case len(o.scrubs) > 0:
+		r.report.Operation = operationScrub
+	case r.rowFilter != "":
+		r.report.Operation = operationDeleteFiltered
+		r.report.RowFilter = r.rowFilter
+	default:
+		r.report.Operation = operationDelete
+	}
+	if o.reportDDL {
+		ddls, err := fetchTableDDLs(ctx, o.adminClient, client.DatabaseName(), o.clientOptions...)
+		if err != nil {
+			warnings.add("", "failed to fetch DDL for the report: %v", err)
 		}
+		r.report.setDDLs(ddls)
 	}
-	for _, table := range flattenTables(coordinator.tables) {
-		showProgressBar(progress, table, maxNameLength)
+	if o.baselineReport != nil {
+		fmt.Fprintf(out, "\n")
+		printReportDiff(out, o.baselineReport, diffReports(o.baselineReport, r.report))
 	}
 
-	if err := coordinator.waitCompleted(); err != nil {
-		progress.Stop()
-		return fmt.Errorf("failed to delete: %v", err)
+	livePlan := newPlan(client.DatabaseName(), r.schemas, r.rowCounts, plan)
+	if o.approvedPlan != nil {
+		if diffs := diffPlans(o.approvedPlan, livePlan); len(diffs) > 0 {
+			return fmt.Errorf("the database has drifted from the plan generated at %s, no rows were deleted:\n  %s",
+				o.approvedPlan.GeneratedAt.Format(time.RFC3339), strings.Join(diffs, "\n  "))
+		}
+		fmt.Fprintf(out, "\nThe target tables and the order of deletion match the plan generated at %s.\n", o.approvedPlan.GeneratedAt.Format(time.RFC3339))
+	}
+	if o.planHandler != nil {
+		o.planHandler(livePlan)
 	}
-	// Wait for reflecting the latest progresses to progress bars.
-	time.Sleep(time.Second)
-	progress.Stop()
 
-	fmt.Fprint(out, "\nDone! All rows have been deleted successfully.\n")
+	// Verify permissions before deletion starts, rather than failing in the middle of it.
+	// The emulator doesn't implement IAM, and grants everything.
+	if !o.onEmulator() {
+		missing, err := checkPermissions(ctx, o.adminClient, client.DatabaseName(), requiredPermissions(o, r.schemas), o.clientOptions...)
+		if err != nil {
+			warnings.add("", "failed to verify permissions on the database, continuing anyway: %v", err)
+		} else if len(missing) > 0 {
+			if !o.dryRun {
+				return fmt.Errorf("missing permissions on %s: %s", client.DatabaseName(), strings.Join(missing, ", "))
+			}
+			warnings.add("", "deletion would fail due to missing permissions: %s", strings.Join(missing, ", "))
+		}
+	}
 	return nil
 }
 
-// confirm returns true if a user confirmed the message, otherwise returns false.
-func confirm(out io.Writer, msg string) bool {
-	fmt.Fprintf(out, "%s [Y/n] ", msg)
+// printPlan prints the tables to delete in the order of the plan, and what happens to them besides the deletion.
+func (r *run) printPlan(ctx context.Context, plan *deletionPlan) {
+	o, out := r.o, r.out
+	// Change streams are only informative, so failing to fetch them, e.g. on an old emulator, doesn't stop the run.
+	changeStreams, err := fetchChangeStreams(ctx, r.client)
+	if err != nil && !o.onEmulator() {
+		r.warnings.add("", "failed to fetch change streams: %v", err)
+	}
 
-	s := bufio.NewScanner(os.Stdin)
-	for {
-		s.Scan()
-		switch s.Text() {
-		case "Y":
-			return true
-		case "n":
-			return false
-		default:
-			fmt.Fprint(out, "Please answer Y or n: ")
+	printTables(out, r.schemas, r.indexes, changeStreams, r.rowCounts, r.tableBytes, plan, o.hideEmptyTables)
+	r.jsonOut.writeTables(r.schemas, r.rowCounts, r.tableBytes, plan)
+	fmt.Fprintf(out, "\n")
+	if len(o.scrubs) > 0 {
+		fmt.Fprintf(out, "Tables marked DELETE receive their own UPDATE statements scrubbing the columns, and no rows are deleted.\n")
+	} else {
+		fmt.Fprintf(out, "Tables marked DELETE receive their own DELETE statements, and the others are emptied in cascade by their ancestors.\n")
+	}
+	fmt.Fprintf(out, "%s\n", formatTotals(r.schemas, r.rowCounts, r.tableBytes))
+	if len(r.skippedTables) > 0 {
+		fmt.Fprintf(out, "%d tables are skipped because of their sizes: %s\n", len(r.skippedTables), strings.Join(sortedKeys(r.skippedTables), ", "))
+	}
+	if len(r.ttlSkippedTables) > 0 {
+		fmt.Fprintf(out, "%d tables are skipped because of their row deletion policies: %s\n", len(r.ttlSkippedTables), strings.Join(sortedKeys(r.ttlSkippedTables), ", "))
+	}
+	if len(r.ageSkippedTables) > 0 {
+		fmt.Fprintf(out, "%d tables are skipped because the age of their rows is unknown: %s\n", len(r.ageSkippedTables), strings.Join(sortedKeys(r.ageSkippedTables), ", "))
+	}
+	printChangeStreamEstimates(out, estimateChangeStreams(changeStreams, r.schemas, r.rowCounts, r.tableBytes))
+	if r.rebuild != nil && len(r.rebuild.indexes) > 0 {
+		fmt.Fprintf(out, "%d indexes are dropped before deletion and recreated afterwards: %s\n", len(r.rebuild.indexes), strings.Join(r.rebuild.names(), ", "))
+	}
+	if r.fkDrop != nil && len(r.fkDrop.foreignKeys) > 0 {
+		fmt.Fprintf(out, "%d foreign keys are dropped before deletion and restored afterwards: %s\n", len(r.fkDrop.foreignKeys), strings.Join(r.fkDrop.names(), ", "))
+	}
+	if len(o.seeds) > 0 {
+		names := make([]string, len(o.seeds))
+		for i, s := range o.seeds {
+			names[i] = s.name
 		}
+		fmt.Fprintf(out, "%d seed statements are executed after deletion: %s\n", countSeedStatements(o.seeds), strings.Join(names, ", "))
+	}
+	if o.batched {
+		fmt.Fprintf(out, "Rows are deleted in %s, instead of Partitioned DML.\n", formatBatchSettings(o.batchRows, o.batchPause))
+	} else if o.batchRows > 0 || o.batchPause > 0 {
+		fmt.Fprintf(out, "Tables deleted without Partitioned DML are deleted in %s.\n", formatBatchSettings(o.batchRows, o.batchPause))
 	}
 }
 
+// confirm asks whether to continue with the deletion unless quiet. It returns false if the deletion is declined.
+func (r *run) confirm(ctx context.Context) bool {
+	o, out := r.o, r.out
+	r.dumper.setPhase("waiting for confirmation")
+	action := "Rows in these tables will be deleted."
+	if len(o.scrubs) > 0 {
+		action = "Columns of these tables will be scrubbed."
+	}
+	if o.quiet {
+		fmt.Fprintf(out, "%s\n", action)
+		return true
+	}
+	if o.sampleRows > 0 && confirm(stdin, out, "Show sample rows of the tables before deletion?", false) {
+		showSampleRows(ctx, r.client, out, r.schemas, r.rowCounts, o.sampleRows, r.warnings)
+	}
+	return confirm(stdin, out, action+" Do you want to continue?", false)
+}
+
+// execute backs up the database, drops indexes and foreign keys if requested, and deletes rows from the tables
+// while showing the progress.
+func (r *run) execute(ctx context.Context) error {
+	client, o, out, logger := r.client, r.o, r.out, r.logger
+	if o.backupRetention > 0 {
+		r.dumper.setPhase("creating backup")
+		logger.Info("creating backup", "retention", o.backupRetention)
+		backup, err := createBackup(ctx, o.adminClient, out, client.DatabaseName(), o.backupRetention, o.clientOptions...)
+		if err != nil {
+			return fmt.Errorf("failed to create backup, no rows were deleted: %v", err)
+		}
+		logger.Info("backup created", "backup", backup)
+	}
+
+	if r.rebuild != nil && len(r.rebuild.indexes) > 0 {
+		r.dumper.setPhase("dropping indexes")
+		logger.Info("dropping indexes", "indexes", len(r.rebuild.indexes))
+		recreate, err := r.rebuild.drop(ctx, o.adminClient, out, client.DatabaseName(), o.clientOptions...)
+		if err != nil {
+			return fmt.Errorf("failed to drop indexes, no rows were deleted: %v", err)
+		}
+		r.recreateIndexes = recreate
+	}
+
+	if r.fkDrop != nil && len(r.fkDrop.foreignKeys) > 0 {
+		r.dumper.setPhase("dropping foreign keys")
+		logger.Info("dropping foreign keys", "foreign_keys", len(r.fkDrop.foreignKeys))
+		restore, err := r.fkDrop.drop(ctx, client, o.adminClient, out, o.clientOptions...)
+		if err != nil {
+			return fmt.Errorf("failed to drop foreign keys, no rows were deleted: %v", err)
+		}
+		r.restoreForeignKeys = restore
+	}
+
+	// Ask what to do on a table failure in interactive mode, pausing progress bars while prompting.
+	var bars *progressBars
+	if o.failureHandler == nil && !o.quiet && !o.continueOnError && !o.failFast {
+		o.failureHandler = func(tableName string, err error) FailureAction {
+			var action FailureAction
+			bars.pause(func() {
+				action = askFailureAction(stdin, out, tableName, err)
+			})
+			return action
+		}
+	}
+
+	coordinator, err := newCoordinator(r.schemas, r.indexes, client, r.warnings, r.emitter, o)
+	if err != nil {
+		return fmt.Errorf("failed to coordinate: %v", err)
+	}
+	r.coordinator = coordinator
+	if o.statsProgress {
+		if r.sizesErr != nil {
+			r.warnings.add("", "failed to fetch table sizes, progress is tracked by counting rows instead: %v", r.sizesErr)
+		}
+		coordinator.statsProgress = &statsProgress{client: client, rowCounts: r.rowCounts, initialBytes: r.tableBytes}
+	}
+	if o.metrics != nil {
+		coordinator.metrics = o.metrics.addDatabase(client.DatabaseName(), coordinator.tables)
+	}
+	if o.skipEmptyTables {
+		empty := emptyTablesFromCounts(r.rowCounts)
+		if o.noRowCounts {
+			empty = fetchEmptyTables(ctx, client, r.schemas, o.rowFilters, o.requestOptions)
+		}
+		for _, name := range coordinator.skipEmptyTables(empty) {
+			fmt.Fprintf(out, "%s: skipped (already empty)\n", name)
+		}
+	}
+
+	r.jsonOut.setTables(coordinator.tables)
+	if o.tablesHandler != nil {
+		o.tablesHandler(coordinator.tables)
+	}
+	mode := progressModeBars
+	switch {
+	case o.jsonOutput:
+		mode = progressModeJSON
+	case o.hideProgressBars:
+		mode = progressModeHidden
+	case o.plainProgress, o.noRowCounts:
+		// Without row counts, there's nothing to draw bars with.
+		mode = progressModeLines
+	}
+	bars = newProgressBars(out, coordinator.tables, mode)
+	bars.json = r.jsonOut
+	bars.start()
+	reports := startProgressReports(o.progressReporter, coordinator.tables)
+	r.dumper.setPhase("deleting rows")
+	r.dumper.setCoordinator(coordinator)
+	logger.Info("deleting rows", "tables", len(r.schemas))
+	deleteCtx, deleteSpan := startSpan(ctx, "delete rows", attribute.Int("truncate.tables", len(r.schemas)))
+	coordinator.start(deleteCtx)
+
+	err = coordinator.waitCompleted()
+	coordinator.endWaveSpans()
+	reports.stop()
+	r.report.setTableResults(coordinator.tables)
+	endSpan(deleteSpan, err)
+	if err != nil {
+		bars.stop()
+		if ctx.Err() != nil {
+			printPartialSummary(out, coordinator.tables)
+		}
+		return fmt.Errorf("failed to delete: %w", err)
+	}
+	if mode == progressModeBars {
+		// Wait for reflecting the latest progresses to progress bars.
+		time.Sleep(time.Second)
+	}
+	bars.stop()
+	return nil
+}
+
+// finalize verifies indexes and seeds the tables after the deletion, and prints the summary of the completed run.
+func (r *run) finalize(ctx context.Context) error {
+	o, out := r.o, r.out
+	if o.verifyIndexes {
+		verifyIndexes(ctx, r.client, out, indexesToVerify(r.indexes, r.coordinator.tables), r.warnings)
+	}
+	if len(o.seeds) > 0 {
+		r.dumper.setPhase("seeding")
+		r.logger.Info("seeding", "statements", countSeedStatements(o.seeds))
+		fmt.Fprintf(out, "\n")
+		if err := runSeeds(ctx, r.client, out, o.seeds, o.requestOptions); err != nil {
+			return fmt.Errorf("rows were deleted, but %w", err)
+		}
+	}
+	if len(o.scrubs) > 0 {
+		fmt.Fprint(out, "\nDone! Columns have been scrubbed successfully.\n")
+	} else {
+		printCompleted(out, r.coordinator)
+	}
+	printBatchedTables(out, r.coordinator.tables, o.batchRows, o.batchPause)
+	printRetryStats(out, r.coordinator.tables)
+	r.status = "completed"
+	return nil
+}
+
+// restoreSchema restores the foreign keys, and then recreates the indexes dropped by execute, if any.
+// They are restored even if the run failed or timed out, so the context isn't cancelled with the run.
+// Errors restoring them are joined to err, which fails the run otherwise completed.
+func (r *run) restoreSchema(ctx context.Context, err error) error {
+	ctx = context.WithoutCancel(ctx)
+	if r.restoreForeignKeys != nil {
+		r.dumper.setPhase("restoring foreign keys")
+		r.logger.Info("restoring foreign keys", "foreign_keys", len(r.fkDrop.foreignKeys))
+		err = r.joinRestoreError(err, r.restoreForeignKeys(ctx))
+	}
+	if r.recreateIndexes != nil {
+		r.dumper.setPhase("recreating indexes")
+		r.logger.Info("recreating indexes", "indexes", len(r.rebuild.indexes))
+		err = r.joinRestoreError(err, r.recreateIndexes(ctx))
+	}
+	return err
+}
+
+// joinRestoreError joins the error restoring the schema to the error of the run. The run fails if only restoring failed.
+func (r *run) joinRestoreError(err, rerr error) error {
+	switch {
+	case rerr == nil:
+		return err
+	case err != nil:
+		return fmt.Errorf("%w, and %v", err, rerr)
+	}
+	r.status = "failed"
+	return rerr
+}
+
+func printCompleted(out io.Writer, coordinator *coordinator) {
+	if hasSkippedTables(coordinator.tables) {
+		fmt.Fprint(out, "\nDone! Rows have been deleted except for the skipped tables. See the warnings below.\n")
+	} else {
+		fmt.Fprint(out, "\nDone! All rows have been deleted successfully.\n")
+	}
+	if n := countAlreadyEmptyTables(coordinator.tables); n > 0 {
+		fmt.Fprintf(out, "%d tables were skipped as they were already empty.\n", n)
+	}
+}
+
+// printBatchedTables prints the tables deleted in read-write transactions instead of PDML with the batch settings, if any.
+func printBatchedTables(out io.Writer, tables []*table, batchRows int64, interval time.Duration) {
+	var names []string
+	for _, t := range flattenTables(tables) {
+		if t.deleter.strategy == strategyBatched || t.deleter.strategy == strategyLeavesFirst {
+			names = append(names, t.tableName)
+		}
+	}
+	if len(names) == 0 {
+		return
+	}
+	fmt.Fprintf(out, "%d tables were deleted in %s: %s\n", len(names), formatBatchSettings(batchRows, interval), strings.Join(names, ", "))
+}
+
+// printRetryStats prints the number of retries of each table, if any retries occurred.
+func printRetryStats(out io.Writer, tables []*table) {
+	var lines []string
+	for _, t := range flattenTables(tables) {
+		if stats := t.deleter.retries.stats(); !stats.isZero() {
+			lines = append(lines, fmt.Sprintf("  %s: %s\n", t.tableName, stats))
+		}
+	}
+	if len(lines) == 0 {
+		return
+	}
+	fmt.Fprint(out, "\nRetries (the deletion may have been contending with other traffic):\n")
+	for _, line := range lines {
+		fmt.Fprint(out, line)
+	}
+}
+
+// printWarnings prints warnings recorded during the run, if any.
+func printWarnings(out io.Writer, warnings *warnings) {
+	list := warnings.all()
+	if len(list) == 0 {
+		return
+	}
+	fmt.Fprintf(out, "\nWarnings:\n")
+	for _, w := range list {
+		fmt.Fprintf(out, "  - %s\n", w.String())
+	}
+}
+
+// progressBars shows progress bars of tables, which can be paused to prompt a user.
+// In lines mode, progress is printed as plain lines instead, for terminals which can't render progress bars.
+type progressBars struct {
+	out           io.Writer
+	json          *jsonOutput // Set in JSON mode.
+	tables        []*table
+	maxNameLength int
+	mode          progressMode
+
+	mu       sync.Mutex // Guards progress and lines.
+	progress *uiprogress.Progress
+	lines    *progressLines
+
+	// Estimates the remaining time of the run. Kept while progress bars are recreated.
+	rate rateTracker
+}
+
+// progressMode is how the progress of deletion is shown.
+type progressMode int
+
+const (
+	progressModeBars   progressMode = iota // Progress bars redrawn in place.
+	progressModeLines                      // Plain lines printed on changes.
+	progressModeJSON                       // JSON records written on changes.
+	progressModeHidden                     // Nothing is shown.
+)
+
+func newProgressBars(out io.Writer, tables []*table, mode progressMode) *progressBars {
+	var maxNameLength int
+	for _, table := range flattenTables(tables) {
+		if l := len(table.tableName); l > maxNameLength {
+			maxNameLength = l
+		}
+	}
+	return &progressBars{out: out, tables: tables, maxNameLength: maxNameLength, mode: mode}
+}
+
+func (p *progressBars) start() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.startLocked()
+}
+
+func (p *progressBars) stop() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.stopLocked()
+}
+
+// pause stops rendering progress bars while f is running.
+// As a stopped uiprogress.Progress can't be restarted, progress bars are recreated after f.
+func (p *progressBars) pause(f func()) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.stopLocked()
+	f()
+	p.startLocked()
+}
+
+func (p *progressBars) startLocked() {
+	switch p.mode {
+	case progressModeHidden:
+		return
+	case progressModeLines, progressModeJSON:
+		p.lines = startProgressLines(p.out, p.json, p.tables, p.maxNameLength, &p.rate)
+		return
+	}
+	p.progress = uiprogress.New()
+	p.progress.SetOut(p.out)
+	p.progress.SetRefreshInterval(time.Millisecond * 500)
+	p.progress.Start()
+	tables := flattenTables(p.tables)
+	for _, table := range tables {
+		if table.deleter.alreadyEmpty {
+			continue
+		}
+		showProgressBar(p.progress, table, p.maxNameLength)
+	}
+	if len(tables) > 1 {
+		showOverallProgressBar(p.progress, tables, p.maxNameLength, &p.rate)
+	}
+}
+
+func (p *progressBars) stopLocked() {
+	if p.lines != nil {
+		p.lines.stop()
+		p.lines = nil
+	}
+	if p.progress == nil {
+		return
+	}
+	p.progress.Stop()
+	p.progress = nil
+}
+
 func showProgressBar(progress *uiprogress.Progress, table *table, maxNameLength int) {
 	bar := progress.AddBar(100)
 	bar.PrependFunc(func(b *uiprogress.Bar) string {
@@ -152,13 +911,21 @@ func showProgressBar(progress *uiprogress.Progress, table *table, maxNameLength
 			s = "deleting " // append space for alignment
 		case statusCompleted:
 			s = "completed"
+		case statusFailed:
+			s = "failed   " // append space for alignment
+		case statusSkipped:
+			s = "skipped  " // append space for alignment
 		}
 		return fmt.Sprintf("%-*s%s", maxNameLength+2, table.tableName+": ", s)
 	})
 	bar.AppendCompleted()
 	bar.AppendFunc(func(b *uiprogress.Bar) string {
 		deletedRows := table.deleter.totalRows - table.deleter.remainedRows
-		return fmt.Sprintf("(%s / %s)", formatNumber(deletedRows), formatNumber(table.deleter.totalRows))
+		s := fmt.Sprintf("(%s / %s)", formatNumber(deletedRows), formatNumber(table.deleter.totalRows))
+		if eta, ok := table.deleter.estimateRemaining(time.Now()); ok {
+			s += " " + formatETA(eta)
+		}
+		return s
 	})
 
 	// HACK: We call progressBar.Incr() to start timer in the progress bar.
@@ -173,7 +940,7 @@ func showProgressBar(progress *uiprogress.Progress, table *table, maxNameLength
 				// Increment the progress bar until it reaches 100
 				for bar.Incr() {
 				}
-			case statusAnalyzing:
+			case statusAnalyzing, statusFailed, statusSkipped:
 				// nop
 			default:
 				deletedRows := table.deleter.totalRows - table.deleter.remainedRows
@@ -187,3 +954,47 @@ func showProgressBar(progress *uiprogress.Progress, table *table, maxNameLength
 		}
 	}()
 }
+
+// showOverallProgressBar shows a progress bar of the rows of all the tables, with the estimated remaining time of the run.
+func showOverallProgressBar(progress *uiprogress.Progress, tables []*table, maxNameLength int, rate *rateTracker) {
+	bar := progress.AddBar(100)
+	bar.PrependFunc(func(b *uiprogress.Bar) string {
+		elapsed := int(b.TimeElapsed().Seconds())
+		return fmt.Sprintf("%5ds", elapsed)
+	})
+	bar.PrependFunc(func(b *uiprogress.Bar) string {
+		var done int
+		for _, table := range tables {
+			if table.deleter.isDone() {
+				done++
+			}
+		}
+		return fmt.Sprintf("%-*s%d/%d done", maxNameLength+2, "total: ", done, len(tables))
+	})
+	bar.AppendCompleted()
+	bar.AppendFunc(func(b *uiprogress.Bar) string {
+		deleted, total := overallRows(tables)
+		s := fmt.Sprintf("(%s / %s)", formatNumber(deleted), formatNumber(total))
+		if eta, ok := estimateOverallRemaining(rate, time.Now(), tables); ok {
+			s += " " + formatETA(eta)
+		}
+		return s
+	})
+
+	// HACK: We call progressBar.Incr() to start timer in the progress bar.
+	bar.Set(-1)
+	bar.Incr()
+
+	// Update progress periodically.
+	go func() {
+		for {
+			if deleted, total := overallRows(tables); total > 0 {
+				target := int(float64(deleted) / float64(total) * 100)
+				for i := bar.Current(); i < target; i++ {
+					bar.Incr()
+				}
+			}
+			time.Sleep(time.Second * 1)
+		}
+	}()
+}
@@ -0,0 +1,144 @@
+//
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package truncate
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"cloud.google.com/go/spanner"
+	structpb "github.com/golang/protobuf/ptypes/struct"
+)
+
+const (
+	// Maximum number of columns shown in sample rows. The leading columns are usually the primary key.
+	maxSampleColumns = 6
+
+	// Maximum length of a value shown in sample rows.
+	maxSampleValueLength = 32
+)
+
+// WithSampleRows offers to show up to n rows of each non-empty target table before confirmation,
+// as a last sanity check that the tables hold the data you think they do.
+// It has no effect in quiet mode.
+func WithSampleRows(n int) Option {
+	return func(o *options) {
+		o.sampleRows = n
+	}
+}
+
+// sampleRows is a few rows sampled from a table, formatted for display.
+type sampleRows struct {
+	columns []string
+	rows    [][]string
+}
+
+// fetchSampleRows fetches up to limit rows from the table, keeping only the leading columns.
+func fetchSampleRows(ctx context.Context, client *spanner.Client, tableName string, limit int) (*sampleRows, error) {
+	stmt := spanner.NewStatement(fmt.Sprintf("SELECT * FROM %s LIMIT %d", quoteIdentifier(tableName), limit))
+	sample := &sampleRows{}
+
+	// Use stale read to minimize the impact on the leader replica.
+	txn := client.Single().WithTimestampBound(spanner.ExactStaleness(time.Second))
+	if err := txn.Query(ctx, stmt).Do(func(r *spanner.Row) error {
+		n := r.Size()
+		if n > maxSampleColumns {
+			n = maxSampleColumns
+		}
+		if sample.columns == nil {
+			sample.columns = r.ColumnNames()[:n]
+		}
+		row := make([]string, n)
+		for i := 0; i < n; i++ {
+			var v spanner.GenericColumnValue
+			if err := r.Column(i, &v); err != nil {
+				return err
+			}
+			row[i] = formatSampleValue(v.Value)
+		}
+		sample.rows = append(sample.rows, row)
+		return nil
+	}); err != nil {
+		return nil, newTableError(tableName, OpSample, stmt.SQL, err)
+	}
+	return sample, nil
+}
+
+// formatSampleValue formats a column value into a short single line.
+func formatSampleValue(v *structpb.Value) string {
+	var s string
+	switch k := v.GetKind().(type) {
+	case *structpb.Value_NullValue:
+		s = "NULL"
+	case *structpb.Value_StringValue:
+		s = k.StringValue
+	case *structpb.Value_BoolValue:
+		s = strconv.FormatBool(k.BoolValue)
+	case *structpb.Value_NumberValue:
+		s = strconv.FormatFloat(k.NumberValue, 'g', -1, 64)
+	case *structpb.Value_ListValue:
+		values := make([]string, len(k.ListValue.GetValues()))
+		for i, e := range k.ListValue.GetValues() {
+			values[i] = formatSampleValue(e)
+		}
+		s = "[" + strings.Join(values, ", ") + "]"
+	default:
+		s = v.String()
+	}
+
+	s = strings.Join(strings.Fields(s), " ")
+	if r := []rune(s); len(r) > maxSampleValueLength {
+		s = string(r[:maxSampleValueLength-3]) + "..."
+	}
+	return s
+}
+
+// printSampleRows prints the sample rows of the table as a table.
+func printSampleRows(out io.Writer, tableName string, sample *sampleRows, rowCount int64) {
+	fmt.Fprintf(out, "\n%s (%d of %s rows):\n", tableName, len(sample.rows), formatNumber(uint64(rowCount)))
+	if len(sample.rows) == 0 {
+		return
+	}
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "  %s\n", strings.Join(sample.columns, "\t"))
+	for _, row := range sample.rows {
+		fmt.Fprintf(w, "  %s\n", strings.Join(row, "\t"))
+	}
+	w.Flush()
+}
+
+// showSampleRows prints sample rows of the non-empty tables. Tables which can't be sampled are reported as warnings.
+func showSampleRows(ctx context.Context, client *spanner.Client, out io.Writer, schemas []*tableSchema, rowCounts map[string]int64, limit int, warnings *warnings) {
+	for _, schema := range schemas {
+		count, ok := rowCounts[schema.tableName]
+		if !ok || count == 0 {
+			continue
+		}
+		sample, err := fetchSampleRows(ctx, client, schema.tableName, limit)
+		if err != nil {
+			warnings.add(schema.tableName, "failed to sample rows: %v", err)
+			continue
+		}
+		printSampleRows(out, schema.tableName, sample, count)
+	}
+	fmt.Fprintf(out, "\n")
+}
@@ -0,0 +1,100 @@
+//
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package truncate
+
+import (
+	"bytes"
+	"testing"
+
+	structpb "github.com/golang/protobuf/ptypes/struct"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestFormatSampleValue(t *testing.T) {
+	for _, test := range []struct {
+		desc string
+		v    *structpb.Value
+		want string
+	}{
+		{
+			desc: "null",
+			v:    &structpb.Value{Kind: &structpb.Value_NullValue{}},
+			want: "NULL",
+		},
+		{
+			desc: "string",
+			v:    &structpb.Value{Kind: &structpb.Value_StringValue{StringValue: "Marc"}},
+			want: "Marc",
+		},
+		{
+			desc: "multiline string",
+			v:    &structpb.Value{Kind: &structpb.Value_StringValue{StringValue: "line 1\n  line 2"}},
+			want: "line 1 line 2",
+		},
+		{
+			desc: "long string",
+			v:    &structpb.Value{Kind: &structpb.Value_StringValue{StringValue: "abcdefghijklmnopqrstuvwxyz0123456789"}},
+			want: "abcdefghijklmnopqrstuvwxyz012...",
+		},
+		{
+			desc: "bool",
+			v:    &structpb.Value{Kind: &structpb.Value_BoolValue{BoolValue: true}},
+			want: "true",
+		},
+		{
+			desc: "number",
+			v:    &structpb.Value{Kind: &structpb.Value_NumberValue{NumberValue: 1.5}},
+			want: "1.5",
+		},
+		{
+			desc: "list",
+			v: &structpb.Value{Kind: &structpb.Value_ListValue{ListValue: &structpb.ListValue{Values: []*structpb.Value{
+				{Kind: &structpb.Value_StringValue{StringValue: "1"}},
+				{Kind: &structpb.Value_NullValue{}},
+			}}}},
+			want: "[1, NULL]",
+		},
+	} {
+		t.Run(test.desc, func(t *testing.T) {
+			if got := formatSampleValue(test.v); got != test.want {
+				t.Errorf("formatSampleValue() = %q, but want %q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestPrintSampleRows(t *testing.T) {
+	sample := &sampleRows{
+		columns: []string{"SingerId", "FirstName"},
+		rows: [][]string{
+			{"1", "Marc"},
+			{"2", "Catalina"},
+		},
+	}
+	var out bytes.Buffer
+	printSampleRows(&out, "Singers", sample, 6000)
+
+	want := `
+Singers (2 of 6,000 rows):
+  SingerId  FirstName
+  1         Marc
+  2         Catalina
+`
+	if got := out.String(); got != want {
+		t.Errorf("diff(+got, -want) = %v", cmp.Diff(got, want))
+	}
+}
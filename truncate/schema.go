@@ -0,0 +1,139 @@
+//
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package truncate
+
+import (
+	"context"
+
+	"cloud.google.com/go/spanner"
+)
+
+// TableSchema represents metadata and relationships of a table.
+type TableSchema struct {
+	// Name of the table.
+	Name string
+
+	// Name of the table which this table is interleaved in. Blank for top-level tables.
+	ParentTableName string
+
+	// Action on deleting rows of the parent table, "CASCADE" or "NO ACTION". Blank for top-level tables.
+	ParentOnDeleteAction string
+
+	// Names of the tables which reference this table with foreign keys.
+	ReferencedBy []string
+}
+
+// IndexSchema represents metadata of a secondary index.
+type IndexSchema struct {
+	// Name of the index.
+	Name string
+
+	// Name of the table on which the index is defined.
+	TableName string
+
+	// Name of the table which the index is interleaved in. Blank for global indexes.
+	ParentTableName string
+
+	// Type of the index, "INDEX" or "SEARCH".
+	Type string
+}
+
+// FetchTableSchemas fetches schemas of the tables in the database.
+// If targetTables is not empty, it returns only the specified tables and their descendants deleted in cascade.
+// If excludeTables is not empty, it excludes the specified tables and their ancestors which delete them in cascade.
+// Table names and patterns are expanded as Run does, but unlike Run, synonyms aren't resolved and table names which
+// match no tables are ignored instead of failing, so the result may differ from the list of tables which Run deletes
+// rows from.
+// Options other than WithSchemas, WithAllSchemas and WithSchemaSnapshot are ignored.
+func FetchTableSchemas(ctx context.Context, client *spanner.Client, targetTables, excludeTables []string, opts ...Option) ([]*TableSchema, error) {
+	schemas, err := fetchFilteredTableSchemas(ctx, client, targetTables, excludeTables, newOptions(opts))
+	if err != nil {
+		return nil, err
+	}
+
+	tables := make([]*TableSchema, len(schemas))
+	for i, schema := range schemas {
+		tables[i] = schema.export()
+	}
+	return tables, nil
+}
+
+// fetchFilteredTableSchemas fetches schemas of the tables filtered as FetchTableSchemas does.
+func fetchFilteredTableSchemas(ctx context.Context, client *spanner.Client, targetTables, excludeTables []string, o *options) ([]*tableSchema, error) {
+	targetTables = qualifyNames(o.schemaScope().defaultSchema(), targetTables)
+	excludeTables = qualifyNames(o.schemaScope().defaultSchema(), excludeTables)
+	schemas, _, err := loadTableSchemas(ctx, client, o)
+	if err != nil {
+		return nil, err
+	}
+	if targetTables, _, err = expandTargetTables(targetTables, schemas); err != nil {
+		return nil, err
+	}
+	if excludeTables, _, err = expandTablePatterns(excludeTables, schemas); err != nil {
+		return nil, err
+	}
+	return filterTableSchemas(schemas, targetTables, excludeTables)
+}
+
+// FetchIndexSchemas fetches schemas of the secondary indexes, including search indexes, in the database.
+// The indexes are filtered by their tables with the same rule as FetchTableSchemas.
+func FetchIndexSchemas(ctx context.Context, client *spanner.Client, targetTables, excludeTables []string, opts ...Option) ([]*IndexSchema, error) {
+	tables, err := FetchTableSchemas(ctx, client, targetTables, excludeTables, opts...)
+	if err != nil {
+		return nil, err
+	}
+	isTarget := make(map[string]bool, len(tables))
+	for _, t := range tables {
+		isTarget[t.Name] = true
+	}
+
+	schemas, err := loadIndexSchemas(ctx, client, newOptions(opts))
+	if err != nil {
+		return nil, err
+	}
+
+	var indexes []*IndexSchema
+	for _, schema := range schemas {
+		if isTarget[schema.baseTableName] {
+			indexes = append(indexes, schema.export())
+		}
+	}
+	return indexes, nil
+}
+
+func (t *tableSchema) export() *TableSchema {
+	return &TableSchema{
+		Name:                 t.tableName,
+		ParentTableName:      t.parentTableName,
+		ParentOnDeleteAction: deleteActionName(t.parentOnDeleteAction),
+		ReferencedBy:         append([]string(nil), t.referencedBy...),
+	}
+}
+
+func (i *indexSchema) export() *IndexSchema {
+	typ := "INDEX"
+	if i.isSearch {
+		typ = "SEARCH"
+	}
+
+	return &IndexSchema{
+		Name:            i.indexName,
+		TableName:       i.baseTableName,
+		ParentTableName: i.parentTableName,
+		Type:            typ,
+	}
+}
@@ -0,0 +1,99 @@
+//
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package truncate
+
+import (
+	"context"
+	"slices"
+
+	"cloud.google.com/go/spanner"
+)
+
+// schemaObjects are objects in the database other than base tables, which may be named in table lists by mistake.
+// Rows can't be deleted from views, and synonyms are alternative names of base tables.
+type schemaObjects struct {
+	views    map[string]bool
+	synonyms map[string]string // Base table names keyed by the synonyms.
+}
+
+// fetchSchemaObjects fetches views and synonyms in the scope.
+// Synonyms are fetched from INFORMATION_SCHEMA.TABLE_SYNONYMS, which may not be available, e.g. on the emulator.
+// The views fetched so far are returned with the error then.
+func fetchSchemaObjects(ctx context.Context, client *spanner.Client, scope schemaScope) (*schemaObjects, error) {
+	objects := &schemaObjects{views: map[string]bool{}, synonyms: map[string]string{}}
+	iter := client.Single().Query(ctx, spanner.Statement{
+		SQL: `
+		SELECT TABLE_SCHEMA, TABLE_NAME FROM INFORMATION_SCHEMA.TABLES
+		WHERE TABLE_CATALOG = '' AND TABLE_TYPE = 'VIEW' AND (@all OR TABLE_SCHEMA IN UNNEST(@schemas));
+	`,
+		Params: scope.params(),
+	})
+	if err := iter.Do(func(r *spanner.Row) error {
+		var schema, viewName string
+		if err := r.Columns(&schema, &viewName); err != nil {
+			return err
+		}
+		objects.views[qualifyName(schema, viewName)] = true
+		return nil
+	}); err != nil {
+		return objects, err
+	}
+
+	iter = client.Single().Query(ctx, spanner.Statement{
+		SQL: `
+		SELECT SYNONYM_SCHEMA, SYNONYM_TABLE_NAME, TABLE_SCHEMA, TABLE_NAME FROM INFORMATION_SCHEMA.TABLE_SYNONYMS
+		WHERE TABLE_CATALOG = '' AND (@all OR TABLE_SCHEMA IN UNNEST(@schemas));
+	`,
+		Params: scope.params(),
+	})
+	if err := iter.Do(func(r *spanner.Row) error {
+		var synonymSchema, synonym, tableSchema, tableName string
+		if err := r.Columns(&synonymSchema, &synonym, &tableSchema, &tableName); err != nil {
+			return err
+		}
+		objects.synonyms[qualifyName(synonymSchema, synonym)] = qualifyName(tableSchema, tableName)
+		return nil
+	}); err != nil {
+		return objects, err
+	}
+	return objects, nil
+}
+
+// resolveSynonyms replaces synonyms in the table names with their base tables, and returns the synonyms resolved.
+// Names appearing twice after resolution are kept once.
+func (s *schemaObjects) resolveSynonyms(names []string) ([]string, map[string]string) {
+	if s == nil || len(s.synonyms) == 0 {
+		return names, nil
+	}
+	resolved := map[string]string{}
+	var tableNames []string
+	for _, name := range names {
+		if table, ok := s.synonyms[name]; ok {
+			resolved[name] = table
+			name = table
+		}
+		if !slices.Contains(tableNames, name) {
+			tableNames = append(tableNames, name)
+		}
+	}
+	return tableNames, resolved
+}
+
+// isView reports whether the name is a view.
+func (s *schemaObjects) isView(name string) bool {
+	return s != nil && s.views[name]
+}
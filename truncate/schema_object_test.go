@@ -0,0 +1,82 @@
+//
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package truncate
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestResolveSynonyms(t *testing.T) {
+	objects := &schemaObjects{
+		views:    map[string]bool{"SingerNames": true},
+		synonyms: map[string]string{"Artists": "Singers", "analytics.Logs": "analytics.Events"},
+	}
+	for _, test := range []struct {
+		desc         string
+		objects      *schemaObjects
+		names        []string
+		want         []string
+		wantResolved map[string]string
+	}{
+		{
+			desc:    "no objects",
+			objects: nil,
+			names:   []string{"Artists", "Albums"},
+			want:    []string{"Artists", "Albums"},
+		},
+		{
+			desc:         "synonyms",
+			objects:      objects,
+			names:        []string{"Artists", "Albums", "analytics.Logs", "SingerNames"},
+			want:         []string{"Singers", "Albums", "analytics.Events", "SingerNames"},
+			wantResolved: map[string]string{"Artists": "Singers", "analytics.Logs": "analytics.Events"},
+		},
+		{
+			desc:         "synonym and its table",
+			objects:      objects,
+			names:        []string{"Singers", "Artists"},
+			want:         []string{"Singers"},
+			wantResolved: map[string]string{"Artists": "Singers"},
+		},
+	} {
+		t.Run(test.desc, func(t *testing.T) {
+			got, resolved := test.objects.resolveSynonyms(test.names)
+			if !cmp.Equal(got, test.want) {
+				t.Errorf("names: diff(+got, -want) = %v", cmp.Diff(got, test.want))
+			}
+			if !cmp.Equal(resolved, test.wantResolved) {
+				t.Errorf("resolved: diff(+got, -want) = %v", cmp.Diff(resolved, test.wantResolved))
+			}
+		})
+	}
+}
+
+func TestSchemaObjectsIsView(t *testing.T) {
+	objects := &schemaObjects{views: map[string]bool{"SingerNames": true}}
+	if !objects.isView("SingerNames") {
+		t.Errorf("isView(SingerNames) = false, want true")
+	}
+	if objects.isView("Singers") {
+		t.Errorf("isView(Singers) = true, want false")
+	}
+	var none *schemaObjects
+	if none.isView("SingerNames") {
+		t.Errorf("isView(SingerNames) on nil = true, want false")
+	}
+}
@@ -0,0 +1,53 @@
+//
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package truncate
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestTableSchemaExport(t *testing.T) {
+	for _, tt := range []struct {
+		desc  string
+		input *tableSchema
+		want  *TableSchema
+	}{
+		{
+			desc:  "Top-level table",
+			input: &tableSchema{tableName: "Singers", referencedBy: []string{"Concerts"}},
+			want:  &TableSchema{Name: "Singers", ReferencedBy: []string{"Concerts"}},
+		},
+		{
+			desc:  "Interleaved table with cascade delete",
+			input: &tableSchema{tableName: "Albums", parentTableName: "Singers", parentOnDeleteAction: deleteActionCascadeDelete},
+			want:  &TableSchema{Name: "Albums", ParentTableName: "Singers", ParentOnDeleteAction: "CASCADE"},
+		},
+		{
+			desc:  "Interleaved table with no action",
+			input: &tableSchema{tableName: "Albums", parentTableName: "Singers", parentOnDeleteAction: deleteActionNoAction},
+			want:  &TableSchema{Name: "Albums", ParentTableName: "Singers", ParentOnDeleteAction: "NO ACTION"},
+		},
+	} {
+		t.Run(tt.desc, func(t *testing.T) {
+			if diff := cmp.Diff(tt.input.export(), tt.want); diff != "" {
+				t.Errorf("mismatch (-got +want):\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,91 @@
+//
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package truncate
+
+import (
+	"fmt"
+	"strings"
+
+	"cloud.google.com/go/spanner"
+)
+
+// WithScrub scrubs the columns of the table instead of deleting its rows, e.g. to anonymize personal data of
+// a database cloned from production. values maps the column names to SQL expressions they are set to, e.g. "NULL",
+// "'redacted'" or "CONCAT('user', CAST(UserId AS STRING), '@example.com')", which may refer to other columns of the row.
+// Once any table is scrubbed, the run scrubs only such tables with Partitioned DML and deletes no rows at all.
+// Counts and the progress count the rows whose columns don't have the values yet.
+// It can't be used with WithTables, WithExcludeTables, WithKeyPrefix, WithTenant or WithOlderThan.
+func WithScrub(tableName string, values map[string]string) Option {
+	return func(o *options) {
+		if o.scrubs[tableName] == nil {
+			o.scrubs[tableName] = map[string]string{}
+		}
+		for column, value := range values {
+			o.scrubs[tableName][column] = value
+		}
+	}
+}
+
+// scrubSchemas returns the schemas of the tables to be scrubbed, qualified with the schema if they aren't.
+// No rows are deleted in cascade and no rows prevent others from being updated, so the tables are detached from
+// their parents and foreign keys, and each table receives its own statement.
+func scrubSchemas(schemas []*tableSchema, scrubs map[string]map[string]string, defaultSchema string) []*tableSchema {
+	names := make(map[string]bool, len(scrubs))
+	for name := range scrubs {
+		names[qualifyNames(defaultSchema, []string{name})[0]] = true
+	}
+	var scrubbed []*tableSchema
+	for _, schema := range schemas {
+		if names[schema.tableName] {
+			scrubbed = append(scrubbed, &tableSchema{tableName: schema.tableName, rowDeletionPolicy: schema.rowDeletionPolicy})
+		}
+	}
+	return scrubbed
+}
+
+// scrubColumns returns the columns to be scrubbed and their values, keyed by the qualified table name.
+func scrubColumns(scrubs map[string]map[string]string, defaultSchema string) map[string]map[string]string {
+	qualified := make(map[string]map[string]string, len(scrubs))
+	for name, values := range scrubs {
+		qualified[qualifyNames(defaultSchema, []string{name})[0]] = values
+	}
+	return qualified
+}
+
+// scrubFilter returns a filter matching rows whose columns don't have the values yet.
+// Values are compared as JSON, which treats NULLs as equal and supports columns of any types, e.g. arrays.
+func scrubFilter(values map[string]string) *rowFilter {
+	columns := sortedKeys(values)
+	conditions := make([]string, len(columns))
+	for i, column := range columns {
+		conditions[i] = fmt.Sprintf("TO_JSON_STRING(%s) != TO_JSON_STRING(%s)", quoteIdentifier(column), values[column])
+	}
+	return &rowFilter{condition: "(" + strings.Join(conditions, " OR ") + ")"}
+}
+
+// scrubStatementBuilder returns a builder of the UPDATE statement setting the columns to the values,
+// only in rows matching the filter so that rows already scrubbed aren't counted as updated again.
+func scrubStatementBuilder(values map[string]string, filter *rowFilter) StatementBuilder {
+	columns := sortedKeys(values)
+	assignments := make([]string, len(columns))
+	for i, column := range columns {
+		assignments[i] = fmt.Sprintf("%s = %s", quoteIdentifier(column), values[column])
+	}
+	return func(tableName string) spanner.Statement {
+		return filter.withParams(spanner.NewStatement(fmt.Sprintf("UPDATE %s SET %s%s", quoteIdentifier(tableName), strings.Join(assignments, ", "), filter.where())))
+	}
+}
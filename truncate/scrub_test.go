@@ -0,0 +1,56 @@
+//
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package truncate
+
+import (
+	"testing"
+
+	"cloud.google.com/go/spanner"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestScrubSchemas(t *testing.T) {
+	schemas := []*tableSchema{
+		{tableName: "Singers", referencedBy: []string{"Concerts"}},
+		{tableName: "Albums", parentTableName: "Singers", parentOnDeleteAction: deleteActionCascadeDelete},
+		{tableName: "Concerts", foreignKeys: []*foreignKey{{constraintName: "FK_Concerts", referencingTable: "Concerts", referencedTable: "Singers"}}},
+	}
+	scrubs := map[string]map[string]string{
+		"Singers": {"Email": "NULL"},
+		"Albums":  {"Title": "'untitled'"},
+	}
+	got := scrubSchemas(schemas, scrubs, "")
+	want := []*tableSchema{{tableName: "Singers"}, {tableName: "Albums"}}
+	if diff := cmp.Diff(got, want, cmp.AllowUnexported(tableSchema{})); diff != "" {
+		t.Errorf("mismatch (-got +want):\n%s", diff)
+	}
+}
+
+func TestScrubStatementBuilder(t *testing.T) {
+	values := map[string]string{"Email": "NULL", "Name": "'redacted'"}
+	filter := scrubFilter(values)
+	wantFilter := &rowFilter{condition: "(TO_JSON_STRING(`Email`) != TO_JSON_STRING(NULL) OR TO_JSON_STRING(`Name`) != TO_JSON_STRING('redacted'))"}
+	if diff := cmp.Diff(filter, wantFilter, cmp.AllowUnexported(rowFilter{})); diff != "" {
+		t.Errorf("filter mismatch (-got +want):\n%s", diff)
+	}
+
+	got := scrubStatementBuilder(values, filter)("Users")
+	want := spanner.NewStatement("UPDATE `Users` SET `Email` = NULL, `Name` = 'redacted' WHERE " + wantFilter.condition)
+	if diff := cmp.Diff(got, want); diff != "" {
+		t.Errorf("statement mismatch (-got +want):\n%s", diff)
+	}
+}
@@ -0,0 +1,141 @@
+//
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package truncate
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+
+	"cloud.google.com/go/spanner"
+)
+
+// seed is DML statements executed in a read-write transaction after deletion, given by WithSeed.
+type seed struct {
+	name       string
+	statements []string
+}
+
+// WithSeed executes the DML statements after all rows have been deleted, e.g. to insert reference data which test
+// environments expect, in a single run. The statements of a seed are executed in the given order with a batch DML
+// in a single read-write transaction, so that they are applied all or nothing, and seeds are executed in the order
+// they are given. name identifies the seed in the output and errors, e.g. the name of the file of the statements.
+// Seeds are not executed in dry runs or if the deletion failed.
+func WithSeed(name string, statements []string) Option {
+	return func(o *options) {
+		o.seeds = append(o.seeds, seed{name: name, statements: statements})
+	}
+}
+
+// SplitStatements splits SQL statements separated by semicolons, e.g. the content of a seed file, removing comments.
+// Semicolons in string literals and quoted identifiers don't separate statements.
+func SplitStatements(sql string) []string {
+	var statements []string
+	var b strings.Builder
+	flush := func() {
+		if s := strings.TrimSpace(b.String()); s != "" {
+			statements = append(statements, s)
+		}
+		b.Reset()
+	}
+	for i := 0; i < len(sql); i++ {
+		switch c := sql[i]; {
+		case c == ';':
+			flush()
+		case c == '#' || strings.HasPrefix(sql[i:], "--"):
+			end := strings.IndexByte(sql[i:], '\n')
+			if end < 0 {
+				i = len(sql)
+				continue
+			}
+			i += end
+			b.WriteByte('\n')
+		case strings.HasPrefix(sql[i:], "/*"):
+			end := strings.Index(sql[i+2:], "*/")
+			if end < 0 {
+				i = len(sql)
+				continue
+			}
+			i += 2 + end + 1
+			b.WriteByte(' ')
+		case c == '\'' || c == '"' || c == '`':
+			end := quotedEnd(sql, i)
+			b.WriteString(sql[i:end])
+			i = end - 1
+		default:
+			b.WriteByte(c)
+		}
+	}
+	flush()
+	return statements
+}
+
+// quotedEnd returns the index right after the literal or identifier quoted at start, or the length of sql if it's
+// not closed. Triple quotes and escapes with backslashes are supported.
+func quotedEnd(sql string, start int) int {
+	quote := sql[start : start+1]
+	if strings.HasPrefix(sql[start:], strings.Repeat(quote, 3)) {
+		quote = strings.Repeat(quote, 3)
+	}
+	for i := start + len(quote); i < len(sql); i++ {
+		if sql[i] == '\\' {
+			i++
+			continue
+		}
+		if strings.HasPrefix(sql[i:], quote) {
+			return i + len(quote)
+		}
+	}
+	return len(sql)
+}
+
+// countSeedStatements returns the total number of statements of the seeds.
+func countSeedStatements(seeds []seed) int {
+	var n int
+	for _, s := range seeds {
+		n += len(s.statements)
+	}
+	return n
+}
+
+// runSeeds executes the statements of each seed in a read-write transaction in order.
+func runSeeds(ctx context.Context, client *spanner.Client, out io.Writer, seeds []seed, reqOpts requestOptions) error {
+	for _, s := range seeds {
+		if len(s.statements) == 0 {
+			continue
+		}
+		stmts := make([]spanner.Statement, len(s.statements))
+		for i, sql := range s.statements {
+			stmts[i] = spanner.NewStatement(sql)
+		}
+		var counts []int64
+		if _, err := client.ReadWriteTransactionWithOptions(ctx, func(ctx context.Context, txn *spanner.ReadWriteTransaction) error {
+			var err error
+			counts, err = txn.BatchUpdateWithOptions(ctx, stmts, reqOpts.query())
+			return err
+		}, reqOpts.transaction()); err != nil {
+			return fmt.Errorf("failed to seed %s: %w", s.name, err)
+		}
+		var rows int64
+		for _, count := range counts {
+			rows += count
+		}
+		fmt.Fprintf(out, "Seeded %s: %d statements affected %s rows.\n", s.name, len(stmts), formatNumber(uint64(rows)))
+	}
+	return nil
+}
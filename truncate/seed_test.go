@@ -0,0 +1,58 @@
+//
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package truncate
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestSplitStatements(t *testing.T) {
+	for _, tt := range []struct {
+		desc string
+		sql  string
+		want []string
+	}{
+		{
+			desc: "statements",
+			sql:  "INSERT INTO Genres (GenreId) VALUES (1);\nINSERT INTO Genres (GenreId) VALUES (2)",
+			want: []string{"INSERT INTO Genres (GenreId) VALUES (1)", "INSERT INTO Genres (GenreId) VALUES (2)"},
+		},
+		{
+			desc: "comments",
+			sql:  "-- fixtures;\nINSERT INTO Genres (GenreId) VALUES (1); # trailing; comment\n/* block; comment */;",
+			want: []string{"INSERT INTO Genres (GenreId) VALUES (1)"},
+		},
+		{
+			desc: "quoted",
+			sql:  "INSERT INTO `Odd;Name` (Name) VALUES ('a;b'), (\"it\\\"s;\"), ('''x;\n-- y''');",
+			want: []string{"INSERT INTO `Odd;Name` (Name) VALUES ('a;b'), (\"it\\\"s;\"), ('''x;\n-- y''')"},
+		},
+		{
+			desc: "empty",
+			sql:  " ;\n-- nothing\n",
+			want: nil,
+		},
+	} {
+		t.Run(tt.desc, func(t *testing.T) {
+			if diff := cmp.Diff(SplitStatements(tt.sql), tt.want); diff != "" {
+				t.Errorf("mismatch (-got +want):\n%s", diff)
+			}
+		})
+	}
+}
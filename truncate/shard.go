@@ -0,0 +1,167 @@
+//
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package truncate
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+
+	"cloud.google.com/go/spanner"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/protobuf/proto"
+)
+
+// shardSampleRows is the number of keys sampled for each shard to find the boundaries of shards.
+const shardSampleRows = 100
+
+// WithShardedDeletion splits the deletion of each table with at least minRows rows, including rows of interleaved
+// descendants, into the given number of Partitioned DML statements deleting ranges of the first primary key column,
+// executed concurrently. The boundaries of the ranges are sampled from the keys, so skewed keys make uneven shards.
+// Tables deleted with custom statements, mutations or leaf rows first, and tables whose rows aren't counted, are not sharded.
+func WithShardedDeletion(minRows int64, shards int) Option {
+	return func(o *options) {
+		o.shardMinRows = minRows
+		o.shards = shards
+	}
+}
+
+// deleteRowsSharded deletes rows from the table with Partitioned DML statements deleting key ranges concurrently.
+// If any of them fails, the others are cancelled, and the deletion is retried as a whole by the retry policy,
+// which only deletes the remaining rows as well as a single statement.
+func (d *deleter) deleteRowsSharded(ctx context.Context) error {
+	columns, err := fetchKeyColumns(ctx, d.client, d.tableName)
+	if err != nil {
+		return newTableError(d.tableName, OpDelete, "", fmt.Errorf("failed to fetch the primary key: %w", err))
+	}
+	if len(columns) == 0 {
+		return d.deleteRowsByPDML(ctx)
+	}
+	column := columns[0]
+	boundaries, err := sampleShardBoundaries(ctx, d.client, d.tableName, column, d.shards)
+	if err != nil {
+		return newTableError(d.tableName, OpDelete, "", fmt.Errorf("failed to sample keys: %w", err))
+	}
+	stmts := shardStatements(d.tableName, column, boundaries)
+	orDiscard(d.logger).Info("deleting shards", "shards", len(stmts), "column", column)
+
+	ctx = withRetryCounter(ctx, &d.retries)
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	defer d.beginOperation(OpDelete)()
+
+	var wg sync.WaitGroup
+	var once sync.Once
+	var firstErr error
+	var deleted int64
+	for _, stmt := range stmts {
+		wg.Add(1)
+		go func(stmt spanner.Statement) {
+			defer wg.Done()
+			err := d.limiter.wait(ctx)
+			if err == nil {
+				atomic.AddInt64(&d.retries.pdmlRuns, 1)
+				var count int64
+				count, err = d.client.PartitionedUpdateWithOptions(ctx, stmt, d.reqOpts.query())
+				atomic.AddInt64(&deleted, count)
+			}
+			if err != nil {
+				once.Do(func() {
+					firstErr = newTableError(d.tableName, OpDelete, stmt.SQL, err)
+					cancel()
+				})
+			}
+		}(stmt)
+	}
+	wg.Wait()
+	if firstErr != nil {
+		return firstErr
+	}
+	orDiscard(d.logger).Info("sharded partitioned DML finished", "rows", deleted)
+	trace.SpanFromContext(ctx).SetAttributes(attribute.Int64("truncate.deleted_rows", deleted))
+	if d.uncounted {
+		// Nobody finds the table empty by counting, so the end of the statements is regarded as the completion.
+		if d.totalRows == 0 {
+			d.totalRows = uint64(deleted)
+		}
+		d.remainedRows = 0
+		d.status = statusCompleted
+	}
+	return nil
+}
+
+// sampleShardBoundaries samples keys of the column, and returns the boundaries splitting them into shards evenly.
+// Fewer boundaries are returned if the sampled keys have fewer distinct values.
+func sampleShardBoundaries(ctx context.Context, client *spanner.Client, tableName, column string, shards int) ([]spanner.GenericColumnValue, error) {
+	stmt := spanner.NewStatement(fmt.Sprintf("SELECT k FROM (SELECT %s AS k FROM %s TABLESAMPLE RESERVOIR (%d ROWS)) WHERE k IS NOT NULL ORDER BY k",
+		quoteIdentifier(column), quoteIdentifier(tableName), shards*shardSampleRows))
+	var keys []spanner.GenericColumnValue
+	if err := client.Single().Query(ctx, stmt).Do(func(r *spanner.Row) error {
+		var key spanner.GenericColumnValue
+		if err := r.Column(0, &key); err != nil {
+			return err
+		}
+		keys = append(keys, key)
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	return shardBoundaries(keys, shards), nil
+}
+
+// shardBoundaries picks boundaries splitting the sorted keys into shards evenly, without duplicates.
+func shardBoundaries(keys []spanner.GenericColumnValue, shards int) []spanner.GenericColumnValue {
+	var boundaries []spanner.GenericColumnValue
+	for i := 1; i < shards; i++ {
+		j := i * len(keys) / shards
+		if j == 0 || j >= len(keys) {
+			continue
+		}
+		if n := len(boundaries); n > 0 && proto.Equal(boundaries[n-1].Value, keys[j].Value) {
+			continue
+		}
+		boundaries = append(boundaries, keys[j])
+	}
+	return boundaries
+}
+
+// shardStatements returns statements deleting the ranges of the column split by the boundaries.
+// The first range includes NULLs, so that the statements delete all rows together.
+func shardStatements(tableName, column string, boundaries []spanner.GenericColumnValue) []spanner.Statement {
+	table, key := quoteIdentifier(tableName), quoteIdentifier(column)
+	if len(boundaries) == 0 {
+		return []spanner.Statement{spanner.NewStatement(fmt.Sprintf("DELETE FROM %s WHERE true", table))}
+	}
+	stmts := make([]spanner.Statement, 0, len(boundaries)+1)
+	stmts = append(stmts, spanner.Statement{
+		SQL:    fmt.Sprintf("DELETE FROM %s WHERE %s < @upper OR %s IS NULL", table, key, key),
+		Params: map[string]interface{}{"upper": boundaries[0]},
+	})
+	for i := 1; i < len(boundaries); i++ {
+		stmts = append(stmts, spanner.Statement{
+			SQL:    fmt.Sprintf("DELETE FROM %s WHERE %s >= @lower AND %s < @upper", table, key, key),
+			Params: map[string]interface{}{"lower": boundaries[i-1], "upper": boundaries[i]},
+		})
+	}
+	stmts = append(stmts, spanner.Statement{
+		SQL:    fmt.Sprintf("DELETE FROM %s WHERE %s >= @lower", table, key),
+		Params: map[string]interface{}{"lower": boundaries[len(boundaries)-1]},
+	})
+	return stmts
+}
@@ -0,0 +1,123 @@
+//
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package truncate
+
+import (
+	"fmt"
+	"testing"
+
+	"cloud.google.com/go/spanner"
+	"github.com/google/go-cmp/cmp"
+	sppb "google.golang.org/genproto/googleapis/spanner/v1"
+	"google.golang.org/protobuf/testing/protocmp"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+func intKey(v int) spanner.GenericColumnValue {
+	return spanner.GenericColumnValue{
+		Type:  &sppb.Type{Code: sppb.TypeCode_INT64},
+		Value: structpb.NewStringValue(fmt.Sprint(v)),
+	}
+}
+
+func intKeys(vs ...int) []spanner.GenericColumnValue {
+	keys := make([]spanner.GenericColumnValue, 0, len(vs))
+	for _, v := range vs {
+		keys = append(keys, intKey(v))
+	}
+	return keys
+}
+
+func TestShardBoundaries(t *testing.T) {
+	for _, tt := range []struct {
+		desc   string
+		keys   []spanner.GenericColumnValue
+		shards int
+		want   []spanner.GenericColumnValue
+	}{
+		{
+			desc:   "Even keys",
+			keys:   intKeys(1, 2, 3, 4, 5, 6, 7, 8),
+			shards: 4,
+			want:   intKeys(3, 5, 7),
+		},
+		{
+			desc:   "Duplicated keys",
+			keys:   intKeys(1, 1, 1, 1, 1, 1, 2, 3),
+			shards: 4,
+			want:   intKeys(1, 2),
+		},
+		{
+			desc:   "Fewer keys than shards",
+			keys:   intKeys(1, 2),
+			shards: 4,
+			want:   intKeys(2),
+		},
+		{
+			desc:   "No keys",
+			keys:   nil,
+			shards: 4,
+			want:   nil,
+		},
+	} {
+		t.Run(tt.desc, func(t *testing.T) {
+			got := shardBoundaries(tt.keys, tt.shards)
+			if diff := cmp.Diff(got, tt.want, protocmp.Transform()); diff != "" {
+				t.Errorf("mismatch (-got +want):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestShardStatements(t *testing.T) {
+	for _, tt := range []struct {
+		desc       string
+		boundaries []spanner.GenericColumnValue
+		want       []spanner.Statement
+	}{
+		{
+			desc:       "No boundaries",
+			boundaries: nil,
+			want:       []spanner.Statement{spanner.NewStatement("DELETE FROM `Events` WHERE true")},
+		},
+		{
+			desc:       "Two boundaries",
+			boundaries: intKeys(10, 20),
+			want: []spanner.Statement{
+				{
+					SQL:    "DELETE FROM `Events` WHERE `EventId` < @upper OR `EventId` IS NULL",
+					Params: map[string]interface{}{"upper": intKey(10)},
+				},
+				{
+					SQL:    "DELETE FROM `Events` WHERE `EventId` >= @lower AND `EventId` < @upper",
+					Params: map[string]interface{}{"lower": intKey(10), "upper": intKey(20)},
+				},
+				{
+					SQL:    "DELETE FROM `Events` WHERE `EventId` >= @lower",
+					Params: map[string]interface{}{"lower": intKey(20)},
+				},
+			},
+		},
+	} {
+		t.Run(tt.desc, func(t *testing.T) {
+			got := shardStatements("Events", "EventId", tt.boundaries)
+			if diff := cmp.Diff(got, tt.want, protocmp.Transform()); diff != "" {
+				t.Errorf("mismatch (-got +want):\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,125 @@
+//
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package truncate
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"cloud.google.com/go/spanner"
+)
+
+// WithSkipLargerThanRows skips tables having more rows than maxRows, e.g. to empty all the small tables
+// while leaving giant tables for a separately scheduled run. Ancestors which would delete the skipped tables
+// in cascade are skipped too. Skipped tables are reported as warnings.
+func WithSkipLargerThanRows(maxRows int64) Option {
+	return func(o *options) {
+		o.maxRows = &maxRows
+	}
+}
+
+// WithSkipLargerThanBytes is like WithSkipLargerThanRows, but compares the bytes used by tables,
+// taken from the latest table size statistics (SPANNER_SYS.TABLE_SIZES_STATS_1HOUR).
+// Tables which don't appear in the statistics yet are not skipped.
+func WithSkipLargerThanBytes(maxBytes int64) Option {
+	return func(o *options) {
+		o.maxBytes = &maxBytes
+	}
+}
+
+// fetchTableSizes fetches bytes used by each table from the latest table size statistics.
+func fetchTableSizes(ctx context.Context, client *spanner.Client) (map[string]int64, error) {
+	iter := client.Single().Query(ctx, spanner.NewStatement(`
+		SELECT TABLE_NAME, USED_BYTES FROM SPANNER_SYS.TABLE_SIZES_STATS_1HOUR
+		WHERE INTERVAL_END = (SELECT MAX(INTERVAL_END) FROM SPANNER_SYS.TABLE_SIZES_STATS_1HOUR);
+	`))
+
+	sizes := map[string]int64{}
+	if err := iter.Do(func(r *spanner.Row) error {
+		var (
+			tableName string
+			usedBytes int64
+		)
+		if err := r.Columns(&tableName, &usedBytes); err != nil {
+			return err
+		}
+		sizes[tableName] = usedBytes
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	return sizes, nil
+}
+
+// findLargeTables returns names of tables exceeding maxRows or maxBytes, with the reasons keyed by the table names.
+// A nil limit isn't checked, and tables whose sizes are unknown are never regarded as large.
+func findLargeTables(schemas []*tableSchema, rowCounts, tableBytes map[string]int64, maxRows, maxBytes *int64) ([]string, map[string]string) {
+	var names []string
+	reasons := map[string]string{}
+	for _, schema := range schemas {
+		if rows, ok := rowCounts[schema.tableName]; ok && maxRows != nil && rows > *maxRows {
+			reasons[schema.tableName] = fmt.Sprintf("it has %s rows, more than %s", formatNumber(uint64(rows)), formatNumber(uint64(*maxRows)))
+		} else if bytes, ok := tableBytes[schema.tableName]; ok && maxBytes != nil && bytes > *maxBytes {
+			reasons[schema.tableName] = fmt.Sprintf("it uses %s, more than %s", formatBytes(bytes), formatBytes(*maxBytes))
+		} else {
+			continue
+		}
+		names = append(names, schema.tableName)
+	}
+	return names, reasons
+}
+
+// skipLargeTables removes large tables and their ancestors deleting them in cascade from schemas,
+// and returns the remaining tables and the skipped tables with the reasons.
+func skipLargeTables(schemas []*tableSchema, rowCounts, tableBytes map[string]int64, maxRows, maxBytes *int64) ([]*tableSchema, map[string]string) {
+	_, reasons := findLargeTables(schemas, rowCounts, tableBytes, maxRows, maxBytes)
+	return skipTables(schemas, reasons)
+}
+
+// skipTables removes tables with the reasons and their ancestors deleting them in cascade from schemas,
+// and returns the remaining tables and the skipped tables with the reasons.
+func skipTables(schemas []*tableSchema, reasons map[string]string) ([]*tableSchema, map[string]string) {
+	remaining := excludeFilterTableSchemas(schemas, sortedKeys(reasons))
+
+	kept := make(map[string]bool, len(remaining))
+	for _, schema := range remaining {
+		kept[schema.tableName] = true
+	}
+	skipped := map[string]string{}
+	for _, schema := range schemas {
+		if kept[schema.tableName] {
+			continue
+		}
+		if reason, ok := reasons[schema.tableName]; ok {
+			skipped[schema.tableName] = reason
+		} else {
+			skipped[schema.tableName] = "its descendants deleted in cascade are skipped"
+		}
+	}
+	return remaining, skipped
+}
+
+// sortedKeys returns keys of the map in ascending order.
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,96 @@
+//
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package truncate
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestSkipLargeTables(t *testing.T) {
+	// Singers -- Albums (cascade) -- Songs (cascade), Concerts, Venues
+	schemas := []*tableSchema{
+		{tableName: "Albums", parentTableName: "Singers", parentOnDeleteAction: deleteActionCascadeDelete},
+		{tableName: "Concerts"},
+		{tableName: "Singers"},
+		{tableName: "Songs", parentTableName: "Albums", parentOnDeleteAction: deleteActionCascadeDelete},
+		{tableName: "Venues"},
+	}
+	rowCounts := map[string]int64{
+		"Albums":   100,
+		"Concerts": 5000,
+		"Singers":  10,
+		"Songs":    2000,
+	}
+	tableBytes := map[string]int64{
+		"Albums":   1024,
+		"Concerts": 1024,
+		"Singers":  1024,
+		"Songs":    1024,
+		"Venues":   4096,
+	}
+	rows := int64(1000)
+	bytes := int64(2048)
+
+	for _, test := range []struct {
+		desc          string
+		maxRows       *int64
+		maxBytes      *int64
+		wantRemaining []string
+		wantSkipped   map[string]string
+	}{
+		{
+			desc:          "no limits",
+			wantRemaining: []string{"Albums", "Concerts", "Singers", "Songs", "Venues"},
+			wantSkipped:   map[string]string{},
+		},
+		{
+			desc:          "by rows",
+			maxRows:       &rows,
+			wantRemaining: []string{"Venues"},
+			wantSkipped: map[string]string{
+				"Albums":   "its descendants deleted in cascade are skipped",
+				"Concerts": "it has 5,000 rows, more than 1,000",
+				"Singers":  "its descendants deleted in cascade are skipped",
+				"Songs":    "it has 2,000 rows, more than 1,000",
+			},
+		},
+		{
+			desc:          "by bytes",
+			maxBytes:      &bytes,
+			wantRemaining: []string{"Albums", "Concerts", "Singers", "Songs"},
+			wantSkipped: map[string]string{
+				"Venues": "it uses 4.0 KiB, more than 2.0 KiB",
+			},
+		},
+	} {
+		t.Run(test.desc, func(t *testing.T) {
+			remaining, skipped := skipLargeTables(schemas, rowCounts, tableBytes, test.maxRows, test.maxBytes)
+			var names []string
+			for _, schema := range remaining {
+				names = append(names, schema.tableName)
+			}
+			if !cmp.Equal(names, test.wantRemaining) {
+				t.Errorf("remaining: diff(+got, -want) = %v", cmp.Diff(names, test.wantRemaining))
+			}
+			if !cmp.Equal(skipped, test.wantSkipped) {
+				t.Errorf("skipped: diff(+got, -want) = %v", cmp.Diff(skipped, test.wantSkipped))
+			}
+		})
+	}
+}
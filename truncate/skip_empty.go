@@ -0,0 +1,134 @@
+//
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package truncate
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"cloud.google.com/go/spanner"
+)
+
+// WithSkipEmptyTables completes tables which are already empty, together with all their descendants, before the
+// deletion starts, so that neither statements nor progress bars are spent on them. Emptiness is taken from the row
+// counts before deletion, or, with WithoutRowCounts, checked by a query reading a single row of each table.
+// It suits cleanup jobs which run frequently against mostly empty tables.
+func WithSkipEmptyTables() Option {
+	return func(o *options) {
+		o.skipEmptyTables = true
+	}
+}
+
+// emptyTablesFromCounts returns the tables counted as empty.
+func emptyTablesFromCounts(rowCounts map[string]int64) map[string]bool {
+	empty := make(map[string]bool, len(rowCounts))
+	for name, count := range rowCounts {
+		if count == 0 {
+			empty[name] = true
+		}
+	}
+	return empty
+}
+
+// fetchEmptyTables checks which tables are empty by reading a single row of each, which is much cheaper than counting.
+// Tables which fail to be checked are regarded as not empty, so that they are deleted as usual.
+func fetchEmptyTables(ctx context.Context, client *spanner.Client, schemas []*tableSchema, filters map[string]*rowFilter, reqOpts requestOptions) map[string]bool {
+	var (
+		mu    sync.Mutex
+		wg    sync.WaitGroup
+		empty = make(map[string]bool, len(schemas))
+		sem   = make(chan struct{}, maxConcurrentCounts)
+	)
+	for _, schema := range schemas {
+		tableName := schema.tableName
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+
+			isEmpty, err := isTableEmpty(ctx, client, tableName, filters[tableName], reqOpts)
+			if err != nil || !isEmpty {
+				return
+			}
+			mu.Lock()
+			empty[tableName] = true
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+	return empty
+}
+
+// isTableEmpty returns true if the table has no rows matching the filter.
+func isTableEmpty(ctx context.Context, client *spanner.Client, tableName string, filter *rowFilter, reqOpts requestOptions) (bool, error) {
+	stmt := filter.withParams(spanner.NewStatement(fmt.Sprintf("SELECT 1 FROM %s%s LIMIT 1", quoteIdentifier(tableName), filter.where())))
+	empty := true
+
+	// Use stale read to minimize the impact on the leader replica.
+	txn := client.Single().WithTimestampBound(spanner.ExactStaleness(time.Second))
+	if err := txn.QueryWithOptions(ctx, stmt, reqOpts.query()).Do(func(*spanner.Row) error {
+		empty = false
+		return nil
+	}); err != nil {
+		return false, newTableError(tableName, OpCount, stmt.SQL, err)
+	}
+	return empty, nil
+}
+
+// skipEmptyTables completes the tables which are empty together with all their descendants before the deletion starts.
+// A table with rows in its descendants is deleted as usual, as rows of a table interleaved with INTERLEAVE IN may
+// exist without their parent rows, and so is a table with hooks, which are expected to run.
+// It returns the names of the skipped tables.
+func (c *coordinator) skipEmptyTables(empty map[string]bool) []string {
+	var skipped []string
+	// walk returns true if the table and all its descendants are skipped.
+	var walk func(t *table) bool
+	walk = func(t *table) bool {
+		skip := empty[t.tableName] && len(t.deleter.preHooks) == 0 && len(t.deleter.postHooks) == 0
+		for _, child := range t.childTables {
+			if !walk(child) {
+				skip = false
+			}
+		}
+		if skip {
+			t.deleter.status = statusCompleted
+			t.deleter.alreadyEmpty = true
+			skipped = append(skipped, t.tableName)
+		}
+		return skip
+	}
+	for _, t := range c.tables {
+		walk(t)
+	}
+	return skipped
+}
+
+// countAlreadyEmptyTables returns the number of tables completed without deletion because they were already empty.
+func countAlreadyEmptyTables(tables []*table) int {
+	var n int
+	for _, t := range flattenTables(tables) {
+		if t.deleter.alreadyEmpty {
+			n++
+		}
+	}
+	return n
+}
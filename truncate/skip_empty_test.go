@@ -0,0 +1,52 @@
+//
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package truncate
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestCoordinatorSkipEmptyTables(t *testing.T) {
+	singers := &table{tableName: "Singers", deleter: &deleter{status: statusAnalyzing}}
+	albums := &table{tableName: "Albums", deleter: &deleter{status: statusAnalyzing}}
+	songs := &table{tableName: "Songs", deleter: &deleter{status: statusAnalyzing}}
+	singers.childTables = []*table{albums}
+	albums.childTables = []*table{songs}
+	events := &table{tableName: "Events", deleter: &deleter{status: statusAnalyzing}}
+	audits := &table{tableName: "Audits", deleter: &deleter{status: statusAnalyzing, postHooks: []string{"DELETE FROM AuditLogs WHERE true"}}}
+	c := &coordinator{tables: []*table{singers, events, audits}}
+
+	// Songs has rows, so neither Albums nor Singers is skipped even though they are empty.
+	empty := emptyTablesFromCounts(map[string]int64{"Singers": 0, "Albums": 0, "Songs": 10, "Events": 0, "Audits": 0})
+	if diff := cmp.Diff(c.skipEmptyTables(empty), []string{"Events"}); diff != "" {
+		t.Errorf("skipEmptyTables() mismatch (-got +want):\n%s", diff)
+	}
+
+	got := map[string]bool{}
+	for _, table := range flattenTables(c.tables) {
+		got[table.tableName] = table.deleter.alreadyEmpty && table.deleter.status == statusCompleted
+	}
+	want := map[string]bool{"Singers": false, "Albums": false, "Songs": false, "Events": true, "Audits": false}
+	if diff := cmp.Diff(got, want); diff != "" {
+		t.Errorf("mismatch (-got +want):\n%s", diff)
+	}
+	if got, want := countAlreadyEmptyTables(c.tables), 1; got != want {
+		t.Errorf("countAlreadyEmptyTables() = %d, want %d", got, want)
+	}
+}
@@ -0,0 +1,236 @@
+//
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package truncate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"cloud.google.com/go/spanner"
+)
+
+// SchemaSnapshot is a serialized schema of the tables, foreign keys and indexes in a database, which deletion can be
+// planned against later without access to the database, e.g. to review plans in an air-gapped environment.
+type SchemaSnapshot struct {
+	Database    string                `json:"database"`
+	GeneratedAt time.Time             `json:"generated_at"`
+	Tables      []*SnapshotTable      `json:"tables"`
+	ForeignKeys []*SnapshotForeignKey `json:"foreign_keys,omitempty"`
+	Indexes     []*SnapshotIndex      `json:"indexes,omitempty"`
+}
+
+// SnapshotTable is a table in SchemaSnapshot.
+type SnapshotTable struct {
+	Name                 string `json:"name"`
+	ParentTableName      string `json:"parent_table_name,omitempty"`
+	ParentOnDeleteAction string `json:"parent_on_delete_action,omitempty"`
+}
+
+// SnapshotForeignKey is a foreign key in SchemaSnapshot.
+type SnapshotForeignKey struct {
+	Name               string   `json:"name"`
+	ReferencingTable   string   `json:"referencing_table"`
+	ReferencedTable    string   `json:"referenced_table"`
+	OnDelete           string   `json:"on_delete"`
+	ReferencingColumns []string `json:"referencing_columns"`
+	ReferencedColumns  []string `json:"referenced_columns"`
+}
+
+// SnapshotIndex is a secondary index in SchemaSnapshot.
+type SnapshotIndex struct {
+	Name            string `json:"name"`
+	TableName       string `json:"table_name"`
+	ParentTableName string `json:"parent_table_name,omitempty"`
+	Type            string `json:"type"`
+}
+
+// FetchSchemaSnapshot fetches the schema of all tables in the database, regardless of target and exclude tables,
+// so that any tables can be planned against the snapshot later with WithSchemaSnapshot.
+// Options other than WithSchemas, WithAllSchemas and WithEmulator are ignored.
+func FetchSchemaSnapshot(ctx context.Context, client *spanner.Client, opts ...Option) (*SchemaSnapshot, error) {
+	o := newOptions(opts)
+	schemas, _, err := fetchTableSchemasOn(ctx, client, o)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch table schema: %v", err)
+	}
+	indexes, err := fetchIndexSchemas(ctx, client, o.schemaScope())
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch index schema: %v", err)
+	}
+	return newSchemaSnapshot(client.DatabaseName(), schemas, indexes), nil
+}
+
+// WithSchemaSnapshot makes FetchTableSchemas, FetchIndexSchemas, WriteDependencyGraph and PlanDeletion read
+// the schema from the snapshot instead of the database, so that they don't need a client.
+// Run doesn't support it, as deletion needs the live database anyway.
+func WithSchemaSnapshot(snapshot *SchemaSnapshot) Option {
+	return func(o *options) {
+		o.schemaSnapshot = snapshot
+	}
+}
+
+// PlanDeletion plans deletion of the target tables filtered as FetchTableSchemas does, like the plan which a dry run
+// passes to WithPlanHandler but without row counts, so no rows are counted or deleted.
+// With WithSchemaSnapshot, the plan is made against the snapshot and client may be nil.
+func PlanDeletion(ctx context.Context, client *spanner.Client, targetTables, excludeTables []string, opts ...Option) (*Plan, error) {
+	o := newOptions(opts)
+	schemas, err := fetchFilteredTableSchemas(ctx, client, targetTables, excludeTables, o)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch table schema: %v", err)
+	}
+	indexes, err := loadIndexSchemas(ctx, client, o)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch index schema: %v", err)
+	}
+	plan, err := planDeletion(schemas, indexes, o)
+	if err != nil {
+		return nil, err
+	}
+	database := o.schemaSnapshot.database()
+	if database == "" {
+		database = client.DatabaseName()
+	}
+	return newPlan(database, schemas, nil, plan), nil
+}
+
+// loadTableSchemas returns the schemas of the tables from the snapshot if any, or fetches them from the database.
+func loadTableSchemas(ctx context.Context, client *spanner.Client, o *options) ([]*tableSchema, bool, error) {
+	if o.schemaSnapshot != nil {
+		schemas, err := o.schemaSnapshot.tableSchemas()
+		return schemas, false, err
+	}
+	return fetchTableSchemasOn(ctx, client, o)
+}
+
+// loadIndexSchemas returns the schemas of the indexes from the snapshot if any, or fetches them from the database.
+func loadIndexSchemas(ctx context.Context, client *spanner.Client, o *options) ([]*indexSchema, error) {
+	if o.schemaSnapshot != nil {
+		return o.schemaSnapshot.indexSchemas(), nil
+	}
+	return fetchIndexSchemas(ctx, client, o.schemaScope())
+}
+
+func newSchemaSnapshot(database string, schemas []*tableSchema, indexes []*indexSchema) *SchemaSnapshot {
+	snapshot := &SchemaSnapshot{Database: database, GeneratedAt: time.Now()}
+	for _, schema := range schemas {
+		exported := schema.export()
+		snapshot.Tables = append(snapshot.Tables, &SnapshotTable{
+			Name:                 exported.Name,
+			ParentTableName:      exported.ParentTableName,
+			ParentOnDeleteAction: exported.ParentOnDeleteAction,
+		})
+		for _, fk := range schema.foreignKeys {
+			snapshot.ForeignKeys = append(snapshot.ForeignKeys, &SnapshotForeignKey{
+				Name:               fk.constraintName,
+				ReferencingTable:   fk.referencingTable,
+				ReferencedTable:    fk.referencedTable,
+				OnDelete:           deleteActionName(fk.onDelete),
+				ReferencingColumns: fk.referencingColumns,
+				ReferencedColumns:  fk.referencedColumns,
+			})
+		}
+	}
+	for _, index := range indexes {
+		exported := index.export()
+		snapshot.Indexes = append(snapshot.Indexes, &SnapshotIndex{
+			Name:            exported.Name,
+			TableName:       exported.TableName,
+			ParentTableName: exported.ParentTableName,
+			Type:            exported.Type,
+		})
+	}
+	return snapshot
+}
+
+// database returns the database of the snapshot, or blank if the snapshot is nil.
+func (s *SchemaSnapshot) database() string {
+	if s == nil {
+		return ""
+	}
+	return s.Database
+}
+
+// tableSchemas restores the schemas of the tables with the foreign keys referencing them.
+func (s *SchemaSnapshot) tableSchemas() ([]*tableSchema, error) {
+	if len(s.Tables) == 0 {
+		return nil, errors.New("the schema snapshot has no tables")
+	}
+	schemas := make([]*tableSchema, len(s.Tables))
+	for i, t := range s.Tables {
+		action, err := parseDeleteAction(t.ParentOnDeleteAction)
+		if err != nil {
+			return nil, fmt.Errorf("table %s in the schema snapshot: %v", t.Name, err)
+		}
+		schemas[i] = &tableSchema{tableName: t.Name, parentTableName: t.ParentTableName, parentOnDeleteAction: action}
+	}
+	fks := make([]*foreignKey, len(s.ForeignKeys))
+	for i, fk := range s.ForeignKeys {
+		action, err := parseDeleteAction(fk.OnDelete)
+		if err != nil {
+			return nil, fmt.Errorf("foreign key %s in the schema snapshot: %v", fk.Name, err)
+		}
+		if action == deleteActionUndefined {
+			action = deleteActionNoAction
+		}
+		fks[i] = &foreignKey{
+			constraintName:   fk.Name,
+			referencingTable: fk.ReferencingTable,
+			referencedTable:  fk.ReferencedTable,
+			onDelete:         action,
+
+			referencingColumns: fk.ReferencingColumns,
+			referencedColumns:  fk.ReferencedColumns,
+		}
+	}
+	attachForeignKeys(schemas, fks)
+	return schemas, nil
+}
+
+// indexSchemas restores the schemas of the indexes.
+func (s *SchemaSnapshot) indexSchemas() []*indexSchema {
+	indexes := make([]*indexSchema, len(s.Indexes))
+	for i, index := range s.Indexes {
+		indexes[i] = &indexSchema{indexName: index.Name, baseTableName: index.TableName, parentTableName: index.ParentTableName, isSearch: index.Type == "SEARCH"}
+	}
+	return indexes
+}
+
+// deleteActionName returns the name of the delete action as in INFORMATION_SCHEMA, e.g. "CASCADE".
+func deleteActionName(action deleteActionType) string {
+	switch action {
+	case deleteActionCascadeDelete:
+		return "CASCADE"
+	case deleteActionNoAction:
+		return "NO ACTION"
+	}
+	return ""
+}
+
+// parseDeleteAction parses the name of a delete action returned by deleteActionName.
+func parseDeleteAction(s string) (deleteActionType, error) {
+	switch s {
+	case "":
+		return deleteActionUndefined, nil
+	case "CASCADE":
+		return deleteActionCascadeDelete, nil
+	case "NO ACTION":
+		return deleteActionNoAction, nil
+	}
+	return deleteActionUndefined, fmt.Errorf("unknown delete action %q", s)
+}
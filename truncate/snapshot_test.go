@@ -0,0 +1,96 @@
+//
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package truncate
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestSchemaSnapshotRoundTrip(t *testing.T) {
+	fk := &foreignKey{
+		constraintName:     "FK_ConcertsSingers",
+		referencingTable:   "Concerts",
+		referencedTable:    "Singers",
+		onDelete:           deleteActionCascadeDelete,
+		referencingColumns: []string{"SingerId"},
+		referencedColumns:  []string{"SingerId"},
+	}
+	schemas := []*tableSchema{
+		{tableName: "Singers", foreignKeys: []*foreignKey{fk}, referencedBy: []string{"Concerts"}},
+		{tableName: "Albums", parentTableName: "Singers", parentOnDeleteAction: deleteActionCascadeDelete},
+		{tableName: "Concerts"},
+	}
+	indexes := []*indexSchema{
+		{indexName: "AlbumsByTitle", baseTableName: "Albums", parentTableName: "Singers"},
+		{indexName: "SingersByName", baseTableName: "Singers", isSearch: true},
+	}
+
+	// The snapshot survives serialization as a file.
+	b, err := json.Marshal(newSchemaSnapshot("projects/p/instances/i/databases/d", schemas, indexes))
+	if err != nil {
+		t.Fatalf("failed to marshal the snapshot: %v", err)
+	}
+	var snapshot SchemaSnapshot
+	if err := json.Unmarshal(b, &snapshot); err != nil {
+		t.Fatalf("failed to unmarshal the snapshot: %v", err)
+	}
+
+	gotSchemas, err := snapshot.tableSchemas()
+	if err != nil {
+		t.Fatalf("tableSchemas() failed: %v", err)
+	}
+	if diff := cmp.Diff(gotSchemas, schemas, cmp.AllowUnexported(tableSchema{}, foreignKey{})); diff != "" {
+		t.Errorf("table schemas mismatch (-got +want):\n%s", diff)
+	}
+	if diff := cmp.Diff(snapshot.indexSchemas(), indexes, cmp.AllowUnexported(indexSchema{})); diff != "" {
+		t.Errorf("index schemas mismatch (-got +want):\n%s", diff)
+	}
+}
+
+func TestPlanDeletionWithSchemaSnapshot(t *testing.T) {
+	snapshot := &SchemaSnapshot{
+		Database: "projects/p/instances/i/databases/d",
+		Tables: []*SnapshotTable{
+			{Name: "Singers"},
+			{Name: "Albums", ParentTableName: "Singers", ParentOnDeleteAction: "CASCADE"},
+			{Name: "Concerts"},
+		},
+		ForeignKeys: []*SnapshotForeignKey{
+			{Name: "FK_ConcertsSingers", ReferencingTable: "Concerts", ReferencedTable: "Singers", OnDelete: "NO ACTION"},
+		},
+	}
+	plan, err := PlanDeletion(context.Background(), nil, nil, nil, WithSchemaSnapshot(snapshot))
+	if err != nil {
+		t.Fatalf("PlanDeletion() failed: %v", err)
+	}
+	if plan.Database != snapshot.Database {
+		t.Errorf("database = %s, want %s", plan.Database, snapshot.Database)
+	}
+	want := [][]string{{"Albums", "Concerts"}, {"Singers"}}
+	if diff := cmp.Diff(plan.Waves, want); diff != "" {
+		t.Errorf("waves mismatch (-got +want):\n%s", diff)
+	}
+
+	snapshot.Tables[1].ParentOnDeleteAction = "SET NULL"
+	if _, err := PlanDeletion(context.Background(), nil, nil, nil, WithSchemaSnapshot(snapshot)); err == nil {
+		t.Errorf("PlanDeletion() succeeded with an unknown delete action, want error")
+	}
+}
@@ -0,0 +1,106 @@
+//
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package truncate
+
+import (
+	"context"
+	"time"
+
+	"cloud.google.com/go/spanner"
+)
+
+// statsProgressInterval is the interval to poll table size statistics in stats progress mode.
+// The statistics are refreshed hourly, so polling more often is pointless.
+const statsProgressInterval = time.Minute
+
+// WithStatsProgress estimates the progress of deletion from table size statistics (SPANNER_SYS.TABLE_SIZES_STATS_1HOUR)
+// instead of counting rows with COUNT(*) every few seconds, which is expensive on huge tables.
+// Remaining rows are estimated from the row count before deletion scaled by the ratio of the bytes used by the table.
+// As the statistics are refreshed hourly, the progress moves in coarse steps. Tables not in the statistics are counted as usual.
+func WithStatsProgress() Option {
+	return func(o *options) {
+		o.statsProgress = true
+	}
+}
+
+// statsProgress estimates remaining rows of tables from table size statistics.
+type statsProgress struct {
+	client *spanner.Client
+
+	// Row counts and bytes used by tables before deletion, which the estimates are based on.
+	rowCounts    map[string]int64
+	initialBytes map[string]int64
+}
+
+// tracks returns true if the progress of the table is estimated from the statistics.
+func (p *statsProgress) tracks(tableName string) bool {
+	if p == nil {
+		return false
+	}
+	_, counted := p.rowCounts[tableName]
+	bytes, sized := p.initialBytes[tableName]
+	return counted && sized && bytes > 0
+}
+
+// run periodically updates remaining rows of the tracked tables until they are all done or the context is done.
+func (p *statsProgress) run(ctx context.Context, tables []*table) {
+	var tracked []*table
+	for _, t := range flattenTables(tables) {
+		if p.tracks(t.tableName) {
+			t.deleter.setRowCount(p.rowCounts[t.tableName])
+			tracked = append(tracked, t)
+		}
+	}
+	if len(tracked) == 0 {
+		return
+	}
+
+	ticker := time.NewTicker(statsProgressInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+		done := true
+		for _, t := range tracked {
+			done = done && t.deleter.isDone()
+		}
+		if done {
+			return
+		}
+		// Failures are ignored, as the progress is just shown as it was.
+		sizes, err := fetchTableSizes(ctx, p.client)
+		if err != nil {
+			continue
+		}
+		for _, t := range tracked {
+			if bytes, ok := sizes[t.tableName]; ok {
+				t.deleter.setEstimatedRows(estimateRemainingRows(p.rowCounts[t.tableName], p.initialBytes[t.tableName], bytes))
+			}
+		}
+	}
+}
+
+// estimateRemainingRows scales the initial row count by the ratio of the current bytes to the initial bytes.
+func estimateRemainingRows(initialRows, initialBytes, currentBytes int64) int64 {
+	if currentBytes >= initialBytes {
+		return initialRows
+	}
+	return int64(float64(initialRows) * float64(currentBytes) / float64(initialBytes))
+}
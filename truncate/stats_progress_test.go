@@ -0,0 +1,68 @@
+//
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package truncate
+
+import (
+	"testing"
+)
+
+func TestEstimateRemainingRows(t *testing.T) {
+	for _, tt := range []struct {
+		desc                                    string
+		initialRows, initialBytes, currentBytes int64
+		want                                    int64
+	}{
+		{desc: "unchanged", initialRows: 1000, initialBytes: 4096, currentBytes: 4096, want: 1000},
+		{desc: "grown", initialRows: 1000, initialBytes: 4096, currentBytes: 8192, want: 1000},
+		{desc: "halved", initialRows: 1000, initialBytes: 4096, currentBytes: 2048, want: 500},
+		{desc: "empty", initialRows: 1000, initialBytes: 4096, currentBytes: 0, want: 0},
+	} {
+		t.Run(tt.desc, func(t *testing.T) {
+			if got := estimateRemainingRows(tt.initialRows, tt.initialBytes, tt.currentBytes); got != tt.want {
+				t.Errorf("estimateRemainingRows() = %d, but want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStatsProgressTracks(t *testing.T) {
+	p := &statsProgress{
+		rowCounts:    map[string]int64{"Singers": 10, "Albums": 20, "Songs": 30},
+		initialBytes: map[string]int64{"Singers": 1024, "Songs": 0, "Venues": 1024},
+	}
+	for name, want := range map[string]bool{"Singers": true, "Albums": false, "Songs": false, "Venues": false} {
+		if got := p.tracks(name); got != want {
+			t.Errorf("tracks(%q) = %v, but want %v", name, got, want)
+		}
+	}
+	var nilProgress *statsProgress
+	if nilProgress.tracks("Singers") {
+		t.Errorf("tracks() on nil = true, but want false")
+	}
+}
+
+func TestDeleterSetEstimatedRows(t *testing.T) {
+	d := &deleter{status: statusDeleting, totalRows: 1000, remainedRows: 500}
+	d.setEstimatedRows(800)
+	if d.remainedRows != 500 {
+		t.Errorf("remainedRows = %d after a larger estimate, but want 500", d.remainedRows)
+	}
+	d.setEstimatedRows(0)
+	if d.remainedRows != 0 || d.status != statusDeleting {
+		t.Errorf("remainedRows = %d, status = %v, but want 0 and deleting", d.remainedRows, d.status)
+	}
+}
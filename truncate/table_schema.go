@@ -19,6 +19,10 @@ package truncate
 import (
 	"context"
 	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
 
 	"cloud.google.com/go/spanner"
 )
@@ -40,8 +44,27 @@ type tableSchema struct {
 	parentTableName      string
 	parentOnDeleteAction deleteActionType
 
-	// Foreign Key Reference.
+	// Foreign keys referencing the table, and the tables which have them without duplicates.
+	// A table may reference the same table by multiple foreign keys.
+	foreignKeys  []*foreignKey
 	referencedBy []string
+
+	// Expression of the row deletion policy (TTL) of the table, blank if the table has none.
+	rowDeletionPolicy string
+}
+
+// foreignKey represents a foreign key constraint, whose referencing table's rows reference rows of the referenced table.
+type foreignKey struct {
+	constraintName   string
+	referencingTable string
+	referencedTable  string
+
+	// Action on deleting referenced rows, either deleteActionCascadeDelete or deleteActionNoAction.
+	onDelete deleteActionType
+
+	// Columns of the referencing table, and the columns of the referenced table they reference in the same order.
+	referencingColumns []string
+	referencedColumns  []string
 }
 
 func (t *tableSchema) isCascadeDeletable() bool {
@@ -52,6 +75,34 @@ func (t *tableSchema) isRoot() bool {
 	return t.parentTableName == ""
 }
 
+// isReferencedInCascade returns true if the referencing table references the table only by foreign keys with
+// ON DELETE CASCADE, so its referencing rows are deleted together with the referenced rows.
+func (t *tableSchema) isReferencedInCascade(referencingTable string) bool {
+	var found bool
+	for _, fk := range t.foreignKeys {
+		if fk.referencingTable != referencingTable {
+			continue
+		}
+		if fk.onDelete != deleteActionCascadeDelete {
+			return false
+		}
+		found = true
+	}
+	return found
+}
+
+// selfReferences returns foreign keys by which the table references itself without ON DELETE CASCADE.
+// Rows of such a table can't be deleted before the rows referencing them.
+func (t *tableSchema) selfReferences() []*foreignKey {
+	var fks []*foreignKey
+	for _, fk := range t.foreignKeys {
+		if fk.referencingTable == t.tableName && fk.onDelete != deleteActionCascadeDelete {
+			fks = append(fks, fk)
+		}
+	}
+	return fks
+}
+
 // indexSchema represents secondary index metadata.
 type indexSchema struct {
 	indexName string
@@ -61,6 +112,41 @@ type indexSchema struct {
 
 	// Table name the index interleaved in. If blank, the index is a global index.
 	parentTableName string
+
+	// Whether the index is a full-text search index.
+	isSearch bool
+}
+
+func (i *indexSchema) isGlobal() bool {
+	return i.parentTableName == ""
+}
+
+// changeStreamSchema represents change stream metadata.
+type changeStreamSchema struct {
+	changeStreamName string
+
+	// Whether the change stream watches all tables, i.e. declared with FOR ALL.
+	all bool
+
+	// Tables explicitly watched by the change stream.
+	tableNames []string
+
+	// Options of the change stream. Blank if not set explicitly.
+	retentionPeriod  string
+	valueCaptureType string
+}
+
+// watches returns true if the change stream watches the table.
+func (c *changeStreamSchema) watches(tableName string) bool {
+	if c.all {
+		return true
+	}
+	for _, name := range c.tableNames {
+		if name == tableName {
+			return true
+		}
+	}
+	return false
 }
 
 // tableLineage represents a table schema and its ancestors and descendants.
@@ -72,39 +158,80 @@ type tableLineage struct {
 	descendants []*tableSchema
 }
 
-// fetchTableSchemas fetches schema information from spanner database.
-func fetchTableSchemas(ctx context.Context, client *spanner.Client) ([]*tableSchema, error) {
-	// This query fetches the table metadata and relationships.
-	iter := client.Single().Query(ctx, spanner.NewStatement(`
-		WITH FKReferences AS (
-			SELECT CCU.TABLE_NAME AS Referenced, ARRAY_AGG(TC.TABLE_NAME) AS Referencing
-			FROM INFORMATION_SCHEMA.TABLE_CONSTRAINTS as TC
-			INNER JOIN INFORMATION_SCHEMA.CONSTRAINT_COLUMN_USAGE AS CCU ON TC.CONSTRAINT_NAME = CCU.CONSTRAINT_NAME
-			WHERE TC.TABLE_CATALOG = '' AND TC.TABLE_SCHEMA = '' AND TC.CONSTRAINT_TYPE = 'FOREIGN KEY' AND CCU.TABLE_CATALOG = '' AND CCU.TABLE_SCHEMA = ''
-			GROUP BY CCU.TABLE_NAME
-		)
-		SELECT T.TABLE_NAME, T.PARENT_TABLE_NAME, T.ON_DELETE_ACTION, IF(F.Referencing IS NULL, ARRAY<STRING>[], F.Referencing) AS referencedBy
+// tableSchemasSQL fetches the table metadata and interleaving relationships.
+const tableSchemasSQL = `
+		SELECT T.TABLE_SCHEMA, T.TABLE_NAME, T.PARENT_TABLE_NAME, T.ON_DELETE_ACTION
 		FROM INFORMATION_SCHEMA.TABLES AS T
-		LEFT OUTER JOIN FKReferences AS F ON T.TABLE_NAME = F.Referenced
-		WHERE T.TABLE_CATALOG = "" AND T.TABLE_SCHEMA = "" AND T.TABLE_TYPE = "BASE TABLE"
-		ORDER BY T.TABLE_NAME ASC
-	`))
+		WHERE T.TABLE_CATALOG = "" AND (@all OR T.TABLE_SCHEMA IN UNNEST(@schemas)) AND T.TABLE_SCHEMA NOT IN ("INFORMATION_SCHEMA", "SPANNER_SYS") AND T.TABLE_TYPE = "BASE TABLE"
+		ORDER BY T.TABLE_SCHEMA ASC, T.TABLE_NAME ASC
+	`
+
+// foreignKeysSQL fetches foreign keys in all schemas, so that references from tables out of the scope are found.
+// The referenced table is the table of the unique constraint which the foreign key refers to, i.e. the primary key
+// or a unique index of the referenced table, and one row is returned for each foreign key regardless of its columns.
+const foreignKeysSQL = `
+		SELECT RC.CONSTRAINT_SCHEMA, RC.CONSTRAINT_NAME, TC.TABLE_SCHEMA, TC.TABLE_NAME, UC.TABLE_SCHEMA, UC.TABLE_NAME, RC.DELETE_RULE,
+			ARRAY(
+				SELECT K.COLUMN_NAME FROM INFORMATION_SCHEMA.KEY_COLUMN_USAGE AS K
+				WHERE K.CONSTRAINT_CATALOG = RC.CONSTRAINT_CATALOG AND K.CONSTRAINT_SCHEMA = RC.CONSTRAINT_SCHEMA AND K.CONSTRAINT_NAME = RC.CONSTRAINT_NAME
+				ORDER BY K.ORDINAL_POSITION
+			) AS ReferencingColumns,
+			ARRAY(
+				SELECT U.COLUMN_NAME FROM INFORMATION_SCHEMA.KEY_COLUMN_USAGE AS K
+				INNER JOIN INFORMATION_SCHEMA.KEY_COLUMN_USAGE AS U
+					ON U.CONSTRAINT_CATALOG = RC.UNIQUE_CONSTRAINT_CATALOG AND U.CONSTRAINT_SCHEMA = RC.UNIQUE_CONSTRAINT_SCHEMA AND U.CONSTRAINT_NAME = RC.UNIQUE_CONSTRAINT_NAME
+					AND U.ORDINAL_POSITION = K.POSITION_IN_UNIQUE_CONSTRAINT
+				WHERE K.CONSTRAINT_CATALOG = RC.CONSTRAINT_CATALOG AND K.CONSTRAINT_SCHEMA = RC.CONSTRAINT_SCHEMA AND K.CONSTRAINT_NAME = RC.CONSTRAINT_NAME
+				ORDER BY K.ORDINAL_POSITION
+			) AS ReferencedColumns
+		FROM INFORMATION_SCHEMA.REFERENTIAL_CONSTRAINTS AS RC
+		INNER JOIN INFORMATION_SCHEMA.TABLE_CONSTRAINTS AS TC
+			ON RC.CONSTRAINT_CATALOG = TC.CONSTRAINT_CATALOG AND RC.CONSTRAINT_SCHEMA = TC.CONSTRAINT_SCHEMA AND RC.CONSTRAINT_NAME = TC.CONSTRAINT_NAME
+		INNER JOIN INFORMATION_SCHEMA.TABLE_CONSTRAINTS AS UC
+			ON RC.UNIQUE_CONSTRAINT_CATALOG = UC.CONSTRAINT_CATALOG AND RC.UNIQUE_CONSTRAINT_SCHEMA = UC.CONSTRAINT_SCHEMA AND RC.UNIQUE_CONSTRAINT_NAME = UC.CONSTRAINT_NAME
+		WHERE RC.CONSTRAINT_CATALOG = ""
+		ORDER BY RC.CONSTRAINT_SCHEMA ASC, RC.CONSTRAINT_NAME ASC
+	`
+
+// fetchTableSchemas fetches schema information from spanner database.
+// Tables are fetched from the schemas in the scope, and tables in a named schema are named with the schema, e.g. "analytics.Events".
+func fetchTableSchemas(ctx context.Context, client *spanner.Client, scope schemaScope) ([]*tableSchema, error) {
+	tables, err := fetchTableSchemasWithoutForeignKeys(ctx, client, scope)
+	if err != nil {
+		return nil, err
+	}
+	fks, err := fetchForeignKeys(ctx, client)
+	if err != nil {
+		return nil, err
+	}
+	attachForeignKeys(tables, fks)
+	return tables, nil
+}
+
+// fetchTableSchemasWithoutForeignKeys is the same as fetchTableSchemas, but no tables are referenced by foreign keys.
+// It's for emulators lacking INFORMATION_SCHEMA.REFERENTIAL_CONSTRAINTS and TABLE_CONSTRAINTS.
+func fetchTableSchemasWithoutForeignKeys(ctx context.Context, client *spanner.Client, scope schemaScope) ([]*tableSchema, error) {
+	iter := client.Single().Query(ctx, spanner.Statement{
+		SQL:    tableSchemasSQL,
+		Params: scope.params(),
+	})
 
 	var tables []*tableSchema
 	if err := iter.Do(func(r *spanner.Row) error {
 		var (
+			schema       string
 			tableName    string
 			parent       spanner.NullString
 			deleteAction spanner.NullString
-			referencedBy []string
 		)
-		if err := r.Columns(&tableName, &parent, &deleteAction, &referencedBy); err != nil {
+		if err := r.Columns(&schema, &tableName, &parent, &deleteAction); err != nil {
 			return err
 		}
 
+		// An interleaved table is always in the same schema as its parent.
 		var parentTableName string
 		if parent.Valid {
-			parentTableName = parent.StringVal
+			parentTableName = qualifyName(schema, parent.StringVal)
 		}
 
 		var typ deleteActionType
@@ -118,10 +245,9 @@ func fetchTableSchemas(ctx context.Context, client *spanner.Client) ([]*tableSch
 		}
 
 		tables = append(tables, &tableSchema{
-			tableName:            tableName,
+			tableName:            qualifyName(schema, tableName),
 			parentTableName:      parentTableName,
 			parentOnDeleteAction: typ,
-			referencedBy:         referencedBy,
 		})
 		return nil
 	}); err != nil {
@@ -131,6 +257,58 @@ func fetchTableSchemas(ctx context.Context, client *spanner.Client) ([]*tableSch
 	return tables, nil
 }
 
+// fetchForeignKeys fetches all foreign keys in the database.
+func fetchForeignKeys(ctx context.Context, client *spanner.Client) ([]*foreignKey, error) {
+	iter := client.Single().Query(ctx, spanner.NewStatement(foreignKeysSQL))
+
+	var fks []*foreignKey
+	if err := iter.Do(func(r *spanner.Row) error {
+		var constraintSchema, constraintName, referencingSchema, referencingTable, referencedSchema, referencedTable, deleteRule string
+		var referencingColumns, referencedColumns []string
+		if err := r.Columns(&constraintSchema, &constraintName, &referencingSchema, &referencingTable, &referencedSchema, &referencedTable, &deleteRule, &referencingColumns, &referencedColumns); err != nil {
+			return err
+		}
+		onDelete := deleteActionNoAction
+		if deleteRule == "CASCADE" {
+			onDelete = deleteActionCascadeDelete
+		}
+		fks = append(fks, &foreignKey{
+			constraintName:   qualifyName(constraintSchema, constraintName),
+			referencingTable: qualifyName(referencingSchema, referencingTable),
+			referencedTable:  qualifyName(referencedSchema, referencedTable),
+			onDelete:         onDelete,
+
+			referencingColumns: referencingColumns,
+			referencedColumns:  referencedColumns,
+		})
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	return fks, nil
+}
+
+// attachForeignKeys sets the foreign keys to the tables they reference.
+// Foreign keys referencing none of the tables, e.g. tables in other schemas, are ignored.
+func attachForeignKeys(tables []*tableSchema, fks []*foreignKey) {
+	tableMap := make(map[string]*tableSchema, len(tables))
+	for _, t := range tables {
+		tableMap[t.tableName] = t
+	}
+
+	for _, fk := range fks {
+		t, ok := tableMap[fk.referencedTable]
+		if !ok {
+			continue
+		}
+		t.foreignKeys = append(t.foreignKeys, fk)
+		if !slices.Contains(t.referencedBy, fk.referencingTable) {
+			t.referencedBy = append(t.referencedBy, fk.referencingTable)
+		}
+	}
+}
+
 // filterTableSchemas filters tables with given targetTables and excludeTables.
 // If targetTables is not empty, it fetches only the specified tables.
 // If excludeTables is not empty, it excludes the specified tables.
@@ -219,6 +397,44 @@ func excludeFilterTableSchemas(tables []*tableSchema, excludeTableSchemas []stri
 	return filtered
 }
 
+// findUnknownTables returns names which don't match any of the given tables.
+func findUnknownTables(tables []*tableSchema, names []string) []string {
+	exists := make(map[string]bool, len(tables))
+	for _, t := range tables {
+		exists[t.tableName] = true
+	}
+
+	var unknown []string
+	for _, name := range names {
+		if !exists[name] {
+			unknown = append(unknown, name)
+		}
+	}
+	return unknown
+}
+
+// tableSchemaNames returns the names of the tables.
+func tableSchemaNames(tables []*tableSchema) []string {
+	names := make([]string, len(tables))
+	for i, t := range tables {
+		names[i] = t.tableName
+	}
+	return names
+}
+
+// didYouMean returns a sentence suggesting existing tables similar to the given name, or blank if there are none.
+func didYouMean(name string, tables []*tableSchema) string {
+	suggestions := suggestNames(name, tableSchemaNames(tables))
+	if len(suggestions) == 0 {
+		return ""
+	}
+	quoted := make([]string, len(suggestions))
+	for i, s := range suggestions {
+		quoted[i] = strconv.Quote(s)
+	}
+	return fmt.Sprintf(". Did you mean %s?", strings.Join(quoted, " or "))
+}
+
 // constructTableLineages returns a list of interleave Lineages.
 // This function creates tableLineage for each of all given tableSchemas.
 func constructTableLineages(tables []*tableSchema) []*tableLineage {
@@ -266,33 +482,39 @@ func findDescendants(table *tableSchema, childRelation map[string][]*tableSchema
 	return descendants
 }
 
-func fetchIndexSchemas(ctx context.Context, client *spanner.Client) ([]*indexSchema, error) {
+func fetchIndexSchemas(ctx context.Context, client *spanner.Client, scope schemaScope) ([]*indexSchema, error) {
 	// This query fetches defined indexes.
-	iter := client.Single().Query(ctx, spanner.NewStatement(`
-		SELECT INDEX_NAME, TABLE_NAME, PARENT_TABLE_NAME FROM INFORMATION_SCHEMA.INDEXES
-		WHERE INDEX_TYPE = 'INDEX' AND TABLE_CATALOG = '' AND TABLE_SCHEMA = '';
-	`))
+	iter := client.Single().Query(ctx, spanner.Statement{
+		SQL: `
+		SELECT TABLE_SCHEMA, INDEX_NAME, TABLE_NAME, PARENT_TABLE_NAME, INDEX_TYPE FROM INFORMATION_SCHEMA.INDEXES
+		WHERE INDEX_TYPE IN ('INDEX', 'SEARCH') AND TABLE_CATALOG = '' AND (@all OR TABLE_SCHEMA IN UNNEST(@schemas));
+	`,
+		Params: scope.params(),
+	})
 
 	var indexes []*indexSchema
 	if err := iter.Do(func(r *spanner.Row) error {
 		var (
+			schema        string
 			indexName     string
 			baseTableName string
 			parent        spanner.NullString
+			indexType     string
 		)
-		if err := r.Columns(&indexName, &baseTableName, &parent); err != nil {
+		if err := r.Columns(&schema, &indexName, &baseTableName, &parent, &indexType); err != nil {
 			return err
 		}
 
 		var parentTableName string
 		if parent.Valid {
-			parentTableName = parent.StringVal
+			parentTableName = qualifyName(schema, parent.StringVal)
 		}
 
 		indexes = append(indexes, &indexSchema{
-			indexName:       indexName,
-			baseTableName:   baseTableName,
+			indexName:       qualifyName(schema, indexName),
+			baseTableName:   qualifyName(schema, baseTableName),
 			parentTableName: parentTableName,
+			isSearch:        indexType == "SEARCH",
 		})
 		return nil
 	}); err != nil {
@@ -301,3 +523,145 @@ func fetchIndexSchemas(ctx context.Context, client *spanner.Client) ([]*indexSch
 
 	return indexes, nil
 }
+
+// fetchKeyColumns fetches the primary key columns of the table in order.
+// A table with an empty primary key has no columns.
+func fetchKeyColumns(ctx context.Context, client *spanner.Client, tableName string) ([]string, error) {
+	schema, name, ok := strings.Cut(tableName, ".")
+	if !ok {
+		schema, name = "", tableName
+	}
+	iter := client.Single().Query(ctx, spanner.Statement{
+		SQL: `
+		SELECT COLUMN_NAME FROM INFORMATION_SCHEMA.INDEX_COLUMNS
+		WHERE TABLE_CATALOG = '' AND TABLE_SCHEMA = @schema AND TABLE_NAME = @table AND INDEX_NAME = 'PRIMARY_KEY'
+		ORDER BY ORDINAL_POSITION;
+	`,
+		Params: map[string]interface{}{"schema": schema, "table": name},
+	})
+	var columns []string
+	if err := iter.Do(func(r *spanner.Row) error {
+		var column string
+		if err := r.Columns(&column); err != nil {
+			return err
+		}
+		columns = append(columns, column)
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	return columns, nil
+}
+
+func fetchChangeStreams(ctx context.Context, client *spanner.Client) ([]*changeStreamSchema, error) {
+	// This query fetches defined change streams and the tables watched by them.
+	iter := client.Single().Query(ctx, spanner.NewStatement(`
+		SELECT CS.CHANGE_STREAM_NAME, CS.`+"`ALL`"+`,
+			ARRAY(
+				SELECT IF(CST.TABLE_SCHEMA = '', CST.TABLE_NAME, CONCAT(CST.TABLE_SCHEMA, '.', CST.TABLE_NAME)) FROM INFORMATION_SCHEMA.CHANGE_STREAM_TABLES AS CST
+				WHERE CST.CHANGE_STREAM_CATALOG = CS.CHANGE_STREAM_CATALOG AND CST.CHANGE_STREAM_SCHEMA = CS.CHANGE_STREAM_SCHEMA AND CST.CHANGE_STREAM_NAME = CS.CHANGE_STREAM_NAME
+				ORDER BY CST.TABLE_NAME
+			) AS TABLE_NAMES,
+			(
+				SELECT CSO.OPTION_VALUE FROM INFORMATION_SCHEMA.CHANGE_STREAM_OPTIONS AS CSO
+				WHERE CSO.CHANGE_STREAM_CATALOG = CS.CHANGE_STREAM_CATALOG AND CSO.CHANGE_STREAM_SCHEMA = CS.CHANGE_STREAM_SCHEMA AND CSO.CHANGE_STREAM_NAME = CS.CHANGE_STREAM_NAME
+					AND CSO.OPTION_NAME = 'retention_period'
+			) AS RETENTION_PERIOD,
+			(
+				SELECT CSO.OPTION_VALUE FROM INFORMATION_SCHEMA.CHANGE_STREAM_OPTIONS AS CSO
+				WHERE CSO.CHANGE_STREAM_CATALOG = CS.CHANGE_STREAM_CATALOG AND CSO.CHANGE_STREAM_SCHEMA = CS.CHANGE_STREAM_SCHEMA AND CSO.CHANGE_STREAM_NAME = CS.CHANGE_STREAM_NAME
+					AND CSO.OPTION_NAME = 'value_capture_type'
+			) AS VALUE_CAPTURE_TYPE
+		FROM INFORMATION_SCHEMA.CHANGE_STREAMS AS CS
+		WHERE CS.CHANGE_STREAM_CATALOG = '' AND CS.CHANGE_STREAM_SCHEMA = ''
+		ORDER BY CS.CHANGE_STREAM_NAME;
+	`))
+
+	var changeStreams []*changeStreamSchema
+	if err := iter.Do(func(r *spanner.Row) error {
+		var (
+			name             string
+			all              spanner.NullBool
+			tableNames       []string
+			retentionPeriod  spanner.NullString
+			valueCaptureType spanner.NullString
+		)
+		if err := r.Columns(&name, &all, &tableNames, &retentionPeriod, &valueCaptureType); err != nil {
+			return err
+		}
+
+		changeStreams = append(changeStreams, &changeStreamSchema{
+			changeStreamName: name,
+			all:              all.Valid && all.Bool,
+			tableNames:       tableNames,
+			retentionPeriod:  retentionPeriod.StringVal,
+			valueCaptureType: valueCaptureType.StringVal,
+		})
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	return changeStreams, nil
+}
+
+// schemaScope is a set of schemas whose tables are targeted.
+type schemaScope struct {
+	// Whether all schemas are targeted. If true, names is ignored.
+	all bool
+
+	// Names of the targeted schemas. A blank name is the default schema.
+	names []string
+}
+
+// defaultSchema returns the schema of tables given without a schema. It's the only schema
+// if the scope is a single schema, otherwise the default schema.
+func (s schemaScope) defaultSchema() string {
+	if !s.all && len(s.names) == 1 {
+		return s.names[0]
+	}
+	return ""
+}
+
+// params returns query parameters to filter rows of INFORMATION_SCHEMA by the scope.
+func (s schemaScope) params() map[string]interface{} {
+	names := s.names
+	if names == nil {
+		names = []string{}
+	}
+	return map[string]interface{}{"all": s.all, "schemas": names}
+}
+
+// qualifyName returns the name of an object in the schema, prefixed with the schema unless it's the default schema.
+func qualifyName(schema, name string) string {
+	if schema == "" {
+		return name
+	}
+	return schema + "." + name
+}
+
+// qualifyNames qualifies names which aren't qualified yet with the schema.
+// Regular expressions are left as is, as they are matched against qualified names.
+func qualifyNames(schema string, names []string) []string {
+	if schema == "" || len(names) == 0 {
+		return names
+	}
+	qualified := make([]string, len(names))
+	for i, name := range names {
+		if strings.Contains(name, ".") || isRegexpPattern(name) {
+			qualified[i] = name
+		} else {
+			qualified[i] = qualifyName(schema, name)
+		}
+	}
+	return qualified
+}
+
+// quoteIdentifier quotes each part of the possibly qualified name for GoogleSQL, e.g. "analytics.Events" => "`analytics`.`Events`".
+func quoteIdentifier(name string) string {
+	parts := strings.Split(name, ".")
+	for i, part := range parts {
+		parts[i] = "`" + part + "`"
+	}
+	return strings.Join(parts, ".")
+}
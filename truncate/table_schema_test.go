@@ -266,3 +266,163 @@ func TestExcludeFilterTableSchemas(t *testing.T) {
 		})
 	}
 }
+
+func TestAttachForeignKeys(t *testing.T) {
+	tables := []*tableSchema{
+		{tableName: "Singers"},
+		{tableName: "Albums", parentTableName: "Singers"},
+		{tableName: "Concerts"},
+	}
+	fks := []*foreignKey{
+		{constraintName: "FK_ConcertsSinger", referencingTable: "Concerts", referencedTable: "Singers"},
+		{constraintName: "FK_ConcertsHeadliner", referencingTable: "Concerts", referencedTable: "Singers"},
+		{constraintName: "FK_AlbumsLabel", referencingTable: "Albums", referencedTable: "Labels"},
+		{constraintName: "analytics.FK_Plays", referencingTable: "analytics.Plays", referencedTable: "Albums"},
+	}
+	attachForeignKeys(tables, fks)
+
+	want := []*tableSchema{
+		{tableName: "Singers", foreignKeys: fks[:2], referencedBy: []string{"Concerts"}},
+		{tableName: "Albums", parentTableName: "Singers", foreignKeys: fks[3:], referencedBy: []string{"analytics.Plays"}},
+		{tableName: "Concerts"},
+	}
+	if diff := cmp.Diff(want, tables, cmp.AllowUnexported(tableSchema{}, foreignKey{})); diff != "" {
+		t.Errorf("(-want, +got)\n%s", diff)
+	}
+}
+
+func TestIsReferencedInCascade(t *testing.T) {
+	singers := &tableSchema{
+		tableName: "Singers",
+		foreignKeys: []*foreignKey{
+			{constraintName: "FK_Concerts", referencingTable: "Concerts", referencedTable: "Singers", onDelete: deleteActionCascadeDelete},
+			{constraintName: "FK_TicketsSinger", referencingTable: "Tickets", referencedTable: "Singers", onDelete: deleteActionCascadeDelete},
+			{constraintName: "FK_TicketsGuest", referencingTable: "Tickets", referencedTable: "Singers", onDelete: deleteActionNoAction},
+			{constraintName: "FK_Venues", referencingTable: "Venues", referencedTable: "Singers", onDelete: deleteActionNoAction},
+		},
+		referencedBy: []string{"Concerts", "Tickets", "Venues"},
+	}
+	for _, tt := range []struct {
+		referencing string
+		want        bool
+	}{
+		{referencing: "Concerts", want: true},
+		{referencing: "Tickets", want: false},
+		{referencing: "Venues", want: false},
+		{referencing: "Albums", want: false},
+	} {
+		if got := singers.isReferencedInCascade(tt.referencing); got != tt.want {
+			t.Errorf("isReferencedInCascade(%q) = %v, want %v", tt.referencing, got, tt.want)
+		}
+	}
+}
+
+func TestFindUnknownTables(t *testing.T) {
+	tables := []*tableSchema{
+		{tableName: "Singers"},
+		{tableName: "Albums", parentTableName: "Singers"},
+	}
+
+	got := findUnknownTables(tables, []string{"Singers", "Album", "Songs"})
+	want := []string{"Album", "Songs"}
+	if !cmp.Equal(got, want) {
+		t.Errorf("diff(+got, -want) = %v", cmp.Diff(got, want))
+	}
+}
+
+func TestDidYouMean(t *testing.T) {
+	tables := []*tableSchema{
+		{tableName: "Singers"},
+		{tableName: "Songs"},
+	}
+	for _, tt := range []struct {
+		input string
+		want  string
+	}{
+		{"singer", `. Did you mean "Singers"?`},
+		{"Song", `. Did you mean "Songs"?`},
+		{"Singrs", `. Did you mean "Singers" or "Songs"?`},
+		{"Venues", ""},
+	} {
+		if got := didYouMean(tt.input, tables); got != tt.want {
+			t.Errorf("didYouMean(%q) = %q, but want = %q", tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestChangeStreamWatches(t *testing.T) {
+	all := &changeStreamSchema{changeStreamName: "All", all: true}
+	singers := &changeStreamSchema{changeStreamName: "SingersStream", tableNames: []string{"Singers", "Albums"}}
+	for _, test := range []struct {
+		changeStream *changeStreamSchema
+		tableName    string
+		want         bool
+	}{
+		{all, "Singers", true},
+		{all, "Concerts", true},
+		{singers, "Albums", true},
+		{singers, "Concerts", false},
+	} {
+		if got := test.changeStream.watches(test.tableName); got != test.want {
+			t.Errorf("%s.watches(%q) = %v, but want = %v", test.changeStream.changeStreamName, test.tableName, got, test.want)
+		}
+	}
+}
+
+func TestQualifyNames(t *testing.T) {
+	for _, test := range []struct {
+		desc   string
+		schema string
+		names  []string
+		want   []string
+	}{
+		{desc: "default schema", schema: "", names: []string{"Events"}, want: []string{"Events"}},
+		{desc: "named schema", schema: "analytics", names: []string{"Events", "analytics.Sessions"}, want: []string{"analytics.Events", "analytics.Sessions"}},
+		{desc: "no names", schema: "analytics", names: nil, want: nil},
+		{desc: "patterns", schema: "analytics", names: []string{"tmp_*", "/^audit_/"}, want: []string{"analytics.tmp_*", "/^audit_/"}},
+	} {
+		t.Run(test.desc, func(t *testing.T) {
+			if got := qualifyNames(test.schema, test.names); !cmp.Equal(got, test.want) {
+				t.Errorf("diff(+got, -want) = %v", cmp.Diff(got, test.want))
+			}
+		})
+	}
+}
+
+func TestQuoteIdentifier(t *testing.T) {
+	for _, test := range []struct {
+		name string
+		want string
+	}{
+		{"Events", "`Events`"},
+		{"analytics.Events", "`analytics`.`Events`"},
+	} {
+		if got := quoteIdentifier(test.name); got != test.want {
+			t.Errorf("quoteIdentifier(%q) = %q, but want = %q", test.name, got, test.want)
+		}
+	}
+}
+
+func TestSchemaScope(t *testing.T) {
+	for _, test := range []struct {
+		desc              string
+		opts              []Option
+		want              schemaScope
+		wantDefaultSchema string
+	}{
+		{desc: "default schema", opts: nil, want: schemaScope{names: []string{""}}, wantDefaultSchema: ""},
+		{desc: "named schema", opts: []Option{WithSchemas("analytics")}, want: schemaScope{names: []string{"analytics"}}, wantDefaultSchema: "analytics"},
+		{desc: "multiple schemas", opts: []Option{WithSchemas("analytics", "sales")}, want: schemaScope{names: []string{"analytics", "sales"}}, wantDefaultSchema: ""},
+		{desc: "all schemas", opts: []Option{WithSchemas("analytics"), WithAllSchemas()}, want: schemaScope{all: true}, wantDefaultSchema: ""},
+	} {
+		t.Run(test.desc, func(t *testing.T) {
+			got := newOptions(test.opts).schemaScope()
+			if !cmp.Equal(got, test.want, cmp.AllowUnexported(schemaScope{})) {
+				t.Errorf("diff(+got, -want) = %v", cmp.Diff(got, test.want, cmp.AllowUnexported(schemaScope{})))
+			}
+			if got := got.defaultSchema(); got != test.wantDefaultSchema {
+				t.Errorf("defaultSchema() = %q, but want %q", got, test.wantDefaultSchema)
+			}
+		})
+	}
+}
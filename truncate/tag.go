@@ -0,0 +1,150 @@
+//
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package truncate
+
+import (
+	"context"
+	"time"
+
+	"google.golang.org/api/option"
+	sppb "google.golang.org/genproto/googleapis/spanner/v1"
+	"google.golang.org/grpc"
+)
+
+// defaultRequestTag is the request tag of requests issued by a run if not specified by WithRequestTag.
+const defaultRequestTag = "spanner-truncate"
+
+// WithRequestTag tags queries and DML statements issued by the run with the request tag,
+// so that they can be identified in query statistics. The default is "spanner-truncate". An empty tag disables tagging.
+// Tags are attached only if the client is created with ClientOptions.
+func WithRequestTag(tag string) Option {
+	return func(o *options) {
+		o.requestTag = &tag
+	}
+}
+
+// WithTransactionTag tags read-write and Partitioned DML transactions issued by the run with the transaction tag,
+// so that they can be identified in transaction and lock statistics. The default is a tag unique to the run like
+// "spanner-truncate-20210601-120000". An empty tag disables tagging.
+// Tags are attached only if the client is created with ClientOptions.
+func WithTransactionTag(tag string) Option {
+	return func(o *options) {
+		o.transactionTag = &tag
+	}
+}
+
+// ClientOptions returns client options to be passed to spanner.NewClient, which enable the features of RunWithClient
+// depending on the client, i.e. counting PDML restarts and RPC retries, and tagging requests.
+func ClientOptions() []option.ClientOption {
+	return []option.ClientOption{
+		RetryStatsClientOption(),
+		option.WithGRPCDialOption(grpc.WithChainUnaryInterceptor(tagUnaryRequest)),
+		option.WithGRPCDialOption(grpc.WithChainStreamInterceptor(tagStreamRequest)),
+	}
+}
+
+// requestTags are tags attached to requests issued with a context carrying them.
+type requestTags struct {
+	request     string
+	transaction string
+}
+
+// requestTags returns the tags of the run started at now.
+func (o *options) requestTags(now time.Time) requestTags {
+	tags := requestTags{
+		request:     defaultRequestTag,
+		transaction: defaultRequestTag + "-" + now.UTC().Format("20060102-150405"),
+	}
+	if o.requestTag != nil {
+		tags.request = *o.requestTag
+	}
+	if o.transactionTag != nil {
+		tags.transaction = *o.transactionTag
+	}
+	return tags
+}
+
+type requestTagsKey struct{}
+
+// withRequestTags returns a context whose requests are tagged with the tags.
+func withRequestTags(ctx context.Context, tags requestTags) context.Context {
+	return context.WithValue(ctx, requestTagsKey{}, tags)
+}
+
+func tagUnaryRequest(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+	tagRequest(ctx, req)
+	return invoker(ctx, method, req, reply, cc, opts...)
+}
+
+func tagStreamRequest(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+	stream, err := streamer(ctx, desc, cc, method, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return &taggingStream{ClientStream: stream, ctx: ctx}, nil
+}
+
+// taggingStream tags requests sent on the stream.
+type taggingStream struct {
+	grpc.ClientStream
+	ctx context.Context
+}
+
+func (s *taggingStream) SendMsg(m interface{}) error {
+	tagRequest(s.ctx, m)
+	return s.ClientStream.SendMsg(m)
+}
+
+// tagRequest sets the tags in the context to the request, unless the request already has tags.
+// Transaction tags are set only to requests in read-write and Partitioned DML transactions.
+func tagRequest(ctx context.Context, req interface{}) {
+	tags, ok := ctx.Value(requestTagsKey{}).(requestTags)
+	if !ok {
+		return
+	}
+	switch r := req.(type) {
+	case *sppb.ExecuteSqlRequest:
+		r.RequestOptions = setTags(r.RequestOptions, tags.request, "")
+		if r.GetTransaction().GetId() != nil {
+			// Queries in single-use or read-only transactions don't belong to transactions to be tagged.
+			r.RequestOptions = setTags(r.RequestOptions, "", tags.transaction)
+		}
+	case *sppb.BeginTransactionRequest:
+		if r.GetOptions().GetReadOnly() == nil {
+			r.RequestOptions = setTags(r.RequestOptions, "", tags.transaction)
+		}
+	case *sppb.CommitRequest:
+		r.RequestOptions = setTags(r.RequestOptions, "", tags.transaction)
+	}
+}
+
+// setTags sets the tags to the options if they are not set yet. Blank tags are ignored.
+func setTags(opts *sppb.RequestOptions, requestTag, transactionTag string) *sppb.RequestOptions {
+	if requestTag == "" && transactionTag == "" {
+		return opts
+	}
+	if opts == nil {
+		opts = &sppb.RequestOptions{}
+	}
+	if opts.RequestTag == "" {
+		opts.RequestTag = requestTag
+	}
+	if opts.TransactionTag == "" {
+		opts.TransactionTag = transactionTag
+	}
+	return opts
+}
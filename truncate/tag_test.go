@@ -0,0 +1,109 @@
+//
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package truncate
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	sppb "google.golang.org/genproto/googleapis/spanner/v1"
+	"google.golang.org/protobuf/testing/protocmp"
+)
+
+func TestRequestTags(t *testing.T) {
+	now := time.Date(2021, 6, 1, 12, 0, 0, 0, time.UTC)
+	for _, tt := range []struct {
+		desc string
+		opts []Option
+		want requestTags
+	}{
+		{desc: "default", opts: nil, want: requestTags{request: "spanner-truncate", transaction: "spanner-truncate-20210601-120000"}},
+		{desc: "custom", opts: []Option{WithRequestTag("req"), WithTransactionTag("txn")}, want: requestTags{request: "req", transaction: "txn"}},
+		{desc: "disabled", opts: []Option{WithRequestTag(""), WithTransactionTag("")}, want: requestTags{}},
+	} {
+		t.Run(tt.desc, func(t *testing.T) {
+			got := newOptions(tt.opts).requestTags(now)
+			if diff := cmp.Diff(got, tt.want, cmp.AllowUnexported(requestTags{})); diff != "" {
+				t.Errorf("diff(+got, -want) = %v", diff)
+			}
+		})
+	}
+}
+
+func TestTagRequest(t *testing.T) {
+	ctx := withRequestTags(context.Background(), requestTags{request: "req", transaction: "txn"})
+	txnID := &sppb.TransactionSelector{Selector: &sppb.TransactionSelector_Id{Id: []byte("id")}}
+	singleUse := &sppb.TransactionSelector{Selector: &sppb.TransactionSelector_SingleUse{SingleUse: &sppb.TransactionOptions{}}}
+	for _, tt := range []struct {
+		desc string
+		ctx  context.Context
+		req  interface{}
+		want interface{}
+	}{
+		{
+			desc: "query in single-use transaction",
+			ctx:  ctx,
+			req:  &sppb.ExecuteSqlRequest{Transaction: singleUse},
+			want: &sppb.ExecuteSqlRequest{Transaction: singleUse, RequestOptions: &sppb.RequestOptions{RequestTag: "req"}},
+		},
+		{
+			desc: "DML in transaction",
+			ctx:  ctx,
+			req:  &sppb.ExecuteSqlRequest{Transaction: txnID, RequestOptions: &sppb.RequestOptions{Priority: sppb.RequestOptions_PRIORITY_LOW}},
+			want: &sppb.ExecuteSqlRequest{Transaction: txnID, RequestOptions: &sppb.RequestOptions{Priority: sppb.RequestOptions_PRIORITY_LOW, RequestTag: "req", TransactionTag: "txn"}},
+		},
+		{
+			desc: "already tagged",
+			ctx:  ctx,
+			req:  &sppb.ExecuteSqlRequest{Transaction: txnID, RequestOptions: &sppb.RequestOptions{RequestTag: "user"}},
+			want: &sppb.ExecuteSqlRequest{Transaction: txnID, RequestOptions: &sppb.RequestOptions{RequestTag: "user", TransactionTag: "txn"}},
+		},
+		{
+			desc: "begin partitioned DML",
+			ctx:  ctx,
+			req:  &sppb.BeginTransactionRequest{Options: &sppb.TransactionOptions{Mode: &sppb.TransactionOptions_PartitionedDml_{PartitionedDml: &sppb.TransactionOptions_PartitionedDml{}}}},
+			want: &sppb.BeginTransactionRequest{Options: &sppb.TransactionOptions{Mode: &sppb.TransactionOptions_PartitionedDml_{PartitionedDml: &sppb.TransactionOptions_PartitionedDml{}}}, RequestOptions: &sppb.RequestOptions{TransactionTag: "txn"}},
+		},
+		{
+			desc: "begin read-only",
+			ctx:  ctx,
+			req:  &sppb.BeginTransactionRequest{Options: &sppb.TransactionOptions{Mode: &sppb.TransactionOptions_ReadOnly_{ReadOnly: &sppb.TransactionOptions_ReadOnly{}}}},
+			want: &sppb.BeginTransactionRequest{Options: &sppb.TransactionOptions{Mode: &sppb.TransactionOptions_ReadOnly_{ReadOnly: &sppb.TransactionOptions_ReadOnly{}}}},
+		},
+		{
+			desc: "commit",
+			ctx:  ctx,
+			req:  &sppb.CommitRequest{},
+			want: &sppb.CommitRequest{RequestOptions: &sppb.RequestOptions{TransactionTag: "txn"}},
+		},
+		{
+			desc: "without tags",
+			ctx:  context.Background(),
+			req:  &sppb.CommitRequest{},
+			want: &sppb.CommitRequest{},
+		},
+	} {
+		t.Run(tt.desc, func(t *testing.T) {
+			tagRequest(tt.ctx, tt.req)
+			if diff := cmp.Diff(tt.req, tt.want, protocmp.Transform()); diff != "" {
+				t.Errorf("diff(+got, -want) = %v", diff)
+			}
+		})
+	}
+}
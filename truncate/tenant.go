@@ -0,0 +1,74 @@
+//
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package truncate
+
+import (
+	"fmt"
+)
+
+// tenant is the tenant whose rows are deleted, and the columns identifying tenants in tables given by WithTenant.
+type tenant struct {
+	id      interface{}
+	columns map[string]string
+}
+
+// WithTenant deletes only the rows of the tenant from the tables with tenant columns, e.g. to offboard a tenant from
+// a schema sharing tables among tenants. columns maps table names to the names of their columns holding tenant IDs,
+// and the tables are the target tables, deleted in the order of foreign keys and interleaving as usual.
+// Interleaved descendants of the tables without their own columns use the column of the nearest ancestor, which is
+// shared with them if it's a key column. It can't be used with WithTables, WithExcludeTables or WithKeyPrefix.
+func WithTenant(id interface{}, columns map[string]string) Option {
+	return func(o *options) {
+		o.tenant = &tenant{id: id, columns: columns}
+	}
+}
+
+// tenantFilters returns filters restricting the tables to the rows of the tenant, keyed by the table name.
+// Table names in the columns are qualified with the schema if they aren't.
+func tenantFilters(t *tenant, defaultSchema string, schemas []*tableSchema) (map[string]*rowFilter, error) {
+	columns := make(map[string]string, len(t.columns))
+	for name, column := range t.columns {
+		columns[qualifyNames(defaultSchema, []string{name})[0]] = column
+	}
+	parents := make(map[string]string, len(schemas))
+	for _, schema := range schemas {
+		parents[schema.tableName] = schema.parentTableName
+	}
+
+	filters := make(map[string]*rowFilter, len(schemas))
+	for _, schema := range schemas {
+		name := schema.tableName
+		column, ok := columns[name]
+		for !ok && parents[name] != "" {
+			name = parents[name]
+			column, ok = columns[name]
+		}
+		if !ok {
+			return nil, fmt.Errorf("table %s has no tenant column", schema.tableName)
+		}
+		filters[schema.tableName] = tenantFilter(column, t.id)
+	}
+	return filters, nil
+}
+
+// tenantFilter returns a filter matching rows whose column equals the tenant ID.
+func tenantFilter(column string, id interface{}) *rowFilter {
+	if id == nil {
+		return &rowFilter{condition: fmt.Sprintf("%s IS NULL", quoteIdentifier(column))}
+	}
+	return &rowFilter{condition: fmt.Sprintf("%s = @tenant", quoteIdentifier(column)), params: map[string]interface{}{"tenant": id}}
+}
@@ -0,0 +1,66 @@
+//
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package truncate
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestTenantFilters(t *testing.T) {
+	schemas := []*tableSchema{
+		{tableName: "Users"},
+		{tableName: "Orders", parentTableName: "Users"},
+		{tableName: "OrderItems", parentTableName: "Orders"},
+		{tableName: "AuditLogs"},
+	}
+	tenant := &tenant{id: "acme", columns: map[string]string{"Users": "TenantId", "AuditLogs": "OrgId"}}
+
+	got, err := tenantFilters(tenant, "", schemas)
+	if err != nil {
+		t.Fatalf("tenantFilters() failed: %v", err)
+	}
+	params := map[string]interface{}{"tenant": "acme"}
+	want := map[string]*rowFilter{
+		"Users":      {condition: "`TenantId` = @tenant", params: params},
+		"Orders":     {condition: "`TenantId` = @tenant", params: params},
+		"OrderItems": {condition: "`TenantId` = @tenant", params: params},
+		"AuditLogs":  {condition: "`OrgId` = @tenant", params: params},
+	}
+	if diff := cmp.Diff(got, want, cmp.AllowUnexported(rowFilter{})); diff != "" {
+		t.Errorf("mismatch (-got +want):\n%s", diff)
+	}
+
+	// A table neither mapped nor interleaved in a mapped table has no column to filter by.
+	if _, err := tenantFilters(tenant, "", append(schemas, &tableSchema{tableName: "Invoices"})); err == nil {
+		t.Errorf("tenantFilters() succeeded for a table without tenant column, want error")
+	}
+}
+
+func TestTenantFiltersNamedSchema(t *testing.T) {
+	schemas := []*tableSchema{{tableName: "sales.Orders"}}
+	tenant := &tenant{id: int64(42), columns: map[string]string{"Orders": "TenantId"}}
+	got, err := tenantFilters(tenant, "sales", schemas)
+	if err != nil {
+		t.Fatalf("tenantFilters() failed: %v", err)
+	}
+	want := map[string]*rowFilter{"sales.Orders": {condition: "`TenantId` = @tenant", params: map[string]interface{}{"tenant": int64(42)}}}
+	if diff := cmp.Diff(got, want, cmp.AllowUnexported(rowFilter{})); diff != "" {
+		t.Errorf("mismatch (-got +want):\n%s", diff)
+	}
+}
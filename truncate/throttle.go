@@ -0,0 +1,120 @@
+//
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package truncate
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"time"
+)
+
+// WithMaxConcurrentDeletes limits the number of tables whose rows are being deleted by their own statements at the same time.
+// Tables deleted in cascade by their ancestors are not counted. It's unlimited if n is 0.
+func WithMaxConcurrentDeletes(n int) Option {
+	return func(o *options) {
+		o.maxConcurrentDeletes = n
+	}
+}
+
+// WithMaxQPS limits the rate of statements issued while deleting rows, i.e. DELETE statements, hooks and
+// queries to count rows, across all tables. It's unlimited if qps is 0.
+func WithMaxQPS(qps float64) Option {
+	return func(o *options) {
+		o.maxQPS = qps
+	}
+}
+
+// rateLimiter spaces out operations to keep their rate under the limit. It's safe for concurrent use.
+type rateLimiter struct {
+	interval time.Duration
+
+	mu   sync.Mutex
+	next time.Time // When the next operation is allowed.
+}
+
+// newRateLimiter creates a limiter allowing qps operations per second. It returns nil, which never waits, if qps is not positive.
+func newRateLimiter(qps float64) *rateLimiter {
+	if qps <= 0 {
+		return nil
+	}
+	return &rateLimiter{interval: time.Duration(float64(time.Second) / qps)}
+}
+
+// wait blocks until the next operation is allowed or the context is done.
+// It is safe to call wait on a nil receiver, which returns immediately.
+func (l *rateLimiter) wait(ctx context.Context) error {
+	if l == nil {
+		return nil
+	}
+	d := l.reserve(time.Now())
+	if d <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// reserve reserves a slot for an operation, and returns how long to wait for the slot.
+func (l *rateLimiter) reserve(now time.Time) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.next.Before(now) {
+		l.next = now
+	}
+	d := l.next.Sub(now)
+	l.next = l.next.Add(l.interval)
+	return d
+}
+
+// limitConcurrentDeletes trims the tables to be deleted so that at most max tables are deleted at the same time,
+// counting tables already being deleted. It returns the tables as is if max is 0.
+func limitConcurrentDeletes(tables, allTables []*table, max int) []*table {
+	if max <= 0 {
+		return tables
+	}
+	var deleting int
+	for _, table := range flattenTables(allTables) {
+		if table.deleter.status == statusDeleting {
+			deleting++
+		}
+	}
+	available := max - deleting
+	if available <= 0 {
+		return nil
+	}
+	if len(tables) <= available {
+		return tables
+	}
+	limited := tables[:available:available]
+	// Tables in a cycle wait for each other's progress, so they are never separated.
+	for _, table := range tables[available:] {
+		for _, other := range table.cycle {
+			if slices.Contains(limited, other) {
+				limited = append(limited, table)
+				break
+			}
+		}
+	}
+	return limited
+}
@@ -0,0 +1,91 @@
+//
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package truncate
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestRateLimiterReserve(t *testing.T) {
+	l := newRateLimiter(2)
+	now := time.Date(2021, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	var got []time.Duration
+	for i := 0; i < 3; i++ {
+		got = append(got, l.reserve(now))
+	}
+	// After an idle period, the next operation is allowed immediately.
+	got = append(got, l.reserve(now.Add(10*time.Second)))
+
+	want := []time.Duration{0, 500 * time.Millisecond, time.Second, 0}
+	if !cmp.Equal(got, want) {
+		t.Errorf("diff(+got, -want) = %v", cmp.Diff(got, want))
+	}
+}
+
+func TestRateLimiterUnlimited(t *testing.T) {
+	l := newRateLimiter(0)
+	if l != nil {
+		t.Fatalf("newRateLimiter(0) = %v, but want nil", l)
+	}
+	if err := l.wait(context.Background()); err != nil {
+		t.Errorf("wait() = %v, but want nil", err)
+	}
+}
+
+func TestRateLimiterWaitCanceled(t *testing.T) {
+	l := newRateLimiter(0.001)
+	ctx, cancel := context.WithCancel(context.Background())
+	if err := l.wait(ctx); err != nil {
+		t.Fatalf("first wait() = %v, but want nil", err)
+	}
+	cancel()
+	if err := l.wait(ctx); err != context.Canceled {
+		t.Errorf("wait() = %v, but want %v", err, context.Canceled)
+	}
+}
+
+func TestLimitConcurrentDeletes(t *testing.T) {
+	deleting := &table{tableName: "Deleting", deleter: &deleter{status: statusDeleting}}
+	cascading := &table{tableName: "Cascading", deleter: &deleter{status: statusCascadeDeleting}}
+	deleting.childTables = []*table{cascading}
+	a := &table{tableName: "A", deleter: &deleter{status: statusWaiting}}
+	b := &table{tableName: "B", deleter: &deleter{status: statusWaiting}}
+	c := &table{tableName: "C", deleter: &deleter{status: statusWaiting}}
+	allTables := []*table{deleting, a, b, c}
+
+	for _, tt := range []struct {
+		desc string
+		max  int
+		want []string
+	}{
+		{desc: "unlimited", max: 0, want: []string{"A", "B", "C"}},
+		{desc: "limited", max: 3, want: []string{"A", "B"}},
+		{desc: "no room", max: 1, want: []string{}},
+	} {
+		t.Run(tt.desc, func(t *testing.T) {
+			got := extractTableNames(limitConcurrentDeletes([]*table{a, b, c}, allTables, tt.max))
+			if !cmp.Equal(got, tt.want) {
+				t.Errorf("diff(+got, -want) = %v", cmp.Diff(got, tt.want))
+			}
+		})
+	}
+}
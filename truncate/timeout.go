@@ -0,0 +1,65 @@
+//
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package truncate
+
+import (
+	"fmt"
+	"io"
+	"time"
+)
+
+// WithTimeout aborts the run with *TimeoutError if it doesn't finish within the timeout. It's unlimited if d is 0,
+// although the run is still bounded by the deadline of the context given by the caller.
+func WithTimeout(d time.Duration) Option {
+	return func(o *options) {
+		o.timeout = d
+	}
+}
+
+// WithTableTimeout fails a table with *TableTimeoutError if its own deletion, including retries, doesn't finish
+// within the timeout, so that a wedged table doesn't consume the timeout of the whole run. The failed table is handled
+// like any other failure, e.g. the run continues with WithContinueOnError. It's unlimited if d is 0.
+func WithTableTimeout(d time.Duration) Option {
+	return func(o *options) {
+		o.tableTimeout = d
+	}
+}
+
+// printPartialSummary prints how far the deletion of each table went, when the run has been aborted in the middle.
+func printPartialSummary(out io.Writer, tables []*table) {
+	var completed int
+	var lines []string
+	for _, t := range flattenTables(tables) {
+		d := t.deleter
+		switch d.status {
+		case statusCompleted:
+			completed++
+		case statusSkipped:
+		case statusDeleting, statusCascadeDeleting:
+			lines = append(lines, fmt.Sprintf("  %s: %s (%s / %s rows deleted)\n", d.tableName, d.status, formatNumber(d.totalRows-d.remainedRows), formatNumber(d.totalRows)))
+		default:
+			lines = append(lines, fmt.Sprintf("  %s: %s\n", d.tableName, d.status))
+		}
+	}
+	fmt.Fprintf(out, "\n%d of %d tables have been deleted before the run was aborted.\n", completed, len(flattenTables(tables)))
+	if len(lines) > 0 {
+		fmt.Fprint(out, "Tables which may still have rows:\n")
+		for _, line := range lines {
+			fmt.Fprint(out, line)
+		}
+	}
+}
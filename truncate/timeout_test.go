@@ -0,0 +1,45 @@
+//
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package truncate
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestPrintPartialSummary(t *testing.T) {
+	albums := &table{tableName: "Albums", deleter: &deleter{tableName: "Albums", status: statusCascadeDeleting, totalRows: 2000, remainedRows: 500}}
+	tables := []*table{
+		{tableName: "Singers", deleter: &deleter{tableName: "Singers", status: statusDeleting, totalRows: 1000, remainedRows: 250}, childTables: []*table{albums}},
+		{tableName: "Venues", deleter: &deleter{tableName: "Venues", status: statusCompleted}},
+		{tableName: "Concerts", deleter: &deleter{tableName: "Concerts", status: statusWaiting}},
+		{tableName: "Logs", deleter: &deleter{tableName: "Logs", status: statusSkipped}},
+	}
+
+	var out bytes.Buffer
+	printPartialSummary(&out, tables)
+	want := `
+1 of 5 tables have been deleted before the run was aborted.
+Tables which may still have rows:
+  Singers: deleting (750 / 1,000 rows deleted)
+  Albums: cascade deleting (1,500 / 2,000 rows deleted)
+  Concerts: waiting
+`
+	if got := out.String(); got != want {
+		t.Errorf("printPartialSummary() = %q, but want %q", got, want)
+	}
+}
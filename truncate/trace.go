@@ -0,0 +1,64 @@
+//
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package truncate
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	otelcodes "go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// instrumentationName is the name of the tracer creating spans of runs.
+const instrumentationName = "github.com/cloudspannerecosystem/spanner-truncate/truncate"
+
+// WithTracerProvider makes the run emit OpenTelemetry spans to the provider: a span of the whole run, and its
+// children for fetching the schema, counting rows, deleting rows, each wave and each table deletion.
+// If not given, the global provider registered by otel.SetTracerProvider is used, which discards spans unless
+// the application sets up the OpenTelemetry SDK, e.g. configured by OTEL_* environment variables.
+func WithTracerProvider(provider trace.TracerProvider) Option {
+	return func(o *options) {
+		o.tracerProvider = provider
+	}
+}
+
+// startRunSpan starts the root span of the run.
+func (o *options) startRunSpan(ctx context.Context, database string) (context.Context, trace.Span) {
+	provider := o.tracerProvider
+	if provider == nil {
+		provider = otel.GetTracerProvider()
+	}
+	return provider.Tracer(instrumentationName).Start(ctx, "spanner-truncate", trace.WithAttributes(attribute.String("db.name", database)))
+}
+
+// startSpan starts a child of the span in ctx. The tracer comes from the provider of the parent span,
+// so that the spans of a run go to the provider given to the run without passing it around.
+func startSpan(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	tracer := trace.SpanFromContext(ctx).TracerProvider().Tracer(instrumentationName)
+	return tracer.Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// endSpan records the error on the span if any, and ends the span.
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(otelcodes.Error, err.Error())
+	}
+	span.End()
+}
@@ -0,0 +1,120 @@
+//
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package truncate
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	otelcodes "go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+// recordingProvider records names of spans with their parents, whether they ended, and their status.
+type recordingProvider struct {
+	noop.TracerProvider
+
+	mu    sync.Mutex
+	spans []*recordedSpan
+}
+
+func (p *recordingProvider) Tracer(string, ...trace.TracerOption) trace.Tracer {
+	return &recordingTracer{provider: p}
+}
+
+type recordingTracer struct {
+	noop.Tracer
+	provider *recordingProvider
+}
+
+func (t *recordingTracer) Start(ctx context.Context, name string, _ ...trace.SpanStartOption) (context.Context, trace.Span) {
+	s := &recordedSpan{name: name, provider: t.provider}
+	if parent, ok := trace.SpanFromContext(ctx).(*recordedSpan); ok {
+		s.parent = parent.name
+	}
+	t.provider.mu.Lock()
+	t.provider.spans = append(t.provider.spans, s)
+	t.provider.mu.Unlock()
+	return trace.ContextWithSpan(ctx, s), s
+}
+
+type recordedSpan struct {
+	noop.Span
+	provider *recordingProvider
+
+	name   string
+	parent string
+	ended  bool
+	failed bool
+}
+
+func (s *recordedSpan) End(...trace.SpanEndOption)              { s.ended = true }
+func (s *recordedSpan) SetStatus(code otelcodes.Code, _ string) { s.failed = code == otelcodes.Error }
+func (s *recordedSpan) TracerProvider() trace.TracerProvider    { return s.provider }
+
+func TestWaveSpans(t *testing.T) {
+	provider := &recordingProvider{}
+	o := newOptions([]Option{WithTracerProvider(provider)})
+	ctx, span := o.startRunSpan(context.Background(), "projects/p/instances/i/databases/d")
+
+	c := &coordinator{}
+	tableA := &table{tableName: "A", deleter: &deleter{}}
+	tableB := &table{tableName: "B", deleter: &deleter{}}
+	waveCtx := c.startWave(ctx, []*table{tableA})
+	_, tableSpan := startSpan(waveCtx, "delete table")
+	endSpan(tableSpan, nil)
+	c.startWave(ctx, []*table{tableB})
+
+	tableA.deleter.status = statusCompleted
+	c.checkWavesCompleted()
+	// The second wave is aborted.
+	c.endWaveSpans()
+	endSpan(span, errors.New("aborted"))
+
+	type summary struct {
+		Name   string
+		Parent string
+		Ended  bool
+		Failed bool
+	}
+	var got []summary
+	for _, s := range provider.spans {
+		got = append(got, summary{s.name, s.parent, s.ended, s.failed})
+	}
+	want := []summary{
+		{Name: "spanner-truncate", Ended: true, Failed: true},
+		{Name: "wave", Parent: "spanner-truncate", Ended: true},
+		{Name: "delete table", Parent: "wave", Ended: true},
+		{Name: "wave", Parent: "spanner-truncate", Ended: true, Failed: true},
+	}
+	if diff := cmp.Diff(got, want); diff != "" {
+		t.Errorf("spans mismatch (-got +want):\n%s", diff)
+	}
+}
+
+func TestStartSpanWithoutParent(t *testing.T) {
+	// Without a span in the context, spans are discarded.
+	_, span := startSpan(context.Background(), "delete table")
+	if span.IsRecording() {
+		t.Errorf("span without parent is recording")
+	}
+	endSpan(span, errors.New("failed"))
+}
@@ -0,0 +1,136 @@
+//
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package truncate
+
+import (
+	"context"
+	"sync"
+
+	"cloud.google.com/go/spanner"
+)
+
+// Truncator truncates a database in two steps, planning and executing, and exposes the state of tables meanwhile.
+// It's an alternative to RunWithClient for programs which want to inspect the plan before deleting anything,
+// or to watch the state of tables from another goroutine.
+type Truncator struct {
+	client *spanner.Client
+	opts   []Option
+
+	mu     sync.Mutex
+	plan   *Plan
+	tables []*table // Tables being deleted, set once Execute starts deleting.
+}
+
+// Result is the result of Truncator.Execute.
+type Result struct {
+	// Status of the run, one of "completed", "cancelled" and "failed".
+	Status string
+
+	// Final states of the tables.
+	Tables []TableProgress
+
+	// Report of the run, the same as the one passed to the handler of WithReportHandler.
+	Report *Report
+
+	// Warnings recorded during the run, which didn't stop it.
+	Warnings []string
+}
+
+// NewTruncator returns a Truncator deleting rows from the database of the client, configured by the options
+// like RunWithClient. The client is not closed by the Truncator.
+func NewTruncator(client *spanner.Client, opts ...Option) *Truncator {
+	return &Truncator{client: client, opts: opts}
+}
+
+// Plan fetches the schema and returns the plan of deletion without deleting anything, like a dry run.
+// Execute called after Plan fails without deleting anything if the database has drifted from the plan.
+func (t *Truncator) Plan(ctx context.Context) (*Plan, error) {
+	var plan *Plan
+	opts := append(append([]Option{}, t.opts...), WithDryRun(), func(o *options) {
+		prev := o.planHandler
+		o.planHandler = func(p *Plan) {
+			if prev != nil {
+				prev(p)
+			}
+			plan = p
+		}
+	})
+	if err := RunWithClient(ctx, t.client, opts...); err != nil {
+		return nil, err
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.plan = plan
+	return plan, nil
+}
+
+// Execute deletes rows, following the plan if Plan has been called. The result is returned even if the run failed.
+func (t *Truncator) Execute(ctx context.Context) (*Result, error) {
+	t.mu.Lock()
+	plan := t.plan
+	t.mu.Unlock()
+
+	var report *Report
+	var warnings []string
+	opts := append([]Option{}, t.opts...)
+	if plan != nil {
+		opts = append(opts, WithPlan(plan))
+	}
+	opts = append(opts, func(o *options) {
+		prev := o.reportHandler
+		o.reportHandler = func(r *Report) {
+			if prev != nil {
+				prev(r)
+			}
+			report = r
+		}
+		o.tablesHandler = t.setTables
+		o.warningsHandler = func(w []string) { warnings = w }
+	})
+	err := RunWithClient(ctx, t.client, opts...)
+
+	result := &Result{Status: "cancelled", Tables: t.Status(), Report: report, Warnings: warnings}
+	if report != nil && report.Status != "" {
+		result.Status = report.Status
+	}
+	if err != nil {
+		result.Status = "failed"
+	}
+	return result, err
+}
+
+func (t *Truncator) setTables(tables []*table) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.tables = flattenTables(tables)
+}
+
+// Status returns a snapshot of the states of the tables being deleted, which is safe to call from any goroutine.
+// It returns nil until Execute starts deleting rows.
+func (t *Truncator) Status() []TableProgress {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.tables == nil {
+		return nil
+	}
+	statuses := make([]TableProgress, len(t.tables))
+	for i, table := range t.tables {
+		statuses[i] = newTableProgress(table)
+	}
+	return statuses
+}
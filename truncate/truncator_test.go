@@ -0,0 +1,43 @@
+//
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package truncate
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestTruncatorStatus(t *testing.T) {
+	tr := NewTruncator(nil)
+	if got := tr.Status(); got != nil {
+		t.Errorf("Status() before execution = %v, want nil", got)
+	}
+
+	singers := &table{tableName: "Singers", deleter: &deleter{status: statusDeleting, totalRows: 6000, remainedRows: 1000}}
+	albums := &table{tableName: "Albums", deleter: &deleter{status: statusAnalyzing}}
+	singers.childTables = []*table{albums}
+	tr.setTables([]*table{singers})
+
+	want := []TableProgress{
+		{Table: "Singers", Status: "deleting", DeletedRows: 5000, TotalRows: 6000},
+		{Table: "Albums", Status: "analyzing"},
+	}
+	if got := tr.Status(); !cmp.Equal(got, want) {
+		t.Errorf("diff(+got, -want) = %v", cmp.Diff(got, want))
+	}
+}
@@ -0,0 +1,76 @@
+//
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package truncate
+
+import (
+	"context"
+	"fmt"
+
+	"cloud.google.com/go/spanner"
+)
+
+// WithSkipTTLTables skips tables with row deletion policies, whose rows are deleted by Cloud Spanner as they age out.
+// Ancestors deleting such tables in cascade are skipped as well.
+func WithSkipTTLTables() Option {
+	return func(o *options) {
+		o.skipTTLTables = true
+	}
+}
+
+// fetchRowDeletionPolicies fetches expressions of row deletion policies, e.g. "OLDER_THAN(CreatedAt, INTERVAL 30 DAY)",
+// keyed by the names of the tables having them.
+func fetchRowDeletionPolicies(ctx context.Context, client *spanner.Client, scope schemaScope) (map[string]string, error) {
+	iter := client.Single().Query(ctx, spanner.Statement{
+		SQL: `
+		SELECT TABLE_SCHEMA, TABLE_NAME, ROW_DELETION_POLICY_EXPRESSION FROM INFORMATION_SCHEMA.TABLES
+		WHERE TABLE_CATALOG = '' AND ROW_DELETION_POLICY_EXPRESSION IS NOT NULL AND (@all OR TABLE_SCHEMA IN UNNEST(@schemas));
+	`,
+		Params: scope.params(),
+	})
+
+	policies := map[string]string{}
+	if err := iter.Do(func(r *spanner.Row) error {
+		var schema, tableName, expression string
+		if err := r.Columns(&schema, &tableName, &expression); err != nil {
+			return err
+		}
+		policies[qualifyName(schema, tableName)] = expression
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	return policies, nil
+}
+
+// attachRowDeletionPolicies sets the row deletion policy of each table.
+func attachRowDeletionPolicies(schemas []*tableSchema, policies map[string]string) {
+	for _, schema := range schemas {
+		schema.rowDeletionPolicy = policies[schema.tableName]
+	}
+}
+
+// skipTTLTables removes tables with row deletion policies and their ancestors deleting them in cascade from schemas,
+// and returns the remaining tables and the skipped tables with the reasons.
+func skipTTLTables(schemas []*tableSchema) ([]*tableSchema, map[string]string) {
+	reasons := map[string]string{}
+	for _, schema := range schemas {
+		if schema.rowDeletionPolicy != "" {
+			reasons[schema.tableName] = fmt.Sprintf("it has a row deletion policy %s", schema.rowDeletionPolicy)
+		}
+	}
+	return skipTables(schemas, reasons)
+}
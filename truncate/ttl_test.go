@@ -0,0 +1,57 @@
+//
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package truncate
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestSkipTTLTables(t *testing.T) {
+	// Singers -- Albums (cascade, TTL) -- Songs (cascade), Events (TTL), Venues
+	policies := map[string]string{
+		"Albums": "OLDER_THAN(ReleasedAt, INTERVAL 3650 DAY)",
+		"Events": "OLDER_THAN(CreatedAt, INTERVAL 30 DAY)",
+	}
+	schemas := []*tableSchema{
+		{tableName: "Albums", parentTableName: "Singers", parentOnDeleteAction: deleteActionCascadeDelete},
+		{tableName: "Events"},
+		{tableName: "Singers"},
+		{tableName: "Songs", parentTableName: "Albums", parentOnDeleteAction: deleteActionCascadeDelete},
+		{tableName: "Venues"},
+	}
+	attachRowDeletionPolicies(schemas, policies)
+
+	remaining, skipped := skipTTLTables(schemas)
+	var names []string
+	for _, schema := range remaining {
+		names = append(names, schema.tableName)
+	}
+	// Songs is still deleted on its own, as only the ancestors of the skipped tables are skipped.
+	if want := []string{"Songs", "Venues"}; !cmp.Equal(names, want) {
+		t.Errorf("remaining: diff(+got, -want) = %v", cmp.Diff(names, want))
+	}
+	wantSkipped := map[string]string{
+		"Albums":  "it has a row deletion policy OLDER_THAN(ReleasedAt, INTERVAL 3650 DAY)",
+		"Events":  "it has a row deletion policy OLDER_THAN(CreatedAt, INTERVAL 30 DAY)",
+		"Singers": "its descendants deleted in cascade are skipped",
+	}
+	if !cmp.Equal(skipped, wantSkipped) {
+		t.Errorf("skipped: diff(+got, -want) = %v", cmp.Diff(skipped, wantSkipped))
+	}
+}
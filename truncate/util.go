@@ -18,6 +18,8 @@ package truncate
 
 import (
 	"fmt"
+	"sort"
+	"strings"
 )
 
 // formatNumber formats the number with thousands separators.
@@ -40,3 +42,80 @@ func formatNumber(num uint64) string {
 	}
 	return fmt.Sprintf("%d", parts[len(parts)-1]) + s
 }
+
+// formatBytes formats the number of bytes in a human readable unit.
+// e.g. 1536 => "1.5 KiB"
+func formatBytes(bytes int64) string {
+	const unit = 1024
+	if bytes < unit {
+		return fmt.Sprintf("%d B", bytes)
+	}
+	div, exp := int64(unit), 0
+	for n := bytes / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(bytes)/float64(div), "KMGTPE"[exp])
+}
+
+// maxSuggestions is the maximum number of suggestions returned by suggestNames.
+const maxSuggestions = 3
+
+// suggestNames returns candidates similar to the given name, the most similar first.
+// Names are compared case-insensitively and a candidate is regarded as similar
+// if it starts with the name, or the edit distance is small enough for the length of the name.
+func suggestNames(name string, candidates []string) []string {
+	lower := strings.ToLower(name)
+	threshold := len(name) / 3
+	if threshold < 2 {
+		threshold = 2
+	}
+
+	type scored struct {
+		name  string
+		score int
+	}
+	var similar []scored
+	for _, c := range candidates {
+		lc := strings.ToLower(c)
+		d := editDistance(lower, lc)
+		switch {
+		case d <= threshold:
+			similar = append(similar, scored{c, d})
+		case strings.HasPrefix(lc, lower) || strings.HasPrefix(lower, lc):
+			// Rank prefix matches after close ones.
+			similar = append(similar, scored{c, threshold + 1})
+		}
+	}
+	sort.SliceStable(similar, func(i, j int) bool {
+		return similar[i].score < similar[j].score
+	})
+
+	var names []string
+	for i := 0; i < len(similar) && i < maxSuggestions; i++ {
+		names = append(names, similar[i].name)
+	}
+	return names
+}
+
+// editDistance returns Levenshtein distance between a and b.
+func editDistance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	curr := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		curr[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(rb)]
+}
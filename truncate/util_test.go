@@ -16,7 +16,11 @@
 
 package truncate
 
-import "testing"
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
 
 func TestFormatNumber(t *testing.T) {
 	for _, tt := range []struct {
@@ -38,3 +42,57 @@ func TestFormatNumber(t *testing.T) {
 		}
 	}
 }
+
+func TestFormatBytes(t *testing.T) {
+	for _, tt := range []struct {
+		input int64
+		want  string
+	}{
+		{0, "0 B"},
+		{1023, "1023 B"},
+		{1024, "1.0 KiB"},
+		{1536, "1.5 KiB"},
+		{1024 * 1024, "1.0 MiB"},
+		{5 * 1024 * 1024 * 1024, "5.0 GiB"},
+	} {
+		if got := formatBytes(tt.input); got != tt.want {
+			t.Errorf("formatBytes(%d) = %s, but want = %s", tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestSuggestNames(t *testing.T) {
+	candidates := []string{"Singers", "Albums", "Songs", "SongGenres", "Concerts"}
+	for _, tt := range []struct {
+		input string
+		want  []string
+	}{
+		{"singers", []string{"Singers"}},
+		{"Singer", []string{"Singers"}},
+		{"Album", []string{"Albums"}},
+		{"Song", []string{"Songs", "SongGenres"}},
+		{"Concrets", []string{"Concerts"}},
+		{"Venues", nil},
+	} {
+		got := suggestNames(tt.input, candidates)
+		if !cmp.Equal(got, tt.want) {
+			t.Errorf("suggestNames(%q) = %v, but want = %v", tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestEditDistance(t *testing.T) {
+	for _, tt := range []struct {
+		a, b string
+		want int
+	}{
+		{"", "", 0},
+		{"abc", "", 3},
+		{"kitten", "sitting", 3},
+		{"Singers", "Singers", 0},
+	} {
+		if got := editDistance(tt.a, tt.b); got != tt.want {
+			t.Errorf("editDistance(%q, %q) = %d, but want = %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,89 @@
+//
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package truncate
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"cloud.google.com/go/spanner"
+)
+
+// WithIndexVerification verifies that secondary indexes, including search indexes, of the deleted tables are empty
+// after the deletion, by reading them with FORCE_INDEX. Indexes which still return rows are reported as warnings.
+func WithIndexVerification() Option {
+	return func(o *options) {
+		o.verifyIndexes = true
+	}
+}
+
+// indexesToVerify returns indexes on the tables whose rows have all been deleted.
+// Tables deleted with custom statements or filters are excluded, as their indexes may have entries of the remaining rows.
+func indexesToVerify(indexes []*indexSchema, tables []*table) []*indexSchema {
+	deleted := map[string]bool{}
+	for _, t := range tables {
+		if t.deleter.status == statusCompleted && t.deleter.statementBuilder == nil && t.deleter.filter == nil {
+			deleted[t.tableName] = true
+		}
+	}
+	var targets []*indexSchema
+	for _, idx := range indexes {
+		if deleted[idx.baseTableName] {
+			targets = append(targets, idx)
+		}
+	}
+	return targets
+}
+
+// verifyIndexes counts entries of the indexes, and reports indexes which are not empty as warnings.
+func verifyIndexes(ctx context.Context, client *spanner.Client, out io.Writer, indexes []*indexSchema, warnings *warnings) {
+	if len(indexes) == 0 {
+		return
+	}
+	fmt.Fprintf(out, "\nVerifying %d indexes are empty...\n", len(indexes))
+
+	var nonEmpty int
+	for _, idx := range indexes {
+		count, err := countIndexEntries(ctx, client, idx)
+		if err != nil {
+			warnings.add(idx.baseTableName, "failed to verify index %s: %v", idx.indexName, err)
+			continue
+		}
+		if count > 0 {
+			nonEmpty++
+			warnings.add(idx.baseTableName, "index %s still returns %s rows after the deletion", idx.indexName, formatNumber(uint64(count)))
+		}
+	}
+	if nonEmpty > 0 {
+		fmt.Fprintf(out, "%d indexes still return rows. See the warnings below.\n", nonEmpty)
+	}
+}
+
+// countIndexEntries counts rows read through the index.
+func countIndexEntries(ctx context.Context, client *spanner.Client, idx *indexSchema) (int64, error) {
+	stmt := spanner.NewStatement(fmt.Sprintf("SELECT COUNT(*) as count FROM %s@{FORCE_INDEX=%s}", quoteIdentifier(idx.baseTableName), quoteIdentifier(idx.indexName)))
+	var count int64
+
+	// Use strong read, as a stale read may see rows which have just been deleted.
+	if err := client.Single().Query(ctx, stmt).Do(func(r *spanner.Row) error {
+		return r.ColumnByName("count", &count)
+	}); err != nil {
+		return 0, newTableError(idx.baseTableName, OpVerify, stmt.SQL, err)
+	}
+	return count, nil
+}
@@ -0,0 +1,49 @@
+//
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package truncate
+
+import (
+	"testing"
+
+	"cloud.google.com/go/spanner"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestIndexesToVerify(t *testing.T) {
+	tables := []*table{
+		{tableName: "Singers", deleter: &deleter{status: statusCompleted}},
+		{tableName: "Albums", deleter: &deleter{status: statusFailed}},
+		{tableName: "Concerts", deleter: &deleter{status: statusSkipped}},
+		{tableName: "Songs", deleter: &deleter{status: statusCompleted, statementBuilder: func(string) spanner.Statement {
+			return spanner.NewStatement("DELETE FROM Songs WHERE SongId > 100")
+		}}},
+	}
+	singersByName := &indexSchema{indexName: "SingersByName", baseTableName: "Singers"}
+	singersSearch := &indexSchema{indexName: "SingersSearch", baseTableName: "Singers", isSearch: true}
+	indexes := []*indexSchema{
+		singersByName,
+		{indexName: "AlbumsByTitle", baseTableName: "Albums", parentTableName: "Singers"},
+		{indexName: "ConcertsByDate", baseTableName: "Concerts"},
+		{indexName: "SongsByName", baseTableName: "Songs"},
+		singersSearch,
+	}
+
+	want := []*indexSchema{singersByName, singersSearch}
+	if got := indexesToVerify(indexes, tables); !cmp.Equal(got, want, cmp.AllowUnexported(indexSchema{})) {
+		t.Errorf("diff(+got, -want) = %v", cmp.Diff(got, want, cmp.AllowUnexported(indexSchema{})))
+	}
+}
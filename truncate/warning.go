@@ -0,0 +1,107 @@
+//
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package truncate
+
+import (
+	"fmt"
+	"sync"
+)
+
+// warning is a non-fatal issue which doesn't stop the run, but should be reported to users.
+type warning struct {
+	tableName string // Blank if the warning isn't related to a specific table.
+	message   string
+	count     int // Number of times the same warning occurred.
+}
+
+func (w *warning) String() string {
+	var s string
+	if w.tableName != "" {
+		s = fmt.Sprintf("%s: %s", w.tableName, w.message)
+	} else {
+		s = w.message
+	}
+	if w.count > 1 {
+		s += fmt.Sprintf(" (occurred %d times)", w.count)
+	}
+	return s
+}
+
+// warnings collects warnings from multiple goroutines.
+// The same warning is recorded only once with its number of occurrences.
+type warnings struct {
+	mu      sync.Mutex
+	list    []*warning
+	index   map[string]*warning
+	emitter *emitter
+}
+
+// newWarnings creates warnings. If emitter is not nil, a warning event is emitted on the first occurrence of each warning.
+func newWarnings(emitter *emitter) *warnings {
+	return &warnings{index: map[string]*warning{}, emitter: emitter}
+}
+
+// add records a warning. It is safe to call add on a nil receiver, which discards the warning.
+func (ws *warnings) add(tableName, format string, a ...interface{}) {
+	if ws == nil {
+		return
+	}
+	msg := fmt.Sprintf(format, a...)
+
+	ws.mu.Lock()
+	key := tableName + "\x00" + msg
+	if w, ok := ws.index[key]; ok {
+		w.count++
+		ws.mu.Unlock()
+		return
+	}
+	w := &warning{tableName: tableName, message: msg, count: 1}
+	ws.list = append(ws.list, w)
+	ws.index[key] = w
+	ws.mu.Unlock()
+
+	var tables []string
+	if tableName != "" {
+		tables = []string{tableName}
+	}
+	ws.emitter.emit(Event{Type: EventWarning, Tables: tables, Message: msg})
+}
+
+// all returns a snapshot of recorded warnings in order of first occurrence.
+func (ws *warnings) all() []warning {
+	if ws == nil {
+		return nil
+	}
+
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+
+	list := make([]warning, len(ws.list))
+	for i, w := range ws.list {
+		list[i] = *w
+	}
+	return list
+}
+
+// strings returns the recorded warnings formatted as strings, in the order they first occurred.
+func (ws *warnings) strings() []string {
+	var list []string
+	for _, w := range ws.all() {
+		list = append(list, w.String())
+	}
+	return list
+}
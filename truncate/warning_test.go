@@ -0,0 +1,66 @@
+//
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package truncate
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestWarnings(t *testing.T) {
+	ws := newWarnings(nil)
+	ws.add("A", "failed to count rows: %v", "timeout")
+	ws.add("", "stats unavailable")
+	ws.add("A", "failed to count rows: %v", "timeout")
+	ws.add("B", "failed to count rows: %v", "timeout")
+
+	var got []string
+	for _, w := range ws.all() {
+		got = append(got, w.String())
+	}
+	want := []string{
+		"A: failed to count rows: timeout (occurred 2 times)",
+		"stats unavailable",
+		"B: failed to count rows: timeout",
+	}
+	if !cmp.Equal(got, want) {
+		t.Errorf("diff(+got, -want) = %v", cmp.Diff(got, want))
+	}
+}
+
+func TestNilWarnings(t *testing.T) {
+	var ws *warnings
+	ws.add("A", "ignored")
+	if got := ws.all(); len(got) != 0 {
+		t.Errorf("nil warnings returned %v, but want empty", got)
+	}
+}
+
+func TestWarningEvents(t *testing.T) {
+	var events []Event
+	ws := newWarnings(newEmitter(func(e Event) { events = append(events, e) }))
+	ws.add("A", "failed to count rows")
+	ws.add("A", "failed to count rows")
+
+	if len(events) != 1 {
+		t.Fatalf("got %d events, but want 1", len(events))
+	}
+	if e := events[0]; e.Type != EventWarning || !cmp.Equal(e.Tables, []string{"A"}) || e.Message != "failed to count rows" {
+		t.Errorf("unexpected event: %#v", e)
+	}
+}